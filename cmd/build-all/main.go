@@ -43,5 +43,5 @@ func main() {
 	runCommandAll("pkg", "go", "build", ".")
 	logger.Info("Testing all packages")
 	runCommandAll("pkg", "go", "generate", "./...")
-	runCommandAll("pkg", "go", "test", "./...")
+	runCommandAll("pkg", "go", "test", "-race", "./...")
 }
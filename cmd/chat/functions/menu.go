@@ -1,12 +0,0 @@
-package functions
-
-type Menu struct {
-	Name string
-}
-
-var menus = []Menu{
-	{Name: "魚香肉絲"},
-	{Name: "宮保雞丁"},
-	{Name: "糖醋排骨"},
-	{Name: "甲板飯"},
-}
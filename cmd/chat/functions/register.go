@@ -0,0 +1,89 @@
+package functions
+
+import (
+	_ "embed"
+	"fmt"
+	"github.com/meta-metopia/go-packages/pkg/ai/gpt/functions"
+	"github.com/meta-metopia/go-packages/pkg/ai/gpt/registry"
+	"github.com/meta-metopia/go-packages/pkg/ai/ordering"
+	"sync"
+)
+
+//go:embed menu.json
+var defaultMenu []byte
+
+// catalogs are the menus already loaded by path, the empty path being the menu of the restaurant.
+// The functions are created for every client, so the menus are read once instead of for every function of every client.
+// The catalog is never changed by the functions, so the clients share it.
+var catalogs = struct {
+	sync.Mutex
+	loaded map[string]*ordering.Catalog
+}{loaded: map[string]*ordering.Catalog{}}
+
+// orderingOptions are the options of the ordering functions.
+type orderingOptions struct {
+	// Catalog is the path of the JSON menu. Defaults to the menu of the restaurant.
+	Catalog string `yaml:"catalog"`
+	// RequiresApproval asks the user before the order is placed.
+	RequiresApproval bool `yaml:"requires_approval"`
+}
+
+// Register registers the functions of the restaurant under their names, so the bot configurations can enable them.
+func Register(r *registry.Registry) {
+	for _, name := range []string{
+		ordering.GetMenuFunction,
+		ordering.AddToOrderFunction,
+		ordering.RemoveFromOrderFunction,
+		ordering.SetQuantityFunction,
+		ordering.ViewOrderFunction,
+		ordering.CheckoutFunction,
+		ordering.ConfirmOrderFunction,
+		ordering.CancelOrderFunction,
+	} {
+		r.RegisterFunction(name, func(options registry.Options) (functions.FunctionInterface, error) {
+			return newOrderingFunction(name, options)
+		})
+	}
+}
+
+// newOrderingFunction returns a new instance of the ordering function [name]. The functions share the order through the store of the conversation.
+func newOrderingFunction(name string, options registry.Options) (functions.FunctionInterface, error) {
+	var config orderingOptions
+	if err := options.Decode(&config); err != nil {
+		return nil, err
+	}
+
+	catalog, err := loadCatalog(config.Catalog)
+	if err != nil {
+		return nil, err
+	}
+	for _, function := range ordering.NewFunctions(ordering.Config{Catalog: catalog, ConfirmRequiresApproval: config.RequiresApproval}) {
+		if function.Name() == name {
+			return function, nil
+		}
+	}
+	return nil, fmt.Errorf("function %s is not found", name)
+}
+
+// loadCatalog loads the menu from the path, or the menu of the restaurant when there is none.
+// The menu is loaded once, changing the file afterwards requires a restart.
+func loadCatalog(path string) (*ordering.Catalog, error) {
+	catalogs.Lock()
+	defer catalogs.Unlock()
+	if catalog, found := catalogs.loaded[path]; found {
+		return catalog, nil
+	}
+
+	var catalog *ordering.Catalog
+	var err error
+	if len(path) == 0 {
+		catalog, err = ordering.ParseCatalog(defaultMenu)
+	} else {
+		catalog, err = ordering.LoadCatalog(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	catalogs.loaded[path] = catalog
+	return catalog, nil
+}
@@ -1,56 +1,137 @@
 package input
 
 import (
+	"bytes"
+	"context"
 	"fmt"
+	"github.com/fatih/color"
 	"github.com/gen2brain/malgo"
+	"github.com/google/logger"
+	"github.com/meta-metopia/go-packages/pkg/ai/audio"
+	"github.com/meta-metopia/go-packages/pkg/ai/audio/whisper"
+	"io"
 )
 
+// whisperFormat is the format captured from the microphone, the native format of whisper.
+var whisperFormat = audio.Format{SampleRate: 16000, Channels: 1}
+
+// WhisperInput captures the microphone, splits the speech into utterances and transcribes them with whisper.
+// It implements audio.Source over the captured samples.
 type WhisperInput struct {
-	device *malgo.Device
+	context     *malgo.AllocatedContext
+	device      *malgo.Device
+	transcriber whisper.Transcriber
+	samples     chan []byte
+	pending     []byte
 }
 
 // NewWhisperInput returns a new instance of whisperInput.
-func NewWhisperInput() Input {
+func NewWhisperInput(config whisper.Config) (Input, error) {
 	ctx, err := malgo.InitContext(nil, malgo.ContextConfig{}, func(message string) {
-		fmt.Printf("LOG <%v>\n", message)
+		logger.Info(message)
 	})
-
 	if err != nil {
-		fmt.Printf("Failed to initialize context: %v\n", err)
-		return nil
+		return nil, fmt.Errorf("failed to initialize audio context: %w", err)
 	}
 
-	deviceConfig := malgo.DefaultDeviceConfig(malgo.Duplex)
-
-	var capturedSampleCount uint32
-	pCapturedSamples := make([]byte, 0)
-	sizeInBytes := uint32(malgo.SampleSizeInBytes(deviceConfig.Capture.Format))
-	onRecvFrames := func(pSample2, pSample []byte, framecount uint32) {
+	deviceConfig := malgo.DefaultDeviceConfig(malgo.Capture)
+	deviceConfig.Capture.Format = malgo.FormatS16
+	deviceConfig.Capture.Channels = uint32(whisperFormat.Channels)
+	deviceConfig.SampleRate = uint32(whisperFormat.SampleRate)
 
-		sampleCount := framecount * deviceConfig.Capture.Channels * sizeInBytes
-
-		newCapturedSampleCount := capturedSampleCount + sampleCount
+	input := &WhisperInput{
+		context:     ctx,
+		transcriber: whisper.NewWhisperClient(config),
+		samples:     make(chan []byte, 256),
+	}
+	device, err := malgo.InitDevice(ctx.Context, deviceConfig, malgo.DeviceCallbacks{
+		Data: input.onSamples,
+	})
+	if err != nil {
+		_ = ctx.Uninit()
+		ctx.Free()
+		return nil, fmt.Errorf("failed to initialize capture device: %w", err)
+	}
+	input.device = device
 
-		pCapturedSamples = append(pCapturedSamples, pSample...)
+	return input, nil
+}
 
-		capturedSampleCount = newCapturedSampleCount
+// Run listens to the microphone and yields the transcript of every utterance.
+// The microphone is paused while the transcript is handled, so the bot does not hear its own answer.
+func (w *WhisperInput) Run(yield func(input string, err error) bool) {
+	defer w.close()
 
+	if err := w.device.Start(); err != nil {
+		yield("", fmt.Errorf("failed to start capture device: %w", err))
+		return
 	}
-	captureCallbacks := malgo.DeviceCallbacks{
-		Data: onRecvFrames,
+	fmt.Println(color.GreenString("Listening..."))
+
+	for transcript, err := range whisper.Transcripts(context.Background(), w.transcriber, w, audio.VadOptions{}) {
+		if err != nil {
+			yield("", err)
+			return
+		}
+
+		fmt.Println(color.GreenString("You: ") + transcript)
+		_ = w.device.Stop()
+		w.drain()
+		if !yield(transcript, nil) {
+			return
+		}
+		if err := w.device.Start(); err != nil {
+			yield("", fmt.Errorf("failed to start capture device: %w", err))
+			return
+		}
+		fmt.Println(color.GreenString("Listening..."))
 	}
-	device, err := malgo.InitDevice(ctx.Context, deviceConfig, captureCallbacks)
+}
 
-	return &WhisperInput{
-		device: device,
+// Read reads the captured samples. It blocks until samples are captured.
+func (w *WhisperInput) Read(p []byte) (int, error) {
+	for len(w.pending) == 0 {
+		samples, ok := <-w.samples
+		if !ok {
+			return 0, io.EOF
+		}
+		w.pending = samples
 	}
+
+	n := copy(p, w.pending)
+	w.pending = w.pending[n:]
+	return n, nil
 }
 
-// Run runs the whisper input.
-func (w *WhisperInput) Run(yield func(input string, err error) bool) {
+// Format returns the format of the captured samples.
+func (w *WhisperInput) Format() audio.Format {
+	return whisperFormat
+}
 
+// onSamples is called on the audio thread. The samples are copied since malgo reuses the buffer,
+// and dropped rather than blocking the audio thread when they are not read fast enough.
+func (w *WhisperInput) onSamples(_, samples []byte, _ uint32) {
+	select {
+	case w.samples <- bytes.Clone(samples):
+	default:
+	}
 }
 
-func (w *WhisperInput) record() {
+// drain discards the samples captured but not read yet.
+func (w *WhisperInput) drain() {
+	w.pending = nil
+	for {
+		select {
+		case <-w.samples:
+		default:
+			return
+		}
+	}
+}
 
+func (w *WhisperInput) close() {
+	_ = w.device.Stop()
+	w.device.Uninit()
+	_ = w.context.Uninit()
+	w.context.Free()
 }
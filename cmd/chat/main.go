@@ -1,7 +1,10 @@
 package main
 
 import (
+	"context"
+	_ "embed"
 	"encoding/json"
+	"flag"
 	"fmt"
 	"github.com/fatih/color"
 	"github.com/google/logger"
@@ -9,12 +12,16 @@ import (
 	"github.com/meta-metopia/go-packages/cmd/chat/input"
 	plugins2 "github.com/meta-metopia/go-packages/cmd/chat/plugins"
 	"github.com/meta-metopia/go-packages/cmd/chat/template"
+	"github.com/meta-metopia/go-packages/pkg/ai/audio/whisper"
 	"github.com/meta-metopia/go-packages/pkg/ai/gpt"
 	"github.com/meta-metopia/go-packages/pkg/ai/gpt/dto"
 	"github.com/meta-metopia/go-packages/pkg/ai/gpt/functions"
-	"github.com/meta-metopia/go-packages/pkg/ai/gpt/plugin"
+	"github.com/meta-metopia/go-packages/pkg/ai/gpt/registry"
+	"github.com/meta-metopia/go-packages/pkg/ai/speech"
 	"io"
+	"log/slog"
 	"os"
+	"strings"
 )
 
 type Model struct {
@@ -36,6 +43,27 @@ var AvailableModels = []Model{
 	},
 }
 
+//go:embed bots/restaurant.yaml
+var defaultBotConfig []byte
+
+// findModel returns the pricing of the model. Unknown models are not priced.
+func findModel(name string) Model {
+	for _, model := range AvailableModels {
+		if model.Name == name {
+			return model
+		}
+	}
+	return Model{Name: name}
+}
+
+// loadBotConfig loads the bot configuration file, or the restaurant bot when there is none.
+func loadBotConfig(path string) (registry.Config, error) {
+	if len(path) == 0 {
+		return registry.ParseConfig(defaultBotConfig)
+	}
+	return registry.LoadConfig(path)
+}
+
 func deleteLastLine() {
 	fmt.Printf("\033[1A\033[K")
 }
@@ -44,14 +72,15 @@ func stringPtr(s string) *string {
 	return &s
 }
 
-func saveHistoryToFile(history []dto.Message, fileName string) {
+// saveToFile saves the value as indented JSON, such as the history or the store of the conversation.
+func saveToFile(value any, fileName string) {
 	file, err := os.Create(fileName)
 	if err != nil {
 		logger.Fatal(err)
 	}
 	defer file.Close()
 
-	indent, err := json.MarshalIndent(history, "", "  ")
+	indent, err := json.MarshalIndent(value, "", "  ")
 	if err != nil {
 		return
 	}
@@ -59,6 +88,15 @@ func saveHistoryToFile(history []dto.Message, fileName string) {
 	_, err = file.Write(indent)
 }
 
+// approvalOf approves the pending function call when the prompt says yes, and rejects it with the prompt as the reason otherwise.
+func approvalOf(prompt string) gpt.Approval {
+	switch strings.ToLower(strings.TrimSpace(prompt)) {
+	case "y", "yes", "好", "是", "確認":
+		return gpt.Approval{Action: gpt.ApproveAction}
+	}
+	return gpt.Approval{Action: gpt.RejectAction, Reason: prompt}
+}
+
 func calculatePricing(model Model, history []dto.Message) (total float64, completionToken int, promptToken int) {
 	for _, message := range history {
 		if message.Usage == nil {
@@ -75,34 +113,59 @@ func calculatePricing(model Model, history []dto.Message) (total float64, comple
 }
 
 func main() {
+	configPath := flag.String("config", "", "path of the YAML or JSON bot configuration. Defaults to the restaurant bot")
+	flag.Parse()
+
 	logger.Init("Chatbot", true, false, io.Discard)
+	botConfig, err := loadBotConfig(*configPath)
+	if err != nil {
+		logger.Fatal(err)
+	}
 	inputClient := input.NewPromptInput()
-	gptFunctions := []functions.FunctionInterface{
-		functions2.NewAddDishFunction(),
-		functions2.NewCompleteOrderFunction(),
-		functions2.NewGetAllMenuFunction(),
+	if os.Getenv("INPUT") == "whisper" {
+		whisperInput, err := input.NewWhisperInput(whisper.Config{
+			Endpoint: "https://api.openai.com/v1/audio/transcriptions",
+			ApiKey:   os.Getenv("OPENAI_KEY"),
+			Model:    "whisper-1",
+			Language: "zh",
+		})
+		if err != nil {
+			logger.Fatal(err)
+		}
+		inputClient = whisperInput
 	}
-	plugins := []plugin.Interface{
-		plugin.NewStandardOutputPlugin(),
-		plugins2.NewAzurePlugin(os.Getenv("VOICE_NAME")),
+	functions2.Register(registry.Default)
+	synthesizer := speech.NewAzureSynthesizer(speech.AzureConfig{
+		Endpoint: os.Getenv("SPEECH_URL"),
+		ApiKey:   os.Getenv("SPEECH_KEY"),
+		Voice:    os.Getenv("VOICE_NAME"),
+	})
+	speakerSink := plugins2.NewSpeakerSink()
+
+	model := findModel(botConfig.Model)
+	store := functions.NewFunctionStore()
+	config, err := registry.Build(botConfig, gpt.Config{
+		Store:    store,
+		Template: template.NewEngine(),
+		// only the warnings and errors are logged, to keep them apart from the conversation
+		Logger: slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelWarn})),
+		Redact: gpt.RedactAll,
+	})
+	if err != nil {
+		logger.Fatal(err)
 	}
 
-	functionStore := functions.FunctionStore{}
-	model := AvailableModels[0]
-	templateEngine := template.NewEngine()
-	config := gpt.Config{
-		Endpoint:  "https://api.openai.com/v1/chat/completions",
-		Model:     model.Name,
-		ApiKey:    os.Getenv("OPENAI_KEY"),
-		Prompt:    `你是一個問答機器人`,
-		Functions: &gptFunctions,
-		Store:     functionStore,
-		Template:  templateEngine,
-		Plugins:   &plugins,
+	gptClient, err := gpt.NewGptClient(config)
+	if err != nil {
+		logger.Fatal(err)
 	}
-
-	gptClient := gpt.NewGptClient(config)
+	defer func() {
+		if err := gptClient.Close(); err != nil {
+			fmt.Println(err)
+		}
+	}()
 	history := make([]dto.Message, 0)
+	var pending *gpt.PendingApproval
 
 	for prompt, err := range inputClient.Run {
 		if err != nil {
@@ -112,30 +175,69 @@ func main() {
 
 		fmt.Println("Generating response...")
 
-		for response, err := range gptClient.GenerateIterator(&prompt, history) {
-			saveHistoryToFile(history, "history.json")
-			if err != nil {
-				fmt.Println(err)
-				return
-			}
-
-			history = response.FullHistory
-			totalPricing, completionToken, promptToken := calculatePricing(model, history)
-			fmt.Printf(color.RedString("Usage: ")+"Total pricing: $%.5f, Prompt Token: %d, Completion Token: %d\n", totalPricing, promptToken, completionToken)
-			if len(response.NewResponses) == 0 {
-				continue
+		// the answer is printed and spoken sentence by sentence while it is generated.
+		// speech.Speak runs the deltas on its own goroutine, which can still be running when Speak fails,
+		// so the turn is only read once done is closed.
+		turnHistory, turnPending := history, (*gpt.PendingApproval)(nil)
+		done := make(chan struct{})
+		deltas := func(yield func(delta string, err error) bool) {
+			defer close(done)
+			for event, err := range gptClient.GenerateStream(context.Background(), prompt, history) {
+				if err != nil {
+					yield("", err)
+					return
+				}
+				if event.Response != nil {
+					turnHistory = event.Response.FullHistory
+					turnPending = event.Response.PendingApproval
+					continue
+				}
+				fmt.Print(event.Delta)
+				if !yield(event.Delta, nil) {
+					return
+				}
 			}
-
-			for _, response := range response.NewResponses {
-				if len(response.Content) > 0 {
-					var content string
-					content = response.Content
-					if response.Name != nil {
-						content = "調用函數" + *response.Name + ": " + content
+		}
+		if pending != nil {
+			// the prompt answers the function waiting for an approval
+			approved := *pending
+			deltas = func(yield func(delta string, err error) bool) {
+				defer close(done)
+				for response, err := range gptClient.Resume(context.Background(), approved, approvalOf(prompt)) {
+					if err != nil {
+						yield("", err)
+						return
+					}
+					turnHistory = response.FullHistory
+					turnPending = response.PendingApproval
+					for _, message := range response.NewResponses {
+						if message.Role != dto.RoleAssistant || len(message.Content) == 0 {
+							continue
+						}
+						fmt.Print(message.Content)
+						if !yield(message.Content, nil) {
+							return
+						}
 					}
-					fmt.Println(content)
 				}
 			}
 		}
+		err = speech.Speak(context.Background(), synthesizer, speakerSink, deltas, speech.StreamOptions{})
+		<-done
+		history, pending = turnHistory, turnPending
+		fmt.Println()
+		saveToFile(history, "history.json")
+		saveToFile(store, "store.json")
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		if pending != nil {
+			arguments, _ := json.Marshal(pending.Arguments)
+			fmt.Println(color.YellowString("Approve %s with %s? (y/n)", pending.Function, arguments))
+		}
+
+		totalPricing, completionToken, promptToken := calculatePricing(model, history)
+		fmt.Printf(color.RedString("Usage: ")+"Total pricing: $%.5f, Prompt Token: %d, Completion Token: %d\n", totalPricing, promptToken, completionToken)
 	}
 }
@@ -0,0 +1,71 @@
+package plugins
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/faiface/beep"
+	"github.com/faiface/beep/mp3"
+	"github.com/faiface/beep/speaker"
+	"github.com/meta-metopia/go-packages/pkg/ai/speech"
+	"io"
+	"sync"
+	"time"
+)
+
+// SpeakerSink plays mp3 speech on the default speaker.
+type SpeakerSink struct {
+	mutex      sync.Mutex
+	sampleRate beep.SampleRate
+}
+
+// NewSpeakerSink returns a new instance of SpeakerSink.
+func NewSpeakerSink() speech.AudioSink {
+	return &SpeakerSink{}
+}
+
+// Write plays the audio and returns once it is played, or when the context is done.
+func (s *SpeakerSink) Write(ctx context.Context, audio speech.Audio) error {
+	if audio.ContentType != "audio/mpeg" {
+		return fmt.Errorf("speaker only plays audio/mpeg, got %s", audio.ContentType)
+	}
+
+	streamer, format, err := mp3.Decode(io.NopCloser(bytes.NewReader(audio.Data)))
+	if err != nil {
+		return err
+	}
+	defer streamer.Close()
+
+	sampleRate, err := s.init(format.SampleRate)
+	if err != nil {
+		return err
+	}
+
+	done := make(chan struct{})
+	speaker.Play(beep.Seq(beep.Resample(4, format.SampleRate, sampleRate, streamer), beep.Callback(func() {
+		close(done)
+	})))
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		speaker.Clear()
+		return ctx.Err()
+	}
+}
+
+// init initializes the speaker with the sample rate of the first audio.
+// The speaker can only be initialized once, so the audio of other sample rates is resampled.
+func (s *SpeakerSink) init(sampleRate beep.SampleRate) (beep.SampleRate, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.sampleRate == 0 {
+		if err := speaker.Init(sampleRate, sampleRate.N(time.Second/10)); err != nil {
+			return 0, err
+		}
+		s.sampleRate = sampleRate
+	}
+	return s.sampleRate, nil
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/meta-metopia/go-packages/pkg/ai/gpt"
+	"github.com/meta-metopia/go-packages/pkg/ai/gpt/dto"
+	"github.com/meta-metopia/go-packages/pkg/ai/gpt/functions"
+	apperrors "github.com/meta-metopia/go-packages/pkg/errors"
+	"strings"
+)
+
+// pendingApprovalKey keeps the function call waiting for an approval in the store of the conversation.
+// The call is returned as tool_calls, and the tool message answering it resumes the turn.
+const pendingApprovalKey = "gateway.pending_approval"
+
+// unansweredToolResult answers the tool calls the caller left without a tool message, such as by sending a user message instead.
+const unansweredToolResult = "The user did not approve the call of the function."
+
+// approvalMessage is the content of a tool message editing the function call, or giving the reason of its rejection.
+type approvalMessage struct {
+	Action    gpt.ApprovalAction     `json:"action"`
+	Arguments map[string]interface{} `json:"arguments"`
+	Reason    string                 `json:"reason"`
+}
+
+// approvalOf returns the approval of the tool message answering the function call.
+// The content is either an approvalMessage, or a text approving the call when it says yes and rejecting it with the text as the reason otherwise.
+func approvalOf(content string) gpt.Approval {
+	var message approvalMessage
+	if err := json.Unmarshal([]byte(content), &message); err == nil && len(message.Action) > 0 {
+		return gpt.Approval{Action: message.Action, Arguments: message.Arguments, Reason: message.Reason}
+	}
+	switch strings.ToLower(strings.TrimSpace(content)) {
+	case "y", "yes", "approve", "approved", "ok":
+		return gpt.Approval{Action: gpt.ApproveAction}
+	}
+	return gpt.Approval{Action: gpt.RejectAction, Reason: content}
+}
+
+// resumption returns the function call the last message answers, with its approval.
+// It returns nil when the last message is not a tool message.
+func resumption(store *functions.FunctionStore, messages []dto.Message) (*gpt.PendingApproval, gpt.Approval, error) {
+	if len(messages) == 0 || messages[len(messages)-1].Role != dto.RoleTool {
+		return nil, gpt.Approval{}, nil
+	}
+	last := messages[len(messages)-1]
+	pending, found := functions.Get[gpt.PendingApproval](store, pendingApprovalKey)
+	if !found || last.ToolCallId == nil || *last.ToolCallId != pending.ToolCallId {
+		return nil, gpt.Approval{}, apperrors.NewInvalidRequest("no function call of the conversation is waiting for the tool message, see the " + conversationHeader + " header")
+	}
+	return &pending, approvalOf(last.Content), nil
+}
+
+// keepPending keeps the function call the turn waits for in the store of the conversation, or forgets the previous one.
+func keepPending(store *functions.FunctionStore, response gpt.GenerateResponse) {
+	if response.PendingApproval == nil {
+		store.Delete(pendingApprovalKey)
+		return
+	}
+	store.Set(pendingApprovalKey, *response.PendingApproval)
+}
+
+// resume resumes the turn waiting for the approval, and returns the whole turn as Generate does.
+func resume(ctx context.Context, client gpt.IGptClient, pending gpt.PendingApproval, approval gpt.Approval) (gpt.GenerateResponse, error) {
+	var turn gpt.GenerateResponse
+	for response, err := range client.Resume(ctx, pending, approval) {
+		if err != nil {
+			return gpt.GenerateResponse{}, err
+		}
+		turn.NewResponses = append(turn.NewResponses, response.NewResponses...)
+		turn.FullHistory = response.FullHistory
+		turn.PendingApproval = response.PendingApproval
+		turn.CacheHit = turn.CacheHit || response.CacheHit
+	}
+	return turn, nil
+}
+
+// streamOf returns the resumed turn as a stream, its answers being sent as a single delta.
+func streamOf(ctx context.Context, client gpt.IGptClient, pending gpt.PendingApproval, approval gpt.Approval) gpt.GenerateStreamRet {
+	return func(yield func(event gpt.StreamEvent, err error) bool) {
+		response, err := resume(ctx, client, pending, approval)
+		if err != nil {
+			yield(gpt.StreamEvent{}, err)
+			return
+		}
+		if content := contentOf(response); len(content) > 0 {
+			if !yield(gpt.StreamEvent{Delta: content}, nil) {
+				return
+			}
+		}
+		yield(gpt.StreamEvent{Response: &response}, nil)
+	}
+}
+
+// answerToolCalls answers the tool calls of the history left without a tool message as not approved,
+// since the model requires every tool call to be answered.
+func answerToolCalls(history []dto.Message) []dto.Message {
+	answered := map[string]bool{}
+	for _, message := range history {
+		if message.Role == dto.RoleTool && message.ToolCallId != nil {
+			answered[*message.ToolCallId] = true
+		}
+	}
+
+	answeredHistory := make([]dto.Message, 0, len(history))
+	for _, message := range history {
+		answeredHistory = append(answeredHistory, message)
+		if message.Role != dto.RoleAssistant || message.ToolCalls == nil {
+			continue
+		}
+		for _, toolCall := range *message.ToolCalls {
+			if !answered[toolCall.Id] {
+				answeredHistory = append(answeredHistory, dto.Message{Role: dto.RoleTool, Content: unansweredToolResult, ToolCallId: &toolCall.Id})
+			}
+		}
+	}
+	return answeredHistory
+}
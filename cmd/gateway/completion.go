@@ -0,0 +1,202 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/meta-metopia/go-packages/pkg/ai/gpt"
+	"github.com/meta-metopia/go-packages/pkg/ai/gpt/dto"
+	"github.com/meta-metopia/go-packages/pkg/ai/gpt/functions"
+	"net/http"
+	"strings"
+	"time"
+)
+
+type completionMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+	// ToolCalls is the call of the function waiting for an approval. The tool message answering it resumes the turn, see approvalOf.
+	ToolCalls []dto.ToolCall `json:"tool_calls,omitempty"`
+}
+
+type completionChoice struct {
+	Index        int               `json:"index"`
+	Message      completionMessage `json:"message"`
+	FinishReason string            `json:"finish_reason"`
+}
+
+type completionUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+// completion is the response of the OpenAI chat completions API.
+type completion struct {
+	Id      string             `json:"id"`
+	Object  string             `json:"object"`
+	Created int64              `json:"created"`
+	Model   string             `json:"model"`
+	Choices []completionChoice `json:"choices"`
+	Usage   completionUsage    `json:"usage"`
+}
+
+type chunkDelta struct {
+	Role      string              `json:"role,omitempty"`
+	Content   string              `json:"content,omitempty"`
+	ToolCalls []dto.ToolCallDelta `json:"tool_calls,omitempty"`
+}
+
+type chunkChoice struct {
+	Index        int        `json:"index"`
+	Delta        chunkDelta `json:"delta"`
+	FinishReason *string    `json:"finish_reason"`
+}
+
+// completionChunk is an event of a streamed response of the OpenAI chat completions API.
+type completionChunk struct {
+	Id      string           `json:"id"`
+	Object  string           `json:"object"`
+	Created int64            `json:"created"`
+	Model   string           `json:"model"`
+	Choices []chunkChoice    `json:"choices"`
+	Usage   *completionUsage `json:"usage,omitempty"`
+}
+
+func newCompletionId() string {
+	id := make([]byte, 12)
+	_, _ = rand.Read(id)
+	return "chatcmpl-" + hex.EncodeToString(id)
+}
+
+func newUsage(usage dto.Usage) completionUsage {
+	return completionUsage{
+		PromptTokens:     usage.PromptToken,
+		CompletionTokens: usage.CompletionToken,
+		TotalTokens:      usage.PromptToken + usage.CompletionToken,
+	}
+}
+
+// contentOf returns the answers of the assistant in the turn, as a single message.
+// The results of the functions are never sent, as they can hold what only the model should see.
+// The functions answering without GPT answer as the assistant, so their answers are sent.
+func contentOf(response gpt.GenerateResponse) string {
+	var contents []string
+	for _, message := range response.NewResponses {
+		if message.Role == dto.RoleAssistant && len(message.Content) > 0 {
+			contents = append(contents, message.Content)
+		}
+	}
+	return strings.Join(contents, "\n")
+}
+
+// pendingToolCalls returns the call of the function waiting for an approval, if any.
+func pendingToolCalls(response gpt.GenerateResponse) ([]dto.ToolCall, string) {
+	pending := response.PendingApproval
+	if pending == nil {
+		return nil, "stop"
+	}
+	arguments, _ := json.Marshal(pending.Arguments)
+	return []dto.ToolCall{{
+		Id:   pending.ToolCallId,
+		Type: "function",
+		Function: dto.Function{
+			Name:      pending.Function,
+			Arguments: string(arguments),
+		},
+	}}, "tool_calls"
+}
+
+func newCompletion(model string, response gpt.GenerateResponse, usage dto.Usage) completion {
+	toolCalls, finishReason := pendingToolCalls(response)
+	return completion{
+		Id:      newCompletionId(),
+		Object:  "chat.completion",
+		Created: time.Now().Unix(),
+		Model:   model,
+		Choices: []completionChoice{{
+			Message: completionMessage{
+				Role:      dto.RoleAssistant,
+				Content:   contentOf(response),
+				ToolCalls: toolCalls,
+			},
+			FinishReason: finishReason,
+		}},
+		Usage: newUsage(usage),
+	}
+}
+
+// stream sends the answer as server-sent events while it is generated.
+// The deltas are the answers once the plugins converted them, so the streamed content is the content of the completion.
+// The errors are sent as an event once the stream started, as the status is already sent.
+func (g *Gateway) stream(writer http.ResponseWriter, request *http.Request, t *tenant, store *functions.FunctionStore, events gpt.GenerateStreamRet, options *dto.StreamOptions) {
+	flusher, _ := writer.(http.Flusher)
+	chunk := completionChunk{
+		Id:      newCompletionId(),
+		Object:  "chat.completion.chunk",
+		Created: time.Now().Unix(),
+		Model:   t.bot.Model,
+	}
+	send := func(value any) {
+		data, _ := json.Marshal(value)
+		_, _ = fmt.Fprintf(writer, "data: %s\n\n", data)
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+	sendDelta := func(delta chunkDelta, finishReason *string) {
+		chunk.Choices = []chunkChoice{{Delta: delta, FinishReason: finishReason}}
+		send(chunk)
+	}
+
+	started := false
+	start := func() {
+		if started {
+			return
+		}
+		started = true
+		writer.Header().Set("Content-Type", "text/event-stream")
+		writer.Header().Set("Cache-Control", "no-cache")
+		writer.WriteHeader(http.StatusOK)
+		sendDelta(chunkDelta{Role: dto.RoleAssistant}, nil)
+	}
+
+	for event, err := range events {
+		if err != nil {
+			g.logger.ErrorContext(request.Context(), "failed to generate", "tenant", t.config.Name, "error", err)
+			if !started {
+				writeError(writer, err)
+				return
+			}
+			send(errorBody(err))
+			return
+		}
+		start()
+		if event.Response == nil {
+			sendDelta(chunkDelta{Content: event.Delta}, nil)
+			continue
+		}
+
+		response := *event.Response
+		keepPending(store, response)
+		usage := g.record(request, t, response, true)
+		toolCalls, finishReason := pendingToolCalls(response)
+		var deltas []dto.ToolCallDelta
+		for i, toolCall := range toolCalls {
+			deltas = append(deltas, dto.ToolCallDelta{Index: i, Id: toolCall.Id, Type: toolCall.Type, Function: toolCall.Function})
+		}
+		sendDelta(chunkDelta{ToolCalls: deltas}, &finishReason)
+
+		if options != nil && options.IncludeUsage {
+			chunk.Choices = []chunkChoice{}
+			completionUsage := newUsage(usage)
+			chunk.Usage = &completionUsage
+			send(chunk)
+		}
+	}
+	_, _ = fmt.Fprint(writer, "data: [DONE]\n\n")
+	if flusher != nil {
+		flusher.Flush()
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Pricing is the price of 1000 tokens in dollars, used to track the cost of the tenants.
+type Pricing struct {
+	Prompt     float64 `yaml:"prompt"`
+	Completion float64 `yaml:"completion"`
+}
+
+// TenantConfig is an application calling the gateway with its own API keys and bot.
+type TenantConfig struct {
+	Name string `yaml:"name"`
+	// ApiKeys are the keys the tenant sends as bearer tokens. The environment variables in the keys are expanded.
+	ApiKeys []string `yaml:"api_keys"`
+	// Bot is the path of the bot configuration answering the tenant, relative to the gateway configuration.
+	Bot     string  `yaml:"bot"`
+	Pricing Pricing `yaml:"pricing"`
+	// Cache is the number of responses of deterministic requests cached in memory. 0 disables the cache.
+	Cache int `yaml:"cache"`
+	// SessionIdleTimeout forgets the store of the conversations without a request for the duration,
+	// as the applications never tell when a conversation ends. Defaults to 30 minutes.
+	SessionIdleTimeout time.Duration `yaml:"session_idle_timeout"`
+	// MaxSessions is the maximum number of conversations whose store is kept.
+	// A new conversation forgets the store of the least recently used one. Defaults to 1000.
+	MaxSessions int `yaml:"max_sessions"`
+}
+
+// Config describes the gateway.
+//
+//	address: :8080
+//	tenants:
+//	  - name: kiosk
+//	    api_keys:
+//	      - ${KIOSK_KEY}
+//	    bot: bots/restaurant.yaml
+//	    pricing:
+//	      prompt: 0.0005
+//	      completion: 0.0015
+//	    session_idle_timeout: 30m
+//	    max_sessions: 1000
+type Config struct {
+	// Address defaults to :8080.
+	Address string         `yaml:"address"`
+	Tenants []TenantConfig `yaml:"tenants"`
+}
+
+// LoadConfig reads the gateway configuration from a YAML or a JSON file.
+func LoadConfig(path string) (Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, err
+	}
+	config, err := ParseConfig(data)
+	if err != nil {
+		return Config{}, err
+	}
+	for i, tenant := range config.Tenants {
+		if !filepath.IsAbs(tenant.Bot) {
+			config.Tenants[i].Bot = filepath.Join(filepath.Dir(path), tenant.Bot)
+		}
+	}
+	return config, nil
+}
+
+// ParseConfig parses the gateway configuration from YAML or JSON, and checks every API key belongs to a single tenant.
+func ParseConfig(data []byte) (Config, error) {
+	var config Config
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&config); err != nil {
+		return Config{}, fmt.Errorf("invalid gateway config: %w", err)
+	}
+	if len(config.Address) == 0 {
+		config.Address = ":8080"
+	}
+	if len(config.Tenants) == 0 {
+		return Config{}, fmt.Errorf("invalid gateway config: tenants are required")
+	}
+
+	keys := map[string]bool{}
+	for i, tenant := range config.Tenants {
+		if len(tenant.Name) == 0 || len(tenant.Bot) == 0 {
+			return Config{}, fmt.Errorf("invalid gateway config: tenants need a name and a bot")
+		}
+		if len(tenant.ApiKeys) == 0 {
+			return Config{}, fmt.Errorf("invalid gateway config: tenant %s has no api keys", tenant.Name)
+		}
+		for j, key := range tenant.ApiKeys {
+			key = os.ExpandEnv(key)
+			if len(key) == 0 {
+				return Config{}, fmt.Errorf("invalid gateway config: empty api key of tenant %s", tenant.Name)
+			}
+			if keys[key] {
+				return Config{}, fmt.Errorf("invalid gateway config: api key of tenant %s is already used", tenant.Name)
+			}
+			keys[key] = true
+			config.Tenants[i].ApiKeys[j] = key
+		}
+	}
+	return config, nil
+}
@@ -0,0 +1,430 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/meta-metopia/go-packages/cmd/chat/template"
+	"github.com/meta-metopia/go-packages/pkg/ai/gpt"
+	"github.com/meta-metopia/go-packages/pkg/ai/gpt/cache"
+	"github.com/meta-metopia/go-packages/pkg/ai/gpt/dto"
+	"github.com/meta-metopia/go-packages/pkg/ai/gpt/functions"
+	"github.com/meta-metopia/go-packages/pkg/ai/gpt/middleware"
+	"github.com/meta-metopia/go-packages/pkg/ai/gpt/plugin"
+	"github.com/meta-metopia/go-packages/pkg/ai/gpt/registry"
+	apperrors "github.com/meta-metopia/go-packages/pkg/errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxRequestSize is the maximum size of the body of a completion request.
+const maxRequestSize = 10 * 1024 * 1024
+
+// conversationHeader keeps the store of the functions across the requests of a conversation.
+// Without it, every request starts with an empty store, and the function calls waiting for an approval cannot be resumed.
+// The stores are kept in memory until the conversation is idle or the tenant keeps too many, see TenantConfig.
+const conversationHeader = "X-Conversation-Id"
+
+const (
+	defaultSessionIdleTimeout = 30 * time.Minute
+	defaultMaxSessions        = 1000
+)
+
+// Usage is what a tenant consumed since the gateway started. The responses served from the cache cost nothing.
+type Usage struct {
+	Requests         int     `json:"requests"`
+	PromptTokens     int     `json:"prompt_tokens"`
+	CompletionTokens int     `json:"completion_tokens"`
+	CacheHits        int     `json:"cache_hits"`
+	Cost             float64 `json:"cost"`
+}
+
+type tenant struct {
+	config      TenantConfig
+	bot         registry.Config
+	sessions    *functions.Sessions
+	middlewares []middleware.Middleware
+
+	mutex sync.Mutex
+	usage Usage
+	// conversations are the last time a request of the conversations kept by the sessions was received.
+	conversations map[string]time.Time
+	now           func() time.Time
+}
+
+// store returns the store of the conversation, and marks it as used.
+// The conversations idle for the SessionIdleTimeout are forgotten, and so is the least recently used one
+// when a new conversation comes while MaxSessions are kept. A forgotten conversation starts again with an empty store.
+func (t *tenant) store(conversationId string) (*functions.FunctionStore, error) {
+	t.mutex.Lock()
+	now := t.now()
+	var oldestId string
+	var oldest time.Time
+	for id, lastUsed := range t.conversations {
+		if now.Sub(lastUsed) > t.config.SessionIdleTimeout {
+			t.forget(id)
+			continue
+		}
+		if id != conversationId && (len(oldestId) == 0 || lastUsed.Before(oldest)) {
+			oldestId, oldest = id, lastUsed
+		}
+	}
+	if _, found := t.conversations[conversationId]; !found && len(t.conversations) >= t.config.MaxSessions {
+		t.forget(oldestId)
+	}
+	t.conversations[conversationId] = now
+	t.mutex.Unlock()
+	return t.sessions.Store(conversationId)
+}
+
+// forget removes the store of the conversation. The requests of the conversation still running keep using it.
+func (t *tenant) forget(conversationId string) {
+	t.sessions.Forget(conversationId)
+	delete(t.conversations, conversationId)
+}
+
+// record adds the usage of the response to the usage of the tenant, and returns the tokens of the response.
+func (t *tenant) record(response gpt.GenerateResponse) dto.Usage {
+	var usage dto.Usage
+	for _, message := range response.NewResponses {
+		if message.Usage == nil || message.CacheHit {
+			continue
+		}
+		usage.PromptToken += message.Usage.PromptToken
+		usage.CompletionToken += message.Usage.CompletionToken
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.usage.Requests++
+	t.usage.PromptTokens += usage.PromptToken
+	t.usage.CompletionTokens += usage.CompletionToken
+	if response.CacheHit {
+		t.usage.CacheHits++
+	}
+	t.usage.Cost += t.config.Pricing.Prompt/1000*float64(usage.PromptToken) + t.config.Pricing.Completion/1000*float64(usage.CompletionToken)
+	return usage
+}
+
+func (t *tenant) Usage() Usage {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.usage
+}
+
+// Gateway serves the OpenAI chat completions API, answering every tenant with its bot.
+// The functions, the plugins and the middlewares of the bots run in the gateway, so the applications get them with any OpenAI client.
+type Gateway struct {
+	tenants  map[string]*tenant
+	registry *registry.Registry
+	logger   *slog.Logger
+	mux      *http.ServeMux
+}
+
+// NewGateway returns a new instance of Gateway, once the bots of the tenants are loaded.
+// The functions and the plugins of the bots are created by the factories of [r].
+func NewGateway(config Config, r *registry.Registry, logger *slog.Logger) (*Gateway, error) {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	gateway := &Gateway{
+		tenants:  map[string]*tenant{},
+		registry: r,
+		logger:   logger,
+		mux:      http.NewServeMux(),
+	}
+
+	for _, tenantConfig := range config.Tenants {
+		bot, err := registry.LoadConfig(tenantConfig.Bot)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load the bot of tenant %s: %w", tenantConfig.Name, err)
+		}
+		// the bot is built once, so its unknown plugins and functions fail on start
+		if _, err := r.Build(bot, gpt.Config{}); err != nil {
+			return nil, fmt.Errorf("failed to build the bot of tenant %s: %w", tenantConfig.Name, err)
+		}
+
+		if tenantConfig.SessionIdleTimeout <= 0 {
+			tenantConfig.SessionIdleTimeout = defaultSessionIdleTimeout
+		}
+		if tenantConfig.MaxSessions <= 0 {
+			tenantConfig.MaxSessions = defaultMaxSessions
+		}
+		created := &tenant{
+			config:        tenantConfig,
+			bot:           bot,
+			sessions:      functions.NewSessions(nil),
+			conversations: map[string]time.Time{},
+			now:           time.Now,
+		}
+		if tenantConfig.Cache > 0 {
+			created.middlewares = append(created.middlewares, cache.Middleware(cache.NewMemoryCache(tenantConfig.Cache), cache.Options{}))
+		}
+		for _, key := range tenantConfig.ApiKeys {
+			gateway.tenants[key] = created
+		}
+	}
+
+	gateway.mux.HandleFunc("POST /v1/chat/completions", gateway.chatCompletions)
+	gateway.mux.HandleFunc("GET /v1/models", gateway.models)
+	gateway.mux.HandleFunc("GET /v1/usage", gateway.usage)
+	return gateway, nil
+}
+
+func (g *Gateway) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	g.mux.ServeHTTP(writer, request)
+}
+
+// authenticate returns the tenant of the bearer token.
+func (g *Gateway) authenticate(request *http.Request) (*tenant, error) {
+	key, found := strings.CutPrefix(request.Header.Get("Authorization"), "Bearer ")
+	key = strings.TrimSpace(key)
+	if !found || len(key) == 0 {
+		return nil, apperrors.NewMissingAPIKey()
+	}
+	foundTenant, found := g.tenants[key]
+	if !found {
+		return nil, apperrors.NewInvalidAPIKey()
+	}
+	return foundTenant, nil
+}
+
+// newClient returns a client of the bot of the tenant, with the sampling parameters of the request and the store of the conversation.
+func (g *Gateway) newClient(t *tenant, request dto.RequestDto, store *functions.FunctionStore) (gpt.IGptClient, error) {
+	bot := t.bot
+	if request.Temperature != nil {
+		bot.Temperature = request.Temperature
+	}
+	if request.TopP != nil {
+		bot.TopP = request.TopP
+	}
+	if request.Seed != nil {
+		bot.Seed = request.Seed
+	}
+	if request.MaxTokens != nil {
+		bot.MaxTokens = request.MaxTokens
+	}
+
+	config, err := g.registry.Build(bot, gpt.Config{
+		Store:       store,
+		Template:    template.NewEngine(),
+		Middlewares: t.middlewares,
+		Logger:      g.logger,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return gpt.NewGptClient(config)
+}
+
+// splitMessages returns the prompt, which is the last message, and the history before it.
+// The system messages are dropped, as the bot has its own prompt, and the tool calls left unanswered are answered as not approved.
+func splitMessages(messages []dto.Message) (any, []dto.Message, error) {
+	if len(messages) == 0 {
+		return nil, nil, apperrors.NewInvalidRequest("messages are required")
+	}
+	last := messages[len(messages)-1]
+	if last.Role != dto.RoleUser {
+		return nil, nil, apperrors.NewInvalidRequest("the last message must be a user message")
+	}
+
+	history := make([]dto.Message, 0, len(messages)-1)
+	for _, message := range messages[:len(messages)-1] {
+		if message.Role != dto.RoleSystem {
+			history = append(history, message)
+		}
+	}
+	history = answerToolCalls(history)
+	if len(last.Parts) > 0 {
+		return last.Parts, history, nil
+	}
+	return last.Content, history, nil
+}
+
+func (g *Gateway) chatCompletions(writer http.ResponseWriter, request *http.Request) {
+	foundTenant, err := g.authenticate(request)
+	if err != nil {
+		writeError(writer, err)
+		return
+	}
+
+	var body dto.RequestDto
+	if err := json.NewDecoder(io.LimitReader(request.Body, maxRequestSize)).Decode(&body); err != nil {
+		writeError(writer, apperrors.NewInvalidRequest("invalid request: "+err.Error()))
+		return
+	}
+
+	store := functions.NewFunctionStore()
+	if conversationId := request.Header.Get(conversationHeader); len(conversationId) > 0 {
+		if store, err = foundTenant.store(conversationId); err != nil {
+			g.logger.ErrorContext(request.Context(), "failed to load conversation", "tenant", foundTenant.config.Name, "error", err)
+			writeError(writer, err)
+			return
+		}
+	}
+	// a tool message answers the function call waiting for an approval, and resumes its turn
+	pending, approval, err := resumption(store, body.Messages)
+	if err != nil {
+		writeError(writer, err)
+		return
+	}
+	var prompt any
+	var history []dto.Message
+	if pending == nil {
+		if prompt, history, err = splitMessages(body.Messages); err != nil {
+			writeError(writer, err)
+			return
+		}
+	}
+
+	client, err := g.newClient(foundTenant, body, store)
+	if err != nil {
+		g.logger.ErrorContext(request.Context(), "failed to create client", "tenant", foundTenant.config.Name, "error", err)
+		writeError(writer, err)
+		return
+	}
+	defer func() {
+		if err := client.Close(); err != nil {
+			g.logger.ErrorContext(request.Context(), "failed to close client", "tenant", foundTenant.config.Name, "error", err)
+		}
+	}()
+
+	if body.Stream {
+		events := client.GenerateStream(request.Context(), prompt, history)
+		if pending != nil {
+			events = streamOf(request.Context(), client, *pending, approval)
+		}
+		g.stream(writer, request, foundTenant, store, events, body.StreamOptions)
+		return
+	}
+
+	var response gpt.GenerateResponse
+	if pending != nil {
+		response, err = resume(request.Context(), client, *pending, approval)
+	} else {
+		response, err = client.GenerateWithContext(request.Context(), prompt, history)
+	}
+	if err != nil {
+		g.logger.ErrorContext(request.Context(), "failed to generate", "tenant", foundTenant.config.Name, "error", err)
+		writeError(writer, err)
+		return
+	}
+	keepPending(store, response)
+	usage := g.record(request, foundTenant, response, false)
+	writeJSON(writer, http.StatusOK, newCompletion(foundTenant.bot.Model, response, usage))
+}
+
+// record tracks the usage of the response, and logs it.
+func (g *Gateway) record(request *http.Request, t *tenant, response gpt.GenerateResponse, stream bool) dto.Usage {
+	usage := t.record(response)
+	g.logger.InfoContext(request.Context(), "completion",
+		"tenant", t.config.Name,
+		"stream", stream,
+		"cache_hit", response.CacheHit,
+		"prompt_tokens", usage.PromptToken,
+		"completion_tokens", usage.CompletionToken,
+	)
+	return usage
+}
+
+func (g *Gateway) models(writer http.ResponseWriter, request *http.Request) {
+	foundTenant, err := g.authenticate(request)
+	if err != nil {
+		writeError(writer, err)
+		return
+	}
+	writeJSON(writer, http.StatusOK, map[string]any{
+		"object": "list",
+		"data": []map[string]any{{
+			"id":       foundTenant.bot.Model,
+			"object":   "model",
+			"owned_by": foundTenant.config.Name,
+		}},
+	})
+}
+
+func (g *Gateway) usage(writer http.ResponseWriter, request *http.Request) {
+	foundTenant, err := g.authenticate(request)
+	if err != nil {
+		writeError(writer, err)
+		return
+	}
+	writeJSON(writer, http.StatusOK, foundTenant.Usage())
+}
+
+// publicError returns the error the caller is told about, or nil when it is an internal error.
+// The messages the plugins refuse are violations of the content policy.
+// The errors of the upstream hold its response, which the caller must not see, so they are only logged.
+// They are bad gateways, except the rate limits.
+func publicError(err error) apperrors.ErrorInterface {
+	var codedError apperrors.ErrorInterface
+	if errors.As(err, &codedError) {
+		return codedError
+	}
+	var moderationError *plugin.ModerationError
+	if errors.As(err, &moderationError) {
+		return apperrors.NewContentPolicyViolation(moderationError.Error())
+	}
+	var injectionError *plugin.InjectionConfirmationError
+	if errors.As(err, &injectionError) {
+		return apperrors.NewContentPolicyViolation(fmt.Sprintf("function %s requires a confirmation after a suspicious function result", injectionError.Function))
+	}
+	var statusError *middleware.StatusError
+	if errors.As(err, &statusError) {
+		if statusError.StatusCode == http.StatusTooManyRequests {
+			return apperrors.NewRateLimited()
+		}
+		return apperrors.NewUpstreamFailed()
+	}
+	return nil
+}
+
+// statusOf returns the HTTP status of the error.
+func statusOf(err error) int {
+	if public := publicError(err); public != nil {
+		return apperrors.MapErrorToHTTPStatus(public)
+	}
+	return http.StatusInternalServerError
+}
+
+// errorBody returns the error as the OpenAI API does. The message of the internal errors is not sent.
+func errorBody(err error) map[string]any {
+	status := statusOf(err)
+	errorType := "api_error"
+	switch status {
+	case http.StatusBadRequest:
+		errorType = "invalid_request_error"
+	case http.StatusUnauthorized:
+		errorType = "authentication_error"
+	case http.StatusTooManyRequests:
+		errorType = "rate_limit_error"
+	}
+
+	message := "The server failed to answer the request"
+	var code any
+	if public := publicError(err); public != nil {
+		message = public.Error()
+		code = public.Code()
+	}
+	return map[string]any{
+		"error": map[string]any{
+			"message": message,
+			"type":    errorType,
+			"code":    code,
+		},
+	}
+}
+
+func writeError(writer http.ResponseWriter, err error) {
+	writeJSON(writer, statusOf(err), errorBody(err))
+}
+
+func writeJSON(writer http.ResponseWriter, status int, body any) {
+	writer.Header().Set("Content-Type", "application/json")
+	writer.WriteHeader(status)
+	_ = json.NewEncoder(writer).Encode(body)
+}
@@ -0,0 +1,683 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"github.com/meta-metopia/go-packages/pkg/ai/gpt"
+	"github.com/meta-metopia/go-packages/pkg/ai/gpt/dto"
+	"github.com/meta-metopia/go-packages/pkg/ai/gpt/functions"
+	"github.com/meta-metopia/go-packages/pkg/ai/gpt/middleware"
+	"github.com/meta-metopia/go-packages/pkg/ai/gpt/plugin"
+	"github.com/meta-metopia/go-packages/pkg/ai/gpt/registry"
+	"github.com/meta-metopia/go-packages/pkg/ai/injection"
+	apperrors "github.com/meta-metopia/go-packages/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// upstream is a fake OpenAI API answering with [answer], recording the requests.
+type upstream struct {
+	mutex    sync.Mutex
+	requests []dto.RequestDto
+	answer   func(request dto.RequestDto) (status int, response string)
+}
+
+func (u *upstream) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	var body dto.RequestDto
+	if err := json.NewDecoder(request.Body).Decode(&body); err != nil {
+		http.Error(writer, err.Error(), http.StatusBadRequest)
+		return
+	}
+	u.mutex.Lock()
+	u.requests = append(u.requests, body)
+	u.mutex.Unlock()
+
+	status, response := u.answer(body)
+	if status != http.StatusOK || !body.Stream {
+		writer.Header().Set("Content-Type", "application/json")
+		writer.WriteHeader(status)
+		_, _ = io.WriteString(writer, response)
+		return
+	}
+
+	// the streamed answer is sent word by word
+	var parsed dto.ResponseDto
+	_ = json.Unmarshal([]byte(response), &parsed)
+	writer.Header().Set("Content-Type", "text/event-stream")
+	for _, word := range strings.SplitAfter(parsed.Choices[0].Message.Content, " ") {
+		data, _ := json.Marshal(dto.StreamChunkDto{Choices: []dto.StreamChoice{{Delta: dto.DeltaDto{Content: word}}}})
+		_, _ = fmt.Fprintf(writer, "data: %s\n\n", data)
+	}
+	data, _ := json.Marshal(dto.StreamChunkDto{Choices: []dto.StreamChoice{}, Usage: parsed.Usage})
+	_, _ = fmt.Fprintf(writer, "data: %s\n\ndata: [DONE]\n\n", data)
+}
+
+func answer(content string, promptTokens int, completionTokens int) string {
+	return fmt.Sprintf(`{"choices":[{"message":{"role":"assistant","content":%q},"finish_reason":"stop"}],"usage":{"prompt_tokens":%d,"completion_tokens":%d}}`, content, promptTokens, completionTokens)
+}
+
+// counterFunction counts its calls in the store of the conversation.
+type counterFunction struct {
+	functions.FunctionClient
+	store            *functions.FunctionStore
+	RequiresApproval bool `yaml:"requires_approval"`
+}
+
+func (c *counterFunction) OnMessage(arguments map[string]interface{}) (*functions.FunctionGptResponse, error) {
+	count := functions.Update(c.store, "count", func(count int, _ bool) int {
+		return count + 1
+	})
+	return &functions.FunctionGptResponse{Content: fmt.Sprintf("count %d", count)}, nil
+}
+
+func (c *counterFunction) Name() string {
+	return "count"
+}
+
+func (c *counterFunction) Description() string {
+	return "Count the calls."
+}
+
+func (c *counterFunction) Parameters() map[string]interface{} {
+	return map[string]interface{}{"type": "object", "properties": map[string]interface{}{}}
+}
+
+func (c *counterFunction) SetStore(store *functions.FunctionStore) {
+	c.store = store
+}
+
+func (c *counterFunction) Config() functions.FunctionConfig {
+	return functions.FunctionConfig{UseGptToInterpretResponses: true, RequiresApproval: c.RequiresApproval}
+}
+
+// newTestGateway returns the gateway of the tenants kiosk, with the key kiosk-key, and web, with the key web-key,
+// answered by the upstream.
+func newTestGateway(t *testing.T, u *upstream) *httptest.Server {
+	return newTestGatewayWithBot(t, u, "functions:\n  - count\n")
+}
+
+// newTestGatewayWithBot returns a gateway whose bot has the functions and the plugins of [bot], given as YAML.
+func newTestGatewayWithBot(t *testing.T, u *upstream, bot string) *httptest.Server {
+	upstreamServer := httptest.NewServer(u)
+	t.Cleanup(upstreamServer.Close)
+
+	directory := t.TempDir()
+	bot = fmt.Sprintf("provider: openai\nendpoint: %s\napi_key: upstream-key\nmodel: gpt-4o-mini\nprompt: You take orders\n", upstreamServer.URL) + bot
+	if err := os.WriteFile(filepath.Join(directory, "bot.yaml"), []byte(bot), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	configPath := filepath.Join(directory, "gateway.yaml")
+	if err := os.WriteFile(configPath, []byte(`
+tenants:
+  - name: kiosk
+    api_keys: [kiosk-key]
+    bot: bot.yaml
+    pricing:
+      prompt: 1
+      completion: 2
+    cache: 10
+  - name: web
+    api_keys: [web-key]
+    bot: bot.yaml
+`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	config, err := LoadConfig(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := registry.NewRegistry()
+	r.RegisterFunction("count", func(options registry.Options) (functions.FunctionInterface, error) {
+		counter := &counterFunction{}
+		return counter, options.Decode(counter)
+	})
+	gateway, err := NewGateway(config, r, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(gateway)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func post(t *testing.T, url string, key string, body string, header ...string) *http.Response {
+	request, err := http.NewRequest(http.MethodPost, url+"/v1/chat/completions", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(key) > 0 {
+		request.Header.Set("Authorization", "Bearer "+key)
+	}
+	for i := 0; i+1 < len(header); i += 2 {
+		request.Header.Set(header[i], header[i+1])
+	}
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		response.Body.Close()
+	})
+	return response
+}
+
+func decode[T any](t *testing.T, response *http.Response) T {
+	var value T
+	if err := json.NewDecoder(response.Body).Decode(&value); err != nil {
+		t.Fatal(err)
+	}
+	return value
+}
+
+func usageOf(t *testing.T, url string, key string) Usage {
+	request, _ := http.NewRequest(http.MethodGet, url+"/v1/usage", nil)
+	request.Header.Set("Authorization", "Bearer "+key)
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+	return decode[Usage](t, response)
+}
+
+func TestGateway_Authentication(t *testing.T) {
+	server := newTestGateway(t, &upstream{})
+
+	tests := []struct {
+		name       string
+		key        string
+		wantStatus int
+		wantCode   float64
+	}{
+		{name: "Test without key", wantStatus: http.StatusUnauthorized, wantCode: 2000},
+		{name: "Test with invalid key", key: "other-key", wantStatus: http.StatusUnauthorized, wantCode: 2001},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response := post(t, server.URL, tt.key, `{"messages":[{"role":"user","content":"Hi"}]}`)
+			assert.Equal(t, tt.wantStatus, response.StatusCode)
+			body := decode[map[string]map[string]any](t, response)
+			assert.Equal(t, "authentication_error", body["error"]["type"])
+			assert.Equal(t, tt.wantCode, body["error"]["code"])
+		})
+	}
+}
+
+func TestGateway_Errors(t *testing.T) {
+	server := newTestGateway(t, &upstream{answer: func(request dto.RequestDto) (int, string) {
+		if request.Messages[len(request.Messages)-1].Content == "busy" {
+			return http.StatusTooManyRequests, `{"error":{"message":"rate limited"}}`
+		}
+		return http.StatusInternalServerError, `{"error":{"message":"down"}}`
+	}})
+
+	tests := []struct {
+		name        string
+		body        string
+		wantStatus  int
+		wantMessage string
+		wantType    string
+	}{
+		{name: "Test with invalid body", body: `{`, wantStatus: http.StatusBadRequest, wantMessage: "invalid request: unexpected EOF", wantType: "invalid_request_error"},
+		{name: "Test without messages", body: `{"messages":[]}`, wantStatus: http.StatusBadRequest, wantMessage: "messages are required", wantType: "invalid_request_error"},
+		{name: "Test without user message", body: `{"messages":[{"role":"assistant","content":"Hi"}]}`, wantStatus: http.StatusBadRequest, wantMessage: "the last message must be a user message", wantType: "invalid_request_error"},
+		{name: "Test with rate limited upstream", body: `{"messages":[{"role":"user","content":"busy"}]}`, wantStatus: http.StatusTooManyRequests, wantMessage: apperrors.NewRateLimited().Error(), wantType: "rate_limit_error"},
+		{name: "Test with failing upstream", body: `{"messages":[{"role":"user","content":"Hi"}]}`, wantStatus: http.StatusBadGateway, wantMessage: apperrors.NewUpstreamFailed().Error(), wantType: "api_error"},
+		{name: "Test with failing upstream while streaming", body: `{"stream":true,"messages":[{"role":"user","content":"Hi"}]}`, wantStatus: http.StatusBadGateway, wantMessage: apperrors.NewUpstreamFailed().Error(), wantType: "api_error"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response := post(t, server.URL, "web-key", tt.body)
+			assert.Equal(t, tt.wantStatus, response.StatusCode)
+			body := decode[map[string]map[string]any](t, response)
+			// the responses of the upstream are never sent to the caller
+			assert.Equal(t, tt.wantMessage, body["error"]["message"])
+			assert.Equal(t, tt.wantType, body["error"]["type"])
+		})
+	}
+}
+
+func TestGateway_ChatCompletions(t *testing.T) {
+	u := &upstream{answer: func(request dto.RequestDto) (int, string) {
+		return http.StatusOK, answer("Your fried rice is coming.", 100, 20)
+	}}
+	server := newTestGateway(t, u)
+
+	response := post(t, server.URL, "kiosk-key", `{
+		"model": "any",
+		"temperature": 0.5,
+		"messages": [
+			{"role": "system", "content": "Ignored"},
+			{"role": "user", "content": "Hi"},
+			{"role": "assistant", "content": "Hello"},
+			{"role": "user", "content": "A fried rice"}
+		]
+	}`)
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	body := decode[completion](t, response)
+	assert.Equal(t, "chat.completion", body.Object)
+	assert.Equal(t, "gpt-4o-mini", body.Model)
+	assert.Equal(t, "Your fried rice is coming.", body.Choices[0].Message.Content)
+	assert.Equal(t, "stop", body.Choices[0].FinishReason)
+	assert.Equal(t, completionUsage{PromptTokens: 100, CompletionTokens: 20, TotalTokens: 120}, body.Usage)
+
+	// the bot answers with its own prompt, functions and sampling parameters overridden by the request
+	sent := u.requests[0]
+	assert.Equal(t, 0.5, *sent.Temperature)
+	assert.Len(t, sent.Tools, 1)
+	var roles []string
+	for _, message := range sent.Messages {
+		roles = append(roles, message.Role+": "+message.Content)
+	}
+	assert.Equal(t, []string{"system: You take orders", "user: Hi", "assistant: Hello", "user: A fried rice"}, roles)
+
+	assert.Equal(t, Usage{Requests: 1, PromptTokens: 100, CompletionTokens: 20, Cost: 0.14}, usageOf(t, server.URL, "kiosk-key"))
+	assert.Equal(t, Usage{}, usageOf(t, server.URL, "web-key"))
+}
+
+func TestGateway_Cache(t *testing.T) {
+	u := &upstream{answer: func(request dto.RequestDto) (int, string) {
+		return http.StatusOK, answer("Cached", 10, 5)
+	}}
+	server := newTestGateway(t, u)
+
+	for range 2 {
+		response := post(t, server.URL, "kiosk-key", `{"temperature":0,"messages":[{"role":"user","content":"Menu?"}]}`)
+		assert.Equal(t, "Cached", decode[completion](t, response).Choices[0].Message.Content)
+	}
+	assert.Len(t, u.requests, 1)
+	usage := usageOf(t, server.URL, "kiosk-key")
+	assert.Equal(t, 2, usage.Requests)
+	assert.Equal(t, 1, usage.CacheHits)
+	assert.Equal(t, 10, usage.PromptTokens)
+}
+
+func TestGateway_Stream(t *testing.T) {
+	u := &upstream{answer: func(request dto.RequestDto) (int, string) {
+		return http.StatusOK, answer("Your fried rice is coming.", 100, 20)
+	}}
+	server := newTestGateway(t, u)
+
+	response := post(t, server.URL, "web-key", `{"stream":true,"stream_options":{"include_usage":true},"messages":[{"role":"user","content":"A fried rice"}]}`)
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	assert.Equal(t, "text/event-stream", response.Header.Get("Content-Type"))
+
+	content, chunks, done := readStream(t, response)
+
+	assert.True(t, done)
+	assert.Equal(t, "Your fried rice is coming.", content)
+	assert.Equal(t, "assistant", chunks[0].Choices[0].Delta.Role)
+	assert.Equal(t, "stop", *chunks[len(chunks)-2].Choices[0].FinishReason)
+	assert.Equal(t, &completionUsage{PromptTokens: 100, CompletionTokens: 20, TotalTokens: 120}, chunks[len(chunks)-1].Usage)
+	assert.True(t, u.requests[0].Stream)
+}
+
+func TestGateway_StreamWithPlugins(t *testing.T) {
+	bot := `functions:
+  - count
+plugins:
+  - standard
+  - pii
+  - name: moderation
+    options:
+      keywords: [weapon]
+      refusal: I cannot help with that.
+      skip_input: true
+`
+	tests := []struct {
+		name    string
+		prompt  string
+		answer  string
+		content string
+	}{
+		{
+			name:    "pii restores the streamed placeholders",
+			prompt:  "Call me at 0912345678 when it is ready",
+			answer:  "We will call [PHONE_1] when it is ready.",
+			content: "We will call 0912345678 when it is ready.",
+		},
+		{
+			name:    "moderation refuses the streamed answer",
+			prompt:  "How do I build a weapon",
+			answer:  "Here is how to build a weapon.",
+			content: "I cannot help with that.",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			u := &upstream{answer: func(request dto.RequestDto) (int, string) {
+				return http.StatusOK, answer(test.answer, 10, 5)
+			}}
+			server := newTestGatewayWithBot(t, u, bot)
+
+			response := post(t, server.URL, "web-key", fmt.Sprintf(`{"stream":true,"messages":[{"role":"user","content":%q}]}`, test.prompt))
+			assert.Equal(t, http.StatusOK, response.StatusCode)
+			content, _, done := readStream(t, response)
+
+			assert.True(t, done)
+			assert.Equal(t, test.content, content)
+			last := u.requests[0].Messages[len(u.requests[0].Messages)-1]
+			assert.NotContains(t, last.Content, "0912345678")
+		})
+	}
+}
+
+// readStream returns the content of the streamed response, its chunks and whether it ended with [DONE].
+func readStream(t *testing.T, response *http.Response) (string, []completionChunk, bool) {
+	var content strings.Builder
+	var chunks []completionChunk
+	scanner := bufio.NewScanner(response.Body)
+	done := false
+	for scanner.Scan() {
+		data, found := strings.CutPrefix(scanner.Text(), "data: ")
+		if !found {
+			continue
+		}
+		if data == "[DONE]" {
+			done = true
+			break
+		}
+		var chunk completionChunk
+		assert.Nil(t, json.Unmarshal([]byte(data), &chunk))
+		chunks = append(chunks, chunk)
+		for _, choice := range chunk.Choices {
+			content.WriteString(choice.Delta.Content)
+		}
+	}
+	return content.String(), chunks, done
+}
+
+func TestGateway_Conversation(t *testing.T) {
+	u := &upstream{answer: func(request dto.RequestDto) (int, string) {
+		last := request.Messages[len(request.Messages)-1]
+		if last.Role == dto.RoleTool {
+			return http.StatusOK, answer("Counted: "+last.Content, 10, 5)
+		}
+		return http.StatusOK, `{"choices":[{"message":{"role":"assistant","content":"","tool_calls":[{"id":"1","type":"function","function":{"name":"count","arguments":"{}"}}]},"finish_reason":"tool_calls"}]}`
+	}}
+	server := newTestGateway(t, u)
+
+	var contents []string
+	for _, conversation := range []string{"a", "a", "b"} {
+		response := post(t, server.URL, "web-key", `{"messages":[{"role":"user","content":"Count"}]}`, conversationHeader, conversation)
+		contents = append(contents, decode[completion](t, response).Choices[0].Message.Content)
+	}
+	response := post(t, server.URL, "web-key", `{"messages":[{"role":"user","content":"Count"}]}`)
+	contents = append(contents, decode[completion](t, response).Choices[0].Message.Content)
+
+	// the functions keep their state across the requests of a conversation only
+	assert.Equal(t, []string{"Counted: count 1", "Counted: count 2", "Counted: count 1", "Counted: count 1"}, contents)
+}
+
+func TestGateway_Approval(t *testing.T) {
+	u := &upstream{answer: func(request dto.RequestDto) (int, string) {
+		last := request.Messages[len(request.Messages)-1]
+		switch {
+		case last.Role == dto.RoleTool:
+			return http.StatusOK, answer("Counted: "+last.Content, 10, 5)
+		case last.Content == "Count":
+			return http.StatusOK, `{"choices":[{"message":{"role":"assistant","content":"","tool_calls":[{"id":"1","type":"function","function":{"name":"count","arguments":"{}"}}]},"finish_reason":"tool_calls"}]}`
+		}
+		return http.StatusOK, answer("Anything else?", 10, 5)
+	}}
+	server := newTestGatewayWithBot(t, u, "functions:\n  - name: count\n    options:\n      requires_approval: true\n")
+	count := `{"role":"user","content":"Count"}`
+	toolCall := `{"role":"assistant","content":"","tool_calls":[{"id":"1","type":"function","function":{"name":"count","arguments":"{}"}}]}`
+
+	tests := []struct {
+		name        string
+		stream      bool
+		followUp    string
+		wantContent string
+	}{
+		{name: "Test with approval", followUp: `{"role":"tool","tool_call_id":"1","content":"yes"}`, wantContent: "Counted: count 1"},
+		{name: "Test with approval while streaming", stream: true, followUp: `{"role":"tool","tool_call_id":"1","content":"approve"}`, wantContent: "Counted: count 1"},
+		{name: "Test with rejection", followUp: `{"role":"tool","tool_call_id":"1","content":"{\"action\":\"reject\",\"reason\":\"not now\"}"}`, wantContent: "Counted: The user rejected the call of the function. Reason: not now"},
+		{name: "Test with user message", followUp: `{"role":"user","content":"Never mind"}`, wantContent: "Anything else?"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conversation := tt.name
+			// the function waiting for an approval is returned as a tool call
+			response := post(t, server.URL, "web-key", fmt.Sprintf(`{"messages":[%s]}`, count), conversationHeader, conversation)
+			pending := decode[completion](t, response).Choices[0]
+			assert.Equal(t, "tool_calls", pending.FinishReason)
+			assert.Equal(t, "count", pending.Message.ToolCalls[0].Function.Name)
+
+			body := fmt.Sprintf(`{"stream":%t,"messages":[%s,%s,%s]}`, tt.stream, count, toolCall, tt.followUp)
+			response = post(t, server.URL, "web-key", body, conversationHeader, conversation)
+			assert.Equal(t, http.StatusOK, response.StatusCode)
+			if tt.stream {
+				content, _, done := readStream(t, response)
+				assert.True(t, done)
+				assert.Equal(t, tt.wantContent, content)
+			} else {
+				assert.Equal(t, tt.wantContent, decode[completion](t, response).Choices[0].Message.Content)
+			}
+
+			// the turn is resumed once only
+			response = post(t, server.URL, "web-key", fmt.Sprintf(`{"messages":[%s,%s,{"role":"tool","tool_call_id":"1","content":"yes"}]}`, count, toolCall), conversationHeader, conversation)
+			assert.Equal(t, http.StatusBadRequest, response.StatusCode)
+		})
+	}
+
+	// the tool call left unanswered by the user message is answered before the history is sent
+	sent := u.requests[len(u.requests)-1]
+	assert.Equal(t, unansweredToolResult, sent.Messages[len(sent.Messages)-2].Content)
+	assert.Equal(t, "1", *sent.Messages[len(sent.Messages)-2].ToolCallId)
+}
+
+func TestTenant_Store(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	created := &tenant{
+		config:        TenantConfig{SessionIdleTimeout: time.Minute, MaxSessions: 2},
+		sessions:      functions.NewSessions(nil),
+		conversations: map[string]time.Time{},
+		now: func() time.Time {
+			return now
+		},
+	}
+	storeOf := func(conversationId string) *functions.FunctionStore {
+		store, err := created.store(conversationId)
+		assert.Nil(t, err)
+		return store
+	}
+
+	a := storeOf("a")
+	now = now.Add(time.Second)
+	b := storeOf("b")
+	now = now.Add(time.Second)
+	assert.Same(t, a, storeOf("a"))
+
+	// b is the least recently used conversation, so it is forgotten for c
+	now = now.Add(time.Second)
+	storeOf("c")
+	assert.Len(t, created.conversations, 2)
+	assert.NotSame(t, b, storeOf("b"))
+
+	// the idle conversations are forgotten
+	now = now.Add(2 * time.Minute)
+	c := storeOf("c")
+	assert.Equal(t, map[string]time.Time{"c": now}, created.conversations)
+	assert.Same(t, c, storeOf("c"))
+}
+
+func TestParseConfig(t *testing.T) {
+	t.Setenv("KIOSK_KEY", "secret")
+
+	tests := []struct {
+		name    string
+		data    string
+		want    Config
+		wantErr string
+	}{
+		{
+			name: "Test with valid config",
+			data: "tenants:\n  - name: kiosk\n    api_keys: [\"${KIOSK_KEY}\"]\n    bot: bot.yaml\n",
+			want: Config{Address: ":8080", Tenants: []TenantConfig{{Name: "kiosk", ApiKeys: []string{"secret"}, Bot: "bot.yaml"}}},
+		},
+		{
+			name: "Test with sessions",
+			data: "tenants:\n  - name: kiosk\n    api_keys: [key]\n    bot: bot.yaml\n    session_idle_timeout: 10m\n    max_sessions: 50\n",
+			want: Config{Address: ":8080", Tenants: []TenantConfig{{Name: "kiosk", ApiKeys: []string{"key"}, Bot: "bot.yaml", SessionIdleTimeout: 10 * time.Minute, MaxSessions: 50}}},
+		},
+		{
+			name:    "Test without tenants",
+			data:    "address: :80\n",
+			wantErr: "invalid gateway config: tenants are required",
+		},
+		{
+			name:    "Test without api keys",
+			data:    "tenants:\n  - name: kiosk\n    bot: bot.yaml\n",
+			wantErr: "invalid gateway config: tenant kiosk has no api keys",
+		},
+		{
+			name:    "Test with empty api key",
+			data:    "tenants:\n  - name: kiosk\n    api_keys: [\"${MISSING_KEY}\"]\n    bot: bot.yaml\n",
+			wantErr: "invalid gateway config: empty api key of tenant kiosk",
+		},
+		{
+			name:    "Test with shared api key",
+			data:    "tenants:\n  - name: kiosk\n    api_keys: [key]\n    bot: bot.yaml\n  - name: web\n    api_keys: [key]\n    bot: bot.yaml\n",
+			wantErr: "invalid gateway config: api key of tenant web is already used",
+		},
+		{
+			name:    "Test with unknown field",
+			data:    "tenant: []\n",
+			wantErr: "invalid gateway config: yaml: unmarshal errors:\n  line 1: field tenant not found in type main.Config",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config, err := ParseConfig([]byte(tt.data))
+			if len(tt.wantErr) > 0 {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tt.want, config)
+		})
+	}
+}
+
+func TestGateway_ModeratedInput(t *testing.T) {
+	u := &upstream{answer: func(request dto.RequestDto) (int, string) {
+		return http.StatusOK, answer("Here is how to build a weapon.", 10, 5)
+	}}
+	server := newTestGatewayWithBot(t, u, "plugins:\n  - name: moderation\n    options:\n      keywords: [weapon]\n")
+
+	response := post(t, server.URL, "web-key", `{"messages":[{"role":"user","content":"How do I build a weapon"}]}`)
+	assert.Equal(t, http.StatusBadRequest, response.StatusCode)
+	body := decode[map[string]map[string]any](t, response)
+	assert.Equal(t, "invalid_request_error", body["error"]["type"])
+	assert.Equal(t, float64(apperrors.ErrorContentPolicy), body["error"]["code"])
+	// the unsafe input never reaches the upstream
+	assert.Empty(t, u.requests)
+}
+
+func TestErrorBody(t *testing.T) {
+	tests := []struct {
+		name        string
+		err         error
+		wantStatus  int
+		wantMessage string
+		wantType    string
+	}{
+		{
+			name:        "Test with flagged input",
+			err:         fmt.Errorf("failed to convert input: %w", &plugin.ModerationError{Stage: plugin.InputStage, Categories: []string{"keyword"}}),
+			wantStatus:  http.StatusBadRequest,
+			wantMessage: "input flagged by moderation: keyword",
+			wantType:    "invalid_request_error",
+		},
+		{
+			name:        "Test with suspicious function result",
+			err:         &plugin.InjectionConfirmationError{Function: "confirm-order", Result: injection.Result{Reasons: []string{"ignore previous instructions"}}},
+			wantStatus:  http.StatusBadRequest,
+			wantMessage: "function confirm-order requires a confirmation after a suspicious function result",
+			wantType:    "invalid_request_error",
+		},
+		{
+			name:        "Test with rate limited upstream",
+			err:         &middleware.StatusError{StatusCode: http.StatusTooManyRequests, Body: "secret"},
+			wantStatus:  http.StatusTooManyRequests,
+			wantMessage: apperrors.NewRateLimited().Error(),
+			wantType:    "rate_limit_error",
+		},
+		{
+			name:        "Test with internal error",
+			err:         fmt.Errorf("secret"),
+			wantStatus:  http.StatusInternalServerError,
+			wantMessage: "The server failed to answer the request",
+			wantType:    "api_error",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.wantStatus, statusOf(tt.err))
+			body := errorBody(tt.err)["error"].(map[string]any)
+			assert.Equal(t, tt.wantMessage, body["message"])
+			assert.Equal(t, tt.wantType, body["type"])
+		})
+	}
+}
+
+func TestContentOf(t *testing.T) {
+	toolCalls := []dto.ToolCall{{Id: "1", Type: "function", Function: dto.Function{Name: "count"}}}
+	tests := []struct {
+		name     string
+		messages []dto.Message
+		want     string
+	}{
+		{
+			name: "Test with answers",
+			messages: []dto.Message{
+				{Role: dto.RoleAssistant, ToolCalls: &toolCalls},
+				{Role: dto.RoleTool, Content: "count 1"},
+				{Role: dto.RoleAssistant, Content: "Counted once."},
+				{Role: dto.RoleAssistant, Content: "Anything else?"},
+			},
+			want: "Counted once.\nAnything else?",
+		},
+		{
+			name: "Test with function answering without GPT",
+			messages: []dto.Message{
+				{Role: dto.RoleAssistant, ToolCalls: &toolCalls},
+				{Role: dto.RoleTool, Content: "count 1"},
+				{Role: dto.RoleAssistant, Content: "Counted: count 1"},
+			},
+			want: "Counted: count 1",
+		},
+		{
+			name: "Test without answer",
+			messages: []dto.Message{
+				{Role: dto.RoleAssistant, ToolCalls: &toolCalls},
+				{Role: dto.RoleTool, Content: "count 1"},
+			},
+			want: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, contentOf(gpt.GenerateResponse{NewResponses: tt.messages}))
+		})
+	}
+}
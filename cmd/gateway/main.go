@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"flag"
+	functions2 "github.com/meta-metopia/go-packages/cmd/chat/functions"
+	"github.com/meta-metopia/go-packages/pkg/ai/gpt/registry"
+	"log"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+)
+
+// gateway serves the OpenAI chat completions API in front of the bots, so the applications in any language get
+// their functions, plugins, caching and cost tracking with an OpenAI client.
+func main() {
+	configPath := flag.String("config", "gateway.yaml", "path of the YAML or JSON gateway configuration")
+	flag.Parse()
+
+	config, err := LoadConfig(*configPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	functions2.Register(registry.Default)
+	gateway, err := NewGateway(config, registry.Default, slog.New(slog.NewTextHandler(os.Stderr, nil)))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	server := &http.Server{Addr: config.Address, Handler: gateway}
+	go func() {
+		<-ctx.Done()
+		_ = server.Shutdown(context.Background())
+	}()
+
+	log.Printf("Serving the gateway on %s", config.Address)
+	if err := server.ListenAndServe(); err != http.ErrServerClosed {
+		log.Fatal(err)
+	}
+}
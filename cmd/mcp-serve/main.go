@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"flag"
+	functions2 "github.com/meta-metopia/go-packages/cmd/chat/functions"
+	"github.com/meta-metopia/go-packages/pkg/ai/gpt/functions"
+	"github.com/meta-metopia/go-packages/pkg/ai/gpt/registry"
+	"github.com/meta-metopia/go-packages/pkg/ai/mcp"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+)
+
+// createFunctions returns the registered functions with the names, or all of them when there are none.
+func createFunctions(names string) ([]functions.FunctionInterface, error) {
+	selected := registry.Default.Functions()
+	if len(names) > 0 {
+		selected = strings.Split(names, ",")
+	}
+
+	var result []functions.FunctionInterface
+	for _, name := range selected {
+		function, err := registry.Default.Function(strings.TrimSpace(name), registry.Options{})
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, function)
+	}
+	return result, nil
+}
+
+// mcp-serve serves the functions of the restaurant to the MCP clients, such as the agents of other applications.
+// It talks over stdio by default, so the clients can start it as a sub-process.
+func main() {
+	address := flag.String("http", "", "address to serve the streamable HTTP transport on, such as :8080. Defaults to stdio")
+	names := flag.String("functions", "", "comma separated names of the functions to serve. Defaults to all of them")
+	flag.Parse()
+
+	// stdout carries the protocol messages, so the logs go to stderr
+	log.SetFlags(0)
+	functions2.Register(registry.Default)
+
+	all, err := createFunctions(*names)
+	if err != nil {
+		log.Fatal(err)
+	}
+	server, err := mcp.NewServer(mcp.ServerConfig{
+		Name:         "restaurant",
+		Instructions: "Take the orders of the restaurant: get the menu, add the dishes to the order, check it out and confirm it.",
+		// the functions keep the order in their store, so every HTTP client gets its own
+		SessionFunctions: func() ([]functions.FunctionInterface, error) {
+			return createFunctions(*names)
+		},
+	}, all)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer func() {
+		if err := server.Close(); err != nil {
+			log.Println(err)
+		}
+	}()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	if len(*address) == 0 {
+		if err := server.ServeStdio(ctx, os.Stdin, os.Stdout); err != nil && ctx.Err() == nil {
+			log.Println(err)
+		}
+		return
+	}
+
+	httpServer := &http.Server{Addr: *address, Handler: server}
+	go func() {
+		<-ctx.Done()
+		_ = httpServer.Shutdown(context.Background())
+	}()
+	log.Printf("Serving MCP on %s", *address)
+	if err := httpServer.ListenAndServe(); err != http.ErrServerClosed {
+		log.Println(err)
+	}
+}
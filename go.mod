@@ -10,6 +10,8 @@ require (
 	github.com/google/logger v1.1.1
 	github.com/manifoldco/promptui v0.9.0
 	github.com/meta-metopia/go-packages/pkg/ai v0.0.0-20240220152237-5b10fc32fde4
+	github.com/stretchr/testify v1.8.4
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -0,0 +1,55 @@
+package audio
+
+import (
+	"io"
+	"time"
+)
+
+// BytesPerSample is the size of a sample. The audio is always 16 bit little endian PCM.
+const BytesPerSample = 2
+
+// Format describes a 16 bit little endian PCM stream.
+type Format struct {
+	SampleRate int
+	Channels   int
+}
+
+// BytesPerSecond returns the number of bytes of one second of audio.
+func (f Format) BytesPerSecond() int {
+	return f.SampleRate * f.Channels * BytesPerSample
+}
+
+// Bytes returns the number of bytes of [duration] of audio, rounded down to a whole frame.
+func (f Format) Bytes(duration time.Duration) int {
+	frameSize := f.Channels * BytesPerSample
+	return int(duration*time.Duration(f.BytesPerSecond())/time.Second) / frameSize * frameSize
+}
+
+// Duration returns the duration of [size] bytes of audio.
+func (f Format) Duration(size int) time.Duration {
+	return time.Duration(size) * time.Second / time.Duration(f.BytesPerSecond())
+}
+
+// Source is a stream of PCM audio, such as a microphone or a WAV file.
+type Source interface {
+	io.Reader
+	// Format returns the format of the audio read from the source.
+	Format() Format
+}
+
+type readerSource struct {
+	io.Reader
+	format Format
+}
+
+func (r readerSource) Format() Format {
+	return r.format
+}
+
+// NewSource returns a Source reading raw PCM audio of the format from the reader.
+func NewSource(reader io.Reader, format Format) Source {
+	return readerSource{
+		Reader: reader,
+		format: format,
+	}
+}
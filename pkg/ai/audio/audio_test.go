@@ -0,0 +1,32 @@
+package audio
+
+import (
+	"encoding/binary"
+	"math"
+	"time"
+)
+
+var testFormat = Format{SampleRate: 16000, Channels: 1}
+
+// tone returns a 440Hz sine wave of the amplitude.
+func tone(duration time.Duration, amplitude float64) []byte {
+	count := testFormat.Bytes(duration) / BytesPerSample
+	pcm := make([]byte, count*BytesPerSample)
+	for i := 0; i < count; i++ {
+		sample := amplitude * math.Sin(2*math.Pi*440*float64(i)/float64(testFormat.SampleRate))
+		binary.LittleEndian.PutUint16(pcm[i*BytesPerSample:], uint16(int16(sample)))
+	}
+	return pcm
+}
+
+func silence(duration time.Duration) []byte {
+	return make([]byte, testFormat.Bytes(duration))
+}
+
+func concat(parts ...[]byte) []byte {
+	var result []byte
+	for _, part := range parts {
+		result = append(result, part...)
+	}
+	return result
+}
@@ -0,0 +1,133 @@
+package audio
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"time"
+)
+
+// VadOptions configure the voice activity detection splitting a stream into utterances.
+// Zero values use the defaults.
+type VadOptions struct {
+	// FrameDuration is the duration of the frames the loudness is measured on. Defaults to 20ms.
+	FrameDuration time.Duration
+	// Threshold is the RMS amplitude above which a frame is speech. Defaults to 500.
+	Threshold float64
+	// MinSpeech is the minimum duration of speech for an utterance to be kept. Defaults to 300ms.
+	MinSpeech time.Duration
+	// SilenceTimeout is the duration of silence ending an utterance. Defaults to 800ms.
+	SilenceTimeout time.Duration
+	// PrePadding is the duration of audio kept before the speech starts. Defaults to 200ms, a negative value disables it.
+	PrePadding time.Duration
+	// MaxUtterance is the maximum duration of an utterance. Longer speech is split. Defaults to 30s.
+	MaxUtterance time.Duration
+}
+
+func (o VadOptions) withDefaults() VadOptions {
+	if o.FrameDuration <= 0 {
+		o.FrameDuration = 20 * time.Millisecond
+	}
+	if o.Threshold <= 0 {
+		o.Threshold = 500
+	}
+	if o.MinSpeech <= 0 {
+		o.MinSpeech = 300 * time.Millisecond
+	}
+	if o.SilenceTimeout <= 0 {
+		o.SilenceTimeout = 800 * time.Millisecond
+	}
+	if o.PrePadding < 0 {
+		o.PrePadding = 0
+	} else if o.PrePadding == 0 {
+		o.PrePadding = 200 * time.Millisecond
+	}
+	if o.MaxUtterance <= 0 {
+		o.MaxUtterance = 30 * time.Second
+	}
+	return o
+}
+
+// Utterances returns the iterator splitting the audio of the source into utterances using the loudness of the audio.
+// Every utterance is the PCM audio of the source's format, from a bit before the speech starts until the silence ending it.
+// The iterator stops at the end of the source, yielding the error if the source fails.
+func Utterances(source Source, options VadOptions) func(yield func(utterance []byte, err error) bool) {
+	return func(yield func(utterance []byte, err error) bool) {
+		options := options.withDefaults()
+		format := source.Format()
+		frameSize := max(format.Bytes(options.FrameDuration), format.Channels*BytesPerSample)
+		prePaddingSize := format.Bytes(options.PrePadding)
+		maxSize := format.Bytes(options.MaxUtterance)
+
+		var utterance []byte
+		var speech, silence time.Duration
+		flush := func() bool {
+			defer func() {
+				utterance = nil
+				speech, silence = 0, 0
+			}()
+			if speech < options.MinSpeech {
+				return true
+			}
+			return yield(utterance, nil)
+		}
+
+		frame := make([]byte, frameSize)
+		for {
+			n, err := io.ReadFull(source, frame)
+			if n > 0 {
+				isSpeech := rms(frame[:n]) >= options.Threshold
+				duration := format.Duration(n)
+
+				switch {
+				case isSpeech:
+					utterance = append(utterance, frame[:n]...)
+					speech += duration
+					silence = 0
+				case speech > 0:
+					utterance = append(utterance, frame[:n]...)
+					silence += duration
+				default:
+					// keep the last frames before the speech starts, so the first syllable is not cut
+					utterance = append(utterance, frame[:n]...)
+					if len(utterance) > prePaddingSize {
+						utterance = utterance[len(utterance)-prePaddingSize:]
+					}
+				}
+
+				if speech > 0 && (silence >= options.SilenceTimeout || len(utterance) >= maxSize) {
+					if !flush() {
+						return
+					}
+				}
+			}
+
+			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+				if speech > 0 {
+					flush()
+				}
+				return
+			}
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+		}
+	}
+}
+
+// rms returns the root mean square amplitude of the samples.
+func rms(pcm []byte) float64 {
+	count := len(pcm) / BytesPerSample
+	if count == 0 {
+		return 0
+	}
+
+	var sum float64
+	for i := 0; i < count; i++ {
+		sample := float64(int16(binary.LittleEndian.Uint16(pcm[i*BytesPerSample:])))
+		sum += sample * sample
+	}
+	return math.Sqrt(sum / float64(count))
+}
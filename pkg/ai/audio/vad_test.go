@@ -0,0 +1,81 @@
+package audio
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+type failingReader struct{}
+
+func (f failingReader) Read(p []byte) (int, error) {
+	return 0, fmt.Errorf("device lost")
+}
+
+func TestUtterances(t *testing.T) {
+	tests := []struct {
+		name     string
+		pcm      []byte
+		options  VadOptions
+		wantSize []time.Duration
+	}{
+		{
+			name:     "Test with silence",
+			pcm:      silence(2 * time.Second),
+			wantSize: nil,
+		},
+		{
+			name: "Test with two utterances",
+			pcm: concat(
+				silence(time.Second), tone(500*time.Millisecond, 3000),
+				silence(time.Second), tone(time.Second, 3000),
+				silence(time.Second),
+			),
+			// 200ms padding + speech + 800ms silence
+			wantSize: []time.Duration{1500 * time.Millisecond, 2000 * time.Millisecond},
+		},
+		{
+			name:     "Test with short pause",
+			pcm:      concat(tone(500*time.Millisecond, 3000), silence(300*time.Millisecond), tone(500*time.Millisecond, 3000)),
+			wantSize: []time.Duration{1300 * time.Millisecond},
+		},
+		{
+			name:     "Test with click",
+			pcm:      concat(silence(time.Second), tone(100*time.Millisecond, 3000), silence(time.Second)),
+			wantSize: nil,
+		},
+		{
+			name:     "Test with quiet speech",
+			pcm:      concat(tone(time.Second, 300)),
+			wantSize: nil,
+		},
+		{
+			name:     "Test with long speech",
+			pcm:      tone(5*time.Second, 3000),
+			options:  VadOptions{MaxUtterance: 2 * time.Second},
+			wantSize: []time.Duration{2 * time.Second, 2 * time.Second, time.Second},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var sizes []time.Duration
+			for utterance, err := range Utterances(NewSource(bytes.NewReader(tt.pcm), testFormat), tt.options) {
+				assert.Nil(t, err)
+				sizes = append(sizes, testFormat.Duration(len(utterance)))
+			}
+			assert.Equal(t, tt.wantSize, sizes)
+		})
+	}
+}
+
+func TestUtterances_Error(t *testing.T) {
+	var errs []error
+	for _, err := range Utterances(NewSource(failingReader{}, testFormat), VadOptions{}) {
+		errs = append(errs, err)
+	}
+	assert.Len(t, errs, 1)
+	assert.ErrorContains(t, errs[0], "device lost")
+}
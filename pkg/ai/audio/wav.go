@@ -0,0 +1,129 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+const (
+	wavHeaderSize = 44
+	wavFormatPCM  = 1
+)
+
+// wavFormatChunk is the content of the fmt chunk of a PCM WAV file.
+type wavFormatChunk struct {
+	AudioFormat   uint16
+	Channels      uint16
+	SampleRate    uint32
+	ByteRate      uint32
+	BlockAlign    uint16
+	BitsPerSample uint16
+}
+
+// ErrUnsupportedWav is returned when the WAV data is not 16 bit PCM.
+var ErrUnsupportedWav = errors.New("unsupported wav: only 16 bit pcm is supported")
+
+// EncodeWav returns the PCM audio wrapped in a WAV container.
+func EncodeWav(format Format, pcm []byte) []byte {
+	var buffer bytes.Buffer
+	buffer.Grow(wavHeaderSize + len(pcm))
+
+	buffer.WriteString("RIFF")
+	_ = binary.Write(&buffer, binary.LittleEndian, uint32(wavHeaderSize-8+len(pcm)))
+	buffer.WriteString("WAVE")
+
+	buffer.WriteString("fmt ")
+	_ = binary.Write(&buffer, binary.LittleEndian, uint32(16))
+	_ = binary.Write(&buffer, binary.LittleEndian, wavFormatChunk{
+		AudioFormat:   wavFormatPCM,
+		Channels:      uint16(format.Channels),
+		SampleRate:    uint32(format.SampleRate),
+		ByteRate:      uint32(format.BytesPerSecond()),
+		BlockAlign:    uint16(format.Channels * BytesPerSample),
+		BitsPerSample: BytesPerSample * 8,
+	})
+
+	buffer.WriteString("data")
+	_ = binary.Write(&buffer, binary.LittleEndian, uint32(len(pcm)))
+	buffer.Write(pcm)
+	return buffer.Bytes()
+}
+
+// NewWavSource returns a Source reading the PCM audio of the WAV data from the reader.
+// Chunks other than fmt and data are skipped.
+func NewWavSource(reader io.Reader) (Source, error) {
+	var riff struct {
+		Id   [4]byte
+		Size uint32
+		Wave [4]byte
+	}
+	if err := binary.Read(reader, binary.LittleEndian, &riff); err != nil {
+		return nil, fmt.Errorf("failed to read wav header: %w", err)
+	}
+	if string(riff.Id[:]) != "RIFF" || string(riff.Wave[:]) != "WAVE" {
+		return nil, fmt.Errorf("failed to read wav header: not a wav file")
+	}
+
+	var format *Format
+	for {
+		var chunk struct {
+			Id   [4]byte
+			Size uint32
+		}
+		if err := binary.Read(reader, binary.LittleEndian, &chunk); err != nil {
+			return nil, fmt.Errorf("failed to read wav chunk: %w", err)
+		}
+
+		switch string(chunk.Id[:]) {
+		case "fmt ":
+			var fmtChunk wavFormatChunk
+			if chunk.Size < 16 {
+				return nil, ErrUnsupportedWav
+			}
+			if err := binary.Read(reader, binary.LittleEndian, &fmtChunk); err != nil {
+				return nil, fmt.Errorf("failed to read wav format: %w", err)
+			}
+			if fmtChunk.AudioFormat != wavFormatPCM || fmtChunk.BitsPerSample != BytesPerSample*8 {
+				return nil, ErrUnsupportedWav
+			}
+			format = &Format{
+				SampleRate: int(fmtChunk.SampleRate),
+				Channels:   int(fmtChunk.Channels),
+			}
+			if err := skip(reader, int64(chunk.Size)-16); err != nil {
+				return nil, err
+			}
+		case "data":
+			if format == nil {
+				return nil, fmt.Errorf("failed to read wav: data chunk before fmt chunk")
+			}
+			return NewSource(io.LimitReader(reader, int64(chunk.Size)), *format), nil
+		default:
+			if err := skip(reader, int64(chunk.Size)); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+// NewWavFileSource returns a Source reading the PCM audio of the WAV file.
+func NewWavFileSource(path string) (Source, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read wav file %s: %w", path, err)
+	}
+	return NewWavSource(bytes.NewReader(data))
+}
+
+// skip discards [size] bytes of the reader, plus the padding byte of odd sized chunks.
+func skip(reader io.Reader, size int64) error {
+	size += size % 2
+	if _, err := io.CopyN(io.Discard, reader, size); err != nil {
+		return fmt.Errorf("failed to read wav chunk: %w", err)
+	}
+	return nil
+}
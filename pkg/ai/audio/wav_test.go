@@ -0,0 +1,71 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestEncodeWav(t *testing.T) {
+	pcm := tone(100*time.Millisecond, 1000)
+	wav := EncodeWav(testFormat, pcm)
+	assert.Equal(t, 44+len(pcm), len(wav))
+	assert.Equal(t, "RIFF", string(wav[:4]))
+	assert.Equal(t, "WAVE", string(wav[8:12]))
+
+	source, err := NewWavSource(bytes.NewReader(wav))
+	assert.Nil(t, err)
+	assert.Equal(t, testFormat, source.Format())
+	decoded, err := io.ReadAll(source)
+	assert.Nil(t, err)
+	assert.Equal(t, pcm, decoded)
+}
+
+func TestNewWavSource_SkipsChunks(t *testing.T) {
+	pcm := tone(10*time.Millisecond, 1000)
+	wav := EncodeWav(testFormat, pcm)
+
+	// insert an odd sized LIST chunk, padded to an even size, between the fmt and data chunks
+	var list bytes.Buffer
+	list.WriteString("LIST")
+	_ = binary.Write(&list, binary.LittleEndian, uint32(3))
+	list.Write([]byte{1, 2, 3, 0})
+	withList := concat(wav[:36], list.Bytes(), wav[36:])
+
+	source, err := NewWavSource(bytes.NewReader(withList))
+	assert.Nil(t, err)
+	decoded, err := io.ReadAll(source)
+	assert.Nil(t, err)
+	assert.Equal(t, pcm, decoded)
+}
+
+func TestNewWavSource_Errors(t *testing.T) {
+	_, err := NewWavSource(bytes.NewReader([]byte("not a wav file at all")))
+	assert.ErrorContains(t, err, "not a wav file")
+
+	wav := EncodeWav(testFormat, silence(10*time.Millisecond))
+	// 8 bit audio
+	binary.LittleEndian.PutUint16(wav[34:], 8)
+	_, err = NewWavSource(bytes.NewReader(wav))
+	assert.ErrorIs(t, err, ErrUnsupportedWav)
+}
+
+func TestNewWavFileSource(t *testing.T) {
+	pcm := tone(10*time.Millisecond, 1000)
+	path := filepath.Join(t.TempDir(), "audio.wav")
+	assert.Nil(t, os.WriteFile(path, EncodeWav(testFormat, pcm), 0o600))
+
+	source, err := NewWavFileSource(path)
+	assert.Nil(t, err)
+	decoded, err := io.ReadAll(source)
+	assert.Nil(t, err)
+	assert.Equal(t, pcm, decoded)
+
+	_, err = NewWavFileSource(filepath.Join(t.TempDir(), "missing.wav"))
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,131 @@
+package whisper
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/go-resty/resty/v2"
+	"github.com/meta-metopia/go-packages/pkg/ai/audio"
+	"os"
+	"strings"
+)
+
+// Transcriber converts speech to text.
+type Transcriber interface {
+	// Transcribe returns the text spoken in the WAV audio.
+	Transcribe(ctx context.Context, wav []byte) (string, error)
+}
+
+type IWhisperClient interface {
+	Transcriber
+	//SetClient sets the resty client for the whisper client.
+	SetClient(client *resty.Client)
+}
+
+type Config struct {
+	// Endpoint is the transcription endpoint, such as https://api.openai.com/v1/audio/transcriptions
+	// or the audio/transcriptions endpoint of an Azure OpenAI whisper deployment.
+	Endpoint string
+	ApiKey   string
+	// Model is required for the OpenAI endpoint. Azure uses the model of the deployment.
+	Model string
+	// Language is the ISO-639-1 language of the audio. Optional, it improves the accuracy and latency.
+	Language string
+	// Prompt guides the style of the transcript, such as the spelling of names.
+	Prompt string
+}
+
+type Client struct {
+	config     Config
+	httpClient *resty.Client
+}
+
+type transcriptionDto struct {
+	Text string `json:"text"`
+}
+
+// NewWhisperClient returns a new instance of the whisper client.
+func NewWhisperClient(config Config) IWhisperClient {
+	client := resty.New()
+	client.SetDebug(os.Getenv("DEBUG") == "true")
+
+	return &Client{
+		config:     config,
+		httpClient: client,
+	}
+}
+
+// SetClient sets the resty client for the whisper client.
+func (c *Client) SetClient(client *resty.Client) {
+	c.httpClient = client
+}
+
+// Transcribe uploads the WAV audio to the transcription endpoint and returns the transcript.
+func (c *Client) Transcribe(ctx context.Context, wav []byte) (string, error) {
+	var transcription transcriptionDto
+	formData := map[string]string{
+		"response_format": "json",
+	}
+	if len(c.config.Model) > 0 {
+		formData["model"] = c.config.Model
+	}
+	if len(c.config.Language) > 0 {
+		formData["language"] = c.config.Language
+	}
+	if len(c.config.Prompt) > 0 {
+		formData["prompt"] = c.config.Prompt
+	}
+
+	request := c.httpClient.R().SetContext(ctx)
+	if isOpenAIEndpoint(c.config.Endpoint) {
+		if len(c.config.Model) == 0 {
+			return "", fmt.Errorf("model is required for openai whisper endpoint")
+		}
+		request = request.SetHeader("Authorization", "Bearer "+c.config.ApiKey)
+	} else {
+		request = request.SetHeader("api-key", c.config.ApiKey)
+	}
+
+	response, err := request.
+		SetFileReader("file", "audio.wav", bytes.NewReader(wav)).
+		SetFormData(formData).
+		SetResult(&transcription).
+		Post(c.config.Endpoint)
+	if err != nil {
+		return "", err
+	}
+	if !response.IsSuccess() {
+		return "", fmt.Errorf("failed to transcribe audio: %d %s", response.StatusCode(), response.String())
+	}
+	return strings.TrimSpace(transcription.Text), nil
+}
+
+// Transcripts returns the iterator splitting the audio of the source into utterances and yielding their transcripts.
+// Utterances without text, such as noise, are skipped. The iterator stops at the end of the source or at the first error.
+func Transcripts(ctx context.Context, transcriber Transcriber, source audio.Source, options audio.VadOptions) func(yield func(transcript string, err error) bool) {
+	return func(yield func(transcript string, err error) bool) {
+		format := source.Format()
+		for utterance, err := range audio.Utterances(source, options) {
+			if err != nil {
+				yield("", err)
+				return
+			}
+
+			transcript, err := transcriber.Transcribe(ctx, audio.EncodeWav(format, utterance))
+			if err != nil {
+				yield("", err)
+				return
+			}
+			if len(transcript) == 0 {
+				continue
+			}
+			if !yield(transcript, nil) {
+				return
+			}
+		}
+	}
+}
+
+func isOpenAIEndpoint(endpoint string) bool {
+	return strings.Contains(endpoint, "api.openai.com")
+}
@@ -0,0 +1,158 @@
+package whisper
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"github.com/go-resty/resty/v2"
+	"github.com/jarcoal/httpmock"
+	"github.com/meta-metopia/go-packages/pkg/ai/audio"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"math"
+	"net/http"
+	"testing"
+	"time"
+)
+
+var testFormat = audio.Format{SampleRate: 16000, Channels: 1}
+
+type fakeTranscriber struct {
+	transcripts []string
+	calls       int
+}
+
+func (f *fakeTranscriber) Transcribe(ctx context.Context, wav []byte) (string, error) {
+	if _, err := audio.NewWavSource(bytes.NewReader(wav)); err != nil {
+		return "", err
+	}
+	if f.calls >= len(f.transcripts) {
+		return "", fmt.Errorf("unexpected utterance")
+	}
+	transcript := f.transcripts[f.calls]
+	f.calls++
+	return transcript, nil
+}
+
+// speech returns the WAV audio of tones separated by one second of silence.
+func speech(count int) []byte {
+	var pcm []byte
+	for i := 0; i < count; i++ {
+		pcm = append(pcm, make([]byte, testFormat.Bytes(time.Second))...)
+		for j := 0; j < testFormat.Bytes(500*time.Millisecond)/audio.BytesPerSample; j++ {
+			sample := 3000 * math.Sin(2*math.Pi*440*float64(j)/float64(testFormat.SampleRate))
+			pcm = binary.LittleEndian.AppendUint16(pcm, uint16(int16(sample)))
+		}
+	}
+	pcm = append(pcm, make([]byte, testFormat.Bytes(time.Second))...)
+	return audio.EncodeWav(testFormat, pcm)
+}
+
+func TestClient_Transcribe(t *testing.T) {
+	tests := []struct {
+		name       string
+		config     Config
+		status     int
+		wantHeader string
+		wantForm   map[string]string
+		want       string
+		wantErr    string
+	}{
+		{
+			name:       "Test with openai",
+			config:     Config{Endpoint: "https://api.openai.com/v1/audio/transcriptions", ApiKey: "key", Model: "whisper-1", Language: "zh"},
+			status:     http.StatusOK,
+			wantHeader: "Bearer key",
+			wantForm:   map[string]string{"model": "whisper-1", "language": "zh", "response_format": "json"},
+			want:       "你好",
+		},
+		{
+			name:       "Test with azure",
+			config:     Config{Endpoint: "https://example.openai.azure.com/openai/deployments/whisper/audio/transcriptions", ApiKey: "key", Prompt: "Menu"},
+			status:     http.StatusOK,
+			wantHeader: "key",
+			wantForm:   map[string]string{"prompt": "Menu", "response_format": "json"},
+			want:       "你好",
+		},
+		{
+			name:    "Test with openai without model",
+			config:  Config{Endpoint: "https://api.openai.com/v1/audio/transcriptions", ApiKey: "key"},
+			wantErr: "model is required",
+		},
+		{
+			name:    "Test with error from server",
+			config:  Config{Endpoint: "https://example.openai.azure.com/transcriptions", ApiKey: "key"},
+			status:  http.StatusBadRequest,
+			wantErr: "failed to transcribe audio: 400",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			restyClient := resty.New()
+			httpmock.ActivateNonDefault(restyClient.GetClient())
+			defer httpmock.DeactivateAndReset()
+
+			var header string
+			form := map[string]string{}
+			var file []byte
+			httpmock.RegisterResponder("POST", tt.config.Endpoint, func(request *http.Request) (*http.Response, error) {
+				header = request.Header.Get("Authorization") + request.Header.Get("api-key")
+				if err := request.ParseMultipartForm(1 << 20); err != nil {
+					return nil, err
+				}
+				for key, values := range request.MultipartForm.Value {
+					form[key] = values[0]
+				}
+				uploaded, _, err := request.FormFile("file")
+				if err != nil {
+					return nil, err
+				}
+				file, _ = io.ReadAll(uploaded)
+				return httpmock.NewJsonResponse(tt.status, map[string]string{"text": " 你好 "})
+			})
+
+			client := NewWhisperClient(tt.config)
+			client.SetClient(restyClient)
+			got, err := client.Transcribe(context.Background(), []byte("wav"))
+			if len(tt.wantErr) > 0 {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.wantHeader, header)
+			assert.Equal(t, tt.wantForm, form)
+			assert.Equal(t, []byte("wav"), file)
+		})
+	}
+}
+
+func TestTranscripts(t *testing.T) {
+	source, err := audio.NewWavSource(bytes.NewReader(speech(3)))
+	assert.Nil(t, err)
+
+	// the empty transcript of the second utterance is skipped
+	transcriber := &fakeTranscriber{transcripts: []string{"我要炒飯", "", "謝謝"}}
+	var transcripts []string
+	for transcript, err := range Transcripts(context.Background(), transcriber, source, audio.VadOptions{}) {
+		assert.Nil(t, err)
+		transcripts = append(transcripts, transcript)
+	}
+	assert.Equal(t, []string{"我要炒飯", "謝謝"}, transcripts)
+	assert.Equal(t, 3, transcriber.calls)
+}
+
+func TestTranscripts_Error(t *testing.T) {
+	source, err := audio.NewWavSource(bytes.NewReader(speech(2)))
+	assert.Nil(t, err)
+
+	transcriber := &fakeTranscriber{}
+	var errs []error
+	for _, err := range Transcripts(context.Background(), transcriber, source, audio.VadOptions{}) {
+		errs = append(errs, err)
+	}
+	assert.Len(t, errs, 1)
+	assert.ErrorContains(t, errs[0], "unexpected utterance")
+}
@@ -0,0 +1,140 @@
+package gpt
+
+import (
+	"context"
+	"fmt"
+	"github.com/meta-metopia/go-packages/pkg/ai/gpt/dto"
+	"github.com/meta-metopia/go-packages/pkg/ai/gpt/telemetry"
+	"slices"
+)
+
+type ApprovalAction string
+
+const (
+	// ApproveAction calls the function with the arguments of the model.
+	ApproveAction ApprovalAction = "approve"
+	// EditAction calls the function with the arguments of the caller.
+	EditAction ApprovalAction = "edit"
+	// RejectAction does not call the function, and lets the model answer the rejection.
+	RejectAction ApprovalAction = "reject"
+)
+
+// Approval is the decision of the caller on a pending approval.
+type Approval struct {
+	Action ApprovalAction
+	// Arguments replace the arguments of the model when the Action is EditAction.
+	Arguments map[string]interface{}
+	// Reason is sent to the model when the Action is RejectAction.
+	Reason string
+}
+
+// PendingApproval is a function call waiting for the caller to approve it.
+// It holds the whole state of the stopped turn and can be stored as JSON, so the turn can be resumed after a restart.
+type PendingApproval struct {
+	ToolCallId string `json:"toolCallId"`
+	Function   string `json:"function"`
+	// Arguments are the arguments of the model, once the plugins converted them.
+	Arguments map[string]interface{} `json:"arguments"`
+	// History is the history of the turn, ending with the message of the assistant calling the function.
+	History []dto.Message `json:"history"`
+}
+
+// withHistory returns a copy of the pending approval with the history of the turn.
+func (p *PendingApproval) withHistory(history []dto.Message) *PendingApproval {
+	pending := *p
+	pending.History = slices.Clone(history)
+	return &pending
+}
+
+// Resume resumes the turn stopped by the pending approval, once the caller approved, edited or rejected the function call.
+// The responses are yielded as GenerateIterator yields them, starting with the result of the function.
+func (g *Client) Resume(ctx context.Context, pending PendingApproval, approval Approval) GenerateIteratorRet {
+	return func(yield func(response GenerateResponse, err error) bool) {
+		g, err := g.snapshot()
+		if err != nil {
+			yield(GenerateResponse{}, err)
+			return
+		}
+		ctx, span := g.config.Telemetry.StartTurn(ctx)
+		defer func() {
+			telemetry.End(span, err)
+		}()
+
+		function := g.findFunction(pending.Function)
+		if function == nil {
+			err = fmt.Errorf("function %s is not found", pending.Function)
+			yield(GenerateResponse{}, err)
+			return
+		}
+
+		_, messages, err := g.createMessages(nil, pending.History)
+		if err != nil {
+			g.log(ctx).ErrorContext(ctx, "failed to render prompt", logKeyError, err)
+			yield(GenerateResponse{}, err)
+			return
+		}
+
+		var outputs func(func(turnMessage, error) bool)
+		switch approval.Action {
+		case ApproveAction:
+			outputs = g.callFunction(ctx, function, function.Config(), pending.ToolCallId, pending.Arguments, messages, nil)
+		case EditAction:
+			if approval.Arguments == nil {
+				err = fmt.Errorf("arguments are required to edit the call of function %s", pending.Function)
+				yield(GenerateResponse{}, err)
+				return
+			}
+			outputs = g.callFunction(ctx, function, function.Config(), pending.ToolCallId, approval.Arguments, messages, nil)
+		case RejectAction:
+			content := "The user rejected the call of the function."
+			if len(approval.Reason) > 0 {
+				content += " Reason: " + approval.Reason
+			}
+			outputs = g.rejectFunction(ctx, pending.ToolCallId, content, messages, nil)
+		default:
+			err = fmt.Errorf("unknown approval action %s", approval.Action)
+			yield(GenerateResponse{}, err)
+			return
+		}
+
+		g.log(ctx).InfoContext(
+			ctx,
+			"function approval resolved",
+			logKeyToolName, pending.Function,
+			logKeyToolCallId, pending.ToolCallId,
+			logKeyApprovalAction, approval.Action,
+		)
+		err = yieldOutputs(outputs, slices.Clone(pending.History), yield)
+	}
+}
+
+// rejectFunction answers the function call with the rejection [content], then lets the model answer it.
+// The rejection is converted by the plugins as the results of the functions are, since it can hold the reason of the caller.
+func (g *Client) rejectFunction(ctx context.Context, toolCallId string, content string, history []dto.Message, onDelta func(delta string)) func(func(turnMessage, error) bool) {
+	return func(yield func(turnMessage, error) bool) {
+		outputs, err := g.usePluginForToolResult(ctx, dto.Message{
+			Role:       dto.RoleTool,
+			Content:    content,
+			ToolCallId: &toolCallId,
+		})
+		if err != nil {
+			yield(turnMessage{}, err)
+			return
+		}
+		newHistory := slices.Clip(history)
+		for _, output := range outputs {
+			if output.history != nil && !output.history.Config.ExcludeFromHistory {
+				newHistory = append(newHistory, *output.history)
+			}
+			if !yield(output, nil) {
+				return
+			}
+		}
+
+		for response, err := range g.generate(ctx, newHistory, onDelta) {
+			if !yield(response, err) || err != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,230 @@
+package gpt
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/jarcoal/httpmock"
+	template "github.com/meta-metopia/go-packages/pkg/ai"
+	"github.com/meta-metopia/go-packages/pkg/ai/gpt/dto"
+	"github.com/meta-metopia/go-packages/pkg/ai/gpt/functions"
+	"github.com/meta-metopia/go-packages/pkg/ai/gpt/plugin"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	"net/http"
+)
+
+const approvalUrl = "http://localhost:8080"
+
+// newApprovalClient returns a client with the function complete-order requiring an approval.
+// The model calls the function, then answers its result.
+func (suite *GptTestSuite) newApprovalClient(function *functions.MockFunctionInterface) IGptClient {
+	engine := template.NewMockEngine(suite.ctrl)
+	engine.EXPECT().Render(gomock.Any()).Return("Mock Data", nil).AnyTimes()
+
+	toolResponder, err := httpmock.NewJsonResponder(http.StatusOK, map[string]interface{}{
+		"choices": []map[string]interface{}{
+			{
+				"message": map[string]interface{}{
+					"role": "assistant",
+					"tool_calls": []map[string]interface{}{
+						{
+							"id":   "1",
+							"type": "function",
+							"function": map[string]interface{}{
+								"name":      "complete-order",
+								"arguments": `{"table":3}`,
+							},
+						},
+					},
+				},
+			},
+		},
+	})
+	assert.Nil(suite.T(), err)
+	httpmock.RegisterResponder("POST", approvalUrl, toolResponder)
+
+	function.EXPECT().Name().Return("complete-order").AnyTimes()
+	function.EXPECT().Description().Return("Complete the order").AnyTimes()
+	function.EXPECT().Parameters().Return(map[string]interface{}{}).AnyTimes()
+	function.EXPECT().SetStore(gomock.Any()).AnyTimes()
+	function.EXPECT().OnInit().AnyTimes()
+	function.EXPECT().OnClose().AnyTimes()
+	function.EXPECT().Config().Return(functions.FunctionConfig{UseGptToInterpretResponses: true, RequiresApproval: true}).AnyTimes()
+
+	client, err := NewGptClient(Config{
+		Endpoint:  approvalUrl,
+		ApiKey:    "123",
+		Functions: &[]functions.FunctionInterface{function},
+		Template:  engine,
+		Store:     functions.NewFunctionStore(),
+	})
+	assert.Nil(suite.T(), err)
+	client.SetClient(suite.client)
+	return client
+}
+
+// pendingApproval runs the turn until it stops, and returns the pending approval after storing it as JSON.
+// The client is closed, as the turn is resumed by another client.
+func (suite *GptTestSuite) pendingApproval(client IGptClient) PendingApproval {
+	defer func() {
+		assert.Nil(suite.T(), client.Close())
+	}()
+	prompt := "結帳"
+	var responses []GenerateResponse
+	for response, err := range client.GenerateIterator(&prompt, nil) {
+		assert.Nil(suite.T(), err)
+		responses = append(responses, response)
+	}
+
+	assert.Len(suite.T(), responses, 2)
+	assert.Nil(suite.T(), responses[0].PendingApproval)
+	pending := responses[1].PendingApproval
+	assert.NotNil(suite.T(), pending)
+	assert.Empty(suite.T(), responses[1].NewResponses)
+	assert.Equal(suite.T(), "complete-order", pending.Function)
+	assert.Equal(suite.T(), "1", pending.ToolCallId)
+	assert.Equal(suite.T(), map[string]interface{}{"table": 3.0}, pending.Arguments)
+	assert.Equal(suite.T(), []dto.Role{dto.RoleUser, dto.RoleAssistant}, roles(pending.History))
+
+	data, err := json.Marshal(pending)
+	assert.Nil(suite.T(), err)
+	var stored PendingApproval
+	assert.Nil(suite.T(), json.Unmarshal(data, &stored))
+	return stored
+}
+
+// answer makes the model answer the next requests, recording the last message sent to it.
+func (suite *GptTestSuite) answer(lastMessage *dto.Message) {
+	httpmock.RegisterResponder("POST", approvalUrl, func(request *http.Request) (*http.Response, error) {
+		var body dto.RequestDto
+		if err := json.NewDecoder(request.Body).Decode(&body); err != nil {
+			return nil, err
+		}
+		*lastMessage = body.Messages[len(body.Messages)-1]
+		return httpmock.NewJsonResponse(http.StatusOK, map[string]interface{}{
+			"choices": []map[string]interface{}{
+				{"message": map[string]interface{}{"role": "assistant", "content": "好的"}},
+			},
+		})
+	})
+}
+
+func roles(messages []dto.Message) []dto.Role {
+	var messageRoles []dto.Role
+	for _, message := range messages {
+		messageRoles = append(messageRoles, message.Role)
+	}
+	return messageRoles
+}
+
+func (suite *GptTestSuite) TestGptWithApproval() {
+	tests := []struct {
+		name          string
+		approval      Approval
+		wantArguments map[string]interface{}
+		wantResult    string
+	}{
+		{
+			name:          "Test with approve",
+			approval:      Approval{Action: ApproveAction},
+			wantArguments: map[string]interface{}{"table": 3.0},
+			wantResult:    "訂單已完成",
+		},
+		{
+			name:          "Test with edit",
+			approval:      Approval{Action: EditAction, Arguments: map[string]interface{}{"table": 4.0}},
+			wantArguments: map[string]interface{}{"table": 4.0},
+			wantResult:    "訂單已完成",
+		},
+		{
+			name:       "Test with reject",
+			approval:   Approval{Action: RejectAction, Reason: "wrong table"},
+			wantResult: "The user rejected the call of the function. Reason: wrong table",
+		},
+	}
+
+	for _, tt := range tests {
+		suite.Run(tt.name, func() {
+			httpmock.Reset()
+			function := functions.NewMockFunctionInterface(suite.ctrl)
+			if tt.wantArguments != nil {
+				function.EXPECT().OnMessage(tt.wantArguments).Return(&functions.FunctionGptResponse{Content: "訂單已完成"}, nil).Times(1)
+			}
+			pending := suite.pendingApproval(suite.newApprovalClient(function))
+
+			// another client resumes the turn, as after a restart
+			client := suite.newApprovalClient(function)
+			var lastMessage dto.Message
+			suite.answer(&lastMessage)
+			var responses []GenerateResponse
+			for response, err := range client.Resume(context.Background(), pending, tt.approval) {
+				assert.Nil(suite.T(), err)
+				responses = append(responses, response)
+			}
+
+			assert.Len(suite.T(), responses, 2)
+			assert.Equal(suite.T(), tt.wantResult, responses[0].NewResponses[0].Content)
+			assert.Equal(suite.T(), "1", *responses[0].NewResponses[0].ToolCallId)
+			assert.Equal(suite.T(), "好的", responses[1].NewResponses[0].Content)
+			assert.Equal(suite.T(), tt.wantResult, lastMessage.Content)
+			assert.Equal(suite.T(), []dto.Role{dto.RoleUser, dto.RoleAssistant, dto.RoleTool, dto.RoleAssistant}, roles(responses[1].FullHistory))
+		})
+	}
+}
+
+func (suite *GptTestSuite) TestGptWithRejectionConvertedByPlugins() {
+	function := functions.NewMockFunctionInterface(suite.ctrl)
+	pending := suite.pendingApproval(suite.newApprovalClient(function))
+
+	client := suite.newApprovalClient(function)
+	client.SetPlugins(&[]plugin.Interface{plugin.NewStandardOutputPlugin(), plugin.NewPiiPlugin(nil)})
+	var lastMessage dto.Message
+	suite.answer(&lastMessage)
+	var responses []GenerateResponse
+	for response, err := range client.Resume(context.Background(), pending, Approval{Action: RejectAction, Reason: "call 0912345678 first"}) {
+		assert.Nil(suite.T(), err)
+		responses = append(responses, response)
+	}
+
+	// the reason is redacted like the results of the functions
+	assert.Len(suite.T(), responses, 2)
+	assert.Equal(suite.T(), "The user rejected the call of the function. Reason: call [PHONE_1] first", lastMessage.Content)
+	assert.Equal(suite.T(), dto.RoleTool, lastMessage.Role)
+	assert.Equal(suite.T(), "1", *lastMessage.ToolCallId)
+	assert.Equal(suite.T(), []dto.Role{dto.RoleUser, dto.RoleAssistant, dto.RoleTool, dto.RoleAssistant}, roles(responses[1].FullHistory))
+}
+
+func (suite *GptTestSuite) TestGptWithPendingApproval() {
+	function := functions.NewMockFunctionInterface(suite.ctrl)
+	client := suite.newApprovalClient(function)
+
+	prompt := "結帳"
+	response, err := client.Generate(&prompt, nil)
+	assert.Nil(suite.T(), err)
+	assert.Len(suite.T(), response.NewResponses, 1)
+	assert.Equal(suite.T(), "complete-order", response.PendingApproval.Function)
+	assert.Equal(suite.T(), response.FullHistory, response.PendingApproval.History)
+
+	httpmock.RegisterResponder("POST", approvalUrl, httpmock.NewStringResponder(http.StatusOK, events(
+		`{"choices":[{"index":0,"delta":{"role":"assistant","tool_calls":[{"index":0,"id":"1","type":"function","function":{"name":"complete-order","arguments":"{\"table\":3}"}}]},"finish_reason":"tool_calls"}]}`,
+	)))
+	var streamed *GenerateResponse
+	for event, err := range client.GenerateStream(context.Background(), prompt, nil) {
+		assert.Nil(suite.T(), err)
+		streamed = event.Response
+	}
+	assert.Equal(suite.T(), response.PendingApproval, streamed.PendingApproval)
+
+	for _, err = range client.Resume(context.Background(), *response.PendingApproval, Approval{Action: "cancel"}) {
+	}
+	assert.EqualError(suite.T(), err, "unknown approval action cancel")
+
+	for _, err = range client.Resume(context.Background(), *response.PendingApproval, Approval{Action: EditAction}) {
+	}
+	assert.EqualError(suite.T(), err, "arguments are required to edit the call of function complete-order")
+
+	response.PendingApproval.Function = "get-menu"
+	for _, err = range client.Resume(context.Background(), *response.PendingApproval, Approval{Action: ApproveAction}) {
+	}
+	assert.EqualError(suite.T(), err, "function get-menu is not found")
+}
@@ -0,0 +1,131 @@
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/meta-metopia/go-packages/pkg/ai/gpt/dto"
+	"github.com/meta-metopia/go-packages/pkg/ai/gpt/middleware"
+	"net/http"
+	"time"
+)
+
+// Cache stores the encoded responses by their request key.
+type Cache interface {
+	// Get returns the value stored for the key. [found] is false when the key is missing or expired.
+	Get(key string) (value []byte, found bool, err error)
+	// Set stores the value for the key. A ttl of 0 keeps the value until it is evicted.
+	Set(key string, value []byte, ttl time.Duration) error
+}
+
+type Options struct {
+	// TTL is how long a response stays in the cache. 0 keeps it until it is evicted.
+	TTL time.Duration
+	// CacheNonDeterministic caches the responses of requests that are not deterministic.
+	// By default, only requests with a temperature of 0 or a seed are cached.
+	CacheNonDeterministic bool
+}
+
+type bypassKey struct{}
+
+// WithBypass returns a copy of ctx that skips the cache for the requests sent with it.
+func WithBypass(ctx context.Context) context.Context {
+	return context.WithValue(ctx, bypassKey{}, true)
+}
+
+// cacheKey is the normalized part of the request used to compute the key.
+type cacheKey struct {
+	Endpoint    string        `json:"endpoint,omitempty"`
+	Model       *string       `json:"model,omitempty"`
+	Messages    []dto.Message `json:"messages"`
+	Tools       []dto.Tool    `json:"tools,omitempty"`
+	Temperature *float64      `json:"temperature,omitempty"`
+	TopP        *float64      `json:"top_p,omitempty"`
+	Seed        *int          `json:"seed,omitempty"`
+	MaxTokens   *int          `json:"max_tokens,omitempty"`
+}
+
+// Key returns the hash of the model, messages, tools and sampling parameters of the request.
+// The endpoint is only part of the key when the request has no model, since Azure deployments pick the model by url.
+func Key(request *middleware.Request) (string, error) {
+	body := request.Body
+	key := cacheKey{
+		Model:       body.Model,
+		Tools:       body.Tools,
+		Temperature: body.Temperature,
+		TopP:        body.TopP,
+		Seed:        body.Seed,
+		MaxTokens:   body.MaxTokens,
+	}
+	if body.Model == nil {
+		key.Endpoint = request.Endpoint
+	}
+	for _, message := range body.Messages {
+		message.Usage = nil
+		key.Messages = append(key.Messages, message)
+	}
+
+	encoded, err := json.Marshal(key)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(encoded)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// IsDeterministic returns true when the request always produces the same completion.
+func IsDeterministic(body *dto.RequestDto) bool {
+	if body.Seed != nil {
+		return true
+	}
+	return body.Temperature != nil && *body.Temperature == 0
+}
+
+// Middleware returns the middleware that serves repeated requests from the cache.
+// Responses served from the cache have CacheHit set and their usage zeroed, since no tokens were spent.
+// A failing cache never fails the request: read errors are treated as a miss and write errors are ignored.
+func Middleware(cache Cache, options Options) middleware.Middleware {
+	return func(next middleware.RoundTrip) middleware.RoundTrip {
+		return func(ctx context.Context, request *middleware.Request) (*middleware.Response, error) {
+			if bypass, _ := ctx.Value(bypassKey{}).(bool); bypass || request.Body == nil {
+				return next(ctx, request)
+			}
+			if !options.CacheNonDeterministic && !IsDeterministic(request.Body) {
+				return next(ctx, request)
+			}
+
+			key, err := Key(request)
+			if err != nil {
+				return nil, err
+			}
+
+			value, found, err := cache.Get(key)
+			if err == nil && found {
+				var body dto.ResponseDto
+				if err := json.Unmarshal(value, &body); err == nil {
+					body.Usage = &dto.Usage{}
+					return &middleware.Response{
+						StatusCode: http.StatusOK,
+						Header:     http.Header{},
+						Body:       &body,
+						CacheHit:   true,
+					}, nil
+				}
+			}
+
+			response, err := next(ctx, request)
+			if err != nil {
+				return nil, err
+			}
+			if response == nil || response.Body == nil || len(response.Body.Choices) == 0 {
+				return response, nil
+			}
+
+			if encoded, err := json.Marshal(response.Body); err == nil {
+				_ = cache.Set(key, encoded, options.TTL)
+			}
+			return response, nil
+		}
+	}
+}
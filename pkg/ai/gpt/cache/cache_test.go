@@ -0,0 +1,183 @@
+package cache
+
+import (
+	"context"
+	"github.com/meta-metopia/go-packages/pkg/ai/gpt/dto"
+	"github.com/meta-metopia/go-packages/pkg/ai/gpt/middleware"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+)
+
+func floatPtr(f float64) *float64 {
+	return &f
+}
+
+func intPtr(i int) *int {
+	return &i
+}
+
+func strPtr(s string) *string {
+	return &s
+}
+
+func newRequest(content string, temperature *float64) *middleware.Request {
+	return &middleware.Request{
+		Endpoint: "https://api.openai.com/v1/chat/completions",
+		Body: &dto.RequestDto{
+			Model:       strPtr("gpt-4"),
+			Messages:    []dto.Message{{Role: dto.RoleUser, Content: content}},
+			Temperature: temperature,
+		},
+	}
+}
+
+func TestKey(t *testing.T) {
+	key, err := Key(newRequest("Hello", floatPtr(0)))
+	assert.Nil(t, err)
+
+	sameKey, err := Key(newRequest("Hello", floatPtr(0)))
+	assert.Nil(t, err)
+	assert.Equal(t, key, sameKey)
+
+	withUsage := newRequest("Hello", floatPtr(0))
+	withUsage.Body.Messages[0].Usage = &dto.Usage{PromptToken: 1}
+	withUsageKey, err := Key(withUsage)
+	assert.Nil(t, err)
+	assert.Equal(t, key, withUsageKey)
+
+	otherContentKey, err := Key(newRequest("Bye", floatPtr(0)))
+	assert.Nil(t, err)
+	assert.NotEqual(t, key, otherContentKey)
+
+	otherTemperatureKey, err := Key(newRequest("Hello", floatPtr(0.5)))
+	assert.Nil(t, err)
+	assert.NotEqual(t, key, otherTemperatureKey)
+
+	otherEndpoint := newRequest("Hello", floatPtr(0))
+	otherEndpoint.Endpoint = "https://example.com"
+	otherEndpointKey, err := Key(otherEndpoint)
+	assert.Nil(t, err)
+	assert.Equal(t, key, otherEndpointKey)
+}
+
+func TestKeyWithoutModel(t *testing.T) {
+	first := &middleware.Request{Endpoint: "https://a.openai.azure.com", Body: &dto.RequestDto{}}
+	second := &middleware.Request{Endpoint: "https://b.openai.azure.com", Body: &dto.RequestDto{}}
+
+	firstKey, err := Key(first)
+	assert.Nil(t, err)
+	secondKey, err := Key(second)
+	assert.Nil(t, err)
+	assert.NotEqual(t, firstKey, secondKey)
+}
+
+func TestIsDeterministic(t *testing.T) {
+	tests := []struct {
+		name string
+		body *dto.RequestDto
+		want bool
+	}{
+		{
+			name: "Test with default temperature",
+			body: &dto.RequestDto{},
+			want: false,
+		},
+		{
+			name: "Test with temperature 0",
+			body: &dto.RequestDto{Temperature: floatPtr(0)},
+			want: true,
+		},
+		{
+			name: "Test with temperature 0.7",
+			body: &dto.RequestDto{Temperature: floatPtr(0.7)},
+			want: false,
+		},
+		{
+			name: "Test with seed",
+			body: &dto.RequestDto{Temperature: floatPtr(0.7), Seed: intPtr(42)},
+			want: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsDeterministic(tt.body); got != tt.want {
+				t.Errorf("IsDeterministic() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func countingTransport(calls *int) middleware.RoundTrip {
+	return func(ctx context.Context, request *middleware.Request) (*middleware.Response, error) {
+		*calls++
+		return &middleware.Response{
+			StatusCode: http.StatusOK,
+			Body: &dto.ResponseDto{
+				Choices: []dto.Choice{{Message: dto.MessageResponseDto{Role: dto.RoleAssistant, Content: "Hi"}}},
+				Usage:   &dto.Usage{PromptToken: 10, CompletionToken: 2},
+			},
+		}, nil
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	calls := 0
+	roundTrip := Middleware(NewMemoryCache(10), Options{})(countingTransport(&calls))
+
+	first, err := roundTrip(context.Background(), newRequest("Hello", floatPtr(0)))
+	assert.Nil(t, err)
+	assert.False(t, first.CacheHit)
+	assert.Equal(t, 10, first.Body.Usage.PromptToken)
+
+	second, err := roundTrip(context.Background(), newRequest("Hello", floatPtr(0)))
+	assert.Nil(t, err)
+	assert.True(t, second.CacheHit)
+	assert.Equal(t, "Hi", second.Body.Choices[0].Message.Content)
+	assert.Equal(t, &dto.Usage{}, second.Body.Usage)
+	assert.Equal(t, 1, calls)
+}
+
+func TestMiddlewareBypass(t *testing.T) {
+	tests := []struct {
+		name    string
+		ctx     context.Context
+		options Options
+		request func() *middleware.Request
+		calls   int
+	}{
+		{
+			name:    "Test with non-deterministic request",
+			ctx:     context.Background(),
+			request: func() *middleware.Request { return newRequest("Hello", nil) },
+			calls:   2,
+		},
+		{
+			name:    "Test with non-deterministic request allowed",
+			ctx:     context.Background(),
+			options: Options{CacheNonDeterministic: true},
+			request: func() *middleware.Request { return newRequest("Hello", nil) },
+			calls:   1,
+		},
+		{
+			name:    "Test with bypass context",
+			ctx:     WithBypass(context.Background()),
+			request: func() *middleware.Request { return newRequest("Hello", floatPtr(0)) },
+			calls:   2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			roundTrip := Middleware(NewMemoryCache(10), tt.options)(countingTransport(&calls))
+
+			for i := 0; i < 2; i++ {
+				_, err := roundTrip(tt.ctx, tt.request())
+				assert.Nil(t, err)
+			}
+			assert.Equal(t, tt.calls, calls)
+		})
+	}
+}
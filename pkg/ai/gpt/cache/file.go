@@ -0,0 +1,90 @@
+package cache
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+type fileEntry struct {
+	ExpiresAt time.Time `json:"expiresAt"`
+	Value     []byte    `json:"value"`
+}
+
+// FileCache is a Cache that stores every entry as a json file in a directory,
+// so the cache survives restarts and can be shared between evaluation runs.
+type FileCache struct {
+	directory string
+	now       func() time.Time
+}
+
+// NewFileCache returns a new instance of FileCache storing its entries in [directory].
+// The directory is created if it does not exist.
+func NewFileCache(directory string) (Cache, error) {
+	if err := os.MkdirAll(directory, 0755); err != nil {
+		return nil, err
+	}
+	return &FileCache{
+		directory: directory,
+		now:       time.Now,
+	}, nil
+}
+
+// Get returns the value stored for the key. Expired entries are removed.
+func (f *FileCache) Get(key string) ([]byte, bool, error) {
+	content, err := os.ReadFile(f.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	var entry fileEntry
+	if err := json.Unmarshal(content, &entry); err != nil {
+		return nil, false, err
+	}
+
+	if !entry.ExpiresAt.IsZero() && !f.now().Before(entry.ExpiresAt) {
+		err := os.Remove(f.path(key))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, false, err
+		}
+		return nil, false, nil
+	}
+	return entry.Value, true, nil
+}
+
+// Set stores the value for the key. The file is written atomically.
+func (f *FileCache) Set(key string, value []byte, ttl time.Duration) error {
+	entry := fileEntry{Value: value}
+	if ttl > 0 {
+		entry.ExpiresAt = f.now().Add(ttl)
+	}
+
+	content, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	temporary, err := os.CreateTemp(f.directory, key+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temporary.Name())
+
+	if _, err := temporary.Write(content); err != nil {
+		temporary.Close()
+		return err
+	}
+	if err := temporary.Close(); err != nil {
+		return err
+	}
+	return os.Rename(temporary.Name(), f.path(key))
+}
+
+func (f *FileCache) path(key string) string {
+	return filepath.Join(f.directory, key+".json")
+}
@@ -0,0 +1,53 @@
+package cache
+
+import (
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFileCache(t *testing.T) {
+	directory := filepath.Join(t.TempDir(), "cache")
+	cache, err := NewFileCache(directory)
+	assert.Nil(t, err)
+
+	_, found, err := cache.Get("missing")
+	assert.Nil(t, err)
+	assert.False(t, found)
+
+	assert.Nil(t, cache.Set("a", []byte(`{"choices":[]}`), 0))
+	value, found, err := cache.Get("a")
+	assert.Nil(t, err)
+	assert.True(t, found)
+	assert.Equal(t, []byte(`{"choices":[]}`), value)
+
+	// a new instance reads the entries written by the previous one
+	reopened, err := NewFileCache(directory)
+	assert.Nil(t, err)
+	_, found, err = reopened.Get("a")
+	assert.Nil(t, err)
+	assert.True(t, found)
+}
+
+func TestFileCache_TTL(t *testing.T) {
+	directory := t.TempDir()
+	cache, err := NewFileCache(directory)
+	assert.Nil(t, err)
+	fileCache := cache.(*FileCache)
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	fileCache.now = func() time.Time { return now }
+
+	assert.Nil(t, cache.Set("a", []byte("a"), time.Minute))
+	_, found, _ := cache.Get("a")
+	assert.True(t, found)
+
+	now = now.Add(time.Hour)
+	_, found, err = cache.Get("a")
+	assert.Nil(t, err)
+	assert.False(t, found)
+
+	_, err = os.Stat(filepath.Join(directory, "a.json"))
+	assert.True(t, os.IsNotExist(err))
+}
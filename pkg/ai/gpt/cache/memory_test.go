@@ -0,0 +1,53 @@
+package cache
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestMemoryCache_Eviction(t *testing.T) {
+	cache := NewMemoryCache(2).(*MemoryCache)
+
+	assert.Nil(t, cache.Set("a", []byte("a"), 0))
+	assert.Nil(t, cache.Set("b", []byte("b"), 0))
+
+	// "a" becomes the most recently used entry, so "b" is evicted
+	_, found, _ := cache.Get("a")
+	assert.True(t, found)
+	assert.Nil(t, cache.Set("c", []byte("c"), 0))
+
+	_, found, _ = cache.Get("b")
+	assert.False(t, found)
+	value, found, _ := cache.Get("a")
+	assert.True(t, found)
+	assert.Equal(t, []byte("a"), value)
+	assert.Equal(t, 2, cache.Len())
+}
+
+func TestMemoryCache_TTL(t *testing.T) {
+	cache := NewMemoryCache(10).(*MemoryCache)
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	cache.now = func() time.Time { return now }
+
+	assert.Nil(t, cache.Set("a", []byte("a"), time.Minute))
+	_, found, _ := cache.Get("a")
+	assert.True(t, found)
+
+	now = now.Add(time.Minute)
+	_, found, _ = cache.Get("a")
+	assert.False(t, found)
+	assert.Equal(t, 0, cache.Len())
+}
+
+func TestMemoryCache_Overwrite(t *testing.T) {
+	cache := NewMemoryCache(10)
+
+	assert.Nil(t, cache.Set("a", []byte("a"), 0))
+	assert.Nil(t, cache.Set("a", []byte("b"), 0))
+
+	value, found, err := cache.Get("a")
+	assert.Nil(t, err)
+	assert.True(t, found)
+	assert.Equal(t, []byte("b"), value)
+}
@@ -0,0 +1,164 @@
+package dto
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"net/http"
+	"os"
+	"strings"
+)
+
+type ContentPartType = string
+
+const (
+	ContentPartText       ContentPartType = "text"
+	ContentPartImageUrl   ContentPartType = "image_url"
+	ContentPartInputAudio ContentPartType = "input_audio"
+)
+
+// ImageDetail controls the resolution the model sees the image at.
+type ImageDetail = string
+
+const (
+	ImageDetailAuto ImageDetail = "auto"
+	ImageDetailLow  ImageDetail = "low"
+	ImageDetailHigh ImageDetail = "high"
+)
+
+// ImageUrl is an image given by an url or a base64 data url.
+type ImageUrl struct {
+	Url    string      `json:"url"`
+	Detail ImageDetail `json:"detail,omitempty"`
+	// Width and Height are the size of the image in pixels, used to estimate its tokens. Zero when unknown.
+	// They are kept in the saved history, and left out of the requests sent to the API.
+	Width  int `json:"width,omitempty"`
+	Height int `json:"height,omitempty"`
+}
+
+// InputAudio is base64 encoded audio sent to the audio capable models.
+type InputAudio struct {
+	Data   string `json:"data"`
+	Format string `json:"format"`
+}
+
+// ContentPart is one part of a multimodal message content.
+type ContentPart struct {
+	Type       ContentPartType `json:"type"`
+	Text       string          `json:"text,omitempty"`
+	ImageUrl   *ImageUrl       `json:"image_url,omitempty"`
+	InputAudio *InputAudio     `json:"input_audio,omitempty"`
+}
+
+// TextPart returns a text content part.
+func TextPart(text string) ContentPart {
+	return ContentPart{
+		Type: ContentPartText,
+		Text: text,
+	}
+}
+
+// ImageUrlPart returns an image content part pointing to the url.
+func ImageUrlPart(url string, detail ImageDetail) ContentPart {
+	return ContentPart{
+		Type: ContentPartImageUrl,
+		ImageUrl: &ImageUrl{
+			Url:    url,
+			Detail: detail,
+		},
+	}
+}
+
+// ImagePart returns an image content part embedding the image as a base64 data url.
+// The mime type is detected from the data when [mimeType] is empty.
+func ImagePart(data []byte, mimeType string, detail ImageDetail) ContentPart {
+	if mimeType == "" {
+		mimeType = http.DetectContentType(data)
+	}
+
+	part := ImageUrlPart(fmt.Sprintf("data:%s;base64,%s", mimeType, base64.StdEncoding.EncodeToString(data)), detail)
+	if config, _, err := image.DecodeConfig(bytes.NewReader(data)); err == nil {
+		part.ImageUrl.Width = config.Width
+		part.ImageUrl.Height = config.Height
+	}
+	return part
+}
+
+// ImageFilePart returns an image content part embedding the image file as a base64 data url.
+func ImageFilePart(path string, detail ImageDetail) (ContentPart, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ContentPart{}, fmt.Errorf("failed to read image %s: %w", path, err)
+	}
+	return ImagePart(data, "", detail), nil
+}
+
+// InputAudioPart returns an audio content part. [format] is the audio format, such as wav or mp3.
+func InputAudioPart(data []byte, format string) ContentPart {
+	return ContentPart{
+		Type: ContentPartInputAudio,
+		InputAudio: &InputAudio{
+			Data:   base64.StdEncoding.EncodeToString(data),
+			Format: format,
+		},
+	}
+}
+
+// Text returns the text of the message: the content, or the text parts joined by new lines.
+func (m Message) Text() string {
+	if len(m.Parts) == 0 {
+		return m.Content
+	}
+
+	var texts []string
+	for _, part := range m.Parts {
+		if part.Type == ContentPartText {
+			texts = append(texts, part.Text)
+		}
+	}
+	return strings.Join(texts, "\n")
+}
+
+// MarshalJSON sends the parts as the content when there are some, and the content string otherwise.
+func (m Message) MarshalJSON() ([]byte, error) {
+	type alias Message
+	if len(m.Parts) == 0 {
+		return json.Marshal(alias(m))
+	}
+
+	return json.Marshal(struct {
+		alias
+		Content []ContentPart `json:"content"`
+	}{
+		alias:   alias(m),
+		Content: m.Parts,
+	})
+}
+
+// UnmarshalJSON accepts the content as a string, an array of parts or null.
+func (m *Message) UnmarshalJSON(data []byte) error {
+	type alias Message
+	var raw struct {
+		alias
+		Content json.RawMessage `json:"content"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	*m = Message(raw.alias)
+	content := bytes.TrimSpace(raw.Content)
+	switch {
+	case len(content) == 0 || string(content) == "null":
+		return nil
+	case content[0] == '[':
+		return json.Unmarshal(content, &m.Parts)
+	default:
+		return json.Unmarshal(content, &m.Content)
+	}
+}
@@ -0,0 +1,133 @@
+package dto
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMessage_MarshalJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		message Message
+		want    string
+	}{
+		{
+			name:    "Test with content",
+			message: Message{Role: RoleUser, Content: "Hello"},
+			want:    `{"role":"user","content":"Hello"}`,
+		},
+		{
+			name: "Test with parts",
+			message: Message{
+				Role:    RoleUser,
+				Content: "ignored",
+				Parts: []ContentPart{
+					TextPart("What is in the image?"),
+					ImageUrlPart("https://example.com/image.png", ImageDetailHigh),
+				},
+			},
+			want: `{"role":"user","content":[{"type":"text","text":"What is in the image?"},{"type":"image_url","image_url":{"url":"https://example.com/image.png","detail":"high"}}]}`,
+		},
+		{
+			name: "Test with audio",
+			message: Message{
+				Role:  RoleUser,
+				Parts: []ContentPart{InputAudioPart([]byte("abc"), "wav")},
+			},
+			want: `{"role":"user","content":[{"type":"input_audio","input_audio":{"data":"YWJj","format":"wav"}}]}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := json.Marshal(tt.message)
+			assert.Nil(t, err)
+			assert.JSONEq(t, tt.want, string(got))
+		})
+	}
+}
+
+func TestMessage_UnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want Message
+	}{
+		{
+			name: "Test with content",
+			data: `{"role":"assistant","content":"Hello"}`,
+			want: Message{Role: RoleAssistant, Content: "Hello"},
+		},
+		{
+			name: "Test with null content",
+			data: `{"role":"assistant","content":null}`,
+			want: Message{Role: RoleAssistant},
+		},
+		{
+			name: "Test with parts",
+			data: `{"role":"user","content":[{"type":"text","text":"Hi"},{"type":"image_url","image_url":{"url":"https://example.com/image.png"}}]}`,
+			want: Message{
+				Role: RoleUser,
+				Parts: []ContentPart{
+					TextPart("Hi"),
+					ImageUrlPart("https://example.com/image.png", ""),
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got Message
+			assert.Nil(t, json.Unmarshal([]byte(tt.data), &got))
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestMessage_Text(t *testing.T) {
+	assert.Equal(t, "Hello", Message{Content: "Hello"}.Text())
+	assert.Equal(t, "Hello\nWorld", Message{
+		Parts: []ContentPart{
+			TextPart("Hello"),
+			ImageUrlPart("https://example.com/image.png", ""),
+			TextPart("World"),
+		},
+	}.Text())
+}
+
+func TestImageFilePart(t *testing.T) {
+	var buffer bytes.Buffer
+	assert.Nil(t, png.Encode(&buffer, image.NewRGBA(image.Rect(0, 0, 40, 30))))
+	path := filepath.Join(t.TempDir(), "image.png")
+	assert.Nil(t, os.WriteFile(path, buffer.Bytes(), 0o600))
+
+	part, err := ImageFilePart(path, ImageDetailAuto)
+	assert.Nil(t, err)
+	assert.Equal(t, ContentPartImageUrl, part.Type)
+	assert.True(t, strings.HasPrefix(part.ImageUrl.Url, "data:image/png;base64,"))
+	assert.Equal(t, 40, part.ImageUrl.Width)
+	assert.Equal(t, 30, part.ImageUrl.Height)
+
+	_, err = ImageFilePart(filepath.Join(t.TempDir(), "missing.png"), ImageDetailAuto)
+	assert.NotNil(t, err)
+}
+
+func TestMessage_ImageSize(t *testing.T) {
+	part := ImageUrlPart("https://example.com/image.png", ImageDetailLow)
+	part.ImageUrl.Width, part.ImageUrl.Height = 40, 30
+	data, err := json.Marshal(Message{Role: RoleUser, Parts: []ContentPart{part}})
+	assert.Nil(t, err)
+
+	// the size is kept when a saved history is loaded
+	var message Message
+	assert.Nil(t, json.Unmarshal(data, &message))
+	assert.Equal(t, []ContentPart{part}, message.Parts)
+}
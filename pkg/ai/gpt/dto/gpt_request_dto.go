@@ -5,4 +5,21 @@ type RequestDto struct {
 	Model    *string   `json:"model,omitempty"`
 	Messages []Message `json:"messages"`
 	Tools    []Tool    `json:"tools,omitempty"`
+	//Temperature is the sampling temperature between 0 and 2. The API defaults to 1 when it is not set.
+	Temperature *float64 `json:"temperature,omitempty"`
+	//TopP is the nucleus sampling probability mass. The API defaults to 1 when it is not set.
+	TopP *float64 `json:"top_p,omitempty"`
+	//Seed makes the sampling deterministic on a best effort basis.
+	Seed *int `json:"seed,omitempty"`
+	//MaxTokens is the maximum number of tokens to generate.
+	MaxTokens *int `json:"max_tokens,omitempty"`
+	//Stream sends the answer as server-sent events while it is generated.
+	Stream bool `json:"stream,omitempty"`
+	//StreamOptions are the options of the stream. Only used when Stream is true.
+	StreamOptions *StreamOptions `json:"stream_options,omitempty"`
+}
+
+type StreamOptions struct {
+	//IncludeUsage sends the usage of the request in the last event of the stream.
+	IncludeUsage bool `json:"include_usage"`
 }
@@ -27,9 +27,42 @@ type Usage struct {
 	CompletionToken int `json:"completion_tokens"`
 }
 
+type Choice struct {
+	Message      MessageResponseDto `json:"message"`
+	FinishReason string             `json:"finish_reason,omitempty"`
+}
+
 type ResponseDto struct {
-	Choices []struct {
-		Message MessageResponseDto `json:"message"`
-	} `json:"choices"`
-	Usage *Usage `json:"usage"`
+	Id      string   `json:"id,omitempty"`
+	Model   string   `json:"model,omitempty"`
+	Choices []Choice `json:"choices"`
+	Usage   *Usage   `json:"usage"`
+}
+
+// ToolCallDelta is a part of a tool call streamed by GPT. The parts of a tool call share its index.
+type ToolCallDelta struct {
+	Index    int      `json:"index"`
+	Id       string   `json:"id,omitempty"`
+	Type     string   `json:"type,omitempty"`
+	Function Function `json:"function"`
+}
+
+type DeltaDto struct {
+	Role      string          `json:"role,omitempty"`
+	Content   string          `json:"content,omitempty"`
+	ToolCalls []ToolCallDelta `json:"tool_calls,omitempty"`
+}
+
+type StreamChoice struct {
+	Index        int      `json:"index"`
+	Delta        DeltaDto `json:"delta"`
+	FinishReason string   `json:"finish_reason,omitempty"`
+}
+
+// StreamChunkDto is an event of a streamed response.
+type StreamChunkDto struct {
+	Id      string         `json:"id,omitempty"`
+	Model   string         `json:"model,omitempty"`
+	Choices []StreamChoice `json:"choices"`
+	Usage   *Usage         `json:"usage,omitempty"`
 }
@@ -10,4 +10,10 @@ type Message struct {
 	Usage      *Usage                              `json:"usage,omitempty"`
 	ToolCalls  *[]ToolCall                         `json:"tool_calls,omitempty"`
 	Config     functions.FunctionGptResponseConfig `json:"-"`
+	// Parts is the multimodal content of the message. When it is not empty, it is sent as the content instead of Content.
+	Parts []ContentPart `json:"-"`
+	// CacheHit is true when the message was served from the response cache.
+	CacheHit bool `json:"-"`
+	// Target is the name of the upstream target that generated the message, if any.
+	Target string `json:"-"`
 }
@@ -0,0 +1,31 @@
+package functions
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrorPolicy is what happens to the turn when a function fails, panics or times out.
+type ErrorPolicy string
+
+const (
+	// FailTurn stops the turn and returns the error to the caller.
+	FailTurn ErrorPolicy = "fail"
+	// ReportToModel sends the error as the result of the function, so the model can apologize or retry.
+	ReportToModel ErrorPolicy = "report"
+)
+
+// ErrTimeout is returned when a function does not return before the Timeout of its FunctionConfig.
+var ErrTimeout = errors.New("function timed out")
+
+// PanicError is returned when a function panics.
+type PanicError struct {
+	Function string
+	Value    any
+	// Stack is the stack trace of the goroutine when it panicked.
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("function %s panicked: %v", e.Function, e.Value)
+}
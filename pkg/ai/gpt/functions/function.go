@@ -1,5 +1,7 @@
 package functions
 
+import "time"
+
 type LifeCycleMethod interface {
 	// OnInit is a life cycle method that is called when the function is initialized.
 	OnInit() error
@@ -16,7 +18,8 @@ type LifeCycleMethod interface {
 type FunctionConfigMethod interface {
 	// SetStore sets the memory store for the function.
 	// This is useful for function to have access to the app's state such as current chatroomId
-	SetStore(store FunctionStore)
+	// It is called once by the client using the function, so an instance serves the conversation of a single store.
+	SetStore(store *FunctionStore)
 	// Config returns the configuration of the function.
 	Config() FunctionConfig
 }
@@ -33,11 +36,15 @@ type FunctionInterface interface {
 	Parameters() map[string]interface{}
 }
 
-type FunctionStore map[string]interface{}
-
 type FunctionConfig struct {
 	// Whether to use GPT to interpret responses from the function.
 	UseGptToInterpretResponses bool `json:"useGptToInterpretResponses"`
+	// RequiresApproval pauses the turn before the function is called, until the caller approves, edits or rejects the call.
+	RequiresApproval bool `json:"requiresApproval"`
+	// Timeout stops waiting for OnMessage after the duration, which keeps running in the background. 0 waits until it returns.
+	Timeout time.Duration `json:"timeout"`
+	// OnError is the policy when OnMessage fails, panics or times out. Defaults to FailTurn.
+	OnError ErrorPolicy `json:"onError"`
 }
 
 type FunctionGptResponseConfig struct {
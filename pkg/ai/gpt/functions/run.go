@@ -0,0 +1,52 @@
+package functions
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"time"
+)
+
+// Run calls OnMessage, recovering its panics as a *PanicError.
+// With a [timeout], it stops waiting for the function after the timeout or once the context is done,
+// the function keeps running in the background. Without one, it waits until the function returns.
+func Run(ctx context.Context, function FunctionInterface, timeout time.Duration, arguments map[string]interface{}) (*FunctionGptResponse, error) {
+	type outcome struct {
+		result *FunctionGptResponse
+		err    error
+	}
+	run := func() (result outcome) {
+		defer func() {
+			if value := recover(); value != nil {
+				result = outcome{err: &PanicError{Function: function.Name(), Value: value, Stack: debug.Stack()}}
+			}
+		}()
+		response, err := function.OnMessage(arguments)
+		if err == nil && response == nil {
+			err = fmt.Errorf("function %s returned no response", function.Name())
+		}
+		return outcome{result: response, err: err}
+	}
+
+	if timeout <= 0 {
+		result := run()
+		return result.result, result.err
+	}
+
+	// buffered, so the function can return after the timeout without blocking
+	done := make(chan outcome, 1)
+	go func() {
+		done <- run()
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case result := <-done:
+		return result.result, result.err
+	case <-timer.C:
+		return nil, fmt.Errorf("function %s: %w after %s", function.Name(), ErrTimeout, timeout)
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
@@ -0,0 +1,89 @@
+package functions
+
+import (
+	"context"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	"testing"
+	"time"
+)
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		name      string
+		onMessage func(arguments map[string]interface{}) (*FunctionGptResponse, error)
+		timeout   time.Duration
+		want      *FunctionGptResponse
+		wantErr   string
+		wantIs    error
+	}{
+		{
+			name: "Test with response",
+			onMessage: func(arguments map[string]interface{}) (*FunctionGptResponse, error) {
+				return &FunctionGptResponse{Content: "ok"}, nil
+			},
+			timeout: time.Second,
+			want:    &FunctionGptResponse{Content: "ok"},
+		},
+		{
+			name: "Test with error",
+			onMessage: func(arguments map[string]interface{}) (*FunctionGptResponse, error) {
+				return nil, fmt.Errorf("menu unavailable")
+			},
+			wantErr: "menu unavailable",
+		},
+		{
+			name: "Test with panic",
+			onMessage: func(arguments map[string]interface{}) (*FunctionGptResponse, error) {
+				panic("boom")
+			},
+			wantErr: "function get-menu panicked: boom",
+		},
+		{
+			name: "Test with panic and timeout",
+			onMessage: func(arguments map[string]interface{}) (*FunctionGptResponse, error) {
+				panic("boom")
+			},
+			timeout: time.Second,
+			wantErr: "function get-menu panicked: boom",
+		},
+		{
+			name: "Test with timeout",
+			onMessage: func(arguments map[string]interface{}) (*FunctionGptResponse, error) {
+				time.Sleep(time.Second)
+				return &FunctionGptResponse{Content: "late"}, nil
+			},
+			timeout: 10 * time.Millisecond,
+			wantErr: "function get-menu: function timed out after 10ms",
+			wantIs:  ErrTimeout,
+		},
+		{
+			name: "Test without response",
+			onMessage: func(arguments map[string]interface{}) (*FunctionGptResponse, error) {
+				return nil, nil
+			},
+			wantErr: "function get-menu returned no response",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			function := NewMockFunctionInterface(ctrl)
+			function.EXPECT().Name().Return("get-menu").AnyTimes()
+			function.EXPECT().OnMessage(gomock.Any()).DoAndReturn(tt.onMessage)
+
+			result, err := Run(context.Background(), function, tt.timeout, map[string]interface{}{})
+			if len(tt.wantErr) > 0 {
+				assert.EqualError(t, err, tt.wantErr)
+				if tt.wantIs != nil {
+					assert.ErrorIs(t, err, tt.wantIs)
+				}
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tt.want, result)
+		})
+	}
+}
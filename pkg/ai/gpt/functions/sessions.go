@@ -0,0 +1,136 @@
+package functions
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// StoreRepository persists the stores of the conversations.
+type StoreRepository interface {
+	// Load returns the store of the conversation, or nil when it was never saved.
+	Load(conversationId string) (*FunctionStore, error)
+	// Save saves the store of the conversation.
+	Save(conversationId string, store *FunctionStore) error
+}
+
+// Sessions hands out one FunctionStore per conversation, so the state of a conversation never leaks into another one.
+// It is safe for concurrent use.
+type Sessions struct {
+	mutex      sync.Mutex
+	stores     map[string]*FunctionStore
+	repository StoreRepository
+}
+
+// NewSessions returns a new instance of Sessions.
+// The stores are loaded from and saved to the repository. Nil keeps them in memory only.
+func NewSessions(repository StoreRepository) *Sessions {
+	return &Sessions{
+		stores:     map[string]*FunctionStore{},
+		repository: repository,
+	}
+}
+
+// Store returns the store of the conversation, loading it from the repository or creating it the first time.
+func (s *Sessions) Store(conversationId string) (*FunctionStore, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if store, found := s.stores[conversationId]; found {
+		return store, nil
+	}
+
+	var store *FunctionStore
+	if s.repository != nil {
+		loaded, err := s.repository.Load(conversationId)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load the store of conversation %s: %w", conversationId, err)
+		}
+		store = loaded
+	}
+	if store == nil {
+		store = NewFunctionStore()
+	}
+	s.stores[conversationId] = store
+	return store, nil
+}
+
+// Save saves the store of the conversation to the repository, such as after saving its history.
+// It does nothing without a repository, or when the store of the conversation was never used.
+func (s *Sessions) Save(conversationId string) error {
+	s.mutex.Lock()
+	store, found := s.stores[conversationId]
+	s.mutex.Unlock()
+	if !found || s.repository == nil {
+		return nil
+	}
+	if err := s.repository.Save(conversationId, store); err != nil {
+		return fmt.Errorf("failed to save the store of conversation %s: %w", conversationId, err)
+	}
+	return nil
+}
+
+// Forget removes the store of the conversation from memory, such as when the conversation ends.
+// The saved store is kept by the repository.
+func (s *Sessions) Forget(conversationId string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.stores, conversationId)
+}
+
+// FileRepository saves the store of every conversation to the JSON file [conversationId].json of its directory.
+type FileRepository struct {
+	directory string
+}
+
+// NewFileRepository returns a new instance of FileRepository saving the stores to the directory.
+func NewFileRepository(directory string) StoreRepository {
+	return &FileRepository{directory: directory}
+}
+
+// Load reads the store of the conversation from its file.
+func (f *FileRepository) Load(conversationId string) (*FunctionStore, error) {
+	path, err := f.path(conversationId)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	store := NewFunctionStore()
+	if err := json.Unmarshal(data, store); err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+// Save writes the store of the conversation to its file, creating the directory if needed.
+func (f *FileRepository) Save(conversationId string, store *FunctionStore) error {
+	path, err := f.path(conversationId)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(store)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(f.directory, 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// path returns the path of the file of the conversation. The conversation id cannot point out of the directory.
+func (f *FileRepository) path(conversationId string) (string, error) {
+	if len(conversationId) == 0 || filepath.Base(conversationId) != conversationId || conversationId == "." || conversationId == ".." {
+		return "", fmt.Errorf("invalid conversation id %q", conversationId)
+	}
+	return filepath.Join(f.directory, conversationId+".json"), nil
+}
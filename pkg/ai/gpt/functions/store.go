@@ -0,0 +1,203 @@
+package functions
+
+import (
+	"encoding/json"
+	"slices"
+	"sync"
+)
+
+// Change describes a value of the FunctionStore being set or deleted.
+type Change struct {
+	Key string
+	// Value is the new value, nil when the value is deleted.
+	Value    any
+	Previous any
+	Deleted  bool
+}
+
+// FunctionStore holds the state of a conversation shared by its functions and plugins, such as the current order.
+// It is safe for concurrent use. See Get, Update and GetOrInit for the typed accessors.
+//
+// It is marshalled to JSON, so it can be persisted alongside the history of the conversation.
+// Once unmarshalled, the values are decoded by the typed accessors, into the type they are read with.
+type FunctionStore struct {
+	mutex    sync.RWMutex
+	values   map[string]any
+	watchers map[int]func(change Change)
+	nextId   int
+}
+
+// NewFunctionStore returns a new instance of an empty FunctionStore.
+func NewFunctionStore() *FunctionStore {
+	return &FunctionStore{
+		values:   map[string]any{},
+		watchers: map[int]func(change Change){},
+	}
+}
+
+// Get returns the value of the key. Once unmarshalled, the values are json.RawMessage until a typed accessor reads them.
+func (s *FunctionStore) Get(key string) (any, bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	value, found := s.values[key]
+	return value, found
+}
+
+// Set sets the value of the key, and notifies the watchers.
+func (s *FunctionStore) Set(key string, value any) {
+	s.mutex.Lock()
+	previous := s.values[key]
+	s.values[key] = value
+	watchers := s.watchersLocked()
+	s.mutex.Unlock()
+
+	notify(watchers, Change{Key: key, Value: value, Previous: previous})
+}
+
+// Delete deletes the value of the key, and notifies the watchers if there was one.
+func (s *FunctionStore) Delete(key string) {
+	s.mutex.Lock()
+	previous, found := s.values[key]
+	delete(s.values, key)
+	watchers := s.watchersLocked()
+	s.mutex.Unlock()
+
+	if found {
+		notify(watchers, Change{Key: key, Previous: previous, Deleted: true})
+	}
+}
+
+// Keys returns the sorted keys of the values.
+func (s *FunctionStore) Keys() []string {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	keys := make([]string, 0, len(s.values))
+	for key := range s.values {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// Watch calls [onChange] after every change of the store, in the goroutine making the change.
+// It returns the function to stop watching.
+func (s *FunctionStore) Watch(onChange func(change Change)) (stop func()) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	id := s.nextId
+	s.nextId++
+	s.watchers[id] = onChange
+	return func() {
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+		delete(s.watchers, id)
+	}
+}
+
+// MarshalJSON marshals the values of the store.
+func (s *FunctionStore) MarshalJSON() ([]byte, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return json.Marshal(s.values)
+}
+
+// UnmarshalJSON replaces the values of the store with the marshalled ones, kept as json.RawMessage until they are read.
+func (s *FunctionStore) UnmarshalJSON(data []byte) error {
+	var values map[string]json.RawMessage
+	if err := json.Unmarshal(data, &values); err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.values = make(map[string]any, len(values))
+	for key, value := range values {
+		s.values[key] = value
+	}
+	if s.watchers == nil {
+		s.watchers = map[int]func(change Change){}
+	}
+	return nil
+}
+
+// watchersLocked returns the watchers in the order they started watching. The mutex must be held.
+func (s *FunctionStore) watchersLocked() []func(change Change) {
+	ids := make([]int, 0, len(s.watchers))
+	for id := range s.watchers {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	watchers := make([]func(change Change), len(ids))
+	for index, id := range ids {
+		watchers[index] = s.watchers[id]
+	}
+	return watchers
+}
+
+func notify(watchers []func(change Change), change Change) {
+	for _, watcher := range watchers {
+		watcher(change)
+	}
+}
+
+// typedLocked returns the value of the key as a T, decoding it the first time it is read after the store was unmarshalled.
+// The mutex must be held for writing.
+func typedLocked[T any](s *FunctionStore, key string) (T, bool) {
+	var typed T
+	value, found := s.values[key]
+	if !found {
+		return typed, false
+	}
+	if typed, ok := value.(T); ok {
+		return typed, true
+	}
+	raw, ok := value.(json.RawMessage)
+	if !ok || json.Unmarshal(raw, &typed) != nil {
+		return typed, false
+	}
+	s.values[key] = typed
+	return typed, true
+}
+
+// Get returns the value of the key as a T. It returns false when there is no value, or when it is not a T.
+func Get[T any](s *FunctionStore, key string) (T, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return typedLocked[T](s, key)
+}
+
+// GetOrInit returns the value of the key as a T, setting it to the value returned by [init] when there is none.
+// It is atomic, so [init] is called once even when several goroutines read the key at the same time.
+func GetOrInit[T any](s *FunctionStore, key string, init func() T) T {
+	s.mutex.Lock()
+	if value, found := typedLocked[T](s, key); found {
+		s.mutex.Unlock()
+		return value
+	}
+	value := init()
+	s.values[key] = value
+	watchers := s.watchersLocked()
+	s.mutex.Unlock()
+
+	notify(watchers, Change{Key: key, Value: value})
+	return value
+}
+
+// Update sets the value of the key to the value returned by [update], and returns it.
+// [update] receives the current value, or the zero value and false when there is none.
+// It is atomic, so the updates of several goroutines are never lost.
+func Update[T any](s *FunctionStore, key string, update func(value T, found bool) T) T {
+	s.mutex.Lock()
+	previous, found := typedLocked[T](s, key)
+	value := update(previous, found)
+	s.values[key] = value
+	watchers := s.watchersLocked()
+	s.mutex.Unlock()
+
+	change := Change{Key: key, Value: value}
+	if found {
+		change.Previous = previous
+	}
+	notify(watchers, change)
+	return value
+}
@@ -0,0 +1,131 @@
+package functions
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+)
+
+type order struct {
+	Dishes []string `json:"dishes"`
+	Table  int      `json:"table"`
+}
+
+func TestFunctionStore(t *testing.T) {
+	store := NewFunctionStore()
+	var changes []Change
+	stop := store.Watch(func(change Change) {
+		changes = append(changes, change)
+	})
+
+	_, found := Get[order](store, "order")
+	assert.False(t, found)
+
+	store.Set("table", 3)
+	table, found := Get[int](store, "table")
+	assert.True(t, found)
+	assert.Equal(t, 3, table)
+	_, found = Get[string](store, "table")
+	assert.False(t, found)
+
+	updated := Update(store, "order", func(value order, found bool) order {
+		value.Dishes = append(value.Dishes, "炒飯")
+		return value
+	})
+	assert.Equal(t, order{Dishes: []string{"炒飯"}}, updated)
+
+	initialized := GetOrInit(store, "order", func() order {
+		return order{}
+	})
+	assert.Equal(t, updated, initialized)
+
+	store.Delete("table")
+	store.Delete("missing")
+	stop()
+	store.Set("table", 4)
+
+	assert.Equal(t, []Change{
+		{Key: "table", Value: 3},
+		{Key: "order", Value: order{Dishes: []string{"炒飯"}}},
+		{Key: "table", Previous: 3, Deleted: true},
+	}, changes)
+	assert.Equal(t, []string{"order", "table"}, store.Keys())
+}
+
+func TestFunctionStore_MarshalJSON(t *testing.T) {
+	store := NewFunctionStore()
+	store.Set("order", order{Dishes: []string{"炒飯"}, Table: 3})
+
+	data, err := json.Marshal(store)
+	assert.Nil(t, err)
+	assert.JSONEq(t, `{"order":{"dishes":["炒飯"],"table":3}}`, string(data))
+
+	restored := NewFunctionStore()
+	assert.Nil(t, json.Unmarshal(data, restored))
+	value, found := Get[order](restored, "order")
+	assert.True(t, found)
+	assert.Equal(t, order{Dishes: []string{"炒飯"}, Table: 3}, value)
+
+	updated := Update(restored, "order", func(value order, found bool) order {
+		value.Table = 4
+		return value
+	})
+	assert.Equal(t, 4, updated.Table)
+}
+
+func TestFunctionStore_Concurrency(t *testing.T) {
+	store := NewFunctionStore()
+	var wait sync.WaitGroup
+	for range 50 {
+		wait.Add(1)
+		go func() {
+			defer wait.Done()
+			Update(store, "count", func(value int, found bool) int {
+				return value + 1
+			})
+		}()
+	}
+	wait.Wait()
+
+	count, _ := Get[int](store, "count")
+	assert.Equal(t, 50, count)
+}
+
+func TestSessions(t *testing.T) {
+	directory := t.TempDir()
+	sessions := NewSessions(NewFileRepository(directory))
+
+	first, err := sessions.Store("first")
+	assert.Nil(t, err)
+	second, err := sessions.Store("second")
+	assert.Nil(t, err)
+	same, err := sessions.Store("first")
+	assert.Nil(t, err)
+	assert.Same(t, first, same)
+	assert.NotSame(t, first, second)
+
+	first.Set("order", order{Table: 3})
+	assert.Nil(t, sessions.Save("first"))
+	assert.Nil(t, sessions.Save("unknown"))
+
+	// the store is loaded again, as after a restart
+	restarted := NewSessions(NewFileRepository(directory))
+	loaded, err := restarted.Store("first")
+	assert.Nil(t, err)
+	value, found := Get[order](loaded, "order")
+	assert.True(t, found)
+	assert.Equal(t, 3, value.Table)
+
+	empty, err := restarted.Store("second")
+	assert.Nil(t, err)
+	assert.Empty(t, empty.Keys())
+
+	sessions.Forget("first")
+	forgotten, err := sessions.Store("first")
+	assert.Nil(t, err)
+	assert.NotSame(t, first, forgotten)
+
+	_, err = restarted.Store("../first")
+	assert.EqualError(t, err, `failed to load the store of conversation ../first: invalid conversation id "../first"`)
+}
@@ -1,94 +1,202 @@
 package gpt
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/go-resty/resty/v2"
-	"github.com/google/logger"
 	template "github.com/meta-metopia/go-packages/pkg/ai"
 	"github.com/meta-metopia/go-packages/pkg/ai/gpt/dto"
 	"github.com/meta-metopia/go-packages/pkg/ai/gpt/functions"
+	"github.com/meta-metopia/go-packages/pkg/ai/gpt/middleware"
 	"github.com/meta-metopia/go-packages/pkg/ai/gpt/plugin"
+	"github.com/meta-metopia/go-packages/pkg/ai/gpt/telemetry"
+	"log/slog"
+	"net/http"
 	"os"
+	"slices"
+	"sync"
 )
 
 type GenerateResponse struct {
 	NewResponses []dto.Message
 	FullHistory  []dto.Message
+	// CacheHit is true when at least one message in NewResponses was served from the response cache.
+	CacheHit bool
+	// PendingApproval is set when the turn stopped before calling a function requiring an approval, see Resume.
+	PendingApproval *PendingApproval
 }
 type GenerateIteratorRet = func(func(response GenerateResponse, err error) bool)
 
 type IGptClient interface {
 	//Generate will generate a response from the GPT API.
+	//The plugins convert the prompt to a string or content parts, so it can be an image with dto.ImagePart.
 	Generate(prompt any, history []dto.Message) (response GenerateResponse, err error)
 	//GenerateIterator will return the iterator for the GPT client. Instead of returning the full history, it will return the history one by one.
 	GenerateIterator(prompt *string, history []dto.Message) GenerateIteratorRet
+	//GenerateWithContext is the same as Generate, but the context is used for cancellation and tracing.
+	GenerateWithContext(ctx context.Context, prompt any, history []dto.Message) (response GenerateResponse, err error)
+	//GenerateIteratorWithContext is the same as GenerateIterator, but the context is used for cancellation and tracing.
+	GenerateIteratorWithContext(ctx context.Context, prompt *string, history []dto.Message) GenerateIteratorRet
+	//Resume resumes the turn stopped by the pending approval, once the caller approved, edited or rejected the function call.
+	//The pending approval can be stored, so the turn can be resumed by another client, such as after a restart.
+	Resume(ctx context.Context, pending PendingApproval, approval Approval) GenerateIteratorRet
+	//GenerateStream streams the answers while they are generated, then returns the response of the turn as Generate does.
+	GenerateStream(ctx context.Context, prompt any, history []dto.Message) GenerateStreamRet
 	//SetClient sets the resty client for the GPT client.
 	SetClient(client *resty.Client)
 	//SetFunctions sets the Functions for the GPT client.
 	SetFunctions(functions *[]functions.FunctionInterface)
 	//SetPlugins sets the Plugins for the GPT client.
 	SetPlugins(plugins *[]plugin.Interface)
+	//SetMiddlewares sets the Middlewares wrapped around every completion request.
+	SetMiddlewares(middlewares []middleware.Middleware)
+	//Close calls OnClose on the functions and the plugins. The client cannot be used afterwards.
+	Close() error
 }
 
 type Config struct {
-	Endpoint  string
-	ApiKey    string
-	Prompt    string
-	Model     string
-	Functions *[]functions.FunctionInterface
-	Plugins   *[]plugin.Interface
-	Store     functions.FunctionStore
-	Template  template.Engine
+	Endpoint string
+	ApiKey   string
+	Prompt   string
+	Model    string
+	// Temperature, TopP, Seed and MaxTokens are the sampling parameters sent with every request. Nil uses the API default.
+	Temperature *float64
+	TopP        *float64
+	Seed        *int
+	MaxTokens   *int
+	Functions   *[]functions.FunctionInterface
+	Plugins     *[]plugin.Interface
+	// Store is the state of the conversation shared by the functions and the plugins. Defaults to an empty store.
+	// Use a store per conversation, see functions.Sessions.
+	// The functions keep the store they are set with, so their instances must not be shared by clients with different stores:
+	// build the functions for every client, NewGptClient fails with ErrFunctionShared otherwise until the other client is closed.
+	Store    *functions.FunctionStore
+	Template template.Engine
+	// Middlewares are wrapped around every completion request. The first middleware is the outermost one.
+	Middlewares []middleware.Middleware
+	// Telemetry emits the traces and metrics of the client. Defaults to the global OpenTelemetry providers.
+	Telemetry *telemetry.Telemetry
+	// Logger is used to write the logs of the client. Defaults to slog.Default().
+	Logger *slog.Logger
+	// Redact is applied to the message content before it is logged. Defaults to RedactAll.
+	Redact Redactor
 }
 
+// Client is safe for concurrent use by multiple goroutines.
+// Every call works on a snapshot of the configuration taken when it starts,
+// so the setters never affect the calls already running.
 type Client struct {
+	mutex      sync.RWMutex
 	config     Config
 	httpClient *resty.Client
+	// initialized are the functions whose OnInit succeeded, in the order they were initialized.
+	initialized []functions.FunctionInterface
+	// dirty is true when the functions changed since they were initialized.
+	dirty  bool
+	closed bool
 }
 
-// NewGptClient returns a new instance of GptClient.
-func NewGptClient(config Config) IGptClient {
-	var initialPlugins []plugin.Interface
+// NewGptClient returns a new instance of GptClient, calling OnInit on the functions.
+// It returns the errors of all the functions failing to initialize.
+func NewGptClient(config Config) (IGptClient, error) {
 	if config.Plugins == nil {
-		initialPlugins = []plugin.Interface{
+		config.Plugins = &[]plugin.Interface{
 			plugin.NewStandardOutputPlugin(),
 		}
-		config.Plugins = &initialPlugins
 	}
-	if config.Functions == nil {
-		config.Functions = &[]functions.FunctionInterface{}
+	config.Plugins = copySlice(config.Plugins)
+	config.Functions = copySlice(config.Functions)
+	config.Middlewares = slices.Clone(config.Middlewares)
+	if config.Store == nil {
+		config.Store = functions.NewFunctionStore()
 	}
-	for functionIndex, _ := range *config.Functions {
-		err := (*config.Functions)[functionIndex].OnInit()
-		if err != nil {
-			logger.Fatal(err)
-		}
-		(*config.Functions)[functionIndex].SetStore(config.Store)
+	if config.Telemetry == nil {
+		config.Telemetry = telemetry.NewGlobal()
+	}
+	if config.Logger == nil {
+		config.Logger = slog.Default()
+	}
+	if config.Redact == nil {
+		config.Redact = RedactAll
 	}
 
-	client := resty.New()
-	client.SetDebug(os.Getenv("DEBUG") == "true")
+	httpClient := resty.New()
+	httpClient.SetDebug(os.Getenv("DEBUG") == "true")
 
-	return &Client{
+	client := &Client{
 		config:     config,
-		httpClient: client,
+		httpClient: httpClient,
 	}
+	if err := client.syncFunctions(); err != nil {
+		// the functions already initialized are closed, as the client is never returned
+		return nil, errors.Join(err, client.closeFunctions())
+	}
+	return client, nil
 }
 
 // SetClient sets the resty client for the GPT client.
 func (g *Client) SetClient(client *resty.Client) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
 	g.httpClient = client
 }
 
 // SetFunctions sets the Functions for the GPT client.
+// The slice is copied, so changing it afterwards does not affect the client.
+// The next call closes the functions removed from the list and initializes the new ones, returning their errors.
 func (g *Client) SetFunctions(functions *[]functions.FunctionInterface) {
-	g.config.Functions = functions
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	g.config.Functions = copySlice(functions)
+	g.dirty = true
 }
 
 // SetPlugins sets the Plugins for the GPT client.
+// The slice is copied, so changing it afterwards does not affect the client.
 func (g *Client) SetPlugins(plugins *[]plugin.Interface) {
-	g.config.Plugins = plugins
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	g.config.Plugins = copySlice(plugins)
+}
+
+// SetMiddlewares sets the Middlewares wrapped around every completion request.
+// The slice is copied, so changing it afterwards does not affect the client.
+func (g *Client) SetMiddlewares(middlewares []middleware.Middleware) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	g.config.Middlewares = slices.Clone(middlewares)
+}
+
+// snapshot returns a copy of the client holding the current configuration.
+// The setters replace the slices instead of changing them, so the copy is never affected by them.
+// It initializes the functions set since the last call, and fails once the client is closed.
+func (g *Client) snapshot() (*Client, error) {
+	g.mutex.RLock()
+	if !g.dirty && !g.closed {
+		defer g.mutex.RUnlock()
+		return &Client{
+			config:     g.config,
+			httpClient: g.httpClient,
+		}, nil
+	}
+	g.mutex.RUnlock()
+
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	if g.closed {
+		return nil, ErrClientClosed
+	}
+	if g.dirty {
+		if err := g.syncFunctions(); err != nil {
+			return nil, err
+		}
+	}
+	return &Client{
+		config:     g.config,
+		httpClient: g.httpClient,
+	}, nil
 }
 
 // Generate generates a response from the GPT API.
@@ -97,9 +205,23 @@ func (g *Client) SetPlugins(plugins *[]plugin.Interface) {
 // [fullHistory] is the full history of the conversation.
 // [err] is the error if there is one.
 func (g *Client) Generate(prompt any, history []dto.Message) (response GenerateResponse, err error) {
-	input, err := g.usePluginForInput(prompt)
+	return g.GenerateWithContext(context.Background(), prompt, history)
+}
+
+// GenerateWithContext is the same as Generate, but the context is used for cancellation and tracing.
+func (g *Client) GenerateWithContext(ctx context.Context, prompt any, history []dto.Message) (response GenerateResponse, err error) {
+	g, err = g.snapshot()
 	if err != nil {
-		logger.Error(err)
+		return GenerateResponse{}, err
+	}
+	ctx, span := g.config.Telemetry.StartTurn(ctx)
+	defer func() {
+		telemetry.End(span, err)
+	}()
+
+	input, err := g.usePluginForInput(ctx, prompt)
+	if err != nil {
+		g.log(ctx).ErrorContext(ctx, "failed to convert input", logKeyError, err)
 		return GenerateResponse{}, err
 	}
 
@@ -109,130 +231,236 @@ func (g *Client) Generate(prompt any, history []dto.Message) (response GenerateR
 		}
 	}
 
-	newMessage, messages := g.createMessages(input, history)
+	newMessage, messages, err := g.createMessages(input, history)
+	if err != nil {
+		g.log(ctx).ErrorContext(ctx, "failed to render prompt", logKeyError, err)
+		return GenerateResponse{}, err
+	}
 	var newResponses []dto.Message
 
-	fullHistory := append(history, *newMessage)
+	// history is copied so that appending never writes to the caller's backing array
+	fullHistory := append(slices.Clone(history), *newMessage)
 
-	for newHistory, err := range g.generate(messages) {
+	var pending *PendingApproval
+	for output, err := range g.generate(ctx, messages, nil) {
 		if err != nil {
 			return GenerateResponse{}, err
 		}
-		if !newHistory.Config.ExcludeFromHistory {
-			fullHistory = append(fullHistory, newHistory)
+		if output.pending != nil {
+			pending = output.pending.withHistory(fullHistory)
+			break
 		}
-	}
-
-	difference := len(fullHistory) + 1 - len(messages)
-	if difference > 0 {
-		startingIndex := len(messages) - 1
-		newResponses = filterOutUserMessages(fullHistory[startingIndex:])
+		newResponses, fullHistory = appendOutput(newResponses, fullHistory, output)
 	}
 
 	return GenerateResponse{
-		NewResponses: newResponses,
-		FullHistory:  fullHistory,
+		NewResponses:    newResponses,
+		FullHistory:     fullHistory,
+		CacheHit:        hasCacheHit(newResponses),
+		PendingApproval: pending,
 	}, err
 }
 
 // GenerateIterator returns the iterator for the GPT client. Instead of returning the full history, it will return the history one by one.
 func (g *Client) GenerateIterator(prompt *string, history []dto.Message) GenerateIteratorRet {
-	input, err := g.usePluginForInput(*prompt)
-	if err != nil {
-		logger.Error(err)
-		return func(func(response GenerateResponse, err error) bool) {
+	return g.GenerateIteratorWithContext(context.Background(), prompt, history)
+}
+
+// GenerateIteratorWithContext is the same as GenerateIterator, but the context is used for cancellation and tracing.
+func (g *Client) GenerateIteratorWithContext(ctx context.Context, prompt *string, history []dto.Message) GenerateIteratorRet {
+	return func(yield func(response GenerateResponse, err error) bool) {
+		g, err := g.snapshot()
+		if err != nil {
+			yield(GenerateResponse{}, err)
 			return
 		}
-	}
+		ctx, span := g.config.Telemetry.StartTurn(ctx)
+		defer func() {
+			telemetry.End(span, err)
+		}()
 
-	return func(yield func(response GenerateResponse, err error) bool) {
-		totalHistory := history
-		newMessage, messages := g.createMessages(input, history)
-		totalHistory = append(totalHistory, *newMessage)
+		input, err := g.usePluginForInput(ctx, *prompt)
+		if err != nil {
+			g.log(ctx).ErrorContext(ctx, "failed to convert input", logKeyError, err)
+			yield(GenerateResponse{}, err)
+			return
+		}
 
-		for response, err := range g.generate(messages) {
-			if err != nil {
-				yield(GenerateResponse{}, err)
-				return
-			}
+		newMessage, messages, err := g.createMessages(input, history)
+		if err != nil {
+			g.log(ctx).ErrorContext(ctx, "failed to render prompt", logKeyError, err)
+			yield(GenerateResponse{}, err)
+			return
+		}
+		totalHistory := append(slices.Clone(history), *newMessage)
+		err = yieldOutputs(g.generate(ctx, messages, nil), totalHistory, yield)
+	}
+}
 
-			for response, err := range g.usePluginForOutput(response) {
-				if err != nil {
-					yield(GenerateResponse{}, err)
-					return
-				}
+// yieldOutputs yields a response for every message of the turn, and the pending approval stopping the turn if there is one.
+// It returns the error of the turn, already yielded.
+func yieldOutputs(outputs func(func(turnMessage, error) bool), totalHistory []dto.Message, yield func(response GenerateResponse, err error) bool) error {
+	for output, err := range outputs {
+		if err != nil {
+			yield(GenerateResponse{}, err)
+			return err
+		}
 
-				if !response.Config.ExcludeFromHistory {
-					totalHistory = append(totalHistory, response)
-				}
+		if output.pending != nil {
+			yield(GenerateResponse{
+				FullHistory:     slices.Clip(totalHistory),
+				PendingApproval: output.pending.withHistory(totalHistory),
+			}, nil)
+			return nil
+		}
 
-				yield(GenerateResponse{
-					NewResponses: []dto.Message{response},
-					FullHistory:  totalHistory,
-				}, nil)
+		if output.history != nil && !output.history.Config.ExcludeFromHistory {
+			totalHistory = append(totalHistory, *output.history)
+		}
 
-			}
+		if !yield(GenerateResponse{
+			NewResponses: []dto.Message{output.response},
+			// clipped so that appending to it never overwrites the history yielded next
+			FullHistory: slices.Clip(totalHistory),
+			CacheHit:    output.response.CacheHit,
+		}, nil) {
+			return nil
 		}
 	}
+	return nil
 }
 
 // generate generates a response from the GPT API. This is the internal function that is called by Generate.
-func (g *Client) generate(messages []dto.Message) func(func(response dto.Message, err error) bool) {
-	return func(yield func(response dto.Message, err error) bool) {
+// It yields the messages of the turn once the plugins converted them.
+// When [onDelta] is not nil, the answers are streamed to it while they are generated.
+func (g *Client) generate(ctx context.Context, messages []dto.Message, onDelta func(delta string)) func(func(response turnMessage, err error) bool) {
+	return func(yield func(response turnMessage, err error) bool) {
 		body := dto.RequestDto{
-			Messages: cleanMessages(messages),
-			Tools:    g.generateFunctions(),
+			Messages:    cleanMessages(messages),
+			Tools:       g.generateFunctions(),
+			Temperature: g.config.Temperature,
+			TopP:        g.config.TopP,
+			Seed:        g.config.Seed,
+			MaxTokens:   g.config.MaxTokens,
 		}
 
-		var gptRequest dto.ResponseDto
-		requestClient := g.httpClient.R()
-
 		if isOpenAIEndpoint(g.config.Endpoint) {
-			requestClient = requestClient.SetHeader("Authorization", "Bearer "+g.config.ApiKey)
 			body.Model = stringPtr(g.config.Model)
-		} else {
-			requestClient = requestClient.SetHeader("api-key", g.config.ApiKey)
 		}
-		response, err := requestClient.SetBody(body).SetResult(
-			&gptRequest,
-		).Post(g.config.Endpoint)
+		request := &middleware.Request{
+			Endpoint: g.config.Endpoint,
+			ApiKey:   g.config.ApiKey,
+			Header:   http.Header{},
+			Body:     &body,
+		}
+		// the deltas are only passed on when the plugins cannot change the answer, otherwise it is streamed once converted
+		streamed := false
+		if onDelta != nil {
+			body.Stream = true
+			if isOpenAIEndpoint(g.config.Endpoint) {
+				body.StreamOptions = &dto.StreamOptions{IncludeUsage: true}
+			}
+			if g.passesOutputThrough() {
+				request.OnDelta = func(delta string) {
+					streamed = true
+					onDelta(delta)
+				}
+			}
+		}
 
+		middlewares := append([]middleware.Middleware{g.config.Telemetry.Middleware()}, g.config.Middlewares...)
+		roundTrip := middleware.Chain(middlewares...)(g.send)
+		gptResponse, err := roundTrip(ctx, request)
 		if err != nil {
-			logger.Error(err)
-			yield(dto.Message{}, err)
+			g.log(ctx).ErrorContext(ctx, "failed to generate response", logKeyError, err)
+			yield(turnMessage{}, err)
 			return
 		}
 
-		if !response.IsSuccess() {
-			logger.Errorf("failed to generate response: %v", response)
-			yield(dto.Message{}, fmt.Errorf("failed to generate response: %v", response))
+		if gptResponse == nil || gptResponse.Body == nil || len(gptResponse.Body.Choices) == 0 {
+			err = fmt.Errorf("failed to generate response: no choices returned")
+			g.log(ctx).ErrorContext(ctx, "failed to generate response", logKeyError, err)
+			yield(turnMessage{}, err)
 			return
 		}
 
 		// use function if there is one
-		message := gptRequest.Choices[0].Message
+		message := gptResponse.Body.Choices[0].Message
 		newResponse := dto.Message{
 			Role:      message.Role,
 			Content:   message.Content,
-			Usage:     gptRequest.Usage,
+			Usage:     gptResponse.Body.Usage,
 			ToolCalls: message.ToolCalls,
+			CacheHit:  gptResponse.CacheHit,
+			Target:    gptResponse.Target,
+		}
+		outputs, err := g.usePluginForOutput(ctx, newResponse)
+		if err != nil {
+			g.log(ctx).ErrorContext(ctx, "failed to convert output", logKeyError, err)
+			yield(turnMessage{}, err)
+			return
+		}
+		if onDelta != nil && !streamed {
+			// the answer was buffered, or not streamed at all such as a cache hit
+			streamOutputs(outputs, onDelta)
+		}
+		for _, output := range outputs {
+			if !yield(output, nil) {
+				return
+			}
+			if output.history != nil {
+				messages = append(messages, *output.history)
+			}
 		}
-		yield(newResponse, nil)
-		messages = append(messages, newResponse)
-		for newHistory, err := range g.useFunction(message, messages) {
+		// the functions are called as the plugins converted the answer, so a refused answer calls none of them
+		for newHistory, err := range g.useFunction(ctx, outputs[0], messages, onDelta) {
 			if err != nil {
-				yield(dto.Message{}, err)
+				yield(turnMessage{}, err)
 				return
 			}
 
-			yield(newHistory, nil)
+			if !yield(newHistory, nil) {
+				return
+			}
 		}
-		if err != nil {
-			logger.Error(err)
-			yield(dto.Message{}, err)
-			return
+	}
+}
+
+// send posts the request to the GPT API. This is the innermost round trip of the middleware chain.
+func (g *Client) send(ctx context.Context, request *middleware.Request) (*middleware.Response, error) {
+	var gptResponse dto.ResponseDto
+	requestClient := g.httpClient.R().SetContext(ctx).SetHeaderMultiValues(request.Header)
+
+	if isOpenAIEndpoint(request.Endpoint) {
+		requestClient = requestClient.SetHeader("Authorization", "Bearer "+request.ApiKey)
+	} else {
+		requestClient = requestClient.SetHeader("api-key", request.ApiKey)
+	}
+	if request.Body != nil && request.Body.Stream {
+		return sendStream(ctx, requestClient, request)
+	}
+
+	response, err := requestClient.SetBody(requestBody(request.Body)).SetResult(
+		&gptResponse,
+	).Post(request.Endpoint)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !response.IsSuccess() {
+		return nil, &middleware.StatusError{
+			StatusCode: response.StatusCode(),
+			Header:     response.Header(),
+			Body:       response.String(),
 		}
 	}
+
+	return &middleware.Response{
+		StatusCode: response.StatusCode(),
+		Header:     response.Header(),
+		Body:       &gptResponse,
+	}, nil
 }
 
 func (g *Client) generateFunctions() []dto.Tool {
@@ -251,140 +479,196 @@ func (g *Client) generateFunctions() []dto.Tool {
 	return returnedFunctions
 }
 
-// usePluginForInput uses the plugin for the input.
-func (g *Client) usePluginForInput(input any) (*string, error) {
-	output := input
-	for _, foundPlugin := range *g.config.Plugins {
-		convertedOutput, err := foundPlugin.ConvertInput(output)
-		if err != nil {
-			return nil, err
-		}
+// refusedToolResult answers the tool calls of an answer a plugin terminated, such as a refusal of the moderation.
+const refusedToolResult = "The answer was refused, so the function was not called."
 
-		if convertedOutput != nil {
-			output = convertedOutput
+// useFunction uses the function if there is one in the answer, once the plugins converted it.
+// It returns the new history and an error if there is one.
+// The tool calls of an answer terminated by a plugin are answered as refused, without calling the functions.
+// A call a plugin rejects with a plugin.ToolCallRejection is answered with its reason, and the model answers it.
+// If the function is configured to use GPT to interpret responses, it will call the GPT API again to interpret the responses.
+// If the function requires an approval, it yields the pending approval instead and the turn stops until it is resumed.
+func (g *Client) useFunction(ctx context.Context, output turnMessage, history []dto.Message, onDelta func(delta string)) func(func(turnMessage, error) bool) {
+	return func(yield func(turnMessage, error) bool) {
+		result := output.history
+		if result == nil || result.ToolCalls == nil {
+			return
 		}
-	}
+		if output.terminated {
+			// the tool calls of the terminated answer are kept in the history, so they must still be answered
+			for _, toolCall := range *result.ToolCalls {
+				refusal := dto.Message{Role: dto.RoleTool, Content: refusedToolResult, ToolCallId: &toolCall.Id}
+				if !yield(turnMessage{response: refusal, history: &refusal}, nil) {
+					return
+				}
+			}
+			return
+		}
+		for _, toolCall := range *result.ToolCalls {
+			function := g.findFunction(toolCall.Function.Name)
+			if function == nil {
+				continue
+			}
 
-	if value, ok := output.(*string); ok {
-		return value, nil
-	}
+			var functionArguments map[string]interface{}
+			err := json.Unmarshal([]byte(toolCall.Function.Arguments), &functionArguments)
+			if err != nil {
+				yield(turnMessage{}, err)
+				return
+			}
+			functionArguments, err = g.usePluginForToolCall(ctx, function.Name(), functionArguments)
+			var rejection plugin.ToolCallRejection
+			if errors.As(err, &rejection) {
+				g.log(ctx).InfoContext(ctx, "function call rejected by a plugin", logKeyToolName, function.Name(), logKeyToolCallId, toolCall.Id, logKeyError, err)
+				for output, err := range g.rejectFunction(ctx, toolCall.Id, rejection.Reason(), history, onDelta) {
+					if !yield(output, err) || err != nil {
+						return
+					}
+				}
+				return
+			}
+			if err != nil {
+				yield(turnMessage{}, err)
+				return
+			}
 
-	if value, ok := output.(string); ok {
-		return &value, nil
+			config := function.Config()
+			if config.RequiresApproval {
+				g.log(ctx).InfoContext(
+					ctx,
+					"function awaiting approval",
+					logKeyToolName, function.Name(),
+					logKeyToolCallId, toolCall.Id,
+				)
+				yield(turnMessage{pending: &PendingApproval{
+					ToolCallId: toolCall.Id,
+					Function:   function.Name(),
+					Arguments:  functionArguments,
+				}}, nil)
+				return
+			}
+
+			for output, err := range g.callFunction(ctx, function, config, toolCall.Id, functionArguments, history, onDelta) {
+				if !yield(output, err) || err != nil {
+					return
+				}
+			}
+			return
+		}
 	}
+}
 
-	return nil, fmt.Errorf("last plugin should return a string value. Got %v", output)
+// findFunction returns the function [name], or nil when there is none.
+func (g *Client) findFunction(name string) functions.FunctionInterface {
+	for _, function := range *g.config.Functions {
+		if function.Name() == name {
+			return function
+		}
+	}
+	return nil
 }
 
-// usePluginForOutput uses the plugin for the output.
-func (g *Client) usePluginForOutput(response dto.Message) func(yield func(response dto.Message, err error) bool) {
-	return func(yield func(response dto.Message, err error) bool) {
-		if g.config.Plugins == nil {
+// callFunction calls the function with the arguments, and yields its result followed by the answers to it.
+// [config] is the configuration of the function, read once per call.
+func (g *Client) callFunction(ctx context.Context, function functions.FunctionInterface, config functions.FunctionConfig, toolCallId string, arguments map[string]interface{}, history []dto.Message, onDelta func(delta string)) func(func(turnMessage, error) bool) {
+	return func(yield func(turnMessage, error) bool) {
+		newHistory := slices.Clip(history)
+		_, span := g.config.Telemetry.StartTool(ctx, function.Name(), toolCallId)
+		result, err := functions.Run(ctx, function, config.Timeout, arguments)
+		telemetry.End(span, err)
+		if err != nil {
+			g.log(ctx).ErrorContext(
+				ctx,
+				"function failed",
+				logKeyToolName, function.Name(),
+				logKeyToolCallId, toolCallId,
+				logKeyError, err,
+			)
+			if config.OnError != functions.ReportToModel {
+				yield(turnMessage{}, err)
+				return
+			}
+			// the model answers the error, whether it interprets the responses of the function or not
+			result = &functions.FunctionGptResponse{Content: "The function failed: " + err.Error()}
+			config.UseGptToInterpretResponses = true
+		}
+		// Add history
+		message := dto.Message{
+			Role:       dto.RoleTool,
+			Content:    convertFunctionContentToString(result.Content),
+			ToolCallId: &toolCallId,
+			Config:     result.Config,
+		}
+		g.log(ctx).InfoContext(
+			ctx,
+			"function executed",
+			logKeyToolName, function.Name(),
+			logKeyToolCallId, toolCallId,
+			logKeyContent, g.config.Redact(message.Content),
+		)
+		outputs, err := g.usePluginForToolResult(ctx, message)
+		if err != nil {
+			yield(turnMessage{}, err)
 			return
 		}
-		for _, foundPlugin := range *g.config.Plugins {
-			convertedResponse, err := foundPlugin.ConvertOutput(response)
-			if err != nil {
-				yield(dto.Message{}, err)
+		for _, output := range outputs {
+			if output.history != nil && !output.history.Config.ExcludeFromHistory {
+				newHistory = append(newHistory, *output.history)
+			}
+			if !yield(output, nil) {
 				return
 			}
-			if convertedResponse != nil {
-				yield(*convertedResponse.Message, nil)
-				if convertedResponse.Action == plugin.TerminateOutputAction {
+		}
+		if config.UseGptToInterpretResponses {
+			for response, err := range g.generate(ctx, newHistory, onDelta) {
+				if err != nil {
+					yield(turnMessage{}, err)
+					return
+				}
+
+				if !yield(response, nil) {
 					return
 				}
 			}
+			return
 		}
-	}
-
-}
+		for response, err := range function.OnAfterGptRespond {
+			if err != nil {
+				yield(turnMessage{}, err)
+				return
+			}
 
-// useFunction uses the function if there is one in the response.
-// It returns the new history and an error if there is one.
-// If the function is configured to use GPT to interpret responses, it will call the GPT API again to interpret the responses.
-func (g *Client) useFunction(result dto.MessageResponseDto, history []dto.Message) func(func(dto.Message, error) bool) {
-	return func(yield func(dto.Message, error) bool) {
-		newHistory := history
-		if result.ToolCalls != nil && len(*result.ToolCalls) > 0 {
-			for _, toolCall := range *result.ToolCalls {
-				for _, function := range *g.config.Functions {
-					if function.Name() == toolCall.Function.Name {
-						var functionArguments map[string]interface{}
-						err := json.Unmarshal([]byte(toolCall.Function.Arguments), &functionArguments)
-						if err != nil {
-							yield(dto.Message{}, err)
-							return
-						}
-						result, err := function.OnMessage(functionArguments)
-						if err != nil {
-							yield(dto.Message{}, err)
-							return
-						}
-						logger.Infof("Function %v executed with result %v", function.Name(), result)
-
-						// Add history
-						message := dto.Message{
-							Role:       dto.RoleTool,
-							Content:    convertFunctionContentToString(result.Content),
-							ToolCallId: &toolCall.Id,
-							Config:     result.Config,
-						}
-						if !result.Config.ExcludeFromHistory {
-							newHistory = append(newHistory, message)
-						}
-						yield(message, nil)
-						if function.Config().UseGptToInterpretResponses {
-							for response, err := range g.generate(newHistory) {
-								if err != nil {
-									yield(dto.Message{}, err)
-									return
-								}
-
-								yield(response, nil)
-								newHistory = append(newHistory, response)
-							}
-
-							if err != nil {
-								yield(dto.Message{}, err)
-								return
-							}
-							return
-						}
-						for response, err := range function.OnAfterGptRespond {
-							if err != nil {
-								yield(dto.Message{}, err)
-								return
-							}
-
-							resp := dto.Message{
-								Role:    dto.RoleAssistant,
-								Content: convertFunctionContentToString(response.Content),
-								Config:  response.Config,
-							}
-							yield(resp, nil)
-							if !response.Config.ExcludeFromHistory {
-								newHistory = append(newHistory, resp)
-							}
-						}
-						return
-					}
+			resp := dto.Message{
+				Role:    dto.RoleAssistant,
+				Content: convertFunctionContentToString(response.Content),
+				Config:  response.Config,
+			}
+			outputs, err := g.usePluginForOutput(ctx, resp)
+			if err != nil {
+				yield(turnMessage{}, err)
+				return
+			}
+			if onDelta != nil {
+				streamOutputs(outputs, onDelta)
+			}
+			for _, output := range outputs {
+				if !yield(output, nil) {
+					return
 				}
 			}
 		}
-		return
 	}
 }
 
 // createMessages creates a list of messages with history and prompt included.
-func (g *Client) createMessages(prompt *string, history []dto.Message) (*dto.Message, []dto.Message) {
+func (g *Client) createMessages(prompt *dto.Message, history []dto.Message) (*dto.Message, []dto.Message, error) {
 	var messages []dto.Message
 	// only add system message if there is no history
 
 	engine := g.config.Template
 	renderedPrompt, err := engine.Render(g.config.Prompt)
 	if err != nil {
-		logger.Error(err)
-		return nil, nil
+		return nil, nil, err
 	}
 	messages = append(messages, dto.Message{
 		Role:    dto.RoleSystem,
@@ -398,11 +682,8 @@ func (g *Client) createMessages(prompt *string, history []dto.Message) (*dto.Mes
 
 	var promptMessage dto.Message
 	if prompt != nil {
-		promptMessage = dto.Message{
-			Role:    dto.RoleUser,
-			Content: *prompt,
-		}
+		promptMessage = *prompt
 		messages = append(messages, promptMessage)
 	}
-	return &promptMessage, messages
+	return &promptMessage, messages, nil
 }
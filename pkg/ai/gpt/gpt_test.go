@@ -1,18 +1,26 @@
 package gpt
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
 	"github.com/go-resty/resty/v2"
-	"github.com/google/logger"
 	"github.com/jarcoal/httpmock"
 	template "github.com/meta-metopia/go-packages/pkg/ai"
+	"github.com/meta-metopia/go-packages/pkg/ai/gpt/cache"
 	"github.com/meta-metopia/go-packages/pkg/ai/gpt/dto"
 	"github.com/meta-metopia/go-packages/pkg/ai/gpt/functions"
+	"github.com/meta-metopia/go-packages/pkg/ai/gpt/middleware"
 	"github.com/meta-metopia/go-packages/pkg/ai/gpt/plugin"
+	"github.com/meta-metopia/go-packages/pkg/ai/gpt/telemetry/telemetrytest"
+	"github.com/meta-metopia/go-packages/pkg/ai/gpt/upstream"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/suite"
 	"go.uber.org/mock/gomock"
-	"io"
+	"log/slog"
 	"net/http"
+	"sync"
 	"testing"
 )
 
@@ -34,7 +42,6 @@ func (suite *GptTestSuite) TearDownTest() {
 }
 
 func (suite *GptTestSuite) TestGptWithoutFunctionCall() {
-	logger.Init("TestLogger", true, false, io.Discard)
 	engine := template.NewMockEngine(suite.ctrl)
 	engine.EXPECT().Render(gomock.Any()).Return("Mock Data", nil).AnyTimes()
 
@@ -56,8 +63,8 @@ func (suite *GptTestSuite) TestGptWithoutFunctionCall() {
 	httpmock.RegisterResponder("POST", url, responder)
 
 	aiFunctions := make([]functions.FunctionInterface, 0)
-	functionStore := make(functions.FunctionStore)
-	client := NewGptClient(
+	functionStore := functions.NewFunctionStore()
+	client, err := NewGptClient(
 		Config{
 			Endpoint:  url,
 			ApiKey:    "123",
@@ -66,6 +73,7 @@ func (suite *GptTestSuite) TestGptWithoutFunctionCall() {
 			Store:     functionStore,
 		},
 	)
+	assert.Nil(suite.T(), err)
 	client.SetClient(suite.client)
 
 	prompt := "Prompt"
@@ -93,7 +101,6 @@ func (suite *GptTestSuite) TestGptWithoutFunctionCall() {
 }
 
 func (suite *GptTestSuite) TestGptWithoutFunctionCallIterator() {
-	logger.Init("TestLogger", true, false, io.Discard)
 	engine := template.NewMockEngine(suite.ctrl)
 	engine.EXPECT().Render(gomock.Any()).Return("Mock Data", nil).AnyTimes()
 
@@ -115,8 +122,8 @@ func (suite *GptTestSuite) TestGptWithoutFunctionCallIterator() {
 	httpmock.RegisterResponder("POST", url, responder)
 
 	aiFunctions := make([]functions.FunctionInterface, 0)
-	functionStore := make(functions.FunctionStore)
-	client := NewGptClient(
+	functionStore := functions.NewFunctionStore()
+	client, err := NewGptClient(
 		Config{
 			Endpoint:  url,
 			ApiKey:    "123",
@@ -125,6 +132,7 @@ func (suite *GptTestSuite) TestGptWithoutFunctionCallIterator() {
 			Store:     functionStore,
 		},
 	)
+	assert.Nil(suite.T(), err)
 	client.SetClient(suite.client)
 
 	prompt := "Prompt"
@@ -161,7 +169,6 @@ func (suite *GptTestSuite) TestGptWithoutFunctionCallIterator() {
 }
 
 func (suite *GptTestSuite) TestGptWithError() {
-	logger.Init("TestLogger", true, false, io.Discard)
 	engine := template.NewMockEngine(suite.ctrl)
 	engine.EXPECT().Render(gomock.Any()).Return("Mock Data", nil).AnyTimes()
 
@@ -173,8 +180,8 @@ func (suite *GptTestSuite) TestGptWithError() {
 	httpmock.RegisterResponder("POST", url, responder)
 
 	aiFunctions := make([]functions.FunctionInterface, 0)
-	functionStore := make(functions.FunctionStore)
-	client := NewGptClient(
+	functionStore := functions.NewFunctionStore()
+	client, err := NewGptClient(
 		Config{
 			Endpoint:  url,
 			ApiKey:    "123",
@@ -183,6 +190,7 @@ func (suite *GptTestSuite) TestGptWithError() {
 			Store:     functionStore,
 		},
 	)
+	assert.Nil(suite.T(), err)
 	client.SetClient(suite.client)
 
 	prompt := "Prompt"
@@ -194,7 +202,6 @@ func (suite *GptTestSuite) TestGptWithError() {
 }
 
 func (suite *GptTestSuite) TestGptWithErrorIterator() {
-	logger.Init("TestLogger", true, false, io.Discard)
 	engine := template.NewMockEngine(suite.ctrl)
 	engine.EXPECT().Render(gomock.Any()).Return("Mock Data", nil).AnyTimes()
 
@@ -206,8 +213,8 @@ func (suite *GptTestSuite) TestGptWithErrorIterator() {
 	httpmock.RegisterResponder("POST", url, responder)
 
 	aiFunctions := make([]functions.FunctionInterface, 0)
-	functionStore := make(functions.FunctionStore)
-	client := NewGptClient(
+	functionStore := functions.NewFunctionStore()
+	client, err := NewGptClient(
 		Config{
 			Endpoint:  url,
 			ApiKey:    "123",
@@ -216,6 +223,7 @@ func (suite *GptTestSuite) TestGptWithErrorIterator() {
 			Store:     functionStore,
 		},
 	)
+	assert.Nil(suite.T(), err)
 	client.SetClient(suite.client)
 
 	prompt := "Prompt"
@@ -228,7 +236,6 @@ func (suite *GptTestSuite) TestGptWithErrorIterator() {
 }
 
 func (suite *GptTestSuite) TestGptWithErrorFromServer() {
-	logger.Init("TestLogger", true, false, io.Discard)
 	engine := template.NewMockEngine(suite.ctrl)
 	engine.EXPECT().Render(gomock.Any()).Return("Mock Data", nil).AnyTimes()
 
@@ -241,8 +248,8 @@ func (suite *GptTestSuite) TestGptWithErrorFromServer() {
 	httpmock.RegisterResponder("POST", url, responder)
 
 	aiFunctions := make([]functions.FunctionInterface, 0)
-	functionStore := make(functions.FunctionStore)
-	client := NewGptClient(
+	functionStore := functions.NewFunctionStore()
+	client, err := NewGptClient(
 		Config{
 			Endpoint:  url,
 			ApiKey:    "123",
@@ -251,6 +258,7 @@ func (suite *GptTestSuite) TestGptWithErrorFromServer() {
 			Store:     functionStore,
 		},
 	)
+	assert.Nil(suite.T(), err)
 	client.SetClient(suite.client)
 
 	prompt := "Prompt"
@@ -262,7 +270,6 @@ func (suite *GptTestSuite) TestGptWithErrorFromServer() {
 }
 
 func (suite *GptTestSuite) TestGptWithFunctionCall() {
-	logger.Init("TestLogger", true, false, io.Discard)
 	engine := template.NewMockEngine(suite.ctrl)
 	engine.EXPECT().Render(gomock.Any()).Return("Mock Data", nil).AnyTimes()
 
@@ -304,8 +311,8 @@ func (suite *GptTestSuite) TestGptWithFunctionCall() {
 	function.EXPECT().OnInit().Times(1)
 	function.EXPECT().OnAfterGptRespond(gomock.Any()).AnyTimes()
 
-	functionStore := make(functions.FunctionStore)
-	client := NewGptClient(
+	functionStore := functions.NewFunctionStore()
+	client, err := NewGptClient(
 		Config{
 			Endpoint:  url,
 			ApiKey:    "123",
@@ -314,6 +321,7 @@ func (suite *GptTestSuite) TestGptWithFunctionCall() {
 			Store:     functionStore,
 		},
 	)
+	assert.Nil(suite.T(), err)
 	client.SetClient(suite.client)
 
 	prompt := "Prompt"
@@ -330,7 +338,6 @@ func (suite *GptTestSuite) TestGptWithFunctionCall() {
 
 // When Multiple function exists, every tool response should follow by a function call
 func (suite *GptTestSuite) TestGptWithMultipleFunctionCalls() {
-	logger.Init("TestLogger", true, false, io.Discard)
 	engine := template.NewMockEngine(suite.ctrl)
 	engine.EXPECT().Render(gomock.Any()).Return("Mock Data", nil).AnyTimes()
 
@@ -392,8 +399,8 @@ func (suite *GptTestSuite) TestGptWithMultipleFunctionCalls() {
 	function.EXPECT().OnInit().Times(1)
 	function.EXPECT().OnAfterGptRespond(gomock.Any()).AnyTimes()
 
-	functionStore := make(functions.FunctionStore)
-	client := NewGptClient(
+	functionStore := functions.NewFunctionStore()
+	client, err := NewGptClient(
 		Config{
 			Endpoint:  url,
 			ApiKey:    "123",
@@ -402,6 +409,7 @@ func (suite *GptTestSuite) TestGptWithMultipleFunctionCalls() {
 			Store:     functionStore,
 		},
 	)
+	assert.Nil(suite.T(), err)
 	client.SetClient(suite.client)
 
 	prompt := "Prompt"
@@ -420,7 +428,6 @@ func (suite *GptTestSuite) TestGptWithMultipleFunctionCalls() {
 }
 
 func (suite *GptTestSuite) TestGptWithFunctionCallAndUseGptToInterpret() {
-	logger.Init("TestLogger", true, false, io.Discard)
 	engine := template.NewMockEngine(suite.ctrl)
 	engine.EXPECT().Render(gomock.Any()).Return("Mock Data", nil).AnyTimes()
 
@@ -470,8 +477,8 @@ func (suite *GptTestSuite) TestGptWithFunctionCallAndUseGptToInterpret() {
 	function.EXPECT().OnInit().Times(1)
 	function.EXPECT().OnAfterGptRespond(gomock.Any()).AnyTimes()
 
-	functionStore := make(functions.FunctionStore)
-	client := NewGptClient(
+	functionStore := functions.NewFunctionStore()
+	client, err := NewGptClient(
 		Config{
 			Endpoint:  url,
 			ApiKey:    "123",
@@ -480,6 +487,7 @@ func (suite *GptTestSuite) TestGptWithFunctionCallAndUseGptToInterpret() {
 			Store:     functionStore,
 		},
 	)
+	assert.Nil(suite.T(), err)
 	client.SetClient(suite.client)
 
 	prompt := "Prompt"
@@ -495,7 +503,6 @@ func (suite *GptTestSuite) TestGptWithFunctionCallAndUseGptToInterpret() {
 }
 
 func (suite *GptTestSuite) TestShouldIncludeInHistoryTrue() {
-	logger.Init("TestLogger", true, false, io.Discard)
 	engine := template.NewMockEngine(suite.ctrl)
 	engine.EXPECT().Render(gomock.Any()).Return("Mock Data", nil).AnyTimes()
 
@@ -535,8 +542,8 @@ func (suite *GptTestSuite) TestShouldIncludeInHistoryTrue() {
 	function.EXPECT().OnInit().Times(1)
 	function.EXPECT().OnAfterGptRespond(gomock.Any()).AnyTimes()
 
-	functionStore := make(functions.FunctionStore)
-	client := NewGptClient(
+	functionStore := functions.NewFunctionStore()
+	client, err := NewGptClient(
 		Config{
 			Endpoint:  url,
 			ApiKey:    "123",
@@ -545,6 +552,7 @@ func (suite *GptTestSuite) TestShouldIncludeInHistoryTrue() {
 			Store:     functionStore,
 		},
 	)
+	assert.Nil(suite.T(), err)
 	client.SetClient(suite.client)
 
 	prompt := "Prompt"
@@ -560,7 +568,6 @@ func (suite *GptTestSuite) TestShouldIncludeInHistoryTrue() {
 }
 
 func (suite *GptTestSuite) TestShouldIncludeInHistoryFalse() {
-	logger.Init("TestLogger", true, false, io.Discard)
 	engine := template.NewMockEngine(suite.ctrl)
 	engine.EXPECT().Render(gomock.Any()).Return("Mock Data", nil).AnyTimes()
 
@@ -600,8 +607,8 @@ func (suite *GptTestSuite) TestShouldIncludeInHistoryFalse() {
 	function.EXPECT().OnInit().Times(1)
 	function.EXPECT().OnAfterGptRespond(gomock.Any()).AnyTimes()
 
-	functionStore := make(functions.FunctionStore)
-	client := NewGptClient(
+	functionStore := functions.NewFunctionStore()
+	client, err := NewGptClient(
 		Config{
 			Endpoint:  url,
 			ApiKey:    "123",
@@ -610,6 +617,7 @@ func (suite *GptTestSuite) TestShouldIncludeInHistoryFalse() {
 			Store:     functionStore,
 		},
 	)
+	assert.Nil(suite.T(), err)
 	client.SetClient(suite.client)
 
 	prompt := "Prompt"
@@ -622,6 +630,514 @@ func (suite *GptTestSuite) TestShouldIncludeInHistoryFalse() {
 	assert.Equal(suite.T(), response.FullHistory[1].Role, dto.RoleAssistant)
 }
 
+func (suite *GptTestSuite) TestGptWithMiddlewares() {
+	engine := template.NewMockEngine(suite.ctrl)
+	engine.EXPECT().Render(gomock.Any()).Return("Mock Data", nil).AnyTimes()
+
+	url := "http://localhost:8080"
+	var receivedHeader http.Header
+	httpmock.RegisterResponder("POST", url, func(request *http.Request) (*http.Response, error) {
+		receivedHeader = request.Header
+		return httpmock.NewJsonResponse(http.StatusOK, map[string]interface{}{
+			"choices": []map[string]interface{}{
+				{
+					"message": map[string]interface{}{
+						"role":    "assistant",
+						"content": "Mock Data",
+					},
+				},
+			},
+		})
+	})
+
+	var calls []string
+	redact := func(next middleware.RoundTrip) middleware.RoundTrip {
+		return func(ctx context.Context, request *middleware.Request) (*middleware.Response, error) {
+			calls = append(calls, "redact")
+			response, err := next(ctx, request)
+			if err != nil {
+				return nil, err
+			}
+			response.Body.Choices[0].Message.Content = "Redacted"
+			return response, nil
+		}
+	}
+	record := func(next middleware.RoundTrip) middleware.RoundTrip {
+		return func(ctx context.Context, request *middleware.Request) (*middleware.Response, error) {
+			calls = append(calls, "record")
+			assert.Equal(suite.T(), "Prompt", request.Body.Messages[1].Content)
+			return next(ctx, request)
+		}
+	}
+
+	functionStore := functions.NewFunctionStore()
+	client, err := NewGptClient(
+		Config{
+			Endpoint:    url,
+			ApiKey:      "123",
+			Template:    engine,
+			Store:       functionStore,
+			Middlewares: []middleware.Middleware{middleware.WithHeader("X-Tenant", "tenant"), redact, record},
+		},
+	)
+	assert.Nil(suite.T(), err)
+	client.SetClient(suite.client)
+
+	prompt := "Prompt"
+	response, err := client.Generate(&prompt, []dto.Message{})
+
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), []string{"redact", "record"}, calls)
+	assert.Equal(suite.T(), "tenant", receivedHeader.Get("X-Tenant"))
+	assert.Equal(suite.T(), "123", receivedHeader.Get("api-key"))
+	assert.Equal(suite.T(), "Redacted", response.NewResponses[0].Content)
+}
+
+func (suite *GptTestSuite) TestGptWithStatusError() {
+	engine := template.NewMockEngine(suite.ctrl)
+	engine.EXPECT().Render(gomock.Any()).Return("Mock Data", nil).AnyTimes()
+
+	url := "http://localhost:8080"
+	httpmock.RegisterResponder("POST", url, httpmock.NewStringResponder(http.StatusTooManyRequests, "rate limited"))
+
+	client, err := NewGptClient(
+		Config{
+			Endpoint: url,
+			ApiKey:   "123",
+			Template: engine,
+			Store:    functions.NewFunctionStore(),
+		},
+	)
+	assert.Nil(suite.T(), err)
+	client.SetClient(suite.client)
+
+	prompt := "Prompt"
+	_, err = client.Generate(&prompt, []dto.Message{})
+
+	var statusError *middleware.StatusError
+	assert.ErrorAs(suite.T(), err, &statusError)
+	assert.Equal(suite.T(), http.StatusTooManyRequests, statusError.StatusCode)
+}
+
+func (suite *GptTestSuite) TestGptWithTelemetry() {
+	engine := template.NewMockEngine(suite.ctrl)
+	engine.EXPECT().Render(gomock.Any()).Return("Mock Data", nil).AnyTimes()
+
+	toolResponse, _ := httpmock.NewJsonResponse(http.StatusOK, map[string]interface{}{
+		"choices": []map[string]interface{}{
+			{
+				"message": map[string]interface{}{
+					"role": "assistant",
+					"tool_calls": []map[string]interface{}{
+						{
+							"id":   "1",
+							"type": "function",
+							"function": map[string]interface{}{
+								"name":      "Mock Function",
+								"arguments": `{"prompt":"Prompt"}`,
+							},
+						},
+					},
+				},
+				"finish_reason": "tool_calls",
+			},
+		},
+		"usage": map[string]interface{}{"prompt_tokens": 10, "completion_tokens": 2},
+	})
+	assistantResponse, _ := httpmock.NewJsonResponse(http.StatusOK, map[string]interface{}{
+		"choices": []map[string]interface{}{
+			{
+				"message": map[string]interface{}{
+					"role":    "assistant",
+					"content": "Mock Data",
+				},
+				"finish_reason": "stop",
+			},
+		},
+		"usage": map[string]interface{}{"prompt_tokens": 20, "completion_tokens": 3},
+	})
+	url := "http://localhost:8080"
+	httpmock.RegisterResponder("POST", url, httpmock.ResponderFromMultipleResponses([]*http.Response{toolResponse, assistantResponse}))
+
+	var aiFunctions = make([]functions.FunctionInterface, 1)
+	function := functions.NewMockFunctionInterface(suite.ctrl)
+	aiFunctions[0] = function
+	function.EXPECT().OnMessage(gomock.Any()).Return(&functions.FunctionGptResponse{Content: "Mock Function Response"}, nil).Times(1)
+	function.EXPECT().Name().Return("Mock Function").AnyTimes()
+	function.EXPECT().Description().Return("Mock Function Description").AnyTimes()
+	function.EXPECT().Parameters().Return(map[string]interface{}{}).AnyTimes()
+	function.EXPECT().SetStore(gomock.Any()).Times(1)
+	function.EXPECT().Config().Return(functions.FunctionConfig{UseGptToInterpretResponses: true}).AnyTimes()
+	function.EXPECT().OnInit().Times(1)
+
+	recorder := telemetrytest.NewRecorder()
+	client, err := NewGptClient(
+		Config{
+			Endpoint:  url,
+			ApiKey:    "123",
+			Functions: &aiFunctions,
+			Template:  engine,
+			Store:     functions.NewFunctionStore(),
+			Telemetry: recorder.Telemetry,
+		},
+	)
+	assert.Nil(suite.T(), err)
+	client.SetClient(suite.client)
+
+	prompt := "Prompt"
+	for _, err := range client.GenerateIteratorWithContext(context.Background(), &prompt, []dto.Message{}) {
+		assert.Nil(suite.T(), err)
+	}
+
+	assert.Equal(suite.T(), []string{
+		"plugin input standard",
+		"chat",
+		"plugin output standard",
+		"execute_tool Mock Function",
+		"chat",
+		"plugin output standard",
+		"gpt.turn",
+	}, recorder.SpanNames())
+
+	spans := recorder.Spans()
+	turn := spans[len(spans)-1]
+	for _, span := range spans[:len(spans)-1] {
+		assert.Equal(suite.T(), turn.SpanContext.TraceID(), span.SpanContext.TraceID())
+	}
+	assert.Equal(suite.T(), int64(2), recorder.Sum("gen_ai.client.requests"))
+	assert.Equal(suite.T(), int64(35), recorder.Sum("gen_ai.client.token.usage"))
+}
+
+func (suite *GptTestSuite) TestNewGptClientWithInitError() {
+	var aiFunctions = make([]functions.FunctionInterface, 1)
+	function := functions.NewMockFunctionInterface(suite.ctrl)
+	aiFunctions[0] = function
+	function.EXPECT().Name().Return("Mock Function").AnyTimes()
+	function.EXPECT().OnInit().Return(fmt.Errorf("init failed")).Times(1)
+
+	client, err := NewGptClient(
+		Config{
+			Endpoint:  "http://localhost:8080",
+			Functions: &aiFunctions,
+			Store:     functions.NewFunctionStore(),
+		},
+	)
+
+	assert.Nil(suite.T(), client)
+	assert.ErrorContains(suite.T(), err, "Mock Function")
+	assert.ErrorContains(suite.T(), err, "init failed")
+}
+
+func (suite *GptTestSuite) TestGptWithLogger() {
+	engine := template.NewMockEngine(suite.ctrl)
+	engine.EXPECT().Render(gomock.Any()).Return("Mock Data", nil).AnyTimes()
+
+	body, _ := httpmock.NewJsonResponse(http.StatusOK, map[string]interface{}{
+		"choices": []map[string]interface{}{
+			{
+				"message": map[string]interface{}{
+					"role": "assistant",
+					"tool_calls": []map[string]interface{}{
+						{
+							"id":   "1",
+							"type": "function",
+							"function": map[string]interface{}{
+								"name":      "Mock Function",
+								"arguments": `{"prompt":"Prompt"}`,
+							},
+						},
+					},
+				},
+			},
+		},
+	})
+	url := "http://localhost:8080"
+	httpmock.RegisterResponder("POST", url, httpmock.ResponderFromResponse(body))
+
+	var aiFunctions = make([]functions.FunctionInterface, 1)
+	function := functions.NewMockFunctionInterface(suite.ctrl)
+	aiFunctions[0] = function
+	function.EXPECT().OnMessage(gomock.Any()).Return(&functions.FunctionGptResponse{Content: "0912345678"}, nil).Times(1)
+	function.EXPECT().Name().Return("Mock Function").AnyTimes()
+	function.EXPECT().Description().Return("Mock Function Description").AnyTimes()
+	function.EXPECT().Parameters().Return(map[string]interface{}{}).AnyTimes()
+	function.EXPECT().SetStore(gomock.Any()).Times(1)
+	function.EXPECT().Config().Return(functions.FunctionConfig{UseGptToInterpretResponses: false}).AnyTimes()
+	function.EXPECT().OnInit().Times(1)
+	function.EXPECT().OnAfterGptRespond(gomock.Any()).AnyTimes()
+
+	var output bytes.Buffer
+	client, err := NewGptClient(
+		Config{
+			Endpoint:  url,
+			ApiKey:    "123",
+			Functions: &aiFunctions,
+			Template:  engine,
+			Store:     functions.NewFunctionStore(),
+			Logger:    slog.New(slog.NewJSONHandler(&output, nil)),
+		},
+	)
+	assert.Nil(suite.T(), err)
+	client.SetClient(suite.client)
+
+	prompt := "Prompt"
+	ctx := WithConversationId(context.Background(), "conversation-1")
+	_, err = client.GenerateWithContext(ctx, &prompt, []dto.Message{})
+	assert.Nil(suite.T(), err)
+
+	var entry map[string]interface{}
+	assert.Nil(suite.T(), json.Unmarshal(output.Bytes(), &entry))
+	assert.Equal(suite.T(), "function executed", entry["msg"])
+	assert.Equal(suite.T(), "conversation-1", entry["conversation_id"])
+	assert.Equal(suite.T(), "Mock Function", entry["tool_name"])
+	assert.Equal(suite.T(), "1", entry["tool_call_id"])
+	assert.Equal(suite.T(), "[redacted 10 bytes]", entry["content"])
+	assert.NotContains(suite.T(), output.String(), "0912345678")
+}
+
+func (suite *GptTestSuite) TestGptWithCache() {
+	engine := template.NewMockEngine(suite.ctrl)
+	engine.EXPECT().Render(gomock.Any()).Return("Mock Data", nil).AnyTimes()
+
+	url := "http://localhost:8080"
+	responder, err := httpmock.NewJsonResponder(http.StatusOK, map[string]interface{}{
+		"choices": []map[string]interface{}{
+			{
+				"message": map[string]interface{}{
+					"role":    "assistant",
+					"content": "Mock Data",
+				},
+			},
+		},
+		"usage": map[string]interface{}{"prompt_tokens": 10, "completion_tokens": 2},
+	})
+	assert.Nil(suite.T(), err)
+	httpmock.RegisterResponder("POST", url, responder)
+
+	temperature := 0.0
+	client, err := NewGptClient(
+		Config{
+			Endpoint:    url,
+			ApiKey:      "123",
+			Template:    engine,
+			Store:       functions.NewFunctionStore(),
+			Temperature: &temperature,
+			Middlewares: []middleware.Middleware{cache.Middleware(cache.NewMemoryCache(10), cache.Options{})},
+		},
+	)
+	assert.Nil(suite.T(), err)
+	client.SetClient(suite.client)
+
+	prompt := "Prompt"
+	first, err := client.Generate(&prompt, []dto.Message{})
+	assert.Nil(suite.T(), err)
+	assert.False(suite.T(), first.CacheHit)
+	assert.Equal(suite.T(), 10, first.NewResponses[0].Usage.PromptToken)
+
+	second, err := client.Generate(&prompt, []dto.Message{})
+	assert.Nil(suite.T(), err)
+	assert.True(suite.T(), second.CacheHit)
+	assert.True(suite.T(), second.NewResponses[0].CacheHit)
+	assert.Equal(suite.T(), "Mock Data", second.NewResponses[0].Content)
+	assert.Equal(suite.T(), 0, second.NewResponses[0].Usage.PromptToken)
+	assert.Equal(suite.T(), 0, second.NewResponses[0].Usage.CompletionToken)
+	assert.Equal(suite.T(), 1, httpmock.GetTotalCallCount())
+}
+
+func (suite *GptTestSuite) TestGptWithUpstreamPool() {
+	engine := template.NewMockEngine(suite.ctrl)
+	engine.EXPECT().Render(gomock.Any()).Return("Mock Data", nil).AnyTimes()
+
+	var primaryKey, secondaryKey string
+	httpmock.RegisterResponder("POST", "http://primary:8080", func(request *http.Request) (*http.Response, error) {
+		primaryKey = request.Header.Get("api-key")
+		return httpmock.NewStringResponse(http.StatusServiceUnavailable, "unavailable"), nil
+	})
+	httpmock.RegisterResponder("POST", "http://secondary:8080", func(request *http.Request) (*http.Response, error) {
+		secondaryKey = request.Header.Get("api-key")
+		return httpmock.NewJsonResponse(http.StatusOK, map[string]interface{}{
+			"choices": []map[string]interface{}{
+				{
+					"message": map[string]interface{}{
+						"role":    "assistant",
+						"content": "Mock Data",
+					},
+				},
+			},
+		})
+	})
+
+	pool, err := upstream.NewPool([]upstream.Target{
+		{Name: "primary", Endpoint: "http://primary:8080", ApiKey: "key-1"},
+		{Name: "secondary", Endpoint: "http://secondary:8080", ApiKey: "key-2"},
+	}, upstream.Options{})
+	assert.Nil(suite.T(), err)
+
+	client, err := NewGptClient(
+		Config{
+			Template:    engine,
+			Store:       functions.NewFunctionStore(),
+			Middlewares: []middleware.Middleware{pool.Middleware()},
+		},
+	)
+	assert.Nil(suite.T(), err)
+	client.SetClient(suite.client)
+
+	prompt := "Prompt"
+	response, err := client.Generate(&prompt, []dto.Message{})
+
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), "secondary", response.NewResponses[0].Target)
+	assert.Equal(suite.T(), "key-1", primaryKey)
+	assert.Equal(suite.T(), "key-2", secondaryKey)
+	assert.Equal(suite.T(), 1, pool.Stats()[0].Failures)
+}
+
+func (suite *GptTestSuite) TestGptWithConcurrentConversations() {
+	engine := template.NewMockEngine(suite.ctrl)
+	engine.EXPECT().Render(gomock.Any()).Return("Mock Data", nil).AnyTimes()
+
+	// echo the prompt so that every conversation can check it got its own answer
+	url := "http://localhost:8080"
+	httpmock.RegisterResponder("POST", url, func(request *http.Request) (*http.Response, error) {
+		var body dto.RequestDto
+		if err := json.NewDecoder(request.Body).Decode(&body); err != nil {
+			return nil, err
+		}
+		return httpmock.NewJsonResponse(http.StatusOK, map[string]interface{}{
+			"choices": []map[string]interface{}{
+				{
+					"message": map[string]interface{}{
+						"role":    "assistant",
+						"content": body.Messages[len(body.Messages)-1].Content,
+					},
+				},
+			},
+		})
+	})
+
+	client, err := NewGptClient(
+		Config{
+			Endpoint: url,
+			ApiKey:   "123",
+			Template: engine,
+			Store:    functions.NewFunctionStore(),
+		},
+	)
+	assert.Nil(suite.T(), err)
+	client.SetClient(suite.client)
+
+	// the spare capacity would let an aliasing append overwrite the other conversations' prompts
+	history := make([]dto.Message, 1, 10)
+	history[0] = dto.Message{Role: dto.RoleUser, Content: "Hello"}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			prompt := fmt.Sprintf("Prompt %d", i)
+			response, err := client.Generate(&prompt, history)
+			assert.Nil(suite.T(), err)
+			assert.Equal(suite.T(), prompt, response.FullHistory[1].Content)
+			assert.Equal(suite.T(), prompt, response.NewResponses[0].Content)
+		}()
+		go func() {
+			defer wg.Done()
+			prompt := fmt.Sprintf("Iterator %d", i)
+			for response, err := range client.GenerateIterator(&prompt, history) {
+				assert.Nil(suite.T(), err)
+				assert.Equal(suite.T(), prompt, response.FullHistory[1].Content)
+			}
+		}()
+	}
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			client.SetPlugins(&[]plugin.Interface{plugin.NewStandardOutputPlugin()})
+			client.SetFunctions(&[]functions.FunctionInterface{})
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(suite.T(), dto.Message{}, history[:2][1])
+}
+
+func (suite *GptTestSuite) TestGptWithImagePrompt() {
+	engine := template.NewMockEngine(suite.ctrl)
+	engine.EXPECT().Render(gomock.Any()).Return("Mock Data", nil).AnyTimes()
+
+	var content json.RawMessage
+	url := "http://localhost:8080"
+	httpmock.RegisterResponder("POST", url, func(request *http.Request) (*http.Response, error) {
+		var body struct {
+			Messages []struct {
+				Content json.RawMessage `json:"content"`
+			} `json:"messages"`
+		}
+		if err := json.NewDecoder(request.Body).Decode(&body); err != nil {
+			return nil, err
+		}
+		// the prompt follows the system message
+		content = body.Messages[1].Content
+		return httpmock.NewJsonResponse(http.StatusOK, map[string]interface{}{
+			"choices": []map[string]interface{}{
+				{
+					"message": map[string]interface{}{
+						"role":    "assistant",
+						"content": "A cat",
+					},
+				},
+			},
+		})
+	})
+
+	client, err := NewGptClient(
+		Config{
+			Endpoint: url,
+			ApiKey:   "123",
+			Template: engine,
+			Store:    functions.NewFunctionStore(),
+		},
+	)
+	assert.Nil(suite.T(), err)
+	client.SetClient(suite.client)
+
+	image := dto.ImageUrlPart("https://example.com/cat.png", dto.ImageDetailLow)
+	image.ImageUrl.Width, image.ImageUrl.Height = 40, 30
+	prompt := []dto.ContentPart{
+		dto.TextPart("What is in the image?"),
+		image,
+	}
+	response, err := client.Generate(prompt, []dto.Message{})
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), "A cat", response.NewResponses[0].Content)
+	assert.Equal(suite.T(), prompt, response.FullHistory[0].Parts)
+	// the size of the image is only sent to the API when it is known by the client
+	assert.JSONEq(
+		suite.T(),
+		`[{"type":"text","text":"What is in the image?"},{"type":"image_url","image_url":{"url":"https://example.com/cat.png","detail":"low"}}]`,
+		string(content),
+	)
+
+	// the saved history keeps the size of the image
+	saved, err := json.Marshal(response.FullHistory)
+	assert.Nil(suite.T(), err)
+	var history []dto.Message
+	assert.Nil(suite.T(), json.Unmarshal(saved, &history))
+	assert.Equal(suite.T(), prompt, history[0].Parts)
+	content = nil
+	_, err = client.Generate("And now?", history)
+	assert.Nil(suite.T(), err)
+	assert.Contains(suite.T(), string(content), "cat.png")
+	assert.NotContains(suite.T(), string(content), "width")
+	assert.Equal(suite.T(), 40, history[0].Parts[1].ImageUrl.Width)
+
+	_, err = client.Generate(42, []dto.Message{})
+	assert.ErrorContains(suite.T(), err, "last plugin should return a string or content parts")
+}
+
 func (suite *GptTestSuite) TestSetPlugin() {
 	client := &Client{}
 	client.SetPlugins(&[]plugin.Interface{plugin.NewStandardOutputPlugin()})
@@ -631,3 +1147,67 @@ func (suite *GptTestSuite) TestSetPlugin() {
 func TestUserRepositoryTestSuite(t *testing.T) {
 	suite.Run(t, new(GptTestSuite))
 }
+
+func (suite *GptTestSuite) TestGptWithFunctionErrorReportedToModel() {
+	engine := template.NewMockEngine(suite.ctrl)
+	engine.EXPECT().Render(gomock.Any()).Return("Mock Data", nil).AnyTimes()
+
+	toolResponse, err := httpmock.NewJsonResponse(http.StatusOK, map[string]interface{}{
+		"choices": []map[string]interface{}{
+			{
+				"message": map[string]interface{}{
+					"role": "assistant",
+					"tool_calls": []map[string]interface{}{
+						{
+							"id":   "1",
+							"type": "function",
+							"function": map[string]interface{}{
+								"name":      "Mock Function",
+								"arguments": `{}`,
+							},
+						},
+					},
+				},
+			},
+		},
+	})
+	assert.Nil(suite.T(), err)
+	assistantResponse, err := httpmock.NewJsonResponse(http.StatusOK, map[string]interface{}{
+		"choices": []map[string]interface{}{
+			{"message": map[string]interface{}{"role": "assistant", "content": "Sorry"}},
+		},
+	})
+	assert.Nil(suite.T(), err)
+	url := "http://localhost:8080"
+	httpmock.RegisterResponder("POST", url, httpmock.ResponderFromMultipleResponses([]*http.Response{toolResponse, assistantResponse}))
+
+	function := functions.NewMockFunctionInterface(suite.ctrl)
+	function.EXPECT().OnMessage(gomock.Any()).DoAndReturn(func(arguments map[string]interface{}) (*functions.FunctionGptResponse, error) {
+		// the assertion panics like a function reading an argument the model did not send
+		return &functions.FunctionGptResponse{Content: arguments["dish"].(string)}, nil
+	}).Times(1)
+	function.EXPECT().Name().Return("Mock Function").AnyTimes()
+	function.EXPECT().Description().Return("Mock Function Description").AnyTimes()
+	function.EXPECT().Parameters().Return(map[string]interface{}{}).AnyTimes()
+	function.EXPECT().SetStore(gomock.Any()).Times(1)
+	function.EXPECT().Config().Return(functions.FunctionConfig{OnError: functions.ReportToModel}).Times(1)
+	function.EXPECT().OnInit().Times(1)
+
+	client, err := NewGptClient(Config{
+		Endpoint:  url,
+		ApiKey:    "123",
+		Functions: &[]functions.FunctionInterface{function},
+		Template:  engine,
+		Store:     functions.NewFunctionStore(),
+	})
+	assert.Nil(suite.T(), err)
+	client.SetClient(suite.client)
+
+	prompt := "Prompt"
+	response, err := client.Generate(&prompt, []dto.Message{})
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), 4, len(response.FullHistory))
+	assert.Equal(suite.T(), dto.RoleTool, response.FullHistory[2].Role)
+	assert.Contains(suite.T(), response.FullHistory[2].Content, "The function failed: function Mock Function panicked: interface conversion")
+	assert.Equal(suite.T(), "Sorry", response.FullHistory[3].Content)
+}
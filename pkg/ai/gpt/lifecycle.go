@@ -0,0 +1,163 @@
+package gpt
+
+import (
+	"errors"
+	"fmt"
+	"github.com/meta-metopia/go-packages/pkg/ai/gpt/functions"
+	"github.com/meta-metopia/go-packages/pkg/ai/gpt/plugin"
+	"reflect"
+	"slices"
+	"sync"
+)
+
+// ErrClientClosed is returned by the calls started after Close.
+var ErrClientClosed = errors.New("gpt client is closed")
+
+// ErrFunctionShared is returned when a function instance is already used by another client with another store.
+var ErrFunctionShared = errors.New("function is used by another client with another store")
+
+// binding is the store a function instance is set with, and the number of clients using it.
+type binding struct {
+	store   *functions.FunctionStore
+	clients int
+}
+
+// bindings are the stores of the functions used by the clients not closed yet.
+// A function keeps the store it is set with, so an instance used by two clients with different stores
+// would mix the states of their conversations.
+var bindings = struct {
+	sync.Mutex
+	stores map[functions.FunctionInterface]binding
+}{stores: map[functions.FunctionInterface]binding{}}
+
+// bindStore records that the function uses the store, failing when another client uses it with another store.
+// The functions of a type that cannot be compared are not recorded.
+func bindStore(function functions.FunctionInterface, store *functions.FunctionStore) error {
+	if !reflect.TypeOf(function).Comparable() {
+		return nil
+	}
+	bindings.Lock()
+	defer bindings.Unlock()
+	bound, found := bindings.stores[function]
+	if found && bound.store != store {
+		return fmt.Errorf("function %s: %w", function.Name(), ErrFunctionShared)
+	}
+	bindings.stores[function] = binding{store: store, clients: bound.clients + 1}
+	return nil
+}
+
+// releaseStore records that a client no longer uses the function.
+func releaseStore(function functions.FunctionInterface) {
+	if !reflect.TypeOf(function).Comparable() {
+		return
+	}
+	bindings.Lock()
+	defer bindings.Unlock()
+	bound, found := bindings.stores[function]
+	if !found {
+		return
+	}
+	if bound.clients <= 1 {
+		delete(bindings.stores, function)
+		return
+	}
+	bound.clients--
+	bindings.stores[function] = bound
+}
+
+// Close calls OnClose on the functions in the reverse order they were initialized,
+// then on the plugins implementing plugin.ClosingPlugin in the reverse order.
+// It should be called once the calls returned, the calls started afterwards fail with ErrClientClosed.
+// Closing the client again does nothing.
+func (g *Client) Close() error {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	if g.closed {
+		return nil
+	}
+	g.closed = true
+
+	err := g.closeFunctions()
+	plugins := *g.config.Plugins
+	for index := len(plugins) - 1; index >= 0; index-- {
+		closingPlugin, ok := plugins[index].(plugin.ClosingPlugin)
+		if !ok {
+			continue
+		}
+		if closeErr := closingPlugin.OnClose(); closeErr != nil {
+			err = errors.Join(err, fmt.Errorf("failed to close plugin %s: %w", plugins[index].Name(), closeErr))
+		}
+	}
+	return err
+}
+
+// closeFunctions calls OnClose on the initialized functions in the reverse order they were initialized.
+func (g *Client) closeFunctions() error {
+	var errs []error
+	for index := len(g.initialized) - 1; index >= 0; index-- {
+		function := g.initialized[index]
+		releaseStore(function)
+		if err := function.OnClose(); err != nil {
+			errs = append(errs, fmt.Errorf("failed to close function %s: %w", function.Name(), err))
+		}
+	}
+	g.initialized = nil
+	return errors.Join(errs...)
+}
+
+// syncFunctions closes the initialized functions removed from the configuration, then initializes the new ones in order.
+// The functions failing to initialize are tried again by the next call, and their errors are returned together.
+// The functions used by another client with another store fail with ErrFunctionShared.
+func (g *Client) syncFunctions() error {
+	current := *g.config.Functions
+	var kept []functions.FunctionInterface
+	for _, function := range g.initialized {
+		if containsFunction(current, function) {
+			kept = append(kept, function)
+		}
+	}
+	for index := len(g.initialized) - 1; index >= 0; index-- {
+		function := g.initialized[index]
+		if containsFunction(kept, function) {
+			continue
+		}
+		// the removed functions are no longer used, so their errors do not fail the call
+		releaseStore(function)
+		if err := function.OnClose(); err != nil {
+			g.config.Logger.Error("failed to close function", logKeyToolName, function.Name(), logKeyError, err)
+		}
+	}
+
+	var errs []error
+	for _, function := range current {
+		if containsFunction(kept, function) {
+			continue
+		}
+		if err := bindStore(function, g.config.Store); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if err := function.OnInit(); err != nil {
+			releaseStore(function)
+			errs = append(errs, fmt.Errorf("failed to initialize function %s: %w", function.Name(), err))
+			continue
+		}
+		function.SetStore(g.config.Store)
+		kept = append(kept, function)
+	}
+
+	g.initialized = kept
+	g.dirty = len(errs) > 0
+	return errors.Join(errs...)
+}
+
+// containsFunction reports whether the function is in the list.
+// The functions of a type that cannot be compared are never found, so they are closed and initialized again.
+func containsFunction(list []functions.FunctionInterface, function functions.FunctionInterface) bool {
+	if !reflect.TypeOf(function).Comparable() {
+		return false
+	}
+	return slices.ContainsFunc(list, func(item functions.FunctionInterface) bool {
+		return reflect.TypeOf(item) == reflect.TypeOf(function) && item == function
+	})
+}
@@ -0,0 +1,192 @@
+package gpt
+
+import (
+	"fmt"
+	"github.com/jarcoal/httpmock"
+	template "github.com/meta-metopia/go-packages/pkg/ai"
+	"github.com/meta-metopia/go-packages/pkg/ai/gpt/functions"
+	"github.com/meta-metopia/go-packages/pkg/ai/gpt/plugin"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	"net/http"
+)
+
+// closingPlugin records when it is closed.
+type closingPlugin struct {
+	recordingPlugin
+	err error
+}
+
+func (c *closingPlugin) OnClose() error {
+	*c.calls = append(*c.calls, c.name+":close")
+	return c.err
+}
+
+// lifecycleFunction returns a function recording its life cycle in [calls].
+// OnInit returns the error [initErr] points to when it is called.
+func (suite *GptTestSuite) lifecycleFunction(name string, calls *[]string, initErr *error, closeErr error) *functions.MockFunctionInterface {
+	function := functions.NewMockFunctionInterface(suite.ctrl)
+	function.EXPECT().Name().Return(name).AnyTimes()
+	function.EXPECT().Description().Return(name).AnyTimes()
+	function.EXPECT().Parameters().Return(map[string]interface{}{}).AnyTimes()
+	function.EXPECT().OnInit().DoAndReturn(func() error {
+		*calls = append(*calls, name+":init")
+		if initErr == nil {
+			return nil
+		}
+		return *initErr
+	}).AnyTimes()
+	function.EXPECT().SetStore(gomock.Any()).Do(func(*functions.FunctionStore) {
+		*calls = append(*calls, name+":store")
+	}).AnyTimes()
+	function.EXPECT().OnClose().DoAndReturn(func() error {
+		*calls = append(*calls, name+":close")
+		return closeErr
+	}).AnyTimes()
+	return function
+}
+
+func (suite *GptTestSuite) newLifecycleClient(aiFunctions []functions.FunctionInterface, plugins []plugin.Interface) (IGptClient, error) {
+	engine := template.NewMockEngine(suite.ctrl)
+	engine.EXPECT().Render(gomock.Any()).Return("Mock Data", nil).AnyTimes()
+	responder, err := httpmock.NewJsonResponder(http.StatusOK, map[string]interface{}{
+		"choices": []map[string]interface{}{
+			{"message": map[string]interface{}{"role": "assistant", "content": "Mock Data"}},
+		},
+	})
+	assert.Nil(suite.T(), err)
+	httpmock.RegisterResponder("POST", "http://localhost:8080", responder)
+
+	client, err := NewGptClient(Config{
+		Endpoint:  "http://localhost:8080",
+		ApiKey:    "123",
+		Functions: &aiFunctions,
+		Plugins:   &plugins,
+		Template:  engine,
+		Store:     functions.NewFunctionStore(),
+	})
+	if client != nil {
+		client.SetClient(suite.client)
+	}
+	return client, err
+}
+
+func (suite *GptTestSuite) TestGptClose() {
+	var calls []string
+	client, err := suite.newLifecycleClient(
+		[]functions.FunctionInterface{
+			suite.lifecycleFunction("first", &calls, nil, fmt.Errorf("connection lost")),
+			suite.lifecycleFunction("second", &calls, nil, nil),
+		},
+		[]plugin.Interface{
+			&closingPlugin{recordingPlugin: recordingPlugin{name: "closing", calls: &calls}},
+			plugin.NewStandardOutputPlugin(),
+		},
+	)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), []string{"first:init", "first:store", "second:init", "second:store"}, calls)
+
+	calls = nil
+	err = client.Close()
+	assert.EqualError(suite.T(), err, "failed to close function first: connection lost")
+	assert.Equal(suite.T(), []string{"second:close", "first:close", "closing:close"}, calls)
+
+	calls = nil
+	assert.Nil(suite.T(), client.Close())
+	assert.Empty(suite.T(), calls)
+
+	prompt := "Prompt"
+	_, err = client.Generate(&prompt, nil)
+	assert.ErrorIs(suite.T(), err, ErrClientClosed)
+	for _, err = range client.GenerateIterator(&prompt, nil) {
+	}
+	assert.ErrorIs(suite.T(), err, ErrClientClosed)
+}
+
+func (suite *GptTestSuite) TestGptWithFunctionsFailingToInitialize() {
+	var calls []string
+	databaseErr := fmt.Errorf("no database")
+	apiKeyErr := fmt.Errorf("no api key")
+	client, err := suite.newLifecycleClient(
+		[]functions.FunctionInterface{
+			suite.lifecycleFunction("first", &calls, &databaseErr, nil),
+			suite.lifecycleFunction("second", &calls, nil, nil),
+			suite.lifecycleFunction("third", &calls, &apiKeyErr, nil),
+		},
+		nil,
+	)
+	assert.Nil(suite.T(), client)
+	assert.EqualError(suite.T(), err, "failed to initialize function first: no database\nfailed to initialize function third: no api key")
+	// the initialized functions are closed, as the client is never returned
+	assert.Equal(suite.T(), []string{"first:init", "second:init", "second:store", "third:init", "second:close"}, calls)
+}
+
+func (suite *GptTestSuite) TestGptSetFunctions() {
+	var calls []string
+	first := suite.lifecycleFunction("first", &calls, nil, nil)
+	second := suite.lifecycleFunction("second", &calls, nil, nil)
+	client, err := suite.newLifecycleClient([]functions.FunctionInterface{first, second}, nil)
+	assert.Nil(suite.T(), err)
+
+	// the functions are swapped when the next call starts
+	calls = nil
+	third := suite.lifecycleFunction("third", &calls, nil, nil)
+	client.SetFunctions(&[]functions.FunctionInterface{second, third})
+	assert.Empty(suite.T(), calls)
+
+	prompt := "Prompt"
+	_, err = client.Generate(&prompt, nil)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), []string{"first:close", "third:init", "third:store"}, calls)
+
+	calls = nil
+	_, err = client.Generate(&prompt, nil)
+	assert.Nil(suite.T(), err)
+	assert.Empty(suite.T(), calls)
+
+	// a function failing to initialize fails the calls until it succeeds
+	initErr := fmt.Errorf("no database")
+	fourth := suite.lifecycleFunction("fourth", &calls, &initErr, nil)
+	client.SetFunctions(&[]functions.FunctionInterface{second, third, fourth})
+	calls = nil
+	_, err = client.Generate(&prompt, nil)
+	assert.EqualError(suite.T(), err, "failed to initialize function fourth: no database")
+
+	initErr = nil
+	_, err = client.Generate(&prompt, nil)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), []string{"fourth:init", "fourth:init", "fourth:store"}, calls)
+
+	calls = nil
+	assert.Nil(suite.T(), client.Close())
+	assert.Equal(suite.T(), []string{"fourth:close", "third:close", "second:close"}, calls)
+}
+
+func (suite *GptTestSuite) TestGptWithSharedFunctions() {
+	var calls []string
+	function := suite.lifecycleFunction("shared", &calls, nil, nil)
+	client, err := suite.newLifecycleClient([]functions.FunctionInterface{function}, nil)
+	assert.Nil(suite.T(), err)
+
+	// the function keeps the store of the first client, so the second one would mix the conversations
+	calls = nil
+	other, err := suite.newLifecycleClient([]functions.FunctionInterface{function}, nil)
+	assert.Nil(suite.T(), other)
+	assert.ErrorIs(suite.T(), err, ErrFunctionShared)
+	assert.Empty(suite.T(), calls)
+
+	// clients with the same store can share it
+	store := functions.NewFunctionStore()
+	shared := suite.lifecycleFunction("same store", &calls, nil, nil)
+	for range 2 {
+		sameStore, err := NewGptClient(Config{Functions: &[]functions.FunctionInterface{shared}, Store: store})
+		assert.Nil(suite.T(), err)
+		defer sameStore.Close()
+	}
+
+	// the function is released once the client is closed
+	assert.Nil(suite.T(), client.Close())
+	other, err = suite.newLifecycleClient([]functions.FunctionInterface{function}, nil)
+	assert.Nil(suite.T(), err)
+	assert.Nil(suite.T(), other.Close())
+}
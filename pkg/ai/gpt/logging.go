@@ -0,0 +1,51 @@
+package gpt
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+)
+
+const (
+	logKeyConversationId = "conversation_id"
+	logKeyToolName       = "tool_name"
+	logKeyToolCallId     = "tool_call_id"
+	logKeyContent        = "content"
+	logKeyError          = "error"
+	logKeyApprovalAction = "approval_action"
+)
+
+type conversationIdKey struct{}
+
+// Redactor redacts message content before it is written to the logs.
+type Redactor func(content string) string
+
+// RedactAll replaces the content with its length. This is the default Redactor.
+func RedactAll(content string) string {
+	return fmt.Sprintf("[redacted %d bytes]", len(content))
+}
+
+// RedactNone logs the content as is. Only use it when the content is known to be free of PII.
+func RedactNone(content string) string {
+	return content
+}
+
+// WithConversationId returns a copy of ctx carrying the conversation id,
+// which is added to every log entry written while generating with the context.
+func WithConversationId(ctx context.Context, conversationId string) context.Context {
+	return context.WithValue(ctx, conversationIdKey{}, conversationId)
+}
+
+// ConversationIdFromContext returns the conversation id carried by ctx.
+func ConversationIdFromContext(ctx context.Context) (string, bool) {
+	conversationId, ok := ctx.Value(conversationIdKey{}).(string)
+	return conversationId, ok
+}
+
+// log returns the logger of the client with the fields carried by ctx.
+func (g *Client) log(ctx context.Context) *slog.Logger {
+	if conversationId, ok := ConversationIdFromContext(ctx); ok {
+		return g.config.Logger.With(logKeyConversationId, conversationId)
+	}
+	return g.config.Logger
+}
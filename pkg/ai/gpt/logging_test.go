@@ -0,0 +1,47 @@
+package gpt
+
+import (
+	"context"
+	"testing"
+)
+
+func TestRedactors(t *testing.T) {
+	tests := []struct {
+		name     string
+		redactor Redactor
+		content  string
+		want     string
+	}{
+		{
+			name:     "Test with RedactAll",
+			redactor: RedactAll,
+			content:  "my phone is 0912345678",
+			want:     "[redacted 22 bytes]",
+		},
+		{
+			name:     "Test with RedactNone",
+			redactor: RedactNone,
+			content:  "my phone is 0912345678",
+			want:     "my phone is 0912345678",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.redactor(tt.content); got != tt.want {
+				t.Errorf("Redactor() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConversationIdFromContext(t *testing.T) {
+	if _, ok := ConversationIdFromContext(context.Background()); ok {
+		t.Errorf("ConversationIdFromContext() ok = true, want false")
+	}
+
+	got, ok := ConversationIdFromContext(WithConversationId(context.Background(), "conversation-1"))
+	if !ok || got != "conversation-1" {
+		t.Errorf("ConversationIdFromContext() = %v, %v, want conversation-1, true", got, ok)
+	}
+}
@@ -0,0 +1,90 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"github.com/meta-metopia/go-packages/pkg/ai/gpt/dto"
+	"net/http"
+)
+
+// Request is the completion request that flows through the middleware chain.
+type Request struct {
+	// Endpoint is the url the request will be posted to.
+	Endpoint string
+	// ApiKey is the key used to authenticate the request.
+	ApiKey string
+	// Header contains the extra headers sent along with the request.
+	Header http.Header
+	// Body is the request body sent to the GPT API.
+	Body *dto.RequestDto
+	// OnDelta receives the content deltas of the answer when Body.Stream is true.
+	// The response is still returned whole once the stream ends.
+	OnDelta func(delta string)
+}
+
+// Response is the completion response that flows back through the middleware chain.
+type Response struct {
+	StatusCode int
+	Header     http.Header
+	Body       *dto.ResponseDto
+	// CacheHit is true when the response was served from a cache instead of the GPT API.
+	CacheHit bool
+	// Target is the name of the upstream target that served the response, if any.
+	Target string
+}
+
+// RoundTrip sends a completion request and returns its response.
+type RoundTrip func(ctx context.Context, request *Request) (*Response, error)
+
+// Middleware wraps a RoundTrip. It can inspect or modify the request before calling next,
+// and inspect or modify the response after next returns.
+type Middleware func(next RoundTrip) RoundTrip
+
+// StatusError is returned when the GPT API responds with a non-successful status code.
+type StatusError struct {
+	StatusCode int
+	Header     http.Header
+	Body       string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("failed to generate response: %d %s", e.StatusCode, e.Body)
+}
+
+// StreamError is returned when a streamed response fails after some deltas were received.
+// It is never retried, since the deltas already received cannot be taken back.
+type StreamError struct {
+	Err error
+}
+
+func (e *StreamError) Error() string {
+	return fmt.Sprintf("failed to read streamed response: %v", e.Err)
+}
+
+func (e *StreamError) Unwrap() error {
+	return e.Err
+}
+
+// Chain composes the middlewares into a single middleware.
+// The first middleware is the outermost one: it sees the request first and the response last.
+func Chain(middlewares ...Middleware) Middleware {
+	return func(next RoundTrip) RoundTrip {
+		for i := len(middlewares) - 1; i >= 0; i-- {
+			next = middlewares[i](next)
+		}
+		return next
+	}
+}
+
+// WithHeader returns a middleware that sets the header on every request.
+func WithHeader(key string, value string) Middleware {
+	return func(next RoundTrip) RoundTrip {
+		return func(ctx context.Context, request *Request) (*Response, error) {
+			if request.Header == nil {
+				request.Header = http.Header{}
+			}
+			request.Header.Set(key, value)
+			return next(ctx, request)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package middleware
+
+import (
+	"context"
+	"github.com/meta-metopia/go-packages/pkg/ai/gpt/dto"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+)
+
+func recordingMiddleware(name string, calls *[]string) Middleware {
+	return func(next RoundTrip) RoundTrip {
+		return func(ctx context.Context, request *Request) (*Response, error) {
+			*calls = append(*calls, "before "+name)
+			response, err := next(ctx, request)
+			*calls = append(*calls, "after "+name)
+			return response, err
+		}
+	}
+}
+
+func TestChain(t *testing.T) {
+	var calls []string
+	transport := func(ctx context.Context, request *Request) (*Response, error) {
+		calls = append(calls, "transport")
+		return &Response{StatusCode: http.StatusOK, Body: &dto.ResponseDto{}}, nil
+	}
+
+	roundTrip := Chain(
+		recordingMiddleware("first", &calls),
+		recordingMiddleware("second", &calls),
+		recordingMiddleware("third", &calls),
+	)(transport)
+
+	response, err := roundTrip(context.Background(), &Request{})
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	assert.Equal(t, []string{
+		"before first",
+		"before second",
+		"before third",
+		"transport",
+		"after third",
+		"after second",
+		"after first",
+	}, calls)
+}
+
+func TestChainWithoutMiddlewares(t *testing.T) {
+	called := false
+	transport := func(ctx context.Context, request *Request) (*Response, error) {
+		called = true
+		return &Response{}, nil
+	}
+
+	_, err := Chain()(transport)(context.Background(), &Request{})
+	assert.Nil(t, err)
+	assert.True(t, called)
+}
+
+func TestWithHeader(t *testing.T) {
+	tests := []struct {
+		name    string
+		request *Request
+	}{
+		{
+			name:    "Test with nil header",
+			request: &Request{},
+		},
+		{
+			name:    "Test with existing header",
+			request: &Request{Header: http.Header{"X-Existing": []string{"1"}}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var header http.Header
+			transport := func(ctx context.Context, request *Request) (*Response, error) {
+				header = request.Header
+				return &Response{}, nil
+			}
+
+			_, err := WithHeader("X-Tenant", "tenant")(transport)(context.Background(), tt.request)
+			assert.Nil(t, err)
+			assert.Equal(t, "tenant", header.Get("X-Tenant"))
+		})
+	}
+}
+
+func TestStatusError(t *testing.T) {
+	err := &StatusError{StatusCode: http.StatusTooManyRequests, Body: "rate limited"}
+	assert.Equal(t, "failed to generate response: 429 rate limited", err.Error())
+}
@@ -0,0 +1,184 @@
+package gpt
+
+import (
+	"context"
+	"github.com/meta-metopia/go-packages/pkg/ai/gpt/dto"
+	"github.com/meta-metopia/go-packages/pkg/ai/gpt/plugin"
+	"github.com/meta-metopia/go-packages/pkg/ai/gpt/telemetry"
+)
+
+// turnMessage is a message of the turn once the plugins converted it.
+type turnMessage struct {
+	// response is the message returned to the caller.
+	response dto.Message
+	// history is the message sent to the model and kept in the history. It is nil when the message is only returned to the caller.
+	history *dto.Message
+	// pending is set instead of the messages when the turn stops before calling a function requiring an approval.
+	pending *PendingApproval
+	// terminated is set when a plugin ended the conversion of the message with plugin.TerminateOutputAction, such as a refusal.
+	terminated bool
+}
+
+// converter returns the conversion of the plugin for the stage, or nil when the plugin does not convert it.
+func converter(foundPlugin plugin.Interface, stage plugin.Stage) func(message dto.Message) (*plugin.ConvertedResponse, error) {
+	switch stage {
+	case plugin.OutputStage:
+		return foundPlugin.ConvertOutput
+	case plugin.ToolResultStage:
+		if toolPlugin, ok := foundPlugin.(plugin.ToolResultPlugin); ok {
+			return toolPlugin.ConvertToolResult
+		}
+	}
+	return nil
+}
+
+// passesOutputThrough returns true when every plugin leaves the answers unchanged, so they can be streamed before they are converted.
+func (g *Client) passesOutputThrough() bool {
+	for _, foundPlugin := range *g.config.Plugins {
+		passthrough, ok := foundPlugin.(plugin.PassthroughPlugin)
+		if !ok || !passthrough.PassesOutputThrough() {
+			return false
+		}
+	}
+	return true
+}
+
+// streamOutputs passes the converted answers of the assistant to [onDelta], leaving out the ones excluded from the responses.
+func streamOutputs(outputs []turnMessage, onDelta func(delta string)) {
+	for _, output := range outputs {
+		message := output.response
+		if message.Role != dto.RoleAssistant || len(message.Content) == 0 || message.Config.ExcludeFromHistory {
+			continue
+		}
+		onDelta(message.Content)
+	}
+}
+
+// usePluginForInput uses the plugin for the input.
+// It returns the user message built from the output of the last plugin.
+func (g *Client) usePluginForInput(ctx context.Context, input any) (*dto.Message, error) {
+	output := input
+	for _, foundPlugin := range *g.config.Plugins {
+		_, span := g.config.Telemetry.StartPlugin(ctx, foundPlugin.Name(), string(plugin.InputStage))
+		convertedOutput, err := foundPlugin.ConvertInput(output)
+		telemetry.End(span, err)
+		if err != nil {
+			return nil, err
+		}
+
+		if convertedOutput != nil {
+			output = convertedOutput
+		}
+	}
+
+	return newUserMessage(output)
+}
+
+// usePluginForOutput uses the plugins for a message of the assistant.
+// It returns the message followed by the ones appended by the plugins.
+func (g *Client) usePluginForOutput(ctx context.Context, message dto.Message) ([]turnMessage, error) {
+	return g.convert(ctx, plugin.OutputStage, message, 0)
+}
+
+// usePluginForToolCall uses the plugins for the arguments of a function call.
+func (g *Client) usePluginForToolCall(ctx context.Context, name string, arguments map[string]any) (map[string]any, error) {
+	for _, foundPlugin := range *g.config.Plugins {
+		toolPlugin, ok := foundPlugin.(plugin.ToolCallPlugin)
+		if !ok {
+			continue
+		}
+		_, span := g.config.Telemetry.StartPlugin(ctx, foundPlugin.Name(), string(plugin.ToolCallStage))
+		convertedArguments, err := toolPlugin.ConvertToolCall(name, arguments)
+		telemetry.End(span, err)
+		if err != nil {
+			return nil, err
+		}
+
+		if convertedArguments != nil {
+			arguments = convertedArguments
+		}
+	}
+	return arguments, nil
+}
+
+// usePluginForToolResult uses the plugins for the result of a function.
+// It returns the result followed by the messages appended by the plugins.
+func (g *Client) usePluginForToolResult(ctx context.Context, result dto.Message) ([]turnMessage, error) {
+	outputs, err := g.convert(ctx, plugin.ToolResultStage, result, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	// the result must still answer the tool call
+	for _, converted := range []*dto.Message{&outputs[0].response, outputs[0].history} {
+		if converted != nil {
+			converted.Role = result.Role
+			converted.ToolCallId = result.ToolCallId
+		}
+	}
+	return outputs, nil
+}
+
+// convert calls the plugins from the index [start] on the message for the stage.
+// The message converted by the plugins comes first, followed by the messages appended by the plugins in their order.
+func (g *Client) convert(ctx context.Context, stage plugin.Stage, message dto.Message, start int) ([]turnMessage, error) {
+	plugins := *g.config.Plugins
+	history := message
+	var appended []turnMessage
+
+	for index := start; index < len(plugins); index++ {
+		foundPlugin := plugins[index]
+		convert := converter(foundPlugin, stage)
+		if convert == nil {
+			continue
+		}
+
+		_, span := g.config.Telemetry.StartPlugin(ctx, foundPlugin.Name(), string(stage))
+		convertedResponse, err := convert(message)
+		telemetry.End(span, err)
+		if err != nil {
+			return nil, err
+		}
+		if convertedResponse == nil {
+			continue
+		}
+
+		if convertedResponse.Message != nil {
+			if convertedResponse.Action == plugin.AppendOutputAction {
+				outputs, err := g.convert(ctx, stage, *convertedResponse.Message, index+1)
+				if err != nil {
+					return nil, err
+				}
+				if !convertedResponse.AddToHistory {
+					for outputIndex := range outputs {
+						outputs[outputIndex].history = nil
+					}
+				}
+				appended = append(appended, outputs...)
+				continue
+			}
+
+			message = *convertedResponse.Message
+			if convertedResponse.AddToHistory {
+				history = message
+			}
+		}
+		if convertedResponse.Action == plugin.TerminateOutputAction {
+			return append([]turnMessage{{response: message, history: &history, terminated: true}}, appended...), nil
+		}
+	}
+
+	return append([]turnMessage{{response: message, history: &history}}, appended...), nil
+}
+
+// appendOutput adds the message of the turn to the responses returned to the caller and to the history.
+// The messages excluded from the history are left out of the responses too.
+func appendOutput(responses []dto.Message, history []dto.Message, message turnMessage) ([]dto.Message, []dto.Message) {
+	if !message.response.Config.ExcludeFromHistory {
+		responses = append(responses, message.response)
+	}
+	if message.history != nil && !message.history.Config.ExcludeFromHistory {
+		history = append(history, *message.history)
+	}
+	return responses, history
+}
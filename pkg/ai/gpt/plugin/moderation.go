@@ -0,0 +1,126 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/meta-metopia/go-packages/pkg/ai/gpt/dto"
+	"github.com/meta-metopia/go-packages/pkg/ai/moderation"
+	"strings"
+)
+
+// ModerationError is returned when the moderation flags a message.
+type ModerationError struct {
+	// Stage is either InputStage or OutputStage.
+	Stage      Stage
+	Categories []string
+}
+
+func (m *ModerationError) Error() string {
+	return fmt.Sprintf("%s flagged by moderation: %s", m.Stage, strings.Join(m.Categories, ", "))
+}
+
+type ModerationOptions struct {
+	// Refusal is the answer replacing the flagged outputs. When it is empty, a *ModerationError is returned instead.
+	// The flagged inputs always return a *ModerationError, since the LLM Model should not receive them.
+	Refusal string
+	// SkipInput disables the moderation of the inputs.
+	SkipInput bool
+	// SkipOutput disables the moderation of the outputs.
+	SkipOutput bool
+}
+
+// ModerationPlugin blocks the unsafe inputs and outputs flagged by its moderators.
+type ModerationPlugin struct {
+	moderators []moderation.Moderator
+	options    ModerationOptions
+}
+
+func (m *ModerationPlugin) Name() string {
+	return "moderation"
+}
+
+func (m *ModerationPlugin) Description() string {
+	return "Blocks the unsafe inputs and outputs."
+}
+
+// ConvertInput returns a *ModerationError when the text of the input is flagged, and keeps the input otherwise.
+func (m *ModerationPlugin) ConvertInput(input any) (any, error) {
+	if m.options.SkipInput {
+		return nil, nil
+	}
+
+	if err := m.moderate(InputStage, inputText(input)); err != nil {
+		return nil, err
+	}
+	return nil, nil
+}
+
+// ConvertOutput replaces the flagged answers of the assistant with the refusal, and skips the next plugins.
+func (m *ModerationPlugin) ConvertOutput(response dto.Message) (*ConvertedResponse, error) {
+	if m.options.SkipOutput || response.Role != dto.RoleAssistant {
+		return nil, nil
+	}
+
+	err := m.moderate(OutputStage, response.Text())
+	var moderationError *ModerationError
+	if !errors.As(err, &moderationError) || len(m.options.Refusal) == 0 {
+		return nil, err
+	}
+
+	// the refusal is kept in the history, so the unsafe answer is never sent back to the LLM Model.
+	// It keeps the tool calls of the answer, which are answered as refused without calling the functions.
+	response.Content = m.options.Refusal
+	response.Parts = nil
+	return &ConvertedResponse{
+		Action:       TerminateOutputAction,
+		Message:      &response,
+		AddToHistory: true,
+	}, nil
+}
+
+// PassesOutputThrough returns true when the outputs are not moderated.
+func (m *ModerationPlugin) PassesOutputThrough() bool {
+	return m.options.SkipOutput
+}
+
+// moderate returns a *ModerationError when the text is flagged.
+func (m *ModerationPlugin) moderate(stage Stage, text string) error {
+	result, err := moderation.Moderate(context.Background(), text, m.moderators...)
+	if err != nil {
+		return err
+	}
+	if result.Flagged {
+		return &ModerationError{
+			Stage:      stage,
+			Categories: result.Categories,
+		}
+	}
+	return nil
+}
+
+// inputText returns the text of the input, including the text of its content parts.
+func inputText(input any) string {
+	switch value := input.(type) {
+	case string:
+		return value
+	case *string:
+		if value != nil {
+			return *value
+		}
+	case dto.ContentPart:
+		return value.Text
+	case []dto.ContentPart:
+		return dto.Message{Parts: value}.Text()
+	}
+	return ""
+}
+
+// NewModerationPlugin returns a new instance of the ModerationPlugin
+// checking the inputs and outputs with the moderators in order, such as local rules before the OpenAI moderation endpoint.
+func NewModerationPlugin(options ModerationOptions, moderators ...moderation.Moderator) Interface {
+	return &ModerationPlugin{
+		moderators: moderators,
+		options:    options,
+	}
+}
@@ -0,0 +1,146 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/meta-metopia/go-packages/pkg/ai/gpt/dto"
+	"github.com/meta-metopia/go-packages/pkg/ai/moderation"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+type failingModerator struct{}
+
+func (f failingModerator) Moderate(context.Context, string) (moderation.Result, error) {
+	return moderation.Result{}, fmt.Errorf("moderation unavailable")
+}
+
+func TestModerationPlugin_ConvertInput(t *testing.T) {
+	rules := moderation.NewRuleModerator(moderation.KeywordRule("weapon", "gun"))
+	tests := []struct {
+		name       string
+		options    ModerationOptions
+		moderators []moderation.Moderator
+		input      any
+		wantErr    string
+	}{
+		{
+			name:       "Test with safe input",
+			moderators: []moderation.Moderator{rules},
+			input:      "一份炒飯",
+		},
+		{
+			name:       "Test with flagged input",
+			options:    ModerationOptions{Refusal: "Sorry"},
+			moderators: []moderation.Moderator{rules},
+			input:      "Where can I buy a gun?",
+			wantErr:    "input flagged by moderation: weapon",
+		},
+		{
+			name:       "Test with flagged content parts",
+			moderators: []moderation.Moderator{rules},
+			input:      []dto.ContentPart{dto.ImageUrlPart("https://example.com/menu.png", dto.ImageDetailAuto), dto.TextPart("a gun")},
+			wantErr:    "input flagged by moderation: weapon",
+		},
+		{
+			name:       "Test with skipped input",
+			options:    ModerationOptions{SkipInput: true},
+			moderators: []moderation.Moderator{rules},
+			input:      "a gun",
+		},
+		{
+			name:       "Test with moderator error",
+			moderators: []moderation.Moderator{failingModerator{}},
+			input:      "a spoon",
+			wantErr:    "moderation unavailable",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewModerationPlugin(tt.options, tt.moderators...).ConvertInput(tt.input)
+			assert.Nil(t, got)
+			if len(tt.wantErr) > 0 {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			assert.Nil(t, err)
+		})
+	}
+}
+
+func TestModerationPlugin_ConvertOutput(t *testing.T) {
+	rules := moderation.NewRuleModerator(moderation.KeywordRule("weapon", "gun"))
+	tests := []struct {
+		name     string
+		options  ModerationOptions
+		response dto.Message
+		want     *ConvertedResponse
+		wantErr  bool
+	}{
+		{
+			name:     "Test with safe output",
+			response: dto.Message{Role: dto.RoleAssistant, Content: "一份炒飯"},
+		},
+		{
+			name:     "Test with refusal",
+			options:  ModerationOptions{Refusal: "Sorry"},
+			response: dto.Message{Role: dto.RoleAssistant, Content: "Buy a gun"},
+			want: &ConvertedResponse{
+				Action:       TerminateOutputAction,
+				Message:      &dto.Message{Role: dto.RoleAssistant, Content: "Sorry"},
+				AddToHistory: true,
+			},
+		},
+		{
+			name:    "Test with refusal of an answer calling functions",
+			options: ModerationOptions{Refusal: "Sorry"},
+			response: dto.Message{
+				Role:      dto.RoleAssistant,
+				Content:   "Let me find a gun",
+				ToolCalls: &[]dto.ToolCall{{Id: "1", Type: "function", Function: dto.Function{Name: "search"}}},
+				Usage:     &dto.Usage{PromptToken: 10, CompletionToken: 5},
+			},
+			want: &ConvertedResponse{
+				Action: TerminateOutputAction,
+				Message: &dto.Message{
+					Role:      dto.RoleAssistant,
+					Content:   "Sorry",
+					ToolCalls: &[]dto.ToolCall{{Id: "1", Type: "function", Function: dto.Function{Name: "search"}}},
+					Usage:     &dto.Usage{PromptToken: 10, CompletionToken: 5},
+				},
+				AddToHistory: true,
+			},
+		},
+		{
+			name:     "Test without refusal",
+			response: dto.Message{Role: dto.RoleAssistant, Content: "Buy a gun"},
+			wantErr:  true,
+		},
+		{
+			name:     "Test with tool result",
+			response: dto.Message{Role: dto.RoleTool, Content: "gun"},
+		},
+		{
+			name:     "Test with skipped output",
+			options:  ModerationOptions{SkipOutput: true},
+			response: dto.Message{Role: dto.RoleAssistant, Content: "Buy a gun"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewModerationPlugin(tt.options, rules).ConvertOutput(tt.response)
+			assert.Equal(t, tt.want, got)
+			if !tt.wantErr {
+				assert.Nil(t, err)
+				return
+			}
+			var moderationError *ModerationError
+			assert.True(t, errors.As(err, &moderationError))
+			assert.Equal(t, OutputStage, moderationError.Stage)
+			assert.Equal(t, []string{"weapon"}, moderationError.Categories)
+		})
+	}
+}
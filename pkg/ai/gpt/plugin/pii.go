@@ -0,0 +1,128 @@
+package plugin
+
+import (
+	"github.com/meta-metopia/go-packages/pkg/ai/gpt/dto"
+	"github.com/meta-metopia/go-packages/pkg/ai/gpt/functions"
+	"github.com/meta-metopia/go-packages/pkg/ai/pii"
+)
+
+// PiiStoreKey is the key of the *pii.Vault of the conversation in the FunctionStore.
+const PiiStoreKey = "pii"
+
+// PiiPlugin keeps the personal information away from the LLM Model.
+// It replaces the personal information of the inputs and the function results with placeholders,
+// and restores them in the answers and in the arguments of the function calls.
+// The history keeps the placeholders.
+type PiiPlugin struct {
+	store     *functions.FunctionStore
+	detectors []pii.Detector
+}
+
+func (p *PiiPlugin) Name() string {
+	return "pii"
+}
+
+func (p *PiiPlugin) Description() string {
+	return "Replaces the personal information with placeholders."
+}
+
+// ConvertInput replaces the personal information of the input text with placeholders.
+func (p *PiiPlugin) ConvertInput(input any) (any, error) {
+	vault := p.vault()
+	switch value := input.(type) {
+	case string:
+		return vault.Redact(value, p.detectors), nil
+	case *string:
+		if value != nil {
+			return vault.Redact(*value, p.detectors), nil
+		}
+	case dto.ContentPart:
+		value.Text = vault.Redact(value.Text, p.detectors)
+		return value, nil
+	case []dto.ContentPart:
+		parts := make([]dto.ContentPart, len(value))
+		for index, part := range value {
+			part.Text = vault.Redact(part.Text, p.detectors)
+			parts[index] = part
+		}
+		return parts, nil
+	}
+	return nil, nil
+}
+
+// ConvertOutput restores the personal information in the answer returned to the caller.
+func (p *PiiPlugin) ConvertOutput(response dto.Message) (*ConvertedResponse, error) {
+	restored := p.vault().Restore(response.Content)
+	if restored == response.Content {
+		return nil, nil
+	}
+
+	response.Content = restored
+	return &ConvertedResponse{
+		Action:  ReplaceOutputAction,
+		Message: &response,
+		// the history sent back to the LLM Model keeps the placeholders
+		AddToHistory: false,
+	}, nil
+}
+
+// ConvertToolCall restores the personal information in the arguments, so the functions receive the original values.
+func (p *PiiPlugin) ConvertToolCall(_ string, arguments map[string]any) (map[string]any, error) {
+	return restoreValue(p.vault(), arguments).(map[string]any), nil
+}
+
+// ConvertToolResult replaces the personal information of the function result with placeholders.
+func (p *PiiPlugin) ConvertToolResult(result dto.Message) (*ConvertedResponse, error) {
+	redacted := p.vault().Redact(result.Content, p.detectors)
+	if redacted == result.Content {
+		return nil, nil
+	}
+
+	result.Content = redacted
+	return &ConvertedResponse{
+		Action:       ReplaceOutputAction,
+		Message:      &result,
+		AddToHistory: true,
+	}, nil
+}
+
+// vault returns the vault of the conversation, adding it to the store the first time.
+func (p *PiiPlugin) vault() *pii.Vault {
+	return functions.GetOrInit(p.store, PiiStoreKey, pii.NewVault)
+}
+
+// restoreValue restores the placeholders in the strings of a decoded JSON value.
+func restoreValue(vault *pii.Vault, value any) any {
+	switch value := value.(type) {
+	case string:
+		return vault.Restore(value)
+	case map[string]any:
+		restored := make(map[string]any, len(value))
+		for key, item := range value {
+			restored[key] = restoreValue(vault, item)
+		}
+		return restored
+	case []any:
+		restored := make([]any, len(value))
+		for index, item := range value {
+			restored[index] = restoreValue(vault, item)
+		}
+		return restored
+	}
+	return value
+}
+
+// NewPiiPlugin returns a new instance of the PiiPlugin keeping the placeholders of the conversation in the store.
+// The detectors default to pii.DefaultDetectors.
+func NewPiiPlugin(store *functions.FunctionStore, detectors ...pii.Detector) Interface {
+	if len(detectors) == 0 {
+		detectors = pii.DefaultDetectors
+	}
+	if store == nil {
+		store = functions.NewFunctionStore()
+	}
+	return &PiiPlugin{
+		store:     store,
+		detectors: detectors,
+	}
+}
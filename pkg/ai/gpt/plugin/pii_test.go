@@ -0,0 +1,63 @@
+package plugin
+
+import (
+	"github.com/meta-metopia/go-packages/pkg/ai/gpt/dto"
+	"github.com/meta-metopia/go-packages/pkg/ai/gpt/functions"
+	"github.com/meta-metopia/go-packages/pkg/ai/pii"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestPiiPlugin(t *testing.T) {
+	store := functions.NewFunctionStore()
+	piiPlugin := NewPiiPlugin(store).(*PiiPlugin)
+
+	prompt := "我的電話是 0912345678"
+	input, err := piiPlugin.ConvertInput(&prompt)
+	assert.Nil(t, err)
+	assert.Equal(t, "我的電話是 [PHONE_1]", input)
+	_, found := functions.Get[*pii.Vault](store, PiiStoreKey)
+	assert.True(t, found)
+
+	input, err = piiPlugin.ConvertInput([]dto.ContentPart{dto.TextPart("寄到 amy@example.com"), dto.ImageUrlPart("https://example.com/a.png", dto.ImageDetailLow)})
+	assert.Nil(t, err)
+	assert.Equal(t, "寄到 [EMAIL_1]", input.([]dto.ContentPart)[0].Text)
+	assert.Equal(t, "https://example.com/a.png", input.([]dto.ContentPart)[1].ImageUrl.Url)
+
+	converted, err := piiPlugin.ConvertOutput(dto.Message{Role: dto.RoleAssistant, Content: "會打給 [PHONE_1]"})
+	assert.Nil(t, err)
+	assert.Equal(t, &ConvertedResponse{
+		Action:  ReplaceOutputAction,
+		Message: &dto.Message{Role: dto.RoleAssistant, Content: "會打給 0912345678"},
+	}, converted)
+
+	converted, err = piiPlugin.ConvertOutput(dto.Message{Role: dto.RoleAssistant, Content: "好的"})
+	assert.Nil(t, err)
+	assert.Nil(t, converted)
+
+	arguments, err := piiPlugin.ConvertToolCall("complete-order", map[string]any{
+		"phone":  "[PHONE_1]",
+		"emails": []any{"[EMAIL_1]"},
+		"count":  2.0,
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]any{"phone": "0912345678", "emails": []any{"amy@example.com"}, "count": 2.0}, arguments)
+
+	toolCallId := "1"
+	converted, err = piiPlugin.ConvertToolResult(dto.Message{Role: dto.RoleTool, Content: "客戶 amy@example.com, 0987654321", ToolCallId: &toolCallId})
+	assert.Nil(t, err)
+	assert.Equal(t, "客戶 [EMAIL_1], [PHONE_2]", converted.Message.Content)
+	assert.True(t, converted.AddToHistory)
+
+	// the store keeps the placeholders of the conversation for the next plugin instance
+	input, err = NewPiiPlugin(store).ConvertInput("0987654321")
+	assert.Nil(t, err)
+	assert.Equal(t, "[PHONE_2]", input)
+}
+
+func TestPiiPluginWithDetectors(t *testing.T) {
+	emailDetector := pii.DefaultDetectors[0]
+	input, err := NewPiiPlugin(nil, emailDetector).ConvertInput("amy@example.com 0912345678")
+	assert.Nil(t, err)
+	assert.Equal(t, "[EMAIL_1] 0912345678", input)
+}
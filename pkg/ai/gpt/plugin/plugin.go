@@ -2,17 +2,47 @@ package plugin
 
 import "github.com/meta-metopia/go-packages/pkg/ai/gpt/dto"
 
+// Stage is a step of the turn where the plugins convert the messages.
+// The plugins are called in order at every stage.
+type Stage string
+
+const (
+	// InputStage converts the prompt of the user, see Interface.ConvertInput.
+	InputStage Stage = "input"
+	// OutputStage converts the messages of the assistant, see Interface.ConvertOutput.
+	OutputStage Stage = "output"
+	// ToolCallStage converts the arguments of the function calls, see ToolCallPlugin.
+	ToolCallStage Stage = "tool_call"
+	// ToolResultStage converts the results of the functions, see ToolResultPlugin.
+	ToolResultStage Stage = "tool_result"
+)
+
 type ConvertedOutputAction string
 
-// ContinueOutputAction will call the next plugin
-var ContinueOutputAction ConvertedOutputAction = "continue"
+// ReplaceOutputAction replaces the message with the converted one, then calls the next plugin with it.
+var ReplaceOutputAction ConvertedOutputAction = "replace"
 
-// TerminateOutputAction will terminate the output
+// AppendOutputAction keeps the message and adds the converted one after it.
+// The next plugins are called with both messages.
+var AppendOutputAction ConvertedOutputAction = "append"
+
+// TerminateOutputAction replaces the message with the converted one and skips the next plugins.
 var TerminateOutputAction ConvertedOutputAction = "terminate"
 
+// ContinueOutputAction is the same as ReplaceOutputAction.
+//
+// Deprecated: use ReplaceOutputAction.
+var ContinueOutputAction = ReplaceOutputAction
+
+// ConvertedResponse is the result of a plugin converting a message.
+// A nil ConvertedResponse, or a nil Message, passes the message through unchanged.
 type ConvertedResponse struct {
-	Action       ConvertedOutputAction
-	Message      *dto.Message
+	// Action defaults to ReplaceOutputAction.
+	Action  ConvertedOutputAction
+	Message *dto.Message
+	// AddToHistory keeps the converted message in the history sent to the LLM Model.
+	// Otherwise the converted message is only returned to the caller:
+	// a replaced message keeps its previous version in the history, and an appended message is left out of it.
 	AddToHistory bool
 }
 
@@ -21,14 +51,52 @@ type Interface interface {
 	Name() string
 	// Description returns the description of the plugin
 	Description() string
-	// ConvertInput converts the input to a string that can be used by the LLM Model
+	// ConvertInput converts the input to a string that can be used by the LLM Model,
+	// or to a dto.ContentPart or []dto.ContentPart to send images and audio.
+	// Return nil will keep the input.
 	ConvertInput(input any) (any, error)
-	// ConvertOutput converts the output from the LLM Model to the expected output.
-	// Return nil will have 0 impact on the output.
-	// Return a non-nil message will replace the output with the returned message or add it to the output depending on the `action`.
+	// ConvertOutput converts the messages of the assistant, including the ones calling functions.
+	// The answers are streamed once they are converted, unless every plugin is a PassthroughPlugin.
+	// Return nil will have 0 impact on the output, see ConvertedResponse for the other results.
 	ConvertOutput(response dto.Message) (*ConvertedResponse, error)
 }
 
+// PassthroughPlugin is implemented by the plugins whose ConvertOutput can leave the answers unchanged, such as the speech plugin.
+// When every plugin passes the output through, GenerateStream streams the answers while they are generated.
+// Otherwise every answer is streamed at once, after the plugins converted it.
+type PassthroughPlugin interface {
+	// PassesOutputThrough returns true when ConvertOutput never changes the answers.
+	PassesOutputThrough() bool
+}
+
+// ToolCallPlugin is implemented by the plugins converting the function calls.
+type ToolCallPlugin interface {
+	// ConvertToolCall converts the arguments of the function [name] before they are passed to the function.
+	// Return nil will keep the arguments, and a ToolCallRejection refuses the call.
+	ConvertToolCall(name string, arguments map[string]any) (map[string]any, error)
+}
+
+// ToolCallRejection is returned by ConvertToolCall to refuse a function call without failing the turn.
+// The call is answered with the Reason instead of the result of the function, so the LLM Model can tell the user.
+type ToolCallRejection interface {
+	error
+	// Reason answers the refused function call.
+	Reason() string
+}
+
+// ToolResultPlugin is implemented by the plugins converting the results of the functions.
+type ToolResultPlugin interface {
+	// ConvertToolResult converts the result of a function before it is sent to the LLM Model, the same way as ConvertOutput.
+	// A replaced result keeps the role and the tool call id of the result.
+	ConvertToolResult(result dto.Message) (*ConvertedResponse, error)
+}
+
+// ClosingPlugin is implemented by the plugins releasing resources when the GPT client is closed.
+type ClosingPlugin interface {
+	// OnClose is called by the Close method of the GPT client.
+	OnClose() error
+}
+
 type Client struct {
 }
 
@@ -0,0 +1,56 @@
+package plugin
+
+import (
+	"context"
+	"github.com/meta-metopia/go-packages/pkg/ai/gpt/dto"
+	"github.com/meta-metopia/go-packages/pkg/ai/speech"
+	"strings"
+)
+
+// SpeechPlugin speaks the answers of the assistant through an audio sink. It leaves the output untouched.
+type SpeechPlugin struct {
+	Client
+	synthesizer speech.Synthesizer
+	sink        speech.AudioSink
+}
+
+func (s *SpeechPlugin) Name() string {
+	return "speech"
+}
+
+func (s *SpeechPlugin) Description() string {
+	return "Speaks the answers of the assistant."
+}
+
+func (s *SpeechPlugin) ConvertOutput(response dto.Message) (*ConvertedResponse, error) {
+	if response.Role != dto.RoleAssistant || len(strings.TrimSpace(response.Content)) == 0 {
+		return nil, nil
+	}
+
+	// long answers are synthesized in chunks of sentences to stay under the limits of the speech services
+	ctx := context.Background()
+	for _, chunk := range speech.Chunk(response.Content, speech.DefaultChunkLength) {
+		audio, err := s.synthesizer.Synthesize(ctx, chunk)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := s.sink.Write(ctx, audio); err != nil {
+			return nil, err
+		}
+	}
+	return nil, nil
+}
+
+func (s *SpeechPlugin) PassesOutputThrough() bool {
+	return true
+}
+
+// NewSpeechPlugin returns a new instance of the SpeechPlugin
+// synthesizing the answers with the synthesizer and writing the speech to the sink.
+func NewSpeechPlugin(synthesizer speech.Synthesizer, sink speech.AudioSink) Interface {
+	return &SpeechPlugin{
+		synthesizer: synthesizer,
+		sink:        sink,
+	}
+}
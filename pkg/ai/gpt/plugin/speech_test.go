@@ -0,0 +1,61 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"github.com/meta-metopia/go-packages/pkg/ai/gpt/dto"
+	"github.com/meta-metopia/go-packages/pkg/ai/speech"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+type fakeSynthesizer struct {
+	err error
+}
+
+func (f fakeSynthesizer) Synthesize(ctx context.Context, text string) (speech.Audio, error) {
+	if f.err != nil {
+		return speech.Audio{}, f.err
+	}
+	return speech.Audio{Data: []byte(text), ContentType: "audio/mpeg"}, nil
+}
+
+func TestSpeechPlugin_ConvertOutput(t *testing.T) {
+	tests := []struct {
+		name       string
+		response   dto.Message
+		err        error
+		wantAudios []speech.Audio
+		wantErr    bool
+	}{
+		{
+			name:       "Test with assistant message",
+			response:   dto.Message{Role: dto.RoleAssistant, Content: "你好"},
+			wantAudios: []speech.Audio{{Data: []byte("你好"), ContentType: "audio/mpeg"}},
+		},
+		{
+			name:     "Test with empty message",
+			response: dto.Message{Role: dto.RoleAssistant, Content: " "},
+		},
+		{
+			name:     "Test with tool message",
+			response: dto.Message{Role: dto.RoleTool, Content: "{}"},
+		},
+		{
+			name:     "Test with synthesizer error",
+			response: dto.Message{Role: dto.RoleAssistant, Content: "你好"},
+			err:      fmt.Errorf("failed"),
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sink := speech.NewMemorySink()
+			s := NewSpeechPlugin(fakeSynthesizer{err: tt.err}, sink)
+			got, err := s.ConvertOutput(tt.response)
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.Nil(t, got)
+			assert.Equal(t, tt.wantAudios, sink.Audios())
+		})
+	}
+}
@@ -23,15 +23,24 @@ func (s StandardOutputPlugin) ConvertInput(input any) (any, error) {
 	if value, ok := input.(*string); ok {
 		return value, nil
 	}
+
+	if value, ok := input.(dto.ContentPart); ok {
+		return value, nil
+	}
+
+	if value, ok := input.([]dto.ContentPart); ok {
+		return value, nil
+	}
 	return nil, nil
 }
 
+// ConvertOutput passes the output through unchanged.
 func (s StandardOutputPlugin) ConvertOutput(response dto.Message) (*ConvertedResponse, error) {
-	return &ConvertedResponse{
-		Action:       ContinueOutputAction,
-		Message:      &response,
-		AddToHistory: true,
-	}, nil
+	return nil, nil
+}
+
+func (s StandardOutputPlugin) PassesOutputThrough() bool {
+	return true
 }
 
 // NewStandardOutputPlugin returns a new instance of the StandardOutputPlugin
@@ -15,6 +15,7 @@ func TestStandardOutputPlugin_ConvertInput(t *testing.T) {
 		input any
 	}
 	testPtr := strPtr("test")
+	imagePart := dto.ImageUrlPart("https://example.com/image.png", dto.ImageDetailLow)
 	tests := []struct {
 		name    string
 		args    args
@@ -53,6 +54,22 @@ func TestStandardOutputPlugin_ConvertInput(t *testing.T) {
 			want:    testPtr,
 			wantErr: false,
 		},
+		{
+			name: "Test 5",
+			args: args{
+				input: imagePart,
+			},
+			want:    imagePart,
+			wantErr: false,
+		},
+		{
+			name: "Test 6",
+			args: args{
+				input: []dto.ContentPart{dto.TextPart("test"), imagePart},
+			},
+			want:    []dto.ContentPart{dto.TextPart("test"), imagePart},
+			wantErr: false,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -103,10 +120,7 @@ func TestStandardOutputPlugin_ConvertOutput(t *testing.T) {
 		args: args{
 			response: mockMessage,
 		},
-		want: &ConvertedResponse{
-			Action:  ContinueOutputAction,
-			Message: &mockMessage,
-		},
+		want:    nil,
 		wantErr: false,
 	},
 	}
@@ -118,11 +132,7 @@ func TestStandardOutputPlugin_ConvertOutput(t *testing.T) {
 				t.Errorf("ConvertOutput() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
-			if tt.want.Message.Content != got.Message.Content {
-				t.Errorf("ConvertOutput() got = %v, want %v", got, tt.want)
-			}
-
-			if tt.want.Action != got.Action {
+			if got != tt.want {
 				t.Errorf("ConvertOutput() got = %v, want %v", got, tt.want)
 			}
 		})
@@ -0,0 +1,140 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Limits are the quotas of a deployment. A limit of 0 is unlimited.
+type Limits struct {
+	RequestsPerMinute int
+	TokensPerMinute   int
+}
+
+// bucket is a token bucket refilled continuously up to its capacity.
+// The level can go negative when more tokens were used than estimated.
+type bucket struct {
+	capacity   float64
+	level      float64
+	perSecond  float64
+	lastRefill time.Time
+}
+
+func newBucket(perMinute int, now time.Time) *bucket {
+	if perMinute <= 0 {
+		return nil
+	}
+	return &bucket{
+		capacity:   float64(perMinute),
+		level:      float64(perMinute),
+		perSecond:  float64(perMinute) / 60,
+		lastRefill: now,
+	}
+}
+
+func (b *bucket) refill(now time.Time) {
+	elapsed := now.Sub(b.lastRefill).Seconds()
+	if elapsed > 0 {
+		b.level = min(b.capacity, b.level+elapsed*b.perSecond)
+		b.lastRefill = now
+	}
+}
+
+// wait returns how long to wait until the bucket holds [amount].
+// Amounts larger than the capacity only wait for a full bucket, so they never block forever.
+func (b *bucket) wait(amount float64) time.Duration {
+	amount = min(amount, b.capacity)
+	if b.level >= amount {
+		return 0
+	}
+	return time.Duration((amount - b.level) / b.perSecond * float64(time.Second))
+}
+
+// Limiter paces the requests and tokens sent to a deployment. It is safe for concurrent use.
+type Limiter struct {
+	mutex    sync.Mutex
+	requests *bucket
+	tokens   *bucket
+	now      func() time.Time
+	sleep    func(ctx context.Context, duration time.Duration) error
+}
+
+// NewLimiter returns a new instance of Limiter enforcing the limits.
+func NewLimiter(limits Limits) *Limiter {
+	now := time.Now()
+	return &Limiter{
+		requests: newBucket(limits.RequestsPerMinute, now),
+		tokens:   newBucket(limits.TokensPerMinute, now),
+		now:      time.Now,
+		sleep:    sleep,
+	}
+}
+
+// Wait blocks until a request using [tokens] tokens can be sent, then takes them from the quota.
+// It returns the context's error if the context is done before that.
+func (l *Limiter) Wait(ctx context.Context, tokens int) error {
+	for {
+		delay := l.reserve(tokens)
+		if delay == 0 {
+			return nil
+		}
+		if err := l.sleep(ctx, delay); err != nil {
+			return err
+		}
+	}
+}
+
+// Adjust corrects the tokens taken by Wait once the actual usage is known.
+// Unused tokens are given back to the quota and extra tokens are taken from it.
+func (l *Limiter) Adjust(estimated int, actual int) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.tokens == nil {
+		return
+	}
+	l.tokens.refill(l.now())
+	l.tokens.level = min(l.tokens.capacity, l.tokens.level+float64(estimated-actual))
+}
+
+// reserve takes the request and tokens from the quota and returns 0,
+// or returns how long to wait before trying again.
+func (l *Limiter) reserve(tokens int) time.Duration {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	now := l.now()
+	var delay time.Duration
+	if l.requests != nil {
+		l.requests.refill(now)
+		delay = max(delay, l.requests.wait(1))
+	}
+	if l.tokens != nil {
+		l.tokens.refill(now)
+		delay = max(delay, l.tokens.wait(float64(tokens)))
+	}
+	if delay > 0 {
+		return delay
+	}
+
+	if l.requests != nil {
+		l.requests.level--
+	}
+	if l.tokens != nil {
+		l.tokens.level -= float64(tokens)
+	}
+	return 0
+}
+
+func sleep(ctx context.Context, duration time.Duration) error {
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
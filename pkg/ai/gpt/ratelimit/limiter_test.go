@@ -0,0 +1,109 @@
+package ratelimit
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newTestLimiter returns a limiter whose sleeps advance a fake clock instead of blocking.
+func newTestLimiter(limits Limits) (*Limiter, *[]time.Duration) {
+	limiter := NewLimiter(limits)
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	var sleeps []time.Duration
+
+	limiter.now = func() time.Time { return now }
+	if limiter.requests != nil {
+		limiter.requests.lastRefill = now
+	}
+	if limiter.tokens != nil {
+		limiter.tokens.lastRefill = now
+	}
+	limiter.sleep = func(ctx context.Context, duration time.Duration) error {
+		sleeps = append(sleeps, duration)
+		now = now.Add(duration)
+		return nil
+	}
+	return limiter, &sleeps
+}
+
+func TestLimiter_RequestsPerMinute(t *testing.T) {
+	limiter, sleeps := newTestLimiter(Limits{RequestsPerMinute: 2})
+
+	assert.Nil(t, limiter.Wait(context.Background(), 0))
+	assert.Nil(t, limiter.Wait(context.Background(), 0))
+	assert.Empty(t, *sleeps)
+
+	// the bucket refills one request every 30 seconds
+	assert.Nil(t, limiter.Wait(context.Background(), 0))
+	assert.Equal(t, []time.Duration{30 * time.Second}, *sleeps)
+}
+
+func TestLimiter_TokensPerMinute(t *testing.T) {
+	limiter, sleeps := newTestLimiter(Limits{TokensPerMinute: 600})
+
+	assert.Nil(t, limiter.Wait(context.Background(), 500))
+	assert.Empty(t, *sleeps)
+
+	// 100 tokens left, 200 needed: waits for 100 tokens at 10 tokens per second
+	assert.Nil(t, limiter.Wait(context.Background(), 200))
+	assert.Equal(t, []time.Duration{10 * time.Second}, *sleeps)
+}
+
+func TestLimiter_Adjust(t *testing.T) {
+	limiter, sleeps := newTestLimiter(Limits{TokensPerMinute: 600})
+
+	assert.Nil(t, limiter.Wait(context.Background(), 600))
+	// only 100 tokens were actually used, so 500 are given back
+	limiter.Adjust(600, 100)
+	assert.Nil(t, limiter.Wait(context.Background(), 500))
+	assert.Empty(t, *sleeps)
+
+	// 100 more tokens were used than estimated, so the next request waits for them too
+	limiter.Adjust(0, 100)
+	assert.Nil(t, limiter.Wait(context.Background(), 10))
+	assert.Equal(t, []time.Duration{11 * time.Second}, *sleeps)
+}
+
+func TestLimiter_LargerThanCapacity(t *testing.T) {
+	limiter, sleeps := newTestLimiter(Limits{TokensPerMinute: 100})
+
+	assert.Nil(t, limiter.Wait(context.Background(), 1000))
+	assert.Empty(t, *sleeps)
+}
+
+func TestLimiter_Unlimited(t *testing.T) {
+	limiter, sleeps := newTestLimiter(Limits{})
+
+	for i := 0; i < 100; i++ {
+		assert.Nil(t, limiter.Wait(context.Background(), 100000))
+	}
+	assert.Empty(t, *sleeps)
+}
+
+func TestLimiter_ContextCanceled(t *testing.T) {
+	limiter := NewLimiter(Limits{RequestsPerMinute: 1})
+	assert.Nil(t, limiter.Wait(context.Background(), 0))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err := limiter.Wait(ctx, 0)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestLimiter_Concurrent(t *testing.T) {
+	limiter := NewLimiter(Limits{RequestsPerMinute: 60000, TokensPerMinute: 600000})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.Nil(t, limiter.Wait(context.Background(), 10))
+			limiter.Adjust(10, 5)
+		}()
+	}
+	wg.Wait()
+}
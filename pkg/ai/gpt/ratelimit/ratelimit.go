@@ -0,0 +1,95 @@
+package ratelimit
+
+import (
+	"context"
+	"github.com/meta-metopia/go-packages/pkg/ai/gpt/middleware"
+	"github.com/meta-metopia/go-packages/pkg/ai/gpt/tokens"
+	"sync"
+)
+
+// Key identifies the quota of a deployment: its endpoint, the api key it is called with, and the model for OpenAI.
+// Azure deployments pick the model by the endpoint, so their requests have no model.
+type Key struct {
+	Endpoint string
+	ApiKey   string
+	Model    string
+}
+
+// KeyOf returns the Key of the quota the request uses.
+func KeyOf(request *middleware.Request) Key {
+	key := Key{Endpoint: request.Endpoint, ApiKey: request.ApiKey}
+	if request.Body != nil && request.Body.Model != nil {
+		key.Model = *request.Body.Model
+	}
+	return key
+}
+
+// Registry holds one Limiter per deployment Key.
+// Share the same Registry between clients to share the quotas of the deployments they target.
+type Registry struct {
+	mutex         sync.Mutex
+	defaultLimits Limits
+	limits        map[Key]Limits
+	limiters      map[Key]*Limiter
+}
+
+// NewRegistry returns a new instance of Registry applying [defaultLimits] to the deployments without their own limits.
+func NewRegistry(defaultLimits Limits) *Registry {
+	return &Registry{
+		defaultLimits: defaultLimits,
+		limits:        make(map[Key]Limits),
+		limiters:      make(map[Key]*Limiter),
+	}
+}
+
+// SetLimits sets the limits of the deployment. It must be called before the first request is sent to the deployment.
+func (r *Registry) SetLimits(key Key, limits Limits) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.limits[key] = limits
+}
+
+// Limiter returns the Limiter of the deployment, creating it on first use.
+func (r *Registry) Limiter(key Key) *Limiter {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if limiter, ok := r.limiters[key]; ok {
+		return limiter
+	}
+
+	limits, ok := r.limits[key]
+	if !ok {
+		limits = r.defaultLimits
+	}
+	limiter := NewLimiter(limits)
+	r.limiters[key] = limiter
+	return limiter
+}
+
+// Middleware returns the middleware that waits for the quota of the request's Key before sending it.
+// The estimated prompt tokens are taken before sending, then corrected with the actual usage of the response.
+// Place it after an upstream pool so the quota of the target serving the request is used.
+func Middleware(registry *Registry) middleware.Middleware {
+	return func(next middleware.RoundTrip) middleware.RoundTrip {
+		return func(ctx context.Context, request *middleware.Request) (*middleware.Response, error) {
+			limiter := registry.Limiter(KeyOf(request))
+			estimated := tokens.EstimateRequest(request.Body)
+			if err := limiter.Wait(ctx, estimated); err != nil {
+				return nil, err
+			}
+
+			response, err := next(ctx, request)
+			if err != nil {
+				limiter.Adjust(estimated, 0)
+				return nil, err
+			}
+
+			if response != nil && response.Body != nil && response.Body.Usage != nil && !response.CacheHit {
+				usage := response.Body.Usage
+				limiter.Adjust(estimated, usage.PromptToken+usage.CompletionToken)
+			}
+			return response, nil
+		}
+	}
+}
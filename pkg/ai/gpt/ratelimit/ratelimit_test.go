@@ -0,0 +1,134 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"github.com/meta-metopia/go-packages/pkg/ai/gpt/dto"
+	"github.com/meta-metopia/go-packages/pkg/ai/gpt/middleware"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+)
+
+func TestRegistry(t *testing.T) {
+	a := Key{Endpoint: "https://a", ApiKey: "1"}
+	b := Key{Endpoint: "https://b", ApiKey: "1"}
+	registry := NewRegistry(Limits{RequestsPerMinute: 10})
+	registry.SetLimits(b, Limits{RequestsPerMinute: 20})
+
+	assert.Same(t, registry.Limiter(a), registry.Limiter(a))
+	assert.NotSame(t, registry.Limiter(a), registry.Limiter(b))
+	assert.Equal(t, float64(10), registry.Limiter(a).requests.capacity)
+	assert.Equal(t, float64(20), registry.Limiter(b).requests.capacity)
+}
+
+func TestKeyOf(t *testing.T) {
+	model := "gpt-4o"
+	otherModel := "gpt-4o-mini"
+	openai := "https://api.openai.com/v1/chat/completions"
+	tests := []struct {
+		name    string
+		request *middleware.Request
+		want    Key
+	}{
+		{
+			name:    "Test with OpenAI",
+			request: &middleware.Request{Endpoint: openai, ApiKey: "1", Body: &dto.RequestDto{Model: &model}},
+			want:    Key{Endpoint: openai, ApiKey: "1", Model: model},
+		},
+		{
+			name:    "Test with another model",
+			request: &middleware.Request{Endpoint: openai, ApiKey: "1", Body: &dto.RequestDto{Model: &otherModel}},
+			want:    Key{Endpoint: openai, ApiKey: "1", Model: otherModel},
+		},
+		{
+			name:    "Test with Azure",
+			request: &middleware.Request{Endpoint: "https://a", ApiKey: "2", Body: &dto.RequestDto{}},
+			want:    Key{Endpoint: "https://a", ApiKey: "2"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, KeyOf(tt.request))
+		})
+	}
+}
+
+func TestMiddleware_QuotasOfApiKeys(t *testing.T) {
+	model := "gpt-4o"
+	openai := "https://api.openai.com/v1/chat/completions"
+	registry := NewRegistry(Limits{RequestsPerMinute: 10})
+	registry.SetLimits(Key{Endpoint: openai, ApiKey: "2", Model: model}, Limits{RequestsPerMinute: 20})
+
+	transport := func(ctx context.Context, request *middleware.Request) (*middleware.Response, error) {
+		return &middleware.Response{StatusCode: http.StatusOK}, nil
+	}
+	for _, apiKey := range []string{"1", "2"} {
+		request := &middleware.Request{Endpoint: openai, ApiKey: apiKey, Body: &dto.RequestDto{Model: &model}}
+		_, err := Middleware(registry)(transport)(context.Background(), request)
+		assert.Nil(t, err)
+	}
+
+	// the keys sharing the endpoint have their own quotas, and the limits set for a key are used
+	first := registry.Limiter(Key{Endpoint: openai, ApiKey: "1", Model: model})
+	second := registry.Limiter(Key{Endpoint: openai, ApiKey: "2", Model: model})
+	assert.NotSame(t, first, second)
+	assert.Equal(t, float64(10), first.requests.capacity)
+	assert.Equal(t, float64(20), second.requests.capacity)
+	assert.InDelta(t, 9, first.requests.level, 0.1)
+	assert.InDelta(t, 19, second.requests.level, 0.1)
+}
+
+func TestMiddleware(t *testing.T) {
+	tests := []struct {
+		name      string
+		response  *middleware.Response
+		err       error
+		wantLevel float64
+	}{
+		{
+			name: "Test with usage",
+			response: &middleware.Response{
+				StatusCode: http.StatusOK,
+				Body:       &dto.ResponseDto{Usage: &dto.Usage{PromptToken: 30, CompletionToken: 20}},
+			},
+			wantLevel: 950,
+		},
+		{
+			name: "Test with cache hit",
+			response: &middleware.Response{
+				StatusCode: http.StatusOK,
+				Body:       &dto.ResponseDto{Usage: &dto.Usage{}},
+				CacheHit:   true,
+			},
+			wantLevel: 1000 - 9,
+		},
+		{
+			name:      "Test with error",
+			err:       fmt.Errorf("failed"),
+			wantLevel: 1000,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			registry := NewRegistry(Limits{TokensPerMinute: 1000})
+			limiter, _ := newTestLimiter(Limits{TokensPerMinute: 1000})
+			registry.limiters[Key{Endpoint: "https://a"}] = limiter
+
+			transport := func(ctx context.Context, request *middleware.Request) (*middleware.Response, error) {
+				return tt.response, tt.err
+			}
+			request := &middleware.Request{
+				Endpoint: "https://a",
+				// estimated as 9 tokens
+				Body: &dto.RequestDto{Messages: []dto.Message{{Role: dto.RoleUser, Content: "Hi"}}},
+			}
+
+			_, err := Middleware(registry)(transport)(context.Background(), request)
+			assert.Equal(t, tt.err, err)
+			assert.Equal(t, tt.wantLevel, limiter.tokens.level)
+		})
+	}
+}
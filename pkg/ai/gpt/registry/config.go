@@ -0,0 +1,134 @@
+package registry
+
+import (
+	"bytes"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"os"
+	"slices"
+)
+
+type Provider string
+
+const (
+	ProviderOpenAI Provider = "openai"
+	ProviderAzure  Provider = "azure"
+)
+
+const openAIEndpoint = "https://api.openai.com/v1/chat/completions"
+
+// Options are the options of a plugin or a function in the bot configuration.
+type Options map[string]any
+
+// Decode decodes the options into [target], a pointer to a struct with yaml tags.
+func (o Options) Decode(target any) error {
+	if len(o) == 0 {
+		return nil
+	}
+	data, err := yaml.Marshal(o)
+	if err != nil {
+		return err
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(target); err != nil {
+		return fmt.Errorf("invalid options: %w", err)
+	}
+	return nil
+}
+
+// Component is a plugin or a function of the bot, created by the factory registered with its name.
+type Component struct {
+	Name    string  `yaml:"name"`
+	Options Options `yaml:"options"`
+}
+
+// UnmarshalYAML accepts the name alone for the components without options.
+func (c *Component) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		return node.Decode(&c.Name)
+	}
+	type component Component
+	return node.Decode((*component)(c))
+}
+
+// Config describes a bot. It is read from a YAML or a JSON file, see LoadConfig.
+//
+//	provider: openai
+//	api_key: ${OPENAI_KEY}
+//	model: gpt-3.5-turbo
+//	prompt: You are a helpful assistant
+//	plugins:
+//	  - standard
+//	functions:
+//	  - name: get-weather
+//	    options:
+//	      unit: celsius
+type Config struct {
+	Name string `yaml:"name"`
+	// Provider is either openai or azure. Defaults to openai.
+	Provider Provider `yaml:"provider"`
+	// Endpoint defaults to the chat completions endpoint of OpenAI. It is required for Azure.
+	// The environment variables in the Endpoint and the ApiKey are expanded, so the secrets can stay out of the file.
+	Endpoint string `yaml:"endpoint"`
+	ApiKey   string `yaml:"api_key"`
+	// Model is required for OpenAI. Azure deployments pick the model by the endpoint.
+	Model       string   `yaml:"model"`
+	Prompt      string   `yaml:"prompt"`
+	Temperature *float64 `yaml:"temperature"`
+	TopP        *float64 `yaml:"top_p"`
+	Seed        *int     `yaml:"seed"`
+	MaxTokens   *int     `yaml:"max_tokens"`
+	// Plugins are called in the order they are listed. Defaults to the standard plugin.
+	Plugins   []Component `yaml:"plugins"`
+	Functions []Component `yaml:"functions"`
+}
+
+// LoadConfig reads the bot configuration from a YAML or a JSON file.
+func LoadConfig(path string) (Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, err
+	}
+	return ParseConfig(data)
+}
+
+// ParseConfig parses the bot configuration from YAML or JSON, and checks it is complete.
+func ParseConfig(data []byte) (Config, error) {
+	var config Config
+	// JSON is valid YAML, so both are parsed the same way
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&config); err != nil {
+		return Config{}, fmt.Errorf("invalid bot config: %w", err)
+	}
+
+	config.Endpoint = os.ExpandEnv(config.Endpoint)
+	config.ApiKey = os.ExpandEnv(config.ApiKey)
+	if len(config.Provider) == 0 {
+		config.Provider = ProviderOpenAI
+	}
+
+	switch config.Provider {
+	case ProviderOpenAI:
+		if len(config.Endpoint) == 0 {
+			config.Endpoint = openAIEndpoint
+		}
+		if len(config.Model) == 0 {
+			return Config{}, fmt.Errorf("invalid bot config: model is required for openai")
+		}
+	case ProviderAzure:
+		if len(config.Endpoint) == 0 {
+			return Config{}, fmt.Errorf("invalid bot config: endpoint is required for azure")
+		}
+	default:
+		return Config{}, fmt.Errorf("invalid bot config: unknown provider %s", config.Provider)
+	}
+
+	for _, component := range slices.Concat(config.Plugins, config.Functions) {
+		if len(component.Name) == 0 {
+			return Config{}, fmt.Errorf("invalid bot config: plugins and functions need a name")
+		}
+	}
+	return config, nil
+}
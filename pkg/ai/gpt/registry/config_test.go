@@ -0,0 +1,143 @@
+package registry
+
+import (
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseConfig(t *testing.T) {
+	t.Setenv("TEST_OPENAI_KEY", "secret")
+
+	tests := []struct {
+		name    string
+		data    string
+		want    Config
+		wantErr string
+	}{
+		{
+			name: "Test with yaml",
+			data: `
+name: restaurant
+api_key: ${TEST_OPENAI_KEY}
+model: gpt-3.5-turbo
+prompt: You are a waiter
+temperature: 0.5
+plugins:
+  - standard
+functions:
+  - get-all-menus
+  - name: add-dishes
+    options:
+      limit: 3
+`,
+			want: Config{
+				Name:        "restaurant",
+				Provider:    ProviderOpenAI,
+				Endpoint:    openAIEndpoint,
+				ApiKey:      "secret",
+				Model:       "gpt-3.5-turbo",
+				Prompt:      "You are a waiter",
+				Temperature: floatPtr(0.5),
+				Plugins:     []Component{{Name: "standard"}},
+				Functions: []Component{
+					{Name: "get-all-menus"},
+					{Name: "add-dishes", Options: Options{"limit": 3}},
+				},
+			},
+		},
+		{
+			name: "Test with json",
+			data: `{
+				"provider": "azure",
+				"endpoint": "https://example.com/deployments/gpt/chat/completions",
+				"api_key": "key",
+				"max_tokens": 100,
+				"plugins": [{"name": "standard", "options": {"verbose": true}}]
+			}`,
+			want: Config{
+				Provider:  ProviderAzure,
+				Endpoint:  "https://example.com/deployments/gpt/chat/completions",
+				ApiKey:    "key",
+				MaxTokens: intPtr(100),
+				Plugins:   []Component{{Name: "standard", Options: Options{"verbose": true}}},
+			},
+		},
+		{
+			name:    "Test without model for openai",
+			data:    `api_key: key`,
+			wantErr: "invalid bot config: model is required for openai",
+		},
+		{
+			name:    "Test without endpoint for azure",
+			data:    `provider: azure`,
+			wantErr: "invalid bot config: endpoint is required for azure",
+		},
+		{
+			name:    "Test with unknown provider",
+			data:    `provider: other`,
+			wantErr: "invalid bot config: unknown provider other",
+		},
+		{
+			name:    "Test with unknown field",
+			data:    "model: gpt-4\nmodels: gpt-4",
+			wantErr: "invalid bot config: yaml: unmarshal errors:\n  line 2: field models not found in type registry.Config",
+		},
+		{
+			name:    "Test without component name",
+			data:    "model: gpt-4\nplugins:\n  - options:\n      verbose: true",
+			wantErr: "invalid bot config: plugins and functions need a name",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseConfig([]byte(tt.data))
+			if len(tt.wantErr) > 0 {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bot.json")
+	err := os.WriteFile(path, []byte(`{"model": "gpt-4"}`), 0644)
+	assert.Nil(t, err)
+
+	config, err := LoadConfig(path)
+	assert.Nil(t, err)
+	assert.Equal(t, "gpt-4", config.Model)
+
+	_, err = LoadConfig(filepath.Join(t.TempDir(), "missing.yaml"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestOptions_Decode(t *testing.T) {
+	type options struct {
+		Limit int    `yaml:"limit"`
+		Unit  string `yaml:"unit"`
+	}
+
+	var decoded options
+	assert.Nil(t, Options{"limit": 3, "unit": "celsius"}.Decode(&decoded))
+	assert.Equal(t, options{Limit: 3, Unit: "celsius"}, decoded)
+
+	decoded = options{Limit: 1}
+	assert.Nil(t, Options(nil).Decode(&decoded))
+	assert.Equal(t, options{Limit: 1}, decoded)
+
+	assert.NotNil(t, Options{"limits": 3}.Decode(&decoded))
+}
+
+func floatPtr(value float64) *float64 {
+	return &value
+}
+
+func intPtr(value int) *int {
+	return &value
+}
@@ -0,0 +1,128 @@
+package registry
+
+import (
+	"fmt"
+	"github.com/meta-metopia/go-packages/pkg/ai/gpt/functions"
+	"github.com/meta-metopia/go-packages/pkg/ai/gpt/plugin"
+	"github.com/meta-metopia/go-packages/pkg/ai/injection"
+	"github.com/meta-metopia/go-packages/pkg/ai/moderation"
+	"github.com/meta-metopia/go-packages/pkg/ai/pii"
+	"os"
+	"regexp"
+	"slices"
+)
+
+// moderationOptions are the options of the moderation plugin.
+// The OpenAI moderation endpoint is only called when there is an api key.
+type moderationOptions struct {
+	Endpoint   string             `yaml:"endpoint"`
+	ApiKey     string             `yaml:"api_key"`
+	Model      string             `yaml:"model"`
+	Thresholds map[string]float64 `yaml:"thresholds"`
+	Keywords   []string           `yaml:"keywords"`
+	Patterns   []string           `yaml:"patterns"`
+	Refusal    string             `yaml:"refusal"`
+	SkipInput  bool               `yaml:"skip_input"`
+	SkipOutput bool               `yaml:"skip_output"`
+}
+
+func newModerationPlugin(options Options, _ *functions.FunctionStore) (plugin.Interface, error) {
+	var config moderationOptions
+	if err := options.Decode(&config); err != nil {
+		return nil, err
+	}
+
+	// the local rules come first, so the endpoint is not called for the texts they already flag
+	rules := []moderation.Rule{moderation.KeywordRule("keyword", config.Keywords...)}
+	for _, pattern := range config.Patterns {
+		compiled, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %s: %w", pattern, err)
+		}
+		rules = append(rules, moderation.Rule{Category: "pattern", Pattern: compiled})
+	}
+	moderators := []moderation.Moderator{moderation.NewRuleModerator(rules...)}
+
+	if apiKey := os.ExpandEnv(config.ApiKey); len(apiKey) > 0 {
+		moderators = append(moderators, moderation.NewOpenAIModerator(moderation.OpenAIConfig{
+			Endpoint:   config.Endpoint,
+			ApiKey:     apiKey,
+			Model:      config.Model,
+			Thresholds: config.Thresholds,
+		}))
+	}
+
+	return plugin.NewModerationPlugin(plugin.ModerationOptions{
+		Refusal:    config.Refusal,
+		SkipInput:  config.SkipInput,
+		SkipOutput: config.SkipOutput,
+	}, moderators...), nil
+}
+
+// piiOptions are the options of the pii plugin.
+type piiOptions struct {
+	// Kinds are the kinds of personal information replaced, such as PHONE or EMAIL. Defaults to all of them.
+	Kinds []pii.Kind `yaml:"kinds"`
+}
+
+func newPiiPlugin(options Options, store *functions.FunctionStore) (plugin.Interface, error) {
+	var config piiOptions
+	if err := options.Decode(&config); err != nil {
+		return nil, err
+	}
+
+	var detectors []pii.Detector
+	for _, kind := range config.Kinds {
+		index := slices.IndexFunc(pii.DefaultDetectors, func(detector pii.Detector) bool {
+			return detector.Kind == kind
+		})
+		if index < 0 {
+			return nil, fmt.Errorf("unknown kind %s", kind)
+		}
+		detectors = append(detectors, pii.DefaultDetectors[index])
+	}
+	return plugin.NewPiiPlugin(store, detectors...), nil
+}
+
+// injectionOptions are the options of the injection plugin.
+// The classifier is only called when there is an endpoint, after the heuristic rules.
+type injectionOptions struct {
+	Action             plugin.InjectionAction `yaml:"action"`
+	SensitiveFunctions []string               `yaml:"sensitive_functions"`
+	Threshold          float64                `yaml:"threshold"`
+	Classifier         struct {
+		Endpoint  string  `yaml:"endpoint"`
+		ApiKey    string  `yaml:"api_key"`
+		Model     string  `yaml:"model"`
+		Threshold float64 `yaml:"threshold"`
+	} `yaml:"classifier"`
+}
+
+func newInjectionPlugin(options Options, store *functions.FunctionStore) (plugin.Interface, error) {
+	var config injectionOptions
+	if err := options.Decode(&config); err != nil {
+		return nil, err
+	}
+
+	switch config.Action {
+	case "", plugin.MarkInjection, plugin.QuarantineInjection:
+	default:
+		return nil, fmt.Errorf("unknown action %s", config.Action)
+	}
+
+	detectors := []injection.Detector{injection.NewHeuristicDetector(config.Threshold)}
+	if endpoint := os.ExpandEnv(config.Classifier.Endpoint); len(endpoint) > 0 {
+		detectors = append(detectors, injection.NewClassifierDetector(injection.ClassifierConfig{
+			Endpoint:  endpoint,
+			ApiKey:    os.ExpandEnv(config.Classifier.ApiKey),
+			Model:     config.Classifier.Model,
+			Threshold: config.Classifier.Threshold,
+		}))
+	}
+
+	// a bot configuration cannot ask the user, so the sensitive calls are rejected after a suspicious result
+	return plugin.NewInjectionPlugin(store, plugin.InjectionOptions{
+		Action:             config.Action,
+		SensitiveFunctions: config.SensitiveFunctions,
+	}, detectors...), nil
+}
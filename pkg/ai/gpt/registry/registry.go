@@ -0,0 +1,180 @@
+package registry
+
+import (
+	"errors"
+	"fmt"
+	"github.com/meta-metopia/go-packages/pkg/ai/gpt"
+	"github.com/meta-metopia/go-packages/pkg/ai/gpt/functions"
+	"github.com/meta-metopia/go-packages/pkg/ai/gpt/plugin"
+	"slices"
+	"sync"
+)
+
+// ErrNotRegistered is returned when no factory is registered with the name of a plugin or a function.
+var ErrNotRegistered = errors.New("not registered")
+
+// PluginFactory creates a plugin from its options in the bot configuration.
+// [store] is the store of the conversation, for the plugins keeping a state such as the pii plugin.
+type PluginFactory func(options Options, store *functions.FunctionStore) (plugin.Interface, error)
+
+// FunctionFactory creates a function from its options in the bot configuration.
+type FunctionFactory func(options Options) (functions.FunctionInterface, error)
+
+// Registry holds the factories of the plugins and functions a bot configuration can use.
+// It is safe for concurrent use.
+type Registry struct {
+	mutex     sync.RWMutex
+	plugins   map[string]PluginFactory
+	functions map[string]FunctionFactory
+}
+
+// Default is the registry used by the package level functions.
+var Default = NewRegistry()
+
+// NewRegistry returns a new instance of Registry with the plugins of this module registered.
+func NewRegistry() *Registry {
+	registry := &Registry{
+		plugins:   map[string]PluginFactory{},
+		functions: map[string]FunctionFactory{},
+	}
+	registry.RegisterPlugin("standard", func(options Options, store *functions.FunctionStore) (plugin.Interface, error) {
+		return plugin.NewStandardOutputPlugin(), nil
+	})
+	registry.RegisterPlugin("moderation", newModerationPlugin)
+	registry.RegisterPlugin("pii", newPiiPlugin)
+	registry.RegisterPlugin("injection", newInjectionPlugin)
+	return registry
+}
+
+// RegisterPlugin registers the factory of the plugin [name].
+// It panics if a plugin is already registered with the name, like database/sql does for drivers.
+func (r *Registry) RegisterPlugin(name string, factory PluginFactory) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if _, found := r.plugins[name]; found {
+		panic("registry: plugin " + name + " is already registered")
+	}
+	r.plugins[name] = factory
+}
+
+// RegisterFunction registers the factory of the function [name].
+// It panics if a function is already registered with the name.
+func (r *Registry) RegisterFunction(name string, factory FunctionFactory) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if _, found := r.functions[name]; found {
+		panic("registry: function " + name + " is already registered")
+	}
+	r.functions[name] = factory
+}
+
+// Plugins returns the sorted names of the registered plugins.
+func (r *Registry) Plugins() []string {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return sortedNames(r.plugins)
+}
+
+// Functions returns the sorted names of the registered functions.
+func (r *Registry) Functions() []string {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return sortedNames(r.functions)
+}
+
+func sortedNames[T any](factories map[string]T) []string {
+	names := make([]string, 0, len(factories))
+	for name := range factories {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Plugin creates the plugin [name] with the options, for the conversation of the store.
+func (r *Registry) Plugin(name string, options Options, store *functions.FunctionStore) (plugin.Interface, error) {
+	r.mutex.RLock()
+	factory, found := r.plugins[name]
+	r.mutex.RUnlock()
+	if !found {
+		return nil, fmt.Errorf("plugin %s: %w", name, ErrNotRegistered)
+	}
+
+	createdPlugin, err := factory(options, store)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create plugin %s: %w", name, err)
+	}
+	return createdPlugin, nil
+}
+
+// Function creates the function [name] with the options.
+func (r *Registry) Function(name string, options Options) (functions.FunctionInterface, error) {
+	r.mutex.RLock()
+	factory, found := r.functions[name]
+	r.mutex.RUnlock()
+	if !found {
+		return nil, fmt.Errorf("function %s: %w", name, ErrNotRegistered)
+	}
+
+	function, err := factory(options)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create function %s: %w", name, err)
+	}
+	return function, nil
+}
+
+// Build returns the configuration of the GPT client described by the bot configuration.
+// [base] provides what the file cannot describe, such as the template engine, the store, the middlewares and the logger.
+func (r *Registry) Build(config Config, base gpt.Config) (gpt.Config, error) {
+	base.Endpoint = config.Endpoint
+	base.ApiKey = config.ApiKey
+	base.Model = config.Model
+	base.Prompt = config.Prompt
+	base.Temperature = config.Temperature
+	base.TopP = config.TopP
+	base.Seed = config.Seed
+	base.MaxTokens = config.MaxTokens
+	if base.Store == nil {
+		// the plugins and the functions share the store of the client
+		base.Store = functions.NewFunctionStore()
+	}
+
+	base.Plugins = nil
+	if len(config.Plugins) > 0 {
+		plugins := make([]plugin.Interface, 0, len(config.Plugins))
+		for _, component := range config.Plugins {
+			createdPlugin, err := r.Plugin(component.Name, component.Options, base.Store)
+			if err != nil {
+				return gpt.Config{}, err
+			}
+			plugins = append(plugins, createdPlugin)
+		}
+		base.Plugins = &plugins
+	}
+
+	gptFunctions := make([]functions.FunctionInterface, 0, len(config.Functions))
+	for _, component := range config.Functions {
+		function, err := r.Function(component.Name, component.Options)
+		if err != nil {
+			return gpt.Config{}, err
+		}
+		gptFunctions = append(gptFunctions, function)
+	}
+	base.Functions = &gptFunctions
+	return base, nil
+}
+
+// RegisterPlugin registers the factory of the plugin [name] in the Default registry.
+func RegisterPlugin(name string, factory PluginFactory) {
+	Default.RegisterPlugin(name, factory)
+}
+
+// RegisterFunction registers the factory of the function [name] in the Default registry.
+func RegisterFunction(name string, factory FunctionFactory) {
+	Default.RegisterFunction(name, factory)
+}
+
+// Build returns the configuration of the GPT client described by the bot configuration, using the Default registry.
+func Build(config Config, base gpt.Config) (gpt.Config, error) {
+	return Default.Build(config, base)
+}
@@ -0,0 +1,133 @@
+package registry
+
+import (
+	"fmt"
+	"github.com/meta-metopia/go-packages/pkg/ai/gpt"
+	"github.com/meta-metopia/go-packages/pkg/ai/gpt/functions"
+	"github.com/meta-metopia/go-packages/pkg/ai/gpt/plugin"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	"testing"
+)
+
+// namedPlugin is a plugin named by its options.
+type namedPlugin struct {
+	plugin.Client
+	name string
+}
+
+func (n *namedPlugin) Name() string {
+	return n.name
+}
+
+func (n *namedPlugin) Description() string {
+	return "Plugin named by its options."
+}
+
+func newNamedPlugin(options Options, _ *functions.FunctionStore) (plugin.Interface, error) {
+	var config struct {
+		Name string `yaml:"name"`
+	}
+	if err := options.Decode(&config); err != nil {
+		return nil, err
+	}
+	return &namedPlugin{name: config.Name}, nil
+}
+
+func TestRegistry_Register(t *testing.T) {
+	registry := NewRegistry()
+	registry.RegisterPlugin("named", newNamedPlugin)
+	registry.RegisterFunction("named", func(options Options) (functions.FunctionInterface, error) {
+		return functions.NewMockFunctionInterface(gomock.NewController(t)), nil
+	})
+
+	assert.Equal(t, []string{"injection", "moderation", "named", "pii", "standard"}, registry.Plugins())
+	assert.Equal(t, []string{"named"}, registry.Functions())
+	assert.Panics(t, func() {
+		registry.RegisterPlugin("standard", newNamedPlugin)
+	})
+	assert.Panics(t, func() {
+		registry.RegisterFunction("named", nil)
+	})
+}
+
+func TestRegistry_Build(t *testing.T) {
+	registry := NewRegistry()
+	registry.RegisterPlugin("named", newNamedPlugin)
+	registry.RegisterPlugin("failing", func(options Options, store *functions.FunctionStore) (plugin.Interface, error) {
+		return nil, fmt.Errorf("missing key")
+	})
+	registry.RegisterFunction("named", func(options Options) (functions.FunctionInterface, error) {
+		return functions.NewMockFunctionInterface(gomock.NewController(t)), nil
+	})
+
+	tests := []struct {
+		name        string
+		config      Config
+		wantPlugins []string
+		wantErr     string
+	}{
+		{
+			name: "Test with plugins in order",
+			config: Config{
+				Model: "gpt-4",
+				Plugins: []Component{
+					{Name: "named", Options: Options{"name": "first"}},
+					{Name: "standard"},
+					{Name: "named", Options: Options{"name": "last"}},
+				},
+				Functions: []Component{{Name: "named"}},
+			},
+			wantPlugins: []string{"first", "standard", "last"},
+		},
+		{
+			name:   "Test without plugins",
+			config: Config{Model: "gpt-4"},
+		},
+		{
+			name:    "Test with unknown plugin",
+			config:  Config{Plugins: []Component{{Name: "unknown"}}},
+			wantErr: "plugin unknown: not registered",
+		},
+		{
+			name:    "Test with unknown function",
+			config:  Config{Functions: []Component{{Name: "unknown"}}},
+			wantErr: "function unknown: not registered",
+		},
+		{
+			name:    "Test with failing factory",
+			config:  Config{Plugins: []Component{{Name: "failing"}}},
+			wantErr: "failed to create plugin failing: missing key",
+		},
+		{
+			name:    "Test with invalid options",
+			config:  Config{Plugins: []Component{{Name: "named", Options: Options{"names": "first"}}}},
+			wantErr: "failed to create plugin named: invalid options: yaml: unmarshal errors:\n  line 1: field names not found in type struct { Name string \"yaml:\\\"name\\\"\" }",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := functions.NewFunctionStore()
+			config, err := registry.Build(tt.config, gpt.Config{Store: store})
+			if len(tt.wantErr) > 0 {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tt.config.Model, config.Model)
+			assert.Equal(t, store, config.Store)
+			assert.Len(t, *config.Functions, len(tt.config.Functions))
+
+			if tt.wantPlugins == nil {
+				assert.Nil(t, config.Plugins)
+				return
+			}
+			var names []string
+			for _, foundPlugin := range *config.Plugins {
+				names = append(names, foundPlugin.Name())
+			}
+			assert.Equal(t, tt.wantPlugins, names)
+		})
+	}
+}
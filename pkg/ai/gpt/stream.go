@@ -0,0 +1,242 @@
+package gpt
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/go-resty/resty/v2"
+	"github.com/meta-metopia/go-packages/pkg/ai/gpt/dto"
+	"github.com/meta-metopia/go-packages/pkg/ai/gpt/middleware"
+	"github.com/meta-metopia/go-packages/pkg/ai/gpt/telemetry"
+	"io"
+	"slices"
+	"strings"
+)
+
+// maxEventSize is the maximum size of a server-sent event of a streamed response.
+const maxEventSize = 1024 * 1024
+
+// StreamEvent is an event of GenerateStream.
+type StreamEvent struct {
+	// Delta is a part of the content of the answer being generated.
+	Delta string
+	// Response is only set on the last event, once the whole turn is generated.
+	Response *GenerateResponse
+}
+
+type GenerateStreamRet = func(func(event StreamEvent, err error) bool)
+
+// GenerateStream streams the answers while they are generated, then yields the response of the turn as Generate returns it.
+// The answers of the functions and of GPT interpreting them are streamed as well.
+// When a plugin can change the answers, such as the moderation or the pii plugin, every answer is streamed at once
+// after the plugins converted it, so the deltas always match the responses. See plugin.PassthroughPlugin.
+// Stopping the iteration cancels the request being streamed.
+func (g *Client) GenerateStream(ctx context.Context, prompt any, history []dto.Message) GenerateStreamRet {
+	return func(yield func(event StreamEvent, err error) bool) {
+		g, err := g.snapshot()
+		if err != nil {
+			yield(StreamEvent{}, err)
+			return
+		}
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		ctx, span := g.config.Telemetry.StartTurn(ctx)
+		defer func() {
+			telemetry.End(span, err)
+		}()
+
+		stopped := false
+		onDelta := func(delta string) {
+			if stopped {
+				return
+			}
+			if !yield(StreamEvent{Delta: delta}, nil) {
+				stopped = true
+				cancel()
+			}
+		}
+
+		input, err := g.usePluginForInput(ctx, prompt)
+		if err != nil {
+			g.log(ctx).ErrorContext(ctx, "failed to convert input", logKeyError, err)
+			yield(StreamEvent{}, err)
+			return
+		}
+
+		if isOpenAIEndpoint(g.config.Endpoint) && len(g.config.Model) == 0 {
+			err = fmt.Errorf("model is required for openai gpt endpoint")
+			yield(StreamEvent{}, err)
+			return
+		}
+
+		newMessage, messages, err := g.createMessages(input, history)
+		if err != nil {
+			g.log(ctx).ErrorContext(ctx, "failed to render prompt", logKeyError, err)
+			yield(StreamEvent{}, err)
+			return
+		}
+
+		// history is copied so that appending never writes to the caller's backing array
+		fullHistory := append(slices.Clone(history), *newMessage)
+		var newResponses []dto.Message
+		var pending *PendingApproval
+		for output, generateErr := range g.generate(ctx, messages, onDelta) {
+			if stopped {
+				return
+			}
+			if generateErr != nil {
+				err = generateErr
+				yield(StreamEvent{}, err)
+				return
+			}
+			if output.pending != nil {
+				pending = output.pending.withHistory(fullHistory)
+				break
+			}
+			newResponses, fullHistory = appendOutput(newResponses, fullHistory, output)
+		}
+		if stopped {
+			return
+		}
+
+		yield(StreamEvent{
+			Response: &GenerateResponse{
+				NewResponses:    newResponses,
+				FullHistory:     fullHistory,
+				CacheHit:        hasCacheHit(newResponses),
+				PendingApproval: pending,
+			},
+		}, nil)
+	}
+}
+
+// sendStream posts the streamed request and assembles the response from its events.
+func sendStream(ctx context.Context, requestClient *resty.Request, request *middleware.Request) (*middleware.Response, error) {
+	response, err := requestClient.SetBody(requestBody(request.Body)).SetDoNotParseResponse(true).Post(request.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+	body := response.RawBody()
+	defer body.Close()
+
+	if !response.IsSuccess() {
+		data, _ := io.ReadAll(body)
+		return nil, &middleware.StatusError{
+			StatusCode: response.StatusCode(),
+			Header:     response.Header(),
+			Body:       string(data),
+		}
+	}
+
+	gptResponse, err := readStream(ctx, body, request.OnDelta)
+	if err != nil {
+		return nil, err
+	}
+	return &middleware.Response{
+		StatusCode: response.StatusCode(),
+		Header:     response.Header(),
+		Body:       gptResponse,
+	}, nil
+}
+
+// readStream reads the server-sent events of a streamed response, passing the content deltas to [onDelta],
+// and returns the response assembled from the events.
+func readStream(ctx context.Context, reader io.Reader, onDelta func(delta string)) (*dto.ResponseDto, error) {
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxEventSize)
+
+	var content strings.Builder
+	var toolCalls []dto.ToolCall
+	response := &dto.ResponseDto{}
+	choice := dto.Choice{
+		Message: dto.MessageResponseDto{Role: dto.RoleAssistant},
+	}
+	received := false
+	fail := func(err error) error {
+		if received {
+			return &middleware.StreamError{Err: err}
+		}
+		return err
+	}
+
+	for scanner.Scan() {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		data, ok := strings.CutPrefix(scanner.Text(), "data:")
+		if !ok {
+			continue
+		}
+		data = strings.TrimSpace(data)
+		if data == "[DONE]" {
+			break
+		}
+
+		var chunk dto.StreamChunkDto
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return nil, fail(fmt.Errorf("invalid event %s: %w", data, err))
+		}
+		if len(chunk.Id) > 0 {
+			response.Id = chunk.Id
+		}
+		if len(chunk.Model) > 0 {
+			response.Model = chunk.Model
+		}
+		if chunk.Usage != nil {
+			response.Usage = chunk.Usage
+		}
+
+		for _, streamChoice := range chunk.Choices {
+			if streamChoice.Index != 0 {
+				continue
+			}
+			delta := streamChoice.Delta
+			if len(delta.Role) > 0 {
+				choice.Message.Role = delta.Role
+			}
+			if len(delta.Content) > 0 {
+				content.WriteString(delta.Content)
+				if onDelta != nil {
+					// the failures after a delta was passed on cannot be retried, the buffered ones still can
+					received = true
+					onDelta(delta.Content)
+				}
+			}
+			for _, toolCallDelta := range delta.ToolCalls {
+				for len(toolCalls) <= toolCallDelta.Index {
+					toolCalls = append(toolCalls, dto.ToolCall{})
+				}
+				toolCall := &toolCalls[toolCallDelta.Index]
+				if len(toolCallDelta.Id) > 0 {
+					toolCall.Id = toolCallDelta.Id
+				}
+				if len(toolCallDelta.Type) > 0 {
+					toolCall.Type = toolCallDelta.Type
+				}
+				toolCall.Function.Name += toolCallDelta.Function.Name
+				toolCall.Function.Arguments += toolCallDelta.Function.Arguments
+			}
+			if len(streamChoice.FinishReason) > 0 {
+				choice.FinishReason = streamChoice.FinishReason
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
+		return nil, fail(err)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	choice.Message.Content = content.String()
+	if len(toolCalls) > 0 {
+		choice.Message.ToolCalls = &toolCalls
+	}
+	response.Choices = []dto.Choice{choice}
+	return response, nil
+}
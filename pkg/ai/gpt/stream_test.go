@@ -0,0 +1,343 @@
+package gpt
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/jarcoal/httpmock"
+	template "github.com/meta-metopia/go-packages/pkg/ai"
+	"github.com/meta-metopia/go-packages/pkg/ai/gpt/dto"
+	"github.com/meta-metopia/go-packages/pkg/ai/gpt/functions"
+	"github.com/meta-metopia/go-packages/pkg/ai/gpt/middleware"
+	"github.com/meta-metopia/go-packages/pkg/ai/gpt/plugin"
+	"github.com/meta-metopia/go-packages/pkg/ai/moderation"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// events returns the body of a streamed response sending the chunks.
+func events(chunks ...string) string {
+	var body strings.Builder
+	for _, chunk := range chunks {
+		body.WriteString("data: " + chunk + "\n\n")
+	}
+	body.WriteString("data: [DONE]\n\n")
+	return body.String()
+}
+
+func (suite *GptTestSuite) TestGptWithStream() {
+	engine := template.NewMockEngine(suite.ctrl)
+	engine.EXPECT().Render(gomock.Any()).Return("Mock Data", nil).AnyTimes()
+
+	var stream bool
+	url := "http://localhost:8080"
+	httpmock.RegisterResponder("POST", url, func(request *http.Request) (*http.Response, error) {
+		var body dto.RequestDto
+		if err := json.NewDecoder(request.Body).Decode(&body); err != nil {
+			return nil, err
+		}
+		stream = body.Stream
+		return httpmock.NewStringResponse(http.StatusOK, events(
+			`{"choices":[{"index":0,"delta":{"role":"assistant","content":"你好"}}]}`,
+			`{"choices":[{"index":0,"delta":{"content":"！"},"finish_reason":"stop"}]}`,
+			`{"choices":[],"usage":{"prompt_tokens":10,"completion_tokens":2}}`,
+		)), nil
+	})
+
+	client, err := NewGptClient(
+		Config{
+			Endpoint: url,
+			ApiKey:   "123",
+			Template: engine,
+			Store:    functions.NewFunctionStore(),
+		},
+	)
+	assert.Nil(suite.T(), err)
+	client.SetClient(suite.client)
+
+	var deltas []string
+	var response *GenerateResponse
+	for event, err := range client.GenerateStream(context.Background(), "Prompt", []dto.Message{}) {
+		assert.Nil(suite.T(), err)
+		if event.Response != nil {
+			response = event.Response
+			continue
+		}
+		deltas = append(deltas, event.Delta)
+	}
+
+	assert.True(suite.T(), stream)
+	assert.Equal(suite.T(), []string{"你好", "！"}, deltas)
+	assert.Equal(suite.T(), 1, len(response.NewResponses))
+	assert.Equal(suite.T(), "你好！", response.NewResponses[0].Content)
+	assert.Equal(suite.T(), 10, response.NewResponses[0].Usage.PromptToken)
+	assert.Equal(suite.T(), 2, len(response.FullHistory))
+}
+
+func (suite *GptTestSuite) TestGptWithStreamAndFunctionCall() {
+	engine := template.NewMockEngine(suite.ctrl)
+	engine.EXPECT().Render(gomock.Any()).Return("Mock Data", nil).AnyTimes()
+
+	url := "http://localhost:8080"
+	httpmock.RegisterResponder("POST", url, httpmock.ResponderFromMultipleResponses([]*http.Response{
+		httpmock.NewStringResponse(http.StatusOK, events(
+			`{"choices":[{"index":0,"delta":{"role":"assistant","tool_calls":[{"index":0,"id":"1","type":"function","function":{"name":"Mock Function","arguments":""}}]}}]}`,
+			`{"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"{\"prompt\":"}}]}}]}`,
+			`{"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"\"Prompt\"}"}}]},"finish_reason":"tool_calls"}]}`,
+		)),
+		httpmock.NewStringResponse(http.StatusOK, events(
+			`{"choices":[{"index":0,"delta":{"role":"assistant","content":"Mock "}}]}`,
+			`{"choices":[{"index":0,"delta":{"content":"Data"}}]}`,
+		)),
+	}))
+
+	var aiFunctions = make([]functions.FunctionInterface, 1)
+	function := functions.NewMockFunctionInterface(suite.ctrl)
+	aiFunctions[0] = function
+	function.EXPECT().OnMessage(map[string]interface{}{"prompt": "Prompt"}).Return(&functions.FunctionGptResponse{Content: "Mock Function Response"}, nil).Times(1)
+	function.EXPECT().Name().Return("Mock Function").AnyTimes()
+	function.EXPECT().Description().Return("Mock Function Description").AnyTimes()
+	function.EXPECT().Parameters().Return(map[string]interface{}{}).AnyTimes()
+	function.EXPECT().SetStore(gomock.Any()).Times(1)
+	function.EXPECT().Config().Return(functions.FunctionConfig{UseGptToInterpretResponses: true}).Times(1)
+	function.EXPECT().OnInit().Times(1)
+
+	client, err := NewGptClient(
+		Config{
+			Endpoint:  url,
+			ApiKey:    "123",
+			Functions: &aiFunctions,
+			Template:  engine,
+			Store:     functions.NewFunctionStore(),
+		},
+	)
+	assert.Nil(suite.T(), err)
+	client.SetClient(suite.client)
+
+	var deltas []string
+	var response *GenerateResponse
+	for event, err := range client.GenerateStream(context.Background(), "Prompt", []dto.Message{}) {
+		assert.Nil(suite.T(), err)
+		if event.Response != nil {
+			response = event.Response
+			continue
+		}
+		deltas = append(deltas, event.Delta)
+	}
+
+	assert.Equal(suite.T(), []string{"Mock ", "Data"}, deltas)
+	assert.Equal(suite.T(), 4, len(response.FullHistory))
+	assert.Equal(suite.T(), "1", (*response.FullHistory[1].ToolCalls)[0].Id)
+	assert.Equal(suite.T(), dto.RoleTool, response.FullHistory[2].Role)
+	assert.Equal(suite.T(), "Mock Data", response.FullHistory[3].Content)
+}
+
+func (suite *GptTestSuite) TestGptWithStreamAndConvertingPlugins() {
+	tests := []struct {
+		name       string
+		plugins    func(store *functions.FunctionStore) []plugin.Interface
+		content    []string
+		wantDeltas []string
+	}{
+		{
+			name: "Test with pii plugin restoring the answer",
+			plugins: func(store *functions.FunctionStore) []plugin.Interface {
+				return []plugin.Interface{plugin.NewStandardOutputPlugin(), plugin.NewPiiPlugin(store)}
+			},
+			content:    []string{"已登記 [PHO", "NE_1]"},
+			wantDeltas: []string{"已登記 0912345678"},
+		},
+		{
+			name: "Test with moderation plugin refusing the answer",
+			plugins: func(*functions.FunctionStore) []plugin.Interface {
+				return []plugin.Interface{plugin.NewModerationPlugin(
+					plugin.ModerationOptions{Refusal: "I cannot answer.", SkipInput: true},
+					moderation.NewRuleModerator(moderation.KeywordRule("violence", "bomb")),
+				)}
+			},
+			content:    []string{"Build a ", "bomb"},
+			wantDeltas: []string{"I cannot answer."},
+		},
+		{
+			name: "Test with moderation plugin skipping the outputs",
+			plugins: func(*functions.FunctionStore) []plugin.Interface {
+				return []plugin.Interface{plugin.NewModerationPlugin(
+					plugin.ModerationOptions{Refusal: "I cannot answer.", SkipOutput: true},
+					moderation.NewRuleModerator(moderation.KeywordRule("violence", "bomb")),
+				)}
+			},
+			content:    []string{"Hello", " there"},
+			wantDeltas: []string{"Hello", " there"},
+		},
+	}
+
+	for _, tt := range tests {
+		suite.Run(tt.name, func() {
+			httpmock.Reset()
+			engine := template.NewMockEngine(suite.ctrl)
+			engine.EXPECT().Render(gomock.Any()).Return("Mock Data", nil).AnyTimes()
+
+			var chunks []string
+			for _, content := range tt.content {
+				chunks = append(chunks, fmt.Sprintf(`{"choices":[{"index":0,"delta":{"role":"assistant","content":%q}}]}`, content))
+			}
+			url := "http://localhost:8080"
+			httpmock.RegisterResponder("POST", url, httpmock.NewStringResponder(http.StatusOK, events(chunks...)))
+
+			store := functions.NewFunctionStore()
+			plugins := tt.plugins(store)
+			client, err := NewGptClient(
+				Config{
+					Endpoint: url,
+					ApiKey:   "123",
+					Template: engine,
+					Store:    store,
+					Plugins:  &plugins,
+				},
+			)
+			assert.Nil(suite.T(), err)
+			client.SetClient(suite.client)
+
+			var deltas []string
+			var response *GenerateResponse
+			for event, err := range client.GenerateStream(context.Background(), "我的電話是 0912345678", []dto.Message{}) {
+				assert.Nil(suite.T(), err)
+				if event.Response != nil {
+					response = event.Response
+					continue
+				}
+				deltas = append(deltas, event.Delta)
+			}
+
+			// the deltas always match the answer returned at the end
+			assert.Equal(suite.T(), tt.wantDeltas, deltas)
+			assert.Equal(suite.T(), strings.Join(tt.wantDeltas, ""), response.NewResponses[0].Content)
+		})
+	}
+}
+
+func (suite *GptTestSuite) TestGptWithStreamStopped() {
+	engine := template.NewMockEngine(suite.ctrl)
+	engine.EXPECT().Render(gomock.Any()).Return("Mock Data", nil).AnyTimes()
+
+	url := "http://localhost:8080"
+	httpmock.RegisterResponder("POST", url, httpmock.NewStringResponder(http.StatusOK, events(
+		`{"choices":[{"index":0,"delta":{"content":"One"}}]}`,
+		`{"choices":[{"index":0,"delta":{"content":"Two"}}]}`,
+	)))
+
+	client, err := NewGptClient(
+		Config{
+			Endpoint: url,
+			ApiKey:   "123",
+			Template: engine,
+			Store:    functions.NewFunctionStore(),
+		},
+	)
+	assert.Nil(suite.T(), err)
+	client.SetClient(suite.client)
+
+	var deltas []string
+	for event, err := range client.GenerateStream(context.Background(), "Prompt", []dto.Message{}) {
+		assert.Nil(suite.T(), err)
+		deltas = append(deltas, event.Delta)
+		break
+	}
+	assert.Equal(suite.T(), []string{"One"}, deltas)
+}
+
+func (suite *GptTestSuite) TestGptWithStreamError() {
+	engine := template.NewMockEngine(suite.ctrl)
+	engine.EXPECT().Render(gomock.Any()).Return("Mock Data", nil).AnyTimes()
+
+	url := "http://localhost:8080"
+	httpmock.RegisterResponder("POST", url, httpmock.NewStringResponder(http.StatusTooManyRequests, "rate limited"))
+
+	client, err := NewGptClient(
+		Config{
+			Endpoint: url,
+			ApiKey:   "123",
+			Template: engine,
+			Store:    functions.NewFunctionStore(),
+		},
+	)
+	assert.Nil(suite.T(), err)
+	client.SetClient(suite.client)
+
+	var errs []error
+	for _, err := range client.GenerateStream(context.Background(), "Prompt", []dto.Message{}) {
+		errs = append(errs, err)
+	}
+	assert.Len(suite.T(), errs, 1)
+	var statusError *middleware.StatusError
+	assert.ErrorAs(suite.T(), errs[0], &statusError)
+	assert.Equal(suite.T(), "rate limited", statusError.Body)
+}
+
+type failingReader struct {
+	reader io.Reader
+}
+
+func (f failingReader) Read(p []byte) (int, error) {
+	n, err := f.reader.Read(p)
+	if err == io.EOF {
+		return n, fmt.Errorf("connection reset")
+	}
+	return n, err
+}
+
+func TestReadStream(t *testing.T) {
+	tests := []struct {
+		name            string
+		reader          io.Reader
+		onDelta         func(delta string)
+		wantErr         bool
+		wantStreamError bool
+	}{
+		{
+			name:   "Test with comments and empty lines",
+			reader: strings.NewReader(": keep alive\n\n" + events(`{"choices":[{"index":0,"delta":{"content":"Hi"}}]}`)),
+		},
+		{
+			name:    "Test with error before the first delta",
+			reader:  failingReader{reader: strings.NewReader(": keep alive\n")},
+			wantErr: true,
+		},
+		{
+			name:            "Test with error after the first delta",
+			reader:          failingReader{reader: strings.NewReader(`data: {"choices":[{"index":0,"delta":{"content":"Hi"}}]}` + "\n")},
+			onDelta:         func(delta string) {},
+			wantErr:         true,
+			wantStreamError: true,
+		},
+		{
+			name:    "Test with error after the first buffered delta",
+			reader:  failingReader{reader: strings.NewReader(`data: {"choices":[{"index":0,"delta":{"content":"Hi"}}]}` + "\n")},
+			wantErr: true,
+		},
+		{
+			name:    "Test with invalid event",
+			reader:  strings.NewReader("data: {\n"),
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response, err := readStream(context.Background(), tt.reader, tt.onDelta)
+			if tt.wantErr {
+				assert.NotNil(t, err)
+				var streamError *middleware.StreamError
+				assert.Equal(t, tt.wantStreamError, errors.As(err, &streamError))
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, "Hi", response.Choices[0].Message.Content)
+		})
+	}
+}
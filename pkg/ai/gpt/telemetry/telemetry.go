@@ -0,0 +1,263 @@
+package telemetry
+
+import (
+	"context"
+	"errors"
+	"github.com/meta-metopia/go-packages/pkg/ai/gpt/middleware"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	metricnoop "go.opentelemetry.io/otel/metric/noop"
+	"go.opentelemetry.io/otel/trace"
+	tracenoop "go.opentelemetry.io/otel/trace/noop"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// InstrumentationName is the name of the tracer and meter used by the gpt client.
+const InstrumentationName = "github.com/meta-metopia/go-packages/pkg/ai/gpt"
+
+// Attribute keys following the OpenTelemetry GenAI semantic conventions.
+const (
+	AttributeSystem         = attribute.Key("gen_ai.system")
+	AttributeOperationName  = attribute.Key("gen_ai.operation.name")
+	AttributeRequestModel   = attribute.Key("gen_ai.request.model")
+	AttributeResponseModel  = attribute.Key("gen_ai.response.model")
+	AttributeResponseId     = attribute.Key("gen_ai.response.id")
+	AttributeFinishReasons  = attribute.Key("gen_ai.response.finish_reasons")
+	AttributeCacheHit       = attribute.Key("gen_ai.response.cache_hit")
+	AttributeTarget         = attribute.Key("gen_ai.response.target")
+	AttributeInputTokens    = attribute.Key("gen_ai.usage.input_tokens")
+	AttributeOutputTokens   = attribute.Key("gen_ai.usage.output_tokens")
+	AttributeTokenType      = attribute.Key("gen_ai.token.type")
+	AttributeToolName       = attribute.Key("gen_ai.tool.name")
+	AttributeToolCallId     = attribute.Key("gen_ai.tool.call.id")
+	AttributePluginName     = attribute.Key("gen_ai.plugin.name")
+	AttributePluginStage    = attribute.Key("gen_ai.plugin.stage")
+	AttributeErrorType      = attribute.Key("error.type")
+	operationChat           = "chat"
+	operationExecuteTool    = "execute_tool"
+	systemOpenAI            = "openai"
+	systemAzureOpenAI       = "az.ai.openai"
+	errorTypeOther          = "_OTHER"
+	tokenTypeInput          = "input"
+	tokenTypeOutput         = "output"
+	metricRequests          = "gen_ai.client.requests"
+	metricOperationDuration = "gen_ai.client.operation.duration"
+	metricTokenUsage        = "gen_ai.client.token.usage"
+	metricErrors            = "gen_ai.client.errors"
+)
+
+// Telemetry emits the traces and metrics of the gpt client.
+type Telemetry struct {
+	tracer   trace.Tracer
+	requests metric.Int64Counter
+	duration metric.Float64Histogram
+	tokens   metric.Int64Counter
+	errors   metric.Int64Counter
+}
+
+// New returns a new instance of Telemetry using the given providers.
+func New(tracerProvider trace.TracerProvider, meterProvider metric.MeterProvider) (*Telemetry, error) {
+	meter := meterProvider.Meter(InstrumentationName)
+	requests, err := meter.Int64Counter(
+		metricRequests,
+		metric.WithDescription("Number of completion requests sent to the GPT API."),
+		metric.WithUnit("{request}"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	duration, err := meter.Float64Histogram(
+		metricOperationDuration,
+		metric.WithDescription("Duration of the completion requests."),
+		metric.WithUnit("s"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	tokens, err := meter.Int64Counter(
+		metricTokenUsage,
+		metric.WithDescription("Number of input and output tokens used."),
+		metric.WithUnit("{token}"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	errorCounter, err := meter.Int64Counter(
+		metricErrors,
+		metric.WithDescription("Number of failed completion requests by error type."),
+		metric.WithUnit("{error}"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Telemetry{
+		tracer:   tracerProvider.Tracer(InstrumentationName),
+		requests: requests,
+		duration: duration,
+		tokens:   tokens,
+		errors:   errorCounter,
+	}, nil
+}
+
+// NewGlobal returns a new instance of Telemetry using the global OpenTelemetry providers.
+// The global providers are no-op unless the host application configures them.
+func NewGlobal() *Telemetry {
+	t, err := New(otel.GetTracerProvider(), otel.GetMeterProvider())
+	if err != nil {
+		otel.Handle(err)
+		t, _ = New(tracenoop.NewTracerProvider(), metricnoop.NewMeterProvider())
+	}
+	return t
+}
+
+// Middleware returns the middleware that traces and measures every completion request.
+func (t *Telemetry) Middleware() middleware.Middleware {
+	return func(next middleware.RoundTrip) middleware.RoundTrip {
+		return func(ctx context.Context, request *middleware.Request) (*middleware.Response, error) {
+			model := requestModel(request)
+			commonAttributes := []attribute.KeyValue{
+				AttributeSystem.String(system(request.Endpoint)),
+				AttributeOperationName.String(operationChat),
+				AttributeRequestModel.String(model),
+			}
+
+			ctx, span := t.tracer.Start(
+				ctx,
+				strings.TrimSpace(operationChat+" "+model),
+				trace.WithSpanKind(trace.SpanKindClient),
+				trace.WithAttributes(commonAttributes...),
+			)
+			defer span.End()
+
+			start := time.Now()
+			response, err := next(ctx, request)
+			elapsed := time.Since(start).Seconds()
+
+			metricAttributes := commonAttributes
+			if err != nil {
+				errorType := ErrorType(err)
+				metricAttributes = append(metricAttributes, AttributeErrorType.String(errorType))
+				span.SetAttributes(AttributeErrorType.String(errorType))
+				span.RecordError(err)
+				span.SetStatus(codes.Error, err.Error())
+				t.errors.Add(ctx, 1, metric.WithAttributes(metricAttributes...))
+			}
+			t.requests.Add(ctx, 1, metric.WithAttributes(metricAttributes...))
+			t.duration.Record(ctx, elapsed, metric.WithAttributes(metricAttributes...))
+			if err != nil {
+				return response, err
+			}
+
+			if response != nil && response.Body != nil {
+				body := response.Body
+				var finishReasons []string
+				for _, choice := range body.Choices {
+					if len(choice.FinishReason) > 0 {
+						finishReasons = append(finishReasons, choice.FinishReason)
+					}
+				}
+				span.SetAttributes(
+					AttributeResponseModel.String(body.Model),
+					AttributeResponseId.String(body.Id),
+					AttributeFinishReasons.StringSlice(finishReasons),
+					AttributeCacheHit.Bool(response.CacheHit),
+					AttributeTarget.String(response.Target),
+				)
+				if body.Usage != nil {
+					span.SetAttributes(
+						AttributeInputTokens.Int(body.Usage.PromptToken),
+						AttributeOutputTokens.Int(body.Usage.CompletionToken),
+					)
+					t.tokens.Add(ctx, int64(body.Usage.PromptToken), metric.WithAttributes(
+						append(commonAttributes, AttributeTokenType.String(tokenTypeInput))...,
+					))
+					t.tokens.Add(ctx, int64(body.Usage.CompletionToken), metric.WithAttributes(
+						append(commonAttributes, AttributeTokenType.String(tokenTypeOutput))...,
+					))
+				}
+			}
+			return response, nil
+		}
+	}
+}
+
+// StartTurn starts the span that covers a whole conversation turn.
+func (t *Telemetry) StartTurn(ctx context.Context) (context.Context, trace.Span) {
+	return t.tracer.Start(ctx, "gpt.turn", trace.WithSpanKind(trace.SpanKindInternal))
+}
+
+// StartTool starts the span that covers a single function call.
+func (t *Telemetry) StartTool(ctx context.Context, name string, toolCallId string) (context.Context, trace.Span) {
+	return t.tracer.Start(
+		ctx,
+		operationExecuteTool+" "+name,
+		trace.WithSpanKind(trace.SpanKindInternal),
+		trace.WithAttributes(
+			AttributeOperationName.String(operationExecuteTool),
+			AttributeToolName.String(name),
+			AttributeToolCallId.String(toolCallId),
+		),
+	)
+}
+
+// StartPlugin starts the span that covers a single plugin conversion.
+// [stage] is one of the plugin stages: "input", "output", "tool_call" or "tool_result".
+func (t *Telemetry) StartPlugin(ctx context.Context, name string, stage string) (context.Context, trace.Span) {
+	return t.tracer.Start(
+		ctx,
+		"plugin "+stage+" "+name,
+		trace.WithSpanKind(trace.SpanKindInternal),
+		trace.WithAttributes(
+			AttributePluginName.String(name),
+			AttributePluginStage.String(stage),
+		),
+	)
+}
+
+// End records the error on the span if there is one and ends the span.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.SetAttributes(AttributeErrorType.String(ErrorType(err)))
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// ErrorType returns the value of the error.type attribute for the error.
+// Errors returned by the GPT API are reported by their status code.
+func ErrorType(err error) string {
+	var statusError *middleware.StatusError
+	if errors.As(err, &statusError) {
+		return strconv.Itoa(statusError.StatusCode)
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return "timeout"
+	}
+	if errors.Is(err, context.Canceled) {
+		return "canceled"
+	}
+	return errorTypeOther
+}
+
+func requestModel(request *middleware.Request) string {
+	if request.Body != nil && request.Body.Model != nil {
+		return *request.Body.Model
+	}
+	return ""
+}
+
+func system(endpoint string) string {
+	if strings.Contains(endpoint, "api.openai.com") {
+		return systemOpenAI
+	}
+	return systemAzureOpenAI
+}
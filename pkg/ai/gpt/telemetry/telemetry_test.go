@@ -0,0 +1,141 @@
+package telemetry
+
+import (
+	"context"
+	"fmt"
+	"github.com/meta-metopia/go-packages/pkg/ai/gpt/dto"
+	"github.com/meta-metopia/go-packages/pkg/ai/gpt/middleware"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"net/http"
+	"testing"
+)
+
+func newTestTelemetry(t *testing.T) (*Telemetry, *tracetest.InMemoryExporter, *sdkmetric.ManualReader) {
+	exporter := tracetest.NewInMemoryExporter()
+	reader := sdkmetric.NewManualReader()
+	telemetry, err := New(
+		sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)),
+		sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return telemetry, exporter, reader
+}
+
+func sumOf(t *testing.T, reader *sdkmetric.ManualReader, name string, filter attribute.KeyValue) int64 {
+	var metrics metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &metrics); err != nil {
+		t.Fatal(err)
+	}
+
+	var total int64
+	for _, scope := range metrics.ScopeMetrics {
+		for _, m := range scope.Metrics {
+			if m.Name != name {
+				continue
+			}
+			for _, point := range m.Data.(metricdata.Sum[int64]).DataPoints {
+				if value, ok := point.Attributes.Value(filter.Key); ok && value == filter.Value {
+					total += point.Value
+				}
+			}
+		}
+	}
+	return total
+}
+
+func TestMiddleware(t *testing.T) {
+	telemetry, exporter, reader := newTestTelemetry(t)
+	model := "gpt-4"
+	transport := func(ctx context.Context, request *middleware.Request) (*middleware.Response, error) {
+		return &middleware.Response{
+			StatusCode: http.StatusOK,
+			Body: &dto.ResponseDto{
+				Id:      "chatcmpl-1",
+				Model:   "gpt-4-0613",
+				Choices: []dto.Choice{{FinishReason: "stop"}},
+				Usage:   &dto.Usage{PromptToken: 10, CompletionToken: 5},
+			},
+		}, nil
+	}
+
+	_, err := telemetry.Middleware()(transport)(context.Background(), &middleware.Request{
+		Endpoint: "https://api.openai.com/v1/chat/completions",
+		Body:     &dto.RequestDto{Model: &model},
+	})
+	assert.Nil(t, err)
+
+	spans := exporter.GetSpans()
+	assert.Equal(t, 1, len(spans))
+	assert.Equal(t, "chat gpt-4", spans[0].Name)
+	assert.Contains(t, spans[0].Attributes, AttributeSystem.String("openai"))
+	assert.Contains(t, spans[0].Attributes, AttributeResponseModel.String("gpt-4-0613"))
+	assert.Contains(t, spans[0].Attributes, AttributeFinishReasons.StringSlice([]string{"stop"}))
+	assert.Contains(t, spans[0].Attributes, AttributeInputTokens.Int(10))
+	assert.Contains(t, spans[0].Attributes, AttributeOutputTokens.Int(5))
+
+	assert.Equal(t, int64(1), sumOf(t, reader, metricRequests, AttributeRequestModel.String(model)))
+	assert.Equal(t, int64(10), sumOf(t, reader, metricTokenUsage, AttributeTokenType.String("input")))
+	assert.Equal(t, int64(5), sumOf(t, reader, metricTokenUsage, AttributeTokenType.String("output")))
+}
+
+func TestMiddlewareWithError(t *testing.T) {
+	telemetry, exporter, reader := newTestTelemetry(t)
+	transport := func(ctx context.Context, request *middleware.Request) (*middleware.Response, error) {
+		return nil, &middleware.StatusError{StatusCode: http.StatusTooManyRequests}
+	}
+
+	_, err := telemetry.Middleware()(transport)(context.Background(), &middleware.Request{Endpoint: "https://example.com"})
+	assert.NotNil(t, err)
+
+	spans := exporter.GetSpans()
+	assert.Equal(t, 1, len(spans))
+	assert.Equal(t, codes.Error, spans[0].Status.Code)
+	assert.Contains(t, spans[0].Attributes, AttributeSystem.String("az.ai.openai"))
+	assert.Equal(t, int64(1), sumOf(t, reader, metricErrors, AttributeErrorType.String("429")))
+	assert.Equal(t, int64(1), sumOf(t, reader, metricRequests, AttributeErrorType.String("429")))
+}
+
+func TestErrorType(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{
+			name: "Test with status error",
+			err:  fmt.Errorf("wrapped: %w", &middleware.StatusError{StatusCode: http.StatusInternalServerError}),
+			want: "500",
+		},
+		{
+			name: "Test with deadline exceeded",
+			err:  context.DeadlineExceeded,
+			want: "timeout",
+		},
+		{
+			name: "Test with canceled",
+			err:  context.Canceled,
+			want: "canceled",
+		},
+		{
+			name: "Test with other error",
+			err:  fmt.Errorf("other error"),
+			want: "_OTHER",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ErrorType(tt.err); got != tt.want {
+				t.Errorf("ErrorType() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package telemetrytest
+
+import (
+	"context"
+	"github.com/meta-metopia/go-packages/pkg/ai/gpt/telemetry"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// Recorder keeps the spans and metrics emitted by a Telemetry in memory.
+// It is meant to be used in tests.
+type Recorder struct {
+	Telemetry *telemetry.Telemetry
+	exporter  *tracetest.InMemoryExporter
+	reader    *sdkmetric.ManualReader
+}
+
+// NewRecorder returns a new instance of Recorder.
+func NewRecorder() *Recorder {
+	exporter := tracetest.NewInMemoryExporter()
+	reader := sdkmetric.NewManualReader()
+	tracerProvider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	meterProvider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+
+	t, err := telemetry.New(tracerProvider, meterProvider)
+	if err != nil {
+		// the sdk providers never fail to create the instruments used by Telemetry
+		panic(err)
+	}
+
+	return &Recorder{
+		Telemetry: t,
+		exporter:  exporter,
+		reader:    reader,
+	}
+}
+
+// Spans returns the ended spans in the order they ended.
+func (r *Recorder) Spans() tracetest.SpanStubs {
+	return r.exporter.GetSpans()
+}
+
+// SpanNames returns the names of the ended spans in the order they ended.
+func (r *Recorder) SpanNames() []string {
+	var names []string
+	for _, span := range r.exporter.GetSpans() {
+		names = append(names, span.Name)
+	}
+	return names
+}
+
+// Metrics collects the metrics recorded so far.
+func (r *Recorder) Metrics() (metricdata.ResourceMetrics, error) {
+	var metrics metricdata.ResourceMetrics
+	err := r.reader.Collect(context.Background(), &metrics)
+	return metrics, err
+}
+
+// Sum returns the total of the counter with the given name, or 0 if it was not recorded.
+func (r *Recorder) Sum(name string) int64 {
+	metrics, err := r.Metrics()
+	if err != nil {
+		return 0
+	}
+
+	var total int64
+	for _, scope := range metrics.ScopeMetrics {
+		for _, m := range scope.Metrics {
+			if m.Name != name {
+				continue
+			}
+			if sum, ok := m.Data.(metricdata.Sum[int64]); ok {
+				for _, point := range sum.DataPoints {
+					total += point.Value
+				}
+			}
+		}
+	}
+	return total
+}
+
+// Reset removes the recorded spans.
+func (r *Recorder) Reset() {
+	r.exporter.Reset()
+}
@@ -0,0 +1,120 @@
+package tokens
+
+import (
+	"encoding/json"
+	"github.com/meta-metopia/go-packages/pkg/ai/gpt/dto"
+	"math"
+	"unicode/utf8"
+)
+
+const (
+	// messageOverhead is the number of tokens added by the chat format for every message.
+	messageOverhead = 4
+	// replyOverhead is the number of tokens priming the assistant reply.
+	replyOverhead = 3
+	// asciiCharactersPerToken is the average number of ascii characters in a token.
+	asciiCharactersPerToken = 4
+	// imageBaseTokens is the number of tokens of every image, and of the whole image in low detail.
+	imageBaseTokens = 85
+	// imageTileTokens is the number of tokens of every 512px tile of an image in high detail.
+	imageTileTokens = 170
+	// unknownImageTokens is the estimation of an image of unknown size, the cost of a 1024px square image.
+	unknownImageTokens = imageBaseTokens + 4*imageTileTokens
+)
+
+// EstimateText returns an estimation of the number of tokens in the text.
+// Ascii text averages 4 characters per token, while other scripts such as Chinese average about one token per character.
+func EstimateText(text string) int {
+	ascii := 0
+	other := 0
+	for len(text) > 0 {
+		r, size := utf8.DecodeRuneInString(text)
+		text = text[size:]
+		if r < utf8.RuneSelf {
+			ascii++
+		} else {
+			other++
+		}
+	}
+	return (ascii+asciiCharactersPerToken-1)/asciiCharactersPerToken + other
+}
+
+// EstimateContent returns an estimation of the number of tokens in the content of the message, including its images.
+// Audio parts are not estimated.
+func EstimateContent(message dto.Message) int {
+	if len(message.Parts) == 0 {
+		return EstimateText(message.Content)
+	}
+
+	total := 0
+	for _, part := range message.Parts {
+		switch part.Type {
+		case dto.ContentPartText:
+			total += EstimateText(part.Text)
+		case dto.ContentPartImageUrl:
+			if part.ImageUrl != nil {
+				total += EstimateImage(part.ImageUrl.Width, part.ImageUrl.Height, part.ImageUrl.Detail)
+			}
+		}
+	}
+	return total
+}
+
+// EstimateImage returns the number of tokens of an image following the OpenAI vision pricing.
+// Low detail images cost a flat amount. Other images are scaled to fit in 2048px, then their shortest side
+// is scaled down to 768px, and every 512px tile is counted. Images of unknown size, with a zero width or height,
+// are estimated as a 1024px square.
+func EstimateImage(width int, height int, detail dto.ImageDetail) int {
+	if detail == dto.ImageDetailLow {
+		return imageBaseTokens
+	}
+	if width <= 0 || height <= 0 {
+		return unknownImageTokens
+	}
+
+	w, h := float64(width), float64(height)
+	if longest := max(w, h); longest > 2048 {
+		w, h = w*2048/longest, h*2048/longest
+	}
+	if shortest := min(w, h); shortest > 768 {
+		w, h = w*768/shortest, h*768/shortest
+	}
+	tiles := int(math.Ceil(w/512)) * int(math.Ceil(h/512))
+	return imageBaseTokens + tiles*imageTileTokens
+}
+
+// EstimateMessages returns an estimation of the number of prompt tokens used by the messages.
+func EstimateMessages(messages []dto.Message) int {
+	total := replyOverhead
+	for _, message := range messages {
+		total += messageOverhead + EstimateText(message.Role) + EstimateContent(message)
+		if message.Name != nil {
+			total += EstimateText(*message.Name)
+		}
+		if message.ToolCalls != nil {
+			for _, toolCall := range *message.ToolCalls {
+				total += EstimateText(toolCall.Function.Name) + EstimateText(toolCall.Function.Arguments)
+			}
+		}
+	}
+	return total
+}
+
+// EstimateRequest returns an estimation of the number of tokens the request counts against a tokens per minute quota:
+// the prompt tokens, the tool definitions and the maximum number of completion tokens.
+func EstimateRequest(request *dto.RequestDto) int {
+	if request == nil {
+		return 0
+	}
+
+	total := EstimateMessages(request.Messages)
+	if len(request.Tools) > 0 {
+		if encoded, err := json.Marshal(request.Tools); err == nil {
+			total += EstimateText(string(encoded))
+		}
+	}
+	if request.MaxTokens != nil {
+		total += *request.MaxTokens
+	}
+	return total
+}
@@ -0,0 +1,155 @@
+package tokens
+
+import (
+	"github.com/meta-metopia/go-packages/pkg/ai/gpt/dto"
+	"testing"
+)
+
+func TestEstimateText(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want int
+	}{
+		{
+			name: "Test with empty text",
+			text: "",
+			want: 0,
+		},
+		{
+			name: "Test with ascii text",
+			text: "Hello world!",
+			want: 3,
+		},
+		{
+			name: "Test with chinese text",
+			text: "你好",
+			want: 2,
+		},
+		{
+			name: "Test with mixed text",
+			text: "我要 fried rice",
+			want: 5,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := EstimateText(tt.text); got != tt.want {
+				t.Errorf("EstimateText() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEstimateImage(t *testing.T) {
+	tests := []struct {
+		name   string
+		width  int
+		height int
+		detail dto.ImageDetail
+		want   int
+	}{
+		{
+			name:   "Test with low detail",
+			width:  4096,
+			height: 4096,
+			detail: dto.ImageDetailLow,
+			want:   85,
+		},
+		{
+			name:   "Test with square image",
+			width:  1024,
+			height: 1024,
+			detail: dto.ImageDetailHigh,
+			// scaled to 768x768: 4 tiles
+			want: 765,
+		},
+		{
+			name:   "Test with large image",
+			width:  2048,
+			height: 4096,
+			detail: dto.ImageDetailAuto,
+			// scaled to 1024x2048 then 768x1536: 6 tiles
+			want: 1105,
+		},
+		{
+			name:   "Test with small image",
+			width:  100,
+			height: 100,
+			detail: dto.ImageDetailHigh,
+			want:   255,
+		},
+		{
+			name:   "Test with unknown size",
+			detail: dto.ImageDetailHigh,
+			want:   765,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := EstimateImage(tt.width, tt.height, tt.detail); got != tt.want {
+				t.Errorf("EstimateImage() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEstimateRequest(t *testing.T) {
+	maxTokens := 100
+	tests := []struct {
+		name    string
+		request *dto.RequestDto
+		want    int
+	}{
+		{
+			name:    "Test with nil request",
+			request: nil,
+			want:    0,
+		},
+		{
+			name: "Test with messages",
+			request: &dto.RequestDto{
+				Messages: []dto.Message{
+					{Role: dto.RoleSystem, Content: "Be nice"},
+					{Role: dto.RoleUser, Content: "你好"},
+				},
+			},
+			// reply 3 + (4 + 2 + 2) + (4 + 1 + 2)
+			want: 18,
+		},
+		{
+			name: "Test with max tokens",
+			request: &dto.RequestDto{
+				Messages:  []dto.Message{{Role: dto.RoleUser, Content: "你好"}},
+				MaxTokens: &maxTokens,
+			},
+			want: 110,
+		},
+		{
+			name: "Test with image",
+			request: &dto.RequestDto{
+				Messages: []dto.Message{
+					{
+						Role: dto.RoleUser,
+						Parts: []dto.ContentPart{
+							dto.TextPart("你好"),
+							dto.ImageUrlPart("https://example.com/image.png", dto.ImageDetailLow),
+						},
+					},
+				},
+			},
+			// reply 3 + (4 + 1 + 2 + 85)
+			want: 95,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := EstimateRequest(tt.request); got != tt.want {
+				t.Errorf("EstimateRequest() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,312 @@
+package upstream
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/meta-metopia/go-packages/pkg/ai/gpt/middleware"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ErrNoTargetAvailable is returned when the circuit of every target is open.
+var ErrNoTargetAvailable = errors.New("no upstream target available")
+
+const (
+	defaultFailureThreshold = 3
+	defaultCooldown         = 30 * time.Second
+	// latencySmoothing is the weight of the latest latency in the moving average.
+	latencySmoothing = 0.2
+)
+
+// Target is an upstream deployment the completion requests can be sent to.
+type Target struct {
+	// Name identifies the target in the responses and stats, so it must be unique. Defaults to the Endpoint,
+	// followed by #index when several targets share the endpoint, such as with several api keys.
+	Name     string
+	Endpoint string
+	ApiKey   string
+	// Model overrides the model of the request. Leave it empty for Azure deployments.
+	Model string
+	// Weight is used by the weighted strategy. Defaults to 1.
+	Weight int
+}
+
+type Options struct {
+	// Strategy decides the order the targets are tried in. Defaults to the failover strategy.
+	Strategy Strategy
+	// FailureThreshold is the number of consecutive failures that opens the circuit of a target. Defaults to 3.
+	FailureThreshold int
+	// Cooldown is how long the circuit of a target stays open. Defaults to 30 seconds.
+	// A 429 response opens the circuit for the duration of its Retry-After header instead, when it has one.
+	Cooldown time.Duration
+	// MaxAttempts is the number of targets tried for a single request. Defaults to the number of targets.
+	MaxAttempts int
+}
+
+// Stats is a snapshot of the state of a target.
+type Stats struct {
+	// Index is the position of the target in the pool, which identifies it across the calls of the strategy.
+	Index  int
+	Target Target
+	// Requests is the number of requests sent to the target.
+	Requests int
+	// Failures is the number of requests that failed with a retryable error.
+	Failures int
+	// ConsecutiveFailures is the number of retryable failures since the last success.
+	ConsecutiveFailures int
+	// Latency is the moving average of the successful requests' latency.
+	Latency time.Duration
+	// OpenUntil is the time the circuit of the target closes again. Zero when the circuit is closed.
+	OpenUntil time.Time
+}
+
+type targetState struct {
+	target              Target
+	requests            int
+	failures            int
+	consecutiveFailures int
+	latency             time.Duration
+	openUntil           time.Time
+}
+
+// Pool spreads the completion requests over several upstream targets and fails over to the next target on 429 and 5xx.
+type Pool struct {
+	mutex   sync.Mutex
+	targets []*targetState
+	options Options
+	now     func() time.Time
+}
+
+// NewPool returns a new instance of Pool.
+func NewPool(targets []Target, options Options) (*Pool, error) {
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("at least one upstream target is required")
+	}
+
+	endpoints := map[string]int{}
+	for _, target := range targets {
+		endpoints[target.Endpoint]++
+	}
+
+	var states []*targetState
+	names := map[string]bool{}
+	for index, target := range targets {
+		if len(target.Endpoint) == 0 {
+			return nil, fmt.Errorf("endpoint is required for upstream target %q", target.Name)
+		}
+		if len(target.Name) == 0 {
+			target.Name = target.Endpoint
+			if endpoints[target.Endpoint] > 1 {
+				target.Name = fmt.Sprintf("%s#%d", target.Endpoint, index)
+			}
+		}
+		if names[target.Name] {
+			return nil, fmt.Errorf("upstream target %q is duplicated", target.Name)
+		}
+		names[target.Name] = true
+		if target.Weight <= 0 {
+			target.Weight = 1
+		}
+		states = append(states, &targetState{target: target})
+	}
+
+	if options.Strategy == nil {
+		options.Strategy = NewFailoverStrategy()
+	}
+	if options.FailureThreshold <= 0 {
+		options.FailureThreshold = defaultFailureThreshold
+	}
+	if options.Cooldown <= 0 {
+		options.Cooldown = defaultCooldown
+	}
+	if options.MaxAttempts <= 0 {
+		options.MaxAttempts = len(states)
+	}
+
+	return &Pool{
+		targets: states,
+		options: options,
+		now:     time.Now,
+	}, nil
+}
+
+// Middleware returns the middleware that sends every request to the targets of the pool.
+// The endpoint, api key and model of the request are replaced by the ones of the target,
+// so Config.Endpoint and Config.ApiKey of the client can be left empty.
+func (p *Pool) Middleware() middleware.Middleware {
+	return func(next middleware.RoundTrip) middleware.RoundTrip {
+		return func(ctx context.Context, request *middleware.Request) (*middleware.Response, error) {
+			order := p.order()
+			if len(order) == 0 {
+				return nil, ErrNoTargetAvailable
+			}
+
+			var lastErr error
+			for attempt, index := range order {
+				if attempt >= p.options.MaxAttempts {
+					break
+				}
+				if err := ctx.Err(); err != nil {
+					return nil, err
+				}
+
+				target := p.targets[index].target
+				start := p.now()
+				response, err := next(ctx, requestFor(request, target))
+				if err == nil {
+					p.recordSuccess(index, p.now().Sub(start))
+					if response != nil {
+						response.Target = target.Name
+					}
+					return response, nil
+				}
+
+				lastErr = err
+				if !IsRetryable(err) {
+					p.recordAttempt(index)
+					return nil, err
+				}
+				p.recordFailure(index, err)
+			}
+			return nil, lastErr
+		}
+	}
+}
+
+// Stats returns a snapshot of the state of every target, in the order they were given.
+func (p *Pool) Stats() []Stats {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.stats()
+}
+
+// IsRetryable returns true when the request can be retried on another target:
+// the target is rate limited, failed with a 5xx, or could not be reached.
+func IsRetryable(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var streamError *middleware.StreamError
+	if errors.As(err, &streamError) {
+		return false
+	}
+
+	var statusError *middleware.StatusError
+	if errors.As(err, &statusError) {
+		return statusError.StatusCode == http.StatusTooManyRequests || statusError.StatusCode >= http.StatusInternalServerError
+	}
+	return true
+}
+
+// order returns the indexes of the available targets in the order given by the strategy.
+func (p *Pool) order() []int {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	now := p.now()
+	var available []int
+	var availableStats []Stats
+	for index, stats := range p.stats() {
+		if !stats.OpenUntil.IsZero() && now.Before(stats.OpenUntil) {
+			continue
+		}
+		available = append(available, index)
+		availableStats = append(availableStats, stats)
+	}
+
+	var order []int
+	for _, position := range p.options.Strategy.Order(availableStats) {
+		order = append(order, available[position])
+	}
+	return order
+}
+
+func (p *Pool) stats() []Stats {
+	var stats []Stats
+	for index, state := range p.targets {
+		stats = append(stats, Stats{
+			Index:               index,
+			Target:              state.target,
+			Requests:            state.requests,
+			Failures:            state.failures,
+			ConsecutiveFailures: state.consecutiveFailures,
+			Latency:             state.latency,
+			OpenUntil:           state.openUntil,
+		})
+	}
+	return stats
+}
+
+func (p *Pool) recordAttempt(index int) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.targets[index].requests++
+}
+
+func (p *Pool) recordSuccess(index int, latency time.Duration) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	state := p.targets[index]
+	state.requests++
+	state.consecutiveFailures = 0
+	state.openUntil = time.Time{}
+	if state.latency == 0 {
+		state.latency = latency
+	} else {
+		state.latency = time.Duration((1-latencySmoothing)*float64(state.latency) + latencySmoothing*float64(latency))
+	}
+}
+
+func (p *Pool) recordFailure(index int, err error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	state := p.targets[index]
+	state.requests++
+	state.failures++
+	state.consecutiveFailures++
+
+	if retryAfter, ok := retryAfter(err); ok {
+		state.openUntil = p.now().Add(retryAfter)
+		return
+	}
+	if state.consecutiveFailures >= p.options.FailureThreshold {
+		state.openUntil = p.now().Add(p.options.Cooldown)
+	}
+}
+
+// retryAfter returns the duration of the Retry-After header of a 429 response.
+func retryAfter(err error) (time.Duration, bool) {
+	var statusError *middleware.StatusError
+	if !errors.As(err, &statusError) || statusError.StatusCode != http.StatusTooManyRequests || statusError.Header == nil {
+		return 0, false
+	}
+
+	seconds, parseErr := strconv.Atoi(statusError.Header.Get("Retry-After"))
+	if parseErr != nil || seconds <= 0 {
+		return 0, false
+	}
+	return time.Duration(seconds) * time.Second, true
+}
+
+// requestFor returns a copy of the request sent to the target.
+func requestFor(request *middleware.Request, target Target) *middleware.Request {
+	targetRequest := *request
+	targetRequest.Endpoint = target.Endpoint
+	targetRequest.ApiKey = target.ApiKey
+	targetRequest.Header = request.Header.Clone()
+	if request.Body != nil {
+		body := *request.Body
+		if len(target.Model) > 0 {
+			model := target.Model
+			body.Model = &model
+		}
+		targetRequest.Body = &body
+	}
+	return &targetRequest
+}
@@ -0,0 +1,263 @@
+package upstream
+
+import (
+	"context"
+	"fmt"
+	"github.com/meta-metopia/go-packages/pkg/ai/gpt/dto"
+	"github.com/meta-metopia/go-packages/pkg/ai/gpt/middleware"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// scriptedTransport returns the error scripted for the endpoint of the request, or a successful response.
+type scriptedTransport struct {
+	errors   map[string][]error
+	requests []*middleware.Request
+}
+
+func (s *scriptedTransport) roundTrip(ctx context.Context, request *middleware.Request) (*middleware.Response, error) {
+	s.requests = append(s.requests, request)
+	if errs := s.errors[request.Endpoint]; len(errs) > 0 {
+		err := errs[0]
+		s.errors[request.Endpoint] = errs[1:]
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &middleware.Response{StatusCode: http.StatusOK, Body: &dto.ResponseDto{}}, nil
+}
+
+func (s *scriptedTransport) endpoints() []string {
+	var endpoints []string
+	for _, request := range s.requests {
+		endpoints = append(endpoints, request.Endpoint)
+	}
+	return endpoints
+}
+
+func newTestPool(t *testing.T, options Options) (*Pool, *time.Time) {
+	pool, err := NewPool([]Target{
+		{Name: "primary", Endpoint: "https://primary", ApiKey: "key-1", Model: "gpt-4"},
+		{Name: "secondary", Endpoint: "https://secondary", ApiKey: "key-2"},
+	}, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	pool.now = func() time.Time { return now }
+	return pool, &now
+}
+
+func newRequest() *middleware.Request {
+	model := "gpt-3.5-turbo"
+	return &middleware.Request{
+		Endpoint: "https://config",
+		ApiKey:   "config-key",
+		Header:   http.Header{},
+		Body:     &dto.RequestDto{Model: &model},
+	}
+}
+
+func TestNewPool(t *testing.T) {
+	_, err := NewPool(nil, Options{})
+	assert.NotNil(t, err)
+
+	_, err = NewPool([]Target{{Name: "missing endpoint"}}, Options{})
+	assert.NotNil(t, err)
+
+	pool, err := NewPool([]Target{{Endpoint: "https://primary"}}, Options{})
+	assert.Nil(t, err)
+	assert.Equal(t, "https://primary", pool.Stats()[0].Target.Name)
+	assert.Equal(t, 1, pool.Stats()[0].Target.Weight)
+
+	// the targets sharing an endpoint get their own names
+	pool, err = NewPool([]Target{{Endpoint: "https://primary", ApiKey: "a"}, {Endpoint: "https://primary", ApiKey: "b"}}, Options{})
+	assert.Nil(t, err)
+	assert.Equal(t, "https://primary#0", pool.Stats()[0].Target.Name)
+	assert.Equal(t, "https://primary#1", pool.Stats()[1].Target.Name)
+
+	_, err = NewPool([]Target{{Name: "primary", Endpoint: "https://a"}, {Name: "primary", Endpoint: "https://b"}}, Options{})
+	assert.NotNil(t, err)
+}
+
+func TestPool_WeightedKeysOfOneEndpoint(t *testing.T) {
+	pool, err := NewPool([]Target{
+		{Endpoint: "https://primary", ApiKey: "key-1", Weight: 3},
+		{Endpoint: "https://primary", ApiKey: "key-2", Weight: 1},
+	}, Options{Strategy: NewWeightedStrategy()})
+	assert.Nil(t, err)
+	transport := &scriptedTransport{}
+
+	targets := map[string]int{}
+	keys := map[string]int{}
+	for i := 0; i < 8; i++ {
+		response, err := pool.Middleware()(transport.roundTrip)(context.Background(), newRequest())
+		assert.Nil(t, err)
+		targets[response.Target]++
+	}
+	for _, request := range transport.requests {
+		keys[request.ApiKey]++
+	}
+
+	assert.Equal(t, map[string]int{"key-1": 6, "key-2": 2}, keys)
+	assert.Equal(t, map[string]int{"https://primary#0": 6, "https://primary#1": 2}, targets)
+	assert.Equal(t, 6, pool.Stats()[0].Requests)
+	assert.Equal(t, 2, pool.Stats()[1].Requests)
+}
+
+func TestPool_RewritesRequest(t *testing.T) {
+	pool, _ := newTestPool(t, Options{})
+	transport := &scriptedTransport{}
+	request := newRequest()
+
+	response, err := pool.Middleware()(transport.roundTrip)(context.Background(), request)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "primary", response.Target)
+	assert.Equal(t, "https://primary", transport.requests[0].Endpoint)
+	assert.Equal(t, "key-1", transport.requests[0].ApiKey)
+	assert.Equal(t, "gpt-4", *transport.requests[0].Body.Model)
+	// the original request is left untouched
+	assert.Equal(t, "https://config", request.Endpoint)
+	assert.Equal(t, "gpt-3.5-turbo", *request.Body.Model)
+}
+
+func TestPool_Failover(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		endpoints []string
+		wantErr   bool
+	}{
+		{
+			name:      "Test with rate limit",
+			err:       &middleware.StatusError{StatusCode: http.StatusTooManyRequests},
+			endpoints: []string{"https://primary", "https://secondary"},
+		},
+		{
+			name:      "Test with server error",
+			err:       &middleware.StatusError{StatusCode: http.StatusBadGateway},
+			endpoints: []string{"https://primary", "https://secondary"},
+		},
+		{
+			name:      "Test with network error",
+			err:       fmt.Errorf("connection refused"),
+			endpoints: []string{"https://primary", "https://secondary"},
+		},
+		{
+			name:      "Test with bad request",
+			err:       &middleware.StatusError{StatusCode: http.StatusBadRequest},
+			endpoints: []string{"https://primary"},
+			wantErr:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pool, _ := newTestPool(t, Options{})
+			transport := &scriptedTransport{errors: map[string][]error{"https://primary": {tt.err}}}
+
+			response, err := pool.Middleware()(transport.roundTrip)(context.Background(), newRequest())
+
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.Equal(t, tt.endpoints, transport.endpoints())
+			if !tt.wantErr {
+				assert.Equal(t, "secondary", response.Target)
+			}
+		})
+	}
+}
+
+func TestPool_CircuitBreaker(t *testing.T) {
+	pool, now := newTestPool(t, Options{FailureThreshold: 2, Cooldown: time.Minute})
+	serverError := &middleware.StatusError{StatusCode: http.StatusInternalServerError}
+	transport := &scriptedTransport{errors: map[string][]error{"https://primary": {serverError, serverError}}}
+	roundTrip := pool.Middleware()(transport.roundTrip)
+
+	for i := 0; i < 3; i++ {
+		response, err := roundTrip(context.Background(), newRequest())
+		assert.Nil(t, err)
+		assert.Equal(t, "secondary", response.Target)
+	}
+	// the circuit of the primary target opened after the second failure
+	assert.Equal(t, []string{
+		"https://primary", "https://secondary",
+		"https://primary", "https://secondary",
+		"https://secondary",
+	}, transport.endpoints())
+	assert.Equal(t, now.Add(time.Minute), pool.Stats()[0].OpenUntil)
+	assert.Equal(t, 2, pool.Stats()[0].Failures)
+
+	// after the cooldown the primary target is tried again
+	*now = now.Add(time.Minute)
+	response, err := roundTrip(context.Background(), newRequest())
+	assert.Nil(t, err)
+	assert.Equal(t, "primary", response.Target)
+	assert.True(t, pool.Stats()[0].OpenUntil.IsZero())
+	assert.Equal(t, 0, pool.Stats()[0].ConsecutiveFailures)
+}
+
+func TestPool_RetryAfter(t *testing.T) {
+	pool, now := newTestPool(t, Options{Cooldown: time.Minute})
+	rateLimited := &middleware.StatusError{
+		StatusCode: http.StatusTooManyRequests,
+		Header:     http.Header{"Retry-After": []string{"5"}},
+	}
+	transport := &scriptedTransport{errors: map[string][]error{"https://primary": {rateLimited}}}
+
+	_, err := pool.Middleware()(transport.roundTrip)(context.Background(), newRequest())
+	assert.Nil(t, err)
+	assert.Equal(t, now.Add(5*time.Second), pool.Stats()[0].OpenUntil)
+}
+
+func TestPool_NoTargetAvailable(t *testing.T) {
+	pool, _ := newTestPool(t, Options{FailureThreshold: 1})
+	serverError := &middleware.StatusError{StatusCode: http.StatusInternalServerError}
+	transport := &scriptedTransport{errors: map[string][]error{
+		"https://primary":   {serverError},
+		"https://secondary": {serverError},
+	}}
+	roundTrip := pool.Middleware()(transport.roundTrip)
+
+	_, err := roundTrip(context.Background(), newRequest())
+	assert.ErrorIs(t, err, serverError)
+
+	_, err = roundTrip(context.Background(), newRequest())
+	assert.ErrorIs(t, err, ErrNoTargetAvailable)
+}
+
+func TestPool_MaxAttempts(t *testing.T) {
+	pool, _ := newTestPool(t, Options{MaxAttempts: 1})
+	serverError := &middleware.StatusError{StatusCode: http.StatusInternalServerError}
+	transport := &scriptedTransport{errors: map[string][]error{"https://primary": {serverError}}}
+
+	_, err := pool.Middleware()(transport.roundTrip)(context.Background(), newRequest())
+	assert.ErrorIs(t, err, serverError)
+	assert.Equal(t, []string{"https://primary"}, transport.endpoints())
+}
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "Test with 429", err: &middleware.StatusError{StatusCode: http.StatusTooManyRequests}, want: true},
+		{name: "Test with 503", err: &middleware.StatusError{StatusCode: http.StatusServiceUnavailable}, want: true},
+		{name: "Test with 401", err: &middleware.StatusError{StatusCode: http.StatusUnauthorized}, want: false},
+		{name: "Test with canceled context", err: context.Canceled, want: false},
+		{name: "Test with network error", err: fmt.Errorf("dial tcp: connection refused"), want: true},
+		{name: "Test with stream error", err: &middleware.StreamError{Err: fmt.Errorf("connection reset")}, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsRetryable(tt.err); got != tt.want {
+				t.Errorf("IsRetryable() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package upstream
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// Strategy decides the order the available targets are tried in.
+type Strategy interface {
+	// Order returns the indexes of [targets] in the order they should be tried.
+	Order(targets []Stats) []int
+}
+
+// FailoverStrategy always tries the targets in the order they were given.
+type FailoverStrategy struct {
+}
+
+// NewFailoverStrategy returns a new instance of FailoverStrategy.
+func NewFailoverStrategy() Strategy {
+	return &FailoverStrategy{}
+}
+
+func (f *FailoverStrategy) Order(targets []Stats) []int {
+	order := make([]int, len(targets))
+	for i := range targets {
+		order[i] = i
+	}
+	return order
+}
+
+// RoundRobinStrategy starts every request on the next target, then fails over to the following ones.
+type RoundRobinStrategy struct {
+	counter atomic.Uint64
+}
+
+// NewRoundRobinStrategy returns a new instance of RoundRobinStrategy.
+func NewRoundRobinStrategy() Strategy {
+	return &RoundRobinStrategy{}
+}
+
+func (r *RoundRobinStrategy) Order(targets []Stats) []int {
+	if len(targets) == 0 {
+		return nil
+	}
+
+	start := int((r.counter.Add(1) - 1) % uint64(len(targets)))
+	order := make([]int, len(targets))
+	for i := range targets {
+		order[i] = (start + i) % len(targets)
+	}
+	return order
+}
+
+// WeightedStrategy starts the requests on the targets proportionally to their weight,
+// using the smooth weighted round-robin algorithm, then fails over by descending weight.
+// The state of the targets is kept by their Stats.Index, so the targets sharing an endpoint are told apart.
+type WeightedStrategy struct {
+	mutex   sync.Mutex
+	current map[int]int
+}
+
+// NewWeightedStrategy returns a new instance of WeightedStrategy.
+func NewWeightedStrategy() Strategy {
+	return &WeightedStrategy{
+		current: make(map[int]int),
+	}
+}
+
+func (w *WeightedStrategy) Order(targets []Stats) []int {
+	if len(targets) == 0 {
+		return nil
+	}
+
+	w.mutex.Lock()
+	total := 0
+	best := 0
+	for i, target := range targets {
+		w.current[target.Index] += target.Target.Weight
+		total += target.Target.Weight
+		if w.current[target.Index] > w.current[targets[best].Index] {
+			best = i
+		}
+	}
+	w.current[targets[best].Index] -= total
+	w.mutex.Unlock()
+
+	order := []int{best}
+	var rest []int
+	for i := range targets {
+		if i != best {
+			rest = append(rest, i)
+		}
+	}
+	sort.SliceStable(rest, func(a, b int) bool {
+		return targets[rest[a]].Target.Weight > targets[rest[b]].Target.Weight
+	})
+	return append(order, rest...)
+}
+
+// LeastLatencyStrategy tries the targets by ascending average latency.
+// Targets without a successful request yet are tried first, so every target gets measured.
+type LeastLatencyStrategy struct {
+}
+
+// NewLeastLatencyStrategy returns a new instance of LeastLatencyStrategy.
+func NewLeastLatencyStrategy() Strategy {
+	return &LeastLatencyStrategy{}
+}
+
+func (l *LeastLatencyStrategy) Order(targets []Stats) []int {
+	order := make([]int, len(targets))
+	for i := range targets {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return targets[order[a]].Latency < targets[order[b]].Latency
+	})
+	return order
+}
@@ -0,0 +1,59 @@
+package upstream
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func statsOf(weights ...int) []Stats {
+	var stats []Stats
+	for i, weight := range weights {
+		stats = append(stats, Stats{Index: i, Target: Target{Name: string(rune('a' + i)), Weight: weight}})
+	}
+	return stats
+}
+
+func TestFailoverStrategy(t *testing.T) {
+	strategy := NewFailoverStrategy()
+	assert.Equal(t, []int{0, 1, 2}, strategy.Order(statsOf(1, 1, 1)))
+	assert.Equal(t, []int{0, 1, 2}, strategy.Order(statsOf(1, 1, 1)))
+}
+
+func TestRoundRobinStrategy(t *testing.T) {
+	strategy := NewRoundRobinStrategy()
+	targets := statsOf(1, 1, 1)
+
+	assert.Equal(t, []int{0, 1, 2}, strategy.Order(targets))
+	assert.Equal(t, []int{1, 2, 0}, strategy.Order(targets))
+	assert.Equal(t, []int{2, 0, 1}, strategy.Order(targets))
+	assert.Equal(t, []int{0, 1, 2}, strategy.Order(targets))
+	assert.Nil(t, strategy.Order(nil))
+}
+
+func TestWeightedStrategy(t *testing.T) {
+	strategy := NewWeightedStrategy()
+	targets := statsOf(5, 1, 1)
+
+	firsts := map[int]int{}
+	for i := 0; i < 7; i++ {
+		order := strategy.Order(targets)
+		assert.Equal(t, 3, len(order))
+		firsts[order[0]]++
+	}
+	assert.Equal(t, map[int]int{0: 5, 1: 1, 2: 1}, firsts)
+
+	// the rest of the targets are ordered by descending weight
+	order := strategy.Order(statsOf(1, 3, 2))
+	assert.Equal(t, []int{1, 2, 0}, order)
+}
+
+func TestLeastLatencyStrategy(t *testing.T) {
+	strategy := NewLeastLatencyStrategy()
+	targets := statsOf(1, 1, 1)
+	targets[0].Latency = 300 * time.Millisecond
+	targets[1].Latency = 100 * time.Millisecond
+	targets[2].Latency = 0
+
+	assert.Equal(t, []int{2, 1, 0}, strategy.Order(targets))
+}
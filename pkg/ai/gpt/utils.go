@@ -2,7 +2,9 @@ package gpt
 
 import (
 	"encoding/json"
+	"fmt"
 	"github.com/meta-metopia/go-packages/pkg/ai/gpt/dto"
+	"slices"
 	"strings"
 )
 
@@ -10,27 +12,85 @@ func isOpenAIEndpoint(endpoint string) bool {
 	return strings.Contains(endpoint, "api.openai.com")
 }
 
-func filterOutUserMessages(messages []dto.Message) []dto.Message {
-	var filteredMessages []dto.Message
+func hasCacheHit(messages []dto.Message) bool {
 	for _, message := range messages {
-		if message.Role != dto.RoleUser {
-			filteredMessages = append(filteredMessages, message)
+		if message.CacheHit {
+			return true
 		}
 	}
-	return filteredMessages
+	return false
+}
+
+// newUserMessage returns the user message of the input.
+// The input can be a string, a string pointer, a content part or a list of content parts.
+func newUserMessage(input any) (*dto.Message, error) {
+	switch value := input.(type) {
+	case string:
+		return &dto.Message{Role: dto.RoleUser, Content: value}, nil
+	case *string:
+		if value != nil {
+			return &dto.Message{Role: dto.RoleUser, Content: *value}, nil
+		}
+	case dto.ContentPart:
+		return &dto.Message{Role: dto.RoleUser, Parts: []dto.ContentPart{value}}, nil
+	case []dto.ContentPart:
+		return &dto.Message{Role: dto.RoleUser, Parts: slices.Clone(value)}, nil
+	}
+
+	return nil, fmt.Errorf("last plugin should return a string or content parts. Got %v", input)
 }
 
 func stringPtr(s string) *string {
 	return &s
 }
 
+// cleanMessages returns a copy of the messages without their usage. The given messages are left untouched.
 func cleanMessages(messages []dto.Message) []dto.Message {
+	cleaned := make([]dto.Message, len(messages))
 	for i, message := range messages {
-		if message.Usage != nil {
-			messages[i].Usage = nil
+		message.Usage = nil
+		cleaned[i] = message
+	}
+	return cleaned
+}
+
+// requestBody returns the body sent to the API: a copy of [body] whose images have no size,
+// since the size is only known by the client. [body] is returned as is when it has no image size.
+func requestBody(body *dto.RequestDto) *dto.RequestDto {
+	sized := slices.ContainsFunc(body.Messages, func(message dto.Message) bool {
+		return slices.ContainsFunc(message.Parts, func(part dto.ContentPart) bool {
+			return part.ImageUrl != nil && (part.ImageUrl.Width > 0 || part.ImageUrl.Height > 0)
+		})
+	})
+	if !sized {
+		return body
+	}
+
+	sent := *body
+	sent.Messages = make([]dto.Message, len(body.Messages))
+	for i, message := range body.Messages {
+		if len(message.Parts) > 0 {
+			message.Parts = slices.Clone(message.Parts)
+			for j, part := range message.Parts {
+				if part.ImageUrl != nil {
+					imageUrl := *part.ImageUrl
+					imageUrl.Width, imageUrl.Height = 0, 0
+					message.Parts[j].ImageUrl = &imageUrl
+				}
+			}
 		}
+		sent.Messages[i] = message
+	}
+	return &sent
+}
+
+// copySlice returns a pointer to a copy of the slice. A nil pointer returns an empty slice.
+func copySlice[T any](slice *[]T) *[]T {
+	copied := make([]T, 0)
+	if slice != nil {
+		copied = append(copied, *slice...)
 	}
-	return messages
+	return &copied
 }
 
 // convertFunctionContentToString converts the content to a string depending on the type
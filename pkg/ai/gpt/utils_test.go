@@ -33,34 +33,6 @@ func TestIsOpenAIEndpoint(t *testing.T) {
 	}
 }
 
-func TestFilterOutUserMessages(t *testing.T) {
-	tests := []struct {
-		name     string
-		messages []dto.Message
-		want     []dto.Message
-	}{
-		{
-			name: "Test with user messages",
-			messages: []dto.Message{
-				{Role: dto.RoleUser},
-				{Role: dto.RoleAssistant},
-				{Role: dto.RoleUser},
-			},
-			want: []dto.Message{
-				{Role: dto.RoleAssistant},
-			},
-		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			if got := filterOutUserMessages(tt.messages); !reflect.DeepEqual(got, tt.want) {
-				t.Errorf("filterOutUserMessages() = %v, want %v", got, tt.want)
-			}
-		})
-	}
-}
-
 func TestConvertFunctionContentToString(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -136,3 +108,13 @@ func TestCleanMessages(t *testing.T) {
 		})
 	}
 }
+
+func TestCleanMessagesKeepsInput(t *testing.T) {
+	usage := &dto.Usage{PromptToken: 10}
+	messages := []dto.Message{{Usage: usage}}
+
+	cleanMessages(messages)
+	if messages[0].Usage != usage {
+		t.Errorf("cleanMessages() changed the usage of the input to %v", messages[0].Usage)
+	}
+}
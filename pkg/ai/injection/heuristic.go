@@ -0,0 +1,90 @@
+package injection
+
+import (
+	"context"
+	"regexp"
+)
+
+const defaultThreshold = 0.5
+
+// Rule adds its weight to the score of the texts matching its pattern.
+type Rule struct {
+	Name    string
+	Pattern *regexp.Regexp
+	Weight  float64
+}
+
+// DefaultRules find the common prompt injections in English and Chinese.
+var DefaultRules = []Rule{
+	{
+		Name:    "ignore instructions",
+		Pattern: regexp.MustCompile(`(?is)\b(ignore|disregard|forget|override)\b.{0,40}\b(previous|prior|above|earlier|all|system)\b.{0,20}\b(instructions?|prompts?|rules|messages?)\b`),
+		Weight:  0.8,
+	},
+	{
+		Name:    "ignore instructions",
+		Pattern: regexp.MustCompile(`(忽略|無視|忽視|无视|忽视|忘記|忘记).{0,10}(之前|以上|先前|上面|所有|前面).{0,10}(指示|指令|規則|规则|提示)`),
+		Weight:  0.8,
+	},
+	{
+		Name:    "special tokens",
+		Pattern: regexp.MustCompile(`(?im)<\|?(im_start|im_end|system|endoftext)\|?>|^\s*#{2,}\s*(system|instructions?)\b`),
+		Weight:  0.6,
+	},
+	{
+		Name:    "exfiltration",
+		Pattern: regexp.MustCompile(`(?is)\b(send|post|forward|leak|reveal|print)\b.{0,40}\b(passwords?|api keys?|tokens?|secrets?|credentials|conversation|history)\b`),
+		Weight:  0.6,
+	},
+	{
+		Name:    "role change",
+		Pattern: regexp.MustCompile(`(?i)\byou are now\b|\bfrom now on\b|\bact as\b.{0,30}\b(admin|administrator|developer|system)\b|你現在是|你现在是|從現在開始|从现在开始`),
+		Weight:  0.4,
+	},
+	{
+		Name:    "system prompt",
+		Pattern: regexp.MustCompile(`(?i)\b(system prompt|developer message|hidden instructions)\b|系統提示|系统提示|系統指令|系统指令`),
+		Weight:  0.4,
+	},
+	{
+		Name:    "tool call",
+		Pattern: regexp.MustCompile(`(?is)\b(call|use|invoke|execute|run)\b.{0,30}\b(function|tool)\b|(呼叫|調用|调用|執行|执行).{0,10}(函數|函数|工具)`),
+		Weight:  0.3,
+	},
+}
+
+type HeuristicDetector struct {
+	rules     []Rule
+	threshold float64
+}
+
+// NewHeuristicDetector returns a new instance of the detector scoring the texts with the rules.
+// A text is suspicious when the weights of the rules it matches add up to the threshold. 0 uses 0.5.
+// The rules default to DefaultRules.
+func NewHeuristicDetector(threshold float64, rules ...Rule) Detector {
+	if threshold <= 0 {
+		threshold = defaultThreshold
+	}
+	if len(rules) == 0 {
+		rules = DefaultRules
+	}
+	return &HeuristicDetector{
+		rules:     rules,
+		threshold: threshold,
+	}
+}
+
+// Detect returns the result scored by the rules the text matches.
+func (h *HeuristicDetector) Detect(_ context.Context, text string) (Result, error) {
+	var result Result
+	for _, rule := range h.rules {
+		if !rule.Pattern.MatchString(text) {
+			continue
+		}
+		result.Score += rule.Weight
+		result.Reasons = append(result.Reasons, rule.Name)
+	}
+	result.Score = min(result.Score, 1)
+	result.Suspicious = result.Score >= h.threshold
+	return result, nil
+}
@@ -0,0 +1,176 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync/atomic"
+)
+
+type ClientConfig struct {
+	// Name of the client the server sees. Defaults to go-packages.
+	Name    string
+	Version string
+}
+
+// Client is a client of a Model Context Protocol server, sharing its tools, resources and prompts.
+type Client struct {
+	transport  Transport
+	nextId     atomic.Int64
+	serverInfo Implementation
+	// Instructions are the hints of the server on using its tools, which may be added to the prompt.
+	instructions string
+}
+
+type IClient interface {
+	// ServerInfo returns the name and the version of the server.
+	ServerInfo() Implementation
+	// Instructions returns the hints of the server on using its tools, which may be added to the prompt.
+	Instructions() string
+	ListTools(ctx context.Context) ([]Tool, error)
+	// CallTool calls the tool. Failed tools are results with IsError, not errors.
+	CallTool(ctx context.Context, name string, arguments map[string]any) (*CallToolResult, error)
+	ListResources(ctx context.Context) ([]Resource, error)
+	ReadResource(ctx context.Context, uri string) ([]ResourceContents, error)
+	ListPrompts(ctx context.Context) ([]Prompt, error)
+	GetPrompt(ctx context.Context, name string, arguments map[string]string) (*GetPromptResult, error)
+	// Close closes the transport.
+	Close() error
+}
+
+// NewClient returns a new instance of the client, once the server accepted the initialization.
+// The transport is closed when the initialization fails.
+func NewClient(ctx context.Context, transport Transport, config ClientConfig) (IClient, error) {
+	if len(config.Name) == 0 {
+		config.Name = "go-packages"
+	}
+	if len(config.Version) == 0 {
+		config.Version = "1.0.0"
+	}
+
+	client := &Client{transport: transport}
+	if err := client.initialize(ctx, config); err != nil {
+		_ = transport.Close()
+		return nil, fmt.Errorf("failed to initialize mcp client: %w", err)
+	}
+	return client, nil
+}
+
+func (c *Client) initialize(ctx context.Context, config ClientConfig) error {
+	result, err := call[initializeResult](ctx, c, "initialize", initializeParams{
+		ProtocolVersion: ProtocolVersion,
+		Capabilities:    map[string]any{},
+		ClientInfo:      Implementation{Name: config.Name, Version: config.Version},
+	})
+	if err != nil {
+		return err
+	}
+	c.serverInfo = result.ServerInfo
+	c.instructions = result.Instructions
+
+	notification, err := newMessage(nil, "notifications/initialized", nil)
+	if err != nil {
+		return err
+	}
+	return c.transport.Notify(ctx, notification)
+}
+
+func (c *Client) ServerInfo() Implementation {
+	return c.serverInfo
+}
+
+func (c *Client) Instructions() string {
+	return c.instructions
+}
+
+func (c *Client) ListTools(ctx context.Context) ([]Tool, error) {
+	return list[Tool](ctx, c, "tools/list", "tools")
+}
+
+func (c *Client) CallTool(ctx context.Context, name string, arguments map[string]any) (*CallToolResult, error) {
+	result, err := call[CallToolResult](ctx, c, "tools/call", callToolParams{Name: name, Arguments: arguments})
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (c *Client) ListResources(ctx context.Context) ([]Resource, error) {
+	return list[Resource](ctx, c, "resources/list", "resources")
+}
+
+func (c *Client) ReadResource(ctx context.Context, uri string) ([]ResourceContents, error) {
+	result, err := call[readResourceResult](ctx, c, "resources/read", readResourceParams{Uri: uri})
+	if err != nil {
+		return nil, err
+	}
+	return result.Contents, nil
+}
+
+func (c *Client) ListPrompts(ctx context.Context) ([]Prompt, error) {
+	return list[Prompt](ctx, c, "prompts/list", "prompts")
+}
+
+func (c *Client) GetPrompt(ctx context.Context, name string, arguments map[string]string) (*GetPromptResult, error) {
+	result, err := call[GetPromptResult](ctx, c, "prompts/get", getPromptParams{Name: name, Arguments: arguments})
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (c *Client) Close() error {
+	return c.transport.Close()
+}
+
+// call sends the request [method] and decodes its result as a T.
+func call[T any](ctx context.Context, c *Client, method string, params any) (T, error) {
+	var result T
+	id := strconv.FormatInt(c.nextId.Add(1), 10)
+	request, err := newMessage(json.RawMessage(id), method, params)
+	if err != nil {
+		return result, err
+	}
+
+	response, err := c.transport.Call(ctx, request)
+	if err != nil {
+		return result, err
+	}
+	if response.Error != nil {
+		return result, response.Error
+	}
+	if err := json.Unmarshal(response.Result, &result); err != nil {
+		return result, fmt.Errorf("invalid result of %s: %w", method, err)
+	}
+	return result, nil
+}
+
+// list returns the items of the result field [key] of all the pages of [method].
+func list[T any](ctx context.Context, c *Client, method string, key string) ([]T, error) {
+	all := make([]T, 0)
+	params := map[string]string{}
+	for {
+		result, err := call[map[string]json.RawMessage](ctx, c, method, params)
+		if err != nil {
+			return nil, err
+		}
+
+		var items []T
+		if data, found := result[key]; found {
+			if err := json.Unmarshal(data, &items); err != nil {
+				return nil, fmt.Errorf("invalid result of %s: %w", method, err)
+			}
+		}
+		all = append(all, items...)
+
+		var cursor string
+		if data, found := result["nextCursor"]; found {
+			_ = json.Unmarshal(data, &cursor)
+		}
+		if len(cursor) == 0 {
+			return all, nil
+		}
+		params = map[string]string{"cursor": cursor}
+	}
+}
@@ -0,0 +1,105 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/meta-metopia/go-packages/pkg/ai/gpt/functions"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestClient_Stdio(t *testing.T) {
+	ctx := context.Background()
+	client, err := NewClient(ctx, fixtureTransport(t), ClientConfig{})
+	assert.Nil(t, err)
+	defer func() {
+		assert.Nil(t, client.Close())
+	}()
+	assert.Equal(t, Implementation{Name: "fixture", Version: "0.1.0"}, client.ServerInfo())
+	assert.Equal(t, "Use echo to repeat a text.", client.Instructions())
+
+	tools, err := client.ListTools(ctx)
+	assert.Nil(t, err)
+	assert.Len(t, tools, 2)
+	assert.Equal(t, "echo", tools[0].Name)
+	assert.Equal(t, "fail", tools[1].Name)
+
+	result, err := client.CallTool(ctx, "echo", map[string]any{"text": "你好"})
+	assert.Nil(t, err)
+	assert.Equal(t, &CallToolResult{Content: []Content{TextContent("你好")}}, result)
+
+	resources, err := client.ListResources(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, []Resource{{Uri: "file:///menu.txt", Name: "menu", MimeType: "text/plain"}}, resources)
+	contents, err := client.ReadResource(ctx, "file:///menu.txt")
+	assert.Nil(t, err)
+	assert.Equal(t, "炒飯 120", contents[0].Text)
+
+	prompts, err := client.ListPrompts(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, "greet", prompts[0].Name)
+	prompt, err := client.GetPrompt(ctx, "greet", map[string]string{"name": "Ann"})
+	assert.Nil(t, err)
+	assert.Equal(t, "Greet Ann", prompt.Messages[0].Content.Text)
+
+	_, err = call[json.RawMessage](ctx, client.(*Client), "unknown", nil)
+	var rpcError *Error
+	assert.ErrorAs(t, err, &rpcError)
+	assert.Equal(t, MethodNotFound, rpcError.Code)
+}
+
+func TestStdioTransport_Closed(t *testing.T) {
+	transport := fixtureTransport(t)
+	assert.Nil(t, transport.Close())
+	assert.Nil(t, transport.Close())
+
+	request, err := newMessage(json.RawMessage("1"), "ping", nil)
+	assert.Nil(t, err)
+	_, err = transport.Call(context.Background(), request)
+	assert.NotNil(t, err)
+
+	_, err = NewStdioTransport(StdioConfig{Command: "/nonexistent/mcp-server"})
+	assert.NotNil(t, err)
+}
+
+func TestToolFunction(t *testing.T) {
+	ctx := context.Background()
+	client, err := NewClient(ctx, fixtureTransport(t), ClientConfig{Name: "test"})
+	assert.Nil(t, err)
+	defer client.Close()
+
+	all, err := NewToolFunctions(ctx, client, ToolOptions{Prefix: "fixture-"})
+	assert.Nil(t, err)
+	assert.Len(t, all, 2)
+
+	echo := all[0]
+	assert.Equal(t, "fixture-echo", echo.Name())
+	assert.Equal(t, "Repeat the text.", echo.Description())
+	assert.Equal(t, []any{"text"}, echo.Parameters()["required"])
+	assert.Equal(t, functions.FunctionConfig{UseGptToInterpretResponses: true, OnError: functions.ReportToModel}, echo.Config())
+	response, err := echo.OnMessage(map[string]interface{}{"text": "炒飯"})
+	assert.Nil(t, err)
+	assert.Equal(t, "炒飯", response.Content)
+
+	fail := all[1]
+	assert.Equal(t, map[string]interface{}{"type": "object", "properties": map[string]interface{}{}}, fail.Parameters())
+	_, err = fail.OnMessage(map[string]interface{}{})
+	assert.EqualError(t, err, "tool fail failed: something went wrong")
+
+	config := functions.FunctionConfig{Timeout: time.Nanosecond}
+	timeout := NewToolFunction(client, Tool{Name: "echo"}, ToolOptions{Config: &config})
+	_, err = timeout.OnMessage(map[string]interface{}{"text": "炒飯"})
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+}
+
+func TestCallToolResult_Text(t *testing.T) {
+	result := CallToolResult{Content: []Content{
+		TextContent("one"),
+		{Type: "image", Data: "aGk=", MimeType: "image/png"},
+		{Type: "resource", Resource: &ResourceContents{Uri: "file:///a.txt", Text: "two"}},
+		{Type: "resource", Resource: &ResourceContents{Uri: "file:///b.bin", Blob: "aGk="}},
+	}}
+	assert.Equal(t, "one\n[image image/png]\ntwo\n[resource file:///b.bin]", result.Text())
+}
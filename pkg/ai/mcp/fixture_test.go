@@ -0,0 +1,125 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"testing"
+)
+
+// fixtureEnv makes the test binary run the fixture server instead of the tests.
+const fixtureEnv = "MCP_FIXTURE"
+
+func TestMain(m *testing.M) {
+	switch os.Getenv(fixtureEnv) {
+	case "stdio":
+		serveFixture(os.Stdin, os.Stdout)
+		os.Exit(0)
+	case "server":
+		server, err := NewServer(ServerConfig{Name: "test-server"}, testFunctions())
+		if err != nil {
+			os.Exit(1)
+		}
+		_ = server.ServeStdio(context.Background(), os.Stdin, os.Stdout)
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// fixtureTransport starts the fixture server as a sub-process.
+func fixtureTransport(t *testing.T) Transport {
+	return processTransport(t, "stdio")
+}
+
+// processTransport starts the test binary as the server [mode].
+func processTransport(t *testing.T, mode string) Transport {
+	transport, err := NewStdioTransport(StdioConfig{
+		Command: os.Args[0],
+		Args:    []string{"-test.run=^$"},
+		Env:     []string{fixtureEnv + "=" + mode},
+		Stderr:  os.Stderr,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return transport
+}
+
+// serveFixture answers the messages of the input, one per line, with a server having two pages of tools,
+// a resource and a prompt. It pings the client and logs before answering a tool call.
+func serveFixture(input io.Reader, output io.Writer) {
+	encoder := json.NewEncoder(output)
+	scanner := bufio.NewScanner(input)
+	for scanner.Scan() {
+		var request Message
+		if err := json.Unmarshal(scanner.Bytes(), &request); err != nil {
+			_ = encoder.Encode(newErrorResponse(json.RawMessage("null"), ParseError, err.Error()))
+			continue
+		}
+		if !request.IsRequest() {
+			continue
+		}
+
+		var result any
+		switch request.Method {
+		case "initialize":
+			result = initializeResult{
+				ProtocolVersion: ProtocolVersion,
+				Capabilities:    map[string]any{"tools": map[string]any{}},
+				ServerInfo:      Implementation{Name: "fixture", Version: "0.1.0"},
+				Instructions:    "Use echo to repeat a text.",
+			}
+		case "tools/list":
+			var params map[string]string
+			_ = json.Unmarshal(request.Params, &params)
+			if params["cursor"] == "2" {
+				result = map[string]any{"tools": []Tool{{Name: "fail", Description: "Always fails."}}}
+				break
+			}
+			result = map[string]any{
+				"tools": []Tool{{
+					Name:        "echo",
+					Description: "Repeat the text.",
+					InputSchema: map[string]any{
+						"type":       "object",
+						"required":   []string{"text"},
+						"properties": map[string]any{"text": map[string]any{"type": "string"}},
+					},
+				}},
+				"nextCursor": "2",
+			}
+		case "tools/call":
+			var params callToolParams
+			_ = json.Unmarshal(request.Params, &params)
+			log, _ := newMessage(nil, "notifications/message", map[string]any{"level": "info", "data": "calling " + params.Name})
+			ping, _ := newMessage(json.RawMessage(`"ping-1"`), "ping", nil)
+			_ = encoder.Encode(log)
+			_ = encoder.Encode(ping)
+			if params.Name == "echo" {
+				result = CallToolResult{Content: []Content{TextContent(fmt.Sprint(params.Arguments["text"]))}}
+			} else {
+				result = CallToolResult{Content: []Content{TextContent("something went wrong")}, IsError: true}
+			}
+		case "resources/list":
+			result = map[string]any{"resources": []Resource{{Uri: "file:///menu.txt", Name: "menu", MimeType: "text/plain"}}}
+		case "resources/read":
+			var params readResourceParams
+			_ = json.Unmarshal(request.Params, &params)
+			result = readResourceResult{Contents: []ResourceContents{{Uri: params.Uri, MimeType: "text/plain", Text: "炒飯 120"}}}
+		case "prompts/list":
+			result = map[string]any{"prompts": []Prompt{{Name: "greet", Arguments: []PromptArgument{{Name: "name", Required: true}}}}}
+		case "prompts/get":
+			var params getPromptParams
+			_ = json.Unmarshal(request.Params, &params)
+			result = GetPromptResult{Messages: []PromptMessage{{Role: "user", Content: TextContent("Greet " + params.Arguments["name"])}}}
+		default:
+			_ = encoder.Encode(newErrorResponse(request.Id, MethodNotFound, "method not found: "+request.Method))
+			continue
+		}
+		response, _ := newResponse(request.Id, result)
+		_ = encoder.Encode(response)
+	}
+}
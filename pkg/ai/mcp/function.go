@@ -0,0 +1,102 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+	"github.com/meta-metopia/go-packages/pkg/ai/gpt/functions"
+	"maps"
+)
+
+type ToolOptions struct {
+	// Prefix is added to the names of the functions, so the tools of several servers do not collide.
+	Prefix string
+	// Config is the configuration of the functions. Defaults to GPT interpreting the results, and reporting the failures to the model.
+	// Its Timeout cancels the call of the tool as well.
+	Config *functions.FunctionConfig
+}
+
+// ToolFunction is a tool of a server, called as a function of the client.
+type ToolFunction struct {
+	functions.FunctionClient
+	client IClient
+	tool   Tool
+	name   string
+	config functions.FunctionConfig
+}
+
+// NewToolFunctions returns the functions calling the tools of the server.
+func NewToolFunctions(ctx context.Context, client IClient, options ToolOptions) ([]functions.FunctionInterface, error) {
+	tools, err := client.ListTools(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]functions.FunctionInterface, 0, len(tools))
+	for _, tool := range tools {
+		result = append(result, NewToolFunction(client, tool, options))
+	}
+	return result, nil
+}
+
+// NewToolFunction returns a new instance of the function calling the tool.
+func NewToolFunction(client IClient, tool Tool, options ToolOptions) functions.FunctionInterface {
+	config := functions.FunctionConfig{
+		UseGptToInterpretResponses: true,
+		OnError:                    functions.ReportToModel,
+	}
+	if options.Config != nil {
+		config = *options.Config
+	}
+	return &ToolFunction{
+		client: client,
+		tool:   tool,
+		name:   options.Prefix + tool.Name,
+		config: config,
+	}
+}
+
+func (t *ToolFunction) Name() string {
+	return t.name
+}
+
+func (t *ToolFunction) Description() string {
+	return t.tool.Description
+}
+
+// Parameters returns the input schema of the tool, as an object with properties.
+func (t *ToolFunction) Parameters() map[string]interface{} {
+	parameters := maps.Clone(t.tool.InputSchema)
+	if parameters == nil {
+		parameters = map[string]interface{}{}
+	}
+	parameters["type"] = "object"
+	if _, found := parameters["properties"]; !found {
+		parameters["properties"] = map[string]interface{}{}
+	}
+	return parameters
+}
+
+func (t *ToolFunction) Config() functions.FunctionConfig {
+	return t.config
+}
+
+func (t *ToolFunction) SetStore(store *functions.FunctionStore) {
+}
+
+// OnMessage calls the tool, and returns the text of its result. Failed tools return an error with the text.
+func (t *ToolFunction) OnMessage(arguments map[string]interface{}) (*functions.FunctionGptResponse, error) {
+	ctx := context.Background()
+	if t.config.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, t.config.Timeout)
+		defer cancel()
+	}
+
+	result, err := t.client.CallTool(ctx, t.tool.Name, arguments)
+	if err != nil {
+		return nil, err
+	}
+	if result.IsError {
+		return nil, fmt.Errorf("tool %s failed: %s", t.tool.Name, result.Text())
+	}
+	return &functions.FunctionGptResponse{Content: result.Text()}, nil
+}
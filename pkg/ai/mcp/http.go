@@ -0,0 +1,189 @@
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/go-resty/resty/v2"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+)
+
+// sessionHeader carries the id of the session the server returns on initialization.
+const sessionHeader = "Mcp-Session-Id"
+
+type HttpConfig struct {
+	// Endpoint is the MCP endpoint of the server, such as https://example.com/mcp.
+	Endpoint string
+	// Headers are sent with every request, such as Authorization.
+	Headers map[string]string
+}
+
+// HttpTransport talks to a server with the streamable HTTP transport.
+// The server answers each request with JSON, or with server-sent events ending with the response.
+type HttpTransport struct {
+	config     HttpConfig
+	httpClient *resty.Client
+
+	mutex     sync.Mutex
+	sessionId string
+}
+
+type IHttpTransport interface {
+	Transport
+	//SetClient sets the resty client for the transport.
+	SetClient(client *resty.Client)
+}
+
+// NewHttpTransport returns a new instance of the transport posting the messages to the endpoint.
+func NewHttpTransport(config HttpConfig) IHttpTransport {
+	client := resty.New()
+	client.SetDebug(os.Getenv("DEBUG") == "true")
+
+	return &HttpTransport{
+		config:     config,
+		httpClient: client,
+	}
+}
+
+// SetClient sets the resty client for the transport.
+func (h *HttpTransport) SetClient(client *resty.Client) {
+	h.httpClient = client
+}
+
+// request returns a request with the headers of the config and of the session.
+func (h *HttpTransport) request(ctx context.Context) *resty.Request {
+	request := h.httpClient.R().
+		SetContext(ctx).
+		SetHeaders(h.config.Headers).
+		SetHeader("Accept", "application/json, text/event-stream")
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if len(h.sessionId) > 0 {
+		request = request.SetHeader(sessionHeader, h.sessionId)
+	}
+	return request
+}
+
+// post posts the message, and returns the body of the response once it succeeded.
+func (h *HttpTransport) post(ctx context.Context, message Message) (*resty.Response, io.ReadCloser, error) {
+	response, err := h.request(ctx).
+		SetHeader("Content-Type", "application/json").
+		SetBody(message).
+		SetDoNotParseResponse(true).
+		Post(h.config.Endpoint)
+	if err != nil {
+		return nil, nil, err
+	}
+	body := response.RawBody()
+
+	if !response.IsSuccess() {
+		defer body.Close()
+		data, _ := io.ReadAll(body)
+		return nil, nil, fmt.Errorf("mcp request %s failed: %d %s", message.Method, response.StatusCode(), strings.TrimSpace(string(data)))
+	}
+	if sessionId := response.Header().Get(sessionHeader); len(sessionId) > 0 {
+		h.mutex.Lock()
+		h.sessionId = sessionId
+		h.mutex.Unlock()
+	}
+	return response, body, nil
+}
+
+func (h *HttpTransport) Call(ctx context.Context, request Message) (Message, error) {
+	response, body, err := h.post(ctx, request)
+	if err != nil {
+		return Message{}, err
+	}
+	defer body.Close()
+
+	mediaType, _, _ := mime.ParseMediaType(response.Header().Get("Content-Type"))
+	if mediaType == "text/event-stream" {
+		return h.readEvents(ctx, body, request.Id)
+	}
+
+	var message Message
+	if err := json.NewDecoder(body).Decode(&message); err != nil {
+		return Message{}, fmt.Errorf("invalid mcp response: %w", err)
+	}
+	return message, nil
+}
+
+// readEvents reads the server-sent events until the response of the request [id].
+// The requests the server sends meanwhile are answered with a new post.
+func (h *HttpTransport) readEvents(ctx context.Context, body io.Reader, id json.RawMessage) (Message, error) {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxMessageSize)
+
+	var data bytes.Buffer
+	for scanner.Scan() {
+		line := scanner.Text()
+		if value, ok := strings.CutPrefix(line, "data:"); ok {
+			if data.Len() > 0 {
+				data.WriteByte('\n')
+			}
+			data.WriteString(strings.TrimPrefix(value, " "))
+			continue
+		}
+		if len(line) > 0 || data.Len() == 0 {
+			continue
+		}
+
+		// an empty line ends the event
+		var message Message
+		err := json.Unmarshal(data.Bytes(), &message)
+		data.Reset()
+		if err != nil {
+			return Message{}, fmt.Errorf("invalid mcp event: %w", err)
+		}
+		switch {
+		case message.IsResponse() && bytes.Equal(message.Id, id):
+			return message, nil
+		case message.IsRequest():
+			if _, answer, err := h.post(ctx, answerServerRequest(message)); err == nil {
+				answer.Close()
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return Message{}, err
+	}
+	return Message{}, fmt.Errorf("mcp event stream ended without a response")
+}
+
+func (h *HttpTransport) Notify(ctx context.Context, notification Message) error {
+	_, body, err := h.post(ctx, notification)
+	if err != nil {
+		return err
+	}
+	return body.Close()
+}
+
+// Close ends the session, when the server started one.
+func (h *HttpTransport) Close() error {
+	h.mutex.Lock()
+	sessionId := h.sessionId
+	h.mutex.Unlock()
+	if len(sessionId) == 0 {
+		return nil
+	}
+
+	response, err := h.request(context.Background()).Delete(h.config.Endpoint)
+	if err != nil {
+		return err
+	}
+	h.mutex.Lock()
+	h.sessionId = ""
+	h.mutex.Unlock()
+	// servers may not allow clients to end the sessions
+	if !response.IsSuccess() && response.StatusCode() != http.StatusMethodNotAllowed {
+		return fmt.Errorf("failed to close mcp session: %d %s", response.StatusCode(), response.String())
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/go-resty/resty/v2"
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+)
+
+const endpoint = "http://localhost:8080/mcp"
+
+// httpServer answers the requests posted to the endpoint with [answer], and records the posted messages.
+func httpServer(t *testing.T, answer func(request Message) *http.Response) (client *resty.Client, posted *[]Message, headers *[]http.Header) {
+	client = resty.New()
+	httpmock.ActivateNonDefault(client.GetClient())
+	t.Cleanup(httpmock.DeactivateAndReset)
+
+	posted = &[]Message{}
+	headers = &[]http.Header{}
+	httpmock.RegisterResponder("POST", endpoint, func(request *http.Request) (*http.Response, error) {
+		var message Message
+		if err := json.NewDecoder(request.Body).Decode(&message); err != nil {
+			return nil, err
+		}
+		*posted = append(*posted, message)
+		*headers = append(*headers, request.Header)
+		if !message.IsRequest() {
+			return httpmock.NewStringResponse(http.StatusAccepted, ""), nil
+		}
+		return answer(message), nil
+	})
+	return client, posted, headers
+}
+
+func jsonResponse(request Message, result any) *http.Response {
+	message, _ := newResponse(request.Id, result)
+	response, _ := httpmock.NewJsonResponse(http.StatusOK, message)
+	response.Header.Set(sessionHeader, "session-1")
+	return response
+}
+
+func TestHttpTransport(t *testing.T) {
+	restyClient, posted, headers := httpServer(t, func(request Message) *http.Response {
+		switch request.Method {
+		case "initialize":
+			return jsonResponse(request, initializeResult{ProtocolVersion: ProtocolVersion, ServerInfo: Implementation{Name: "http"}})
+		case "tools/call":
+			result, _ := newResponse(request.Id, CallToolResult{Content: []Content{TextContent("streamed")}})
+			log, _ := newMessage(nil, "notifications/message", map[string]any{"data": "working"})
+			ping, _ := newMessage(json.RawMessage(`"ping-1"`), "ping", nil)
+			var body string
+			for _, message := range []Message{log, ping, result} {
+				data, _ := json.Marshal(message)
+				body += "event: message\ndata: " + string(data) + "\n\n"
+			}
+			response := httpmock.NewStringResponse(http.StatusOK, body)
+			response.Header.Set("Content-Type", "text/event-stream")
+			return response
+		}
+		response, _ := httpmock.NewJsonResponse(http.StatusOK, newErrorResponse(request.Id, MethodNotFound, "method not found"))
+		return response
+	})
+	httpmock.RegisterResponder("DELETE", endpoint, httpmock.NewStringResponder(http.StatusMethodNotAllowed, ""))
+
+	transport := NewHttpTransport(HttpConfig{Endpoint: endpoint, Headers: map[string]string{"Authorization": "Bearer key"}})
+	transport.SetClient(restyClient)
+
+	client, err := NewClient(context.Background(), transport, ClientConfig{})
+	assert.Nil(t, err)
+	assert.Equal(t, "http", client.ServerInfo().Name)
+
+	result, err := client.CallTool(context.Background(), "echo", map[string]any{"text": "streamed"})
+	assert.Nil(t, err)
+	assert.Equal(t, "streamed", result.Text())
+
+	_, err = client.ListPrompts(context.Background())
+	assert.EqualError(t, err, "mcp error -32601: method not found")
+	assert.Nil(t, client.Close())
+
+	var methods []string
+	for _, message := range *posted {
+		methods = append(methods, message.Method)
+	}
+	// the ping of the server is answered with a response, which has no method
+	assert.Equal(t, []string{"initialize", "notifications/initialized", "tools/call", "", "prompts/list"}, methods)
+	assert.Equal(t, json.RawMessage(`"ping-1"`), (*posted)[3].Id)
+	assert.Equal(t, "", (*headers)[0].Get(sessionHeader))
+	assert.Equal(t, "session-1", (*headers)[1].Get(sessionHeader))
+	assert.Equal(t, "Bearer key", (*headers)[2].Get("Authorization"))
+	assert.Equal(t, "application/json, text/event-stream", (*headers)[2].Get("Accept"))
+	assert.Equal(t, 1, httpmock.GetCallCountInfo()["DELETE "+endpoint])
+}
+
+func TestHttpTransport_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		answer  func(request Message) *http.Response
+		wantErr string
+	}{
+		{
+			name: "Test with failed request",
+			answer: func(request Message) *http.Response {
+				return httpmock.NewStringResponse(http.StatusUnauthorized, "invalid key\n")
+			},
+			wantErr: "failed to initialize mcp client: mcp request initialize failed: 401 invalid key",
+		},
+		{
+			name: "Test with invalid response",
+			answer: func(request Message) *http.Response {
+				return httpmock.NewStringResponse(http.StatusOK, "{")
+			},
+			wantErr: "failed to initialize mcp client: invalid mcp response: unexpected EOF",
+		},
+		{
+			name: "Test with event stream without response",
+			answer: func(request Message) *http.Response {
+				response := httpmock.NewStringResponse(http.StatusOK, "data: {\"jsonrpc\":\"2.0\",\"method\":\"notifications/message\"}\n\n")
+				response.Header.Set("Content-Type", "text/event-stream; charset=utf-8")
+				return response
+			},
+			wantErr: "failed to initialize mcp client: mcp event stream ended without a response",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			restyClient, _, _ := httpServer(t, tt.answer)
+			transport := NewHttpTransport(HttpConfig{Endpoint: endpoint})
+			transport.SetClient(restyClient)
+
+			_, err := NewClient(context.Background(), transport, ClientConfig{})
+			assert.EqualError(t, err, tt.wantErr)
+		})
+	}
+}
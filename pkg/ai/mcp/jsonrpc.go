@@ -0,0 +1,80 @@
+package mcp
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+const jsonRpcVersion = "2.0"
+
+// The codes of the JSON-RPC errors.
+const (
+	ParseError     = -32700
+	InvalidRequest = -32600
+	MethodNotFound = -32601
+	InvalidParams  = -32602
+	InternalError  = -32603
+)
+
+// Message is a JSON-RPC request, notification or response. Notifications have no id.
+type Message struct {
+	JsonRpc string          `json:"jsonrpc"`
+	Id      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *Error          `json:"error,omitempty"`
+}
+
+// IsRequest returns whether the message is a request, waiting for a response.
+func (m Message) IsRequest() bool {
+	return len(m.Method) > 0 && len(m.Id) > 0
+}
+
+// IsNotification returns whether the message is a notification, which has no response.
+func (m Message) IsNotification() bool {
+	return len(m.Method) > 0 && len(m.Id) == 0
+}
+
+// IsResponse returns whether the message is the response of a request.
+func (m Message) IsResponse() bool {
+	return len(m.Method) == 0 && len(m.Id) > 0
+}
+
+// Error is the error of a JSON-RPC response.
+type Error struct {
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("mcp error %d: %s", e.Code, e.Message)
+}
+
+// newMessage returns a request, or a notification when [id] is empty, with the params encoded.
+func newMessage(id json.RawMessage, method string, params any) (Message, error) {
+	message := Message{JsonRpc: jsonRpcVersion, Id: id, Method: method}
+	if params != nil {
+		data, err := json.Marshal(params)
+		if err != nil {
+			return Message{}, err
+		}
+		message.Params = data
+	}
+	return message, nil
+}
+
+// newResponse returns the response of the request [id] with the result encoded.
+func newResponse(id json.RawMessage, result any) (Message, error) {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return Message{}, err
+	}
+	return Message{JsonRpc: jsonRpcVersion, Id: id, Result: data}, nil
+}
+
+// newErrorResponse returns the response of the request [id] failing with the error.
+func newErrorResponse(id json.RawMessage, code int, message string) Message {
+	return Message{JsonRpc: jsonRpcVersion, Id: id, Error: &Error{Code: code, Message: message}}
+}
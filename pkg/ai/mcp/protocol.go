@@ -0,0 +1,136 @@
+package mcp
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ProtocolVersion is the version of the Model Context Protocol the client and the server speak.
+const ProtocolVersion = "2025-03-26"
+
+// Implementation is the name and the version of a client or a server.
+type Implementation struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type initializeParams struct {
+	ProtocolVersion string         `json:"protocolVersion"`
+	Capabilities    map[string]any `json:"capabilities"`
+	ClientInfo      Implementation `json:"clientInfo"`
+}
+
+type initializeResult struct {
+	ProtocolVersion string         `json:"protocolVersion"`
+	Capabilities    map[string]any `json:"capabilities"`
+	ServerInfo      Implementation `json:"serverInfo"`
+	Instructions    string         `json:"instructions,omitempty"`
+}
+
+// Tool is a tool of the server the model can call.
+type Tool struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	// InputSchema is the JSON schema of the arguments.
+	InputSchema map[string]any `json:"inputSchema"`
+}
+
+type callToolParams struct {
+	Name      string         `json:"name"`
+	Arguments map[string]any `json:"arguments,omitempty"`
+}
+
+// Content is a part of the result of a tool or of a prompt message, such as a text or an image.
+type Content struct {
+	// Type is text, image, audio or resource.
+	Type string `json:"type"`
+	Text string `json:"text,omitempty"`
+	// Data is the base64 encoded image or audio.
+	Data     string            `json:"data,omitempty"`
+	MimeType string            `json:"mimeType,omitempty"`
+	Resource *ResourceContents `json:"resource,omitempty"`
+}
+
+// TextContent returns a new text content.
+func TextContent(text string) Content {
+	return Content{Type: "text", Text: text}
+}
+
+// CallToolResult is the result of a tool. Failed tools are results with IsError, so the model can see the failure.
+type CallToolResult struct {
+	Content []Content `json:"content"`
+	IsError bool      `json:"isError,omitempty"`
+}
+
+// Text returns the text of the contents, describing the contents that are not text.
+func (r CallToolResult) Text() string {
+	texts := make([]string, 0, len(r.Content))
+	for _, content := range r.Content {
+		switch {
+		case content.Type == "text":
+			texts = append(texts, content.Text)
+		case content.Resource != nil && len(content.Resource.Text) > 0:
+			texts = append(texts, content.Resource.Text)
+		case content.Resource != nil:
+			texts = append(texts, fmt.Sprintf("[resource %s]", content.Resource.Uri))
+		default:
+			texts = append(texts, fmt.Sprintf("[%s %s]", content.Type, content.MimeType))
+		}
+	}
+	return strings.Join(texts, "\n")
+}
+
+// Resource is a document or some data the server shares, identified by its URI.
+type Resource struct {
+	Uri         string `json:"uri"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	MimeType    string `json:"mimeType,omitempty"`
+}
+
+// ResourceContents is the content of a resource, as a text or a base64 encoded blob.
+type ResourceContents struct {
+	Uri      string `json:"uri"`
+	MimeType string `json:"mimeType,omitempty"`
+	Text     string `json:"text,omitempty"`
+	Blob     string `json:"blob,omitempty"`
+}
+
+type readResourceParams struct {
+	Uri string `json:"uri"`
+}
+
+type readResourceResult struct {
+	Contents []ResourceContents `json:"contents"`
+}
+
+// Prompt is a prompt template of the server.
+type Prompt struct {
+	Name        string           `json:"name"`
+	Description string           `json:"description,omitempty"`
+	Arguments   []PromptArgument `json:"arguments,omitempty"`
+}
+
+type PromptArgument struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Required    bool   `json:"required,omitempty"`
+}
+
+type getPromptParams struct {
+	Name      string            `json:"name"`
+	Arguments map[string]string `json:"arguments,omitempty"`
+}
+
+// PromptMessage is a message of a rendered prompt.
+type PromptMessage struct {
+	// Role is user or assistant.
+	Role    string  `json:"role"`
+	Content Content `json:"content"`
+}
+
+// GetPromptResult is the prompt rendered with the arguments.
+type GetPromptResult struct {
+	Description string          `json:"description,omitempty"`
+	Messages    []PromptMessage `json:"messages"`
+}
@@ -0,0 +1,305 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/meta-metopia/go-packages/pkg/ai/gpt/functions"
+	"io"
+	"sync"
+	"time"
+)
+
+const (
+	defaultSessionIdleTimeout = 30 * time.Minute
+	defaultMaxSessions        = 1000
+)
+
+type ServerConfig struct {
+	// Name of the server the clients see. Defaults to go-packages.
+	Name    string
+	Version string
+	// Instructions are the hints for the clients on using the tools.
+	Instructions string
+	// Store is shared by the functions. Defaults to a new store.
+	// The functions serve every client with it, so the stateful functions, such as an order, are only served to a single client:
+	// over stdio, whose process serves one client, or over HTTP with SessionFunctions.
+	Store *functions.FunctionStore
+	// SessionFunctions creates the functions of every HTTP session, set with the store of the session,
+	// so the clients never share the state of the functions. They are closed when the session ends.
+	// Without it, the HTTP sessions share the functions of the server and the Store.
+	SessionFunctions func() ([]functions.FunctionInterface, error)
+	// SessionIdleTimeout ends the HTTP sessions unused for the duration, as the clients can leave without ending them.
+	// Defaults to 30 minutes.
+	SessionIdleTimeout time.Duration
+	// MaxSessions is the maximum number of HTTP sessions. Starting another one ends the least recently used one.
+	// Defaults to 1000.
+	MaxSessions int
+}
+
+// Server serves functions as the tools of a Model Context Protocol server, over stdio or HTTP.
+type Server struct {
+	config  ServerConfig
+	toolset *toolset
+
+	mutex sync.Mutex
+	// sessions are the HTTP sessions by id.
+	sessions map[string]*session
+	// stores are the stores of the HTTP sessions, used with SessionFunctions.
+	stores *functions.Sessions
+	closed bool
+	now    func() time.Time
+}
+
+// toolset is the functions serving a client, by name.
+type toolset struct {
+	functions []functions.FunctionInterface
+	byName    map[string]functions.FunctionInterface
+}
+
+// newToolset returns the functions once they are initialized with the store.
+// The functions initialized are closed when one of them fails.
+func newToolset(all []functions.FunctionInterface, store *functions.FunctionStore) (*toolset, error) {
+	created := &toolset{byName: map[string]functions.FunctionInterface{}}
+	for _, function := range all {
+		if _, found := created.byName[function.Name()]; found {
+			return nil, errors.Join(fmt.Errorf("duplicated function %s", function.Name()), created.close())
+		}
+		function.SetStore(store)
+		if err := function.OnInit(); err != nil {
+			return nil, errors.Join(fmt.Errorf("failed to initialize function %s: %w", function.Name(), err), created.close())
+		}
+		created.functions = append(created.functions, function)
+		created.byName[function.Name()] = function
+	}
+	return created, nil
+}
+
+// close closes the functions in the reverse order of their initialization.
+func (t *toolset) close() error {
+	var errs []error
+	for i := len(t.functions) - 1; i >= 0; i-- {
+		if err := t.functions[i].OnClose(); err != nil {
+			errs = append(errs, fmt.Errorf("failed to close function %s: %w", t.functions[i].Name(), err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// NewServer returns a new instance of the server, once the functions are initialized.
+// The functions initialized are closed when one of them fails.
+func NewServer(config ServerConfig, all []functions.FunctionInterface) (*Server, error) {
+	if len(config.Name) == 0 {
+		config.Name = "go-packages"
+	}
+	if len(config.Version) == 0 {
+		config.Version = "1.0.0"
+	}
+	if config.Store == nil {
+		config.Store = functions.NewFunctionStore()
+	}
+	if config.SessionIdleTimeout <= 0 {
+		config.SessionIdleTimeout = defaultSessionIdleTimeout
+	}
+	if config.MaxSessions <= 0 {
+		config.MaxSessions = defaultMaxSessions
+	}
+
+	created, err := newToolset(all, config.Store)
+	if err != nil {
+		return nil, err
+	}
+	return &Server{
+		config:   config,
+		toolset:  created,
+		sessions: map[string]*session{},
+		stores:   functions.NewSessions(nil),
+		now:      time.Now,
+	}, nil
+}
+
+// Close closes the functions in the reverse order of their initialization, then the functions of the HTTP sessions.
+func (s *Server) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+
+	errs := []error{s.toolset.close()}
+	for sessionId := range s.sessions {
+		errs = append(errs, s.removeSession(sessionId))
+	}
+	return errors.Join(errs...)
+}
+
+// Handle returns the response of the message, or false for the notifications and the responses, which have none.
+// The tools are the functions of the server.
+func (s *Server) Handle(ctx context.Context, message Message) (Message, bool) {
+	return s.handle(ctx, message, s.toolset)
+}
+
+// handle returns the response of the message, calling the functions of [tools].
+func (s *Server) handle(ctx context.Context, message Message, tools *toolset) (Message, bool) {
+	if !message.IsRequest() {
+		return Message{}, false
+	}
+
+	var result any
+	var err error
+	switch message.Method {
+	case "initialize":
+		result, err = s.initialize(message.Params)
+	case "ping":
+		result = struct{}{}
+	case "tools/list":
+		result = map[string]any{"tools": tools.tools()}
+	case "tools/call":
+		result, err = tools.callTool(ctx, message.Params)
+	default:
+		return newErrorResponse(message.Id, MethodNotFound, "method not found: "+message.Method), true
+	}
+
+	if err != nil {
+		var rpcError *Error
+		if errors.As(err, &rpcError) {
+			return Message{JsonRpc: jsonRpcVersion, Id: message.Id, Error: rpcError}, true
+		}
+		return newErrorResponse(message.Id, InternalError, err.Error()), true
+	}
+	response, err := newResponse(message.Id, result)
+	if err != nil {
+		return newErrorResponse(message.Id, InternalError, err.Error()), true
+	}
+	return response, true
+}
+
+func (s *Server) initialize(data json.RawMessage) (initializeResult, error) {
+	var params initializeParams
+	if err := json.Unmarshal(data, &params); err != nil {
+		return initializeResult{}, &Error{Code: InvalidParams, Message: err.Error()}
+	}
+	return initializeResult{
+		ProtocolVersion: ProtocolVersion,
+		Capabilities:    map[string]any{"tools": map[string]any{}},
+		ServerInfo:      Implementation{Name: s.config.Name, Version: s.config.Version},
+		Instructions:    s.config.Instructions,
+	}, nil
+}
+
+// tools returns the functions as tools, with their parameters as input schemas.
+func (t *toolset) tools() []Tool {
+	tools := make([]Tool, 0, len(t.functions))
+	for _, function := range t.functions {
+		tools = append(tools, Tool{
+			Name:        function.Name(),
+			Description: function.Description(),
+			InputSchema: function.Parameters(),
+		})
+	}
+	return tools
+}
+
+// callTool calls the function. Its failures are results with IsError, so the model of the client can see them.
+func (t *toolset) callTool(ctx context.Context, data json.RawMessage) (CallToolResult, error) {
+	var params callToolParams
+	if err := json.Unmarshal(data, &params); err != nil {
+		return CallToolResult{}, &Error{Code: InvalidParams, Message: err.Error()}
+	}
+	function, found := t.byName[params.Name]
+	if !found {
+		return CallToolResult{}, &Error{Code: InvalidParams, Message: "unknown tool " + params.Name}
+	}
+	if params.Arguments == nil {
+		params.Arguments = map[string]any{}
+	}
+
+	response, err := functions.Run(ctx, function, function.Config().Timeout, params.Arguments)
+	if err != nil {
+		return CallToolResult{Content: []Content{TextContent(err.Error())}, IsError: true}, nil
+	}
+	content, err := toContents(response.Content)
+	if err != nil {
+		return CallToolResult{}, err
+	}
+	return CallToolResult{Content: content}, nil
+}
+
+// toContents returns the content of a function response as the content of a tool result.
+// Texts are kept as is, contents are passed through and the other values are sent as JSON.
+func toContents(content any) ([]Content, error) {
+	switch value := content.(type) {
+	case nil:
+		return []Content{}, nil
+	case string:
+		return []Content{TextContent(value)}, nil
+	case Content:
+		return []Content{value}, nil
+	case []Content:
+		return value, nil
+	}
+	data, err := json.Marshal(content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode the content: %w", err)
+	}
+	return []Content{TextContent(string(data))}, nil
+}
+
+// ServeStdio answers the messages of the input, one per line, until the input ends or the context is done.
+// The requests are handled concurrently, so pings are answered during the long tool calls.
+func (s *Server) ServeStdio(ctx context.Context, input io.Reader, output io.Writer) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var writeMutex sync.Mutex
+	encoder := json.NewEncoder(output)
+	write := func(message Message) {
+		writeMutex.Lock()
+		defer writeMutex.Unlock()
+		_ = encoder.Encode(message)
+	}
+
+	lines := make(chan []byte)
+	scanErr := make(chan error, 1)
+	go func() {
+		scanner := bufio.NewScanner(input)
+		scanner.Buffer(make([]byte, 0, 64*1024), maxMessageSize)
+		for scanner.Scan() {
+			select {
+			case lines <- append([]byte(nil), scanner.Bytes()...):
+			case <-ctx.Done():
+				return
+			}
+		}
+		scanErr <- scanner.Err()
+		close(lines)
+	}()
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case line, ok := <-lines:
+			if !ok {
+				return <-scanErr
+			}
+			var message Message
+			if err := json.Unmarshal(line, &message); err != nil {
+				write(newErrorResponse(json.RawMessage("null"), ParseError, err.Error()))
+				continue
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if response, ok := s.Handle(ctx, message); ok {
+					write(response)
+				}
+			}()
+		}
+	}
+}
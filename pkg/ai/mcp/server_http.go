@@ -0,0 +1,190 @@
+package mcp
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"time"
+)
+
+// ServeHTTP serves the streamable HTTP transport, answering each posted request with JSON.
+// The initialization starts a session, whose id the other requests must send.
+// The sessions end when the client deletes them, once they are unused for the SessionIdleTimeout,
+// or when MaxSessions are reached and another one starts.
+// With ServerConfig.SessionFunctions, every session is served by its own functions and store.
+func (s *Server) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	switch request.Method {
+	case http.MethodPost:
+		s.post(writer, request)
+	case http.MethodDelete:
+		if !s.endSession(request.Header.Get(sessionHeader)) {
+			http.Error(writer, "session not found", http.StatusNotFound)
+			return
+		}
+		writer.WriteHeader(http.StatusOK)
+	default:
+		// the server never sends messages on its own, so there is no stream to get
+		writer.Header().Set("Allow", "POST, DELETE")
+		http.Error(writer, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) post(writer http.ResponseWriter, request *http.Request) {
+	data, err := io.ReadAll(io.LimitReader(request.Body, maxMessageSize))
+	if err != nil {
+		http.Error(writer, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var message Message
+	if err := json.Unmarshal(data, &message); err != nil {
+		writeMessage(writer, http.StatusBadRequest, newErrorResponse(json.RawMessage("null"), ParseError, err.Error()))
+		return
+	}
+
+	var tools *toolset
+	if message.Method == "initialize" {
+		sessionId, started, err := s.startSession()
+		if err != nil {
+			http.Error(writer, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writer.Header().Set(sessionHeader, sessionId)
+		tools = started
+	} else {
+		sessionId := request.Header.Get(sessionHeader)
+		if len(sessionId) == 0 {
+			http.Error(writer, "missing "+sessionHeader, http.StatusBadRequest)
+			return
+		}
+		found, ok := s.session(sessionId)
+		if !ok {
+			http.Error(writer, "session not found", http.StatusNotFound)
+			return
+		}
+		tools = found
+	}
+
+	response, ok := s.handle(request.Context(), message, tools)
+	if !ok {
+		writer.WriteHeader(http.StatusAccepted)
+		return
+	}
+	writeMessage(writer, http.StatusOK, response)
+}
+
+func writeMessage(writer http.ResponseWriter, status int, message Message) {
+	writer.Header().Set("Content-Type", "application/json")
+	writer.WriteHeader(status)
+	_ = json.NewEncoder(writer).Encode(message)
+}
+
+// session is an HTTP session.
+type session struct {
+	lastUsed time.Time
+	// toolset is the functions of the session, or nil when it uses the functions of the server.
+	toolset *toolset
+}
+
+// startSession starts a new session and returns its functions, ending the expired sessions
+// and the least recently used one when there are too many.
+func (s *Server) startSession() (string, *toolset, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", nil, err
+	}
+	sessionId := hex.EncodeToString(id)
+	started := &session{}
+	if s.config.SessionFunctions != nil {
+		all, err := s.config.SessionFunctions()
+		if err != nil {
+			return "", nil, err
+		}
+		store, err := s.stores.Store(sessionId)
+		if err != nil {
+			return "", nil, err
+		}
+		if started.toolset, err = newToolset(all, store); err != nil {
+			s.stores.Forget(sessionId)
+			return "", nil, err
+		}
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	now := s.now()
+	var oldestId string
+	var oldest time.Time
+	for id, existing := range s.sessions {
+		if s.expired(existing.lastUsed, now) {
+			_ = s.removeSession(id)
+			continue
+		}
+		if len(oldestId) == 0 || existing.lastUsed.Before(oldest) {
+			oldestId, oldest = id, existing.lastUsed
+		}
+	}
+	if len(s.sessions) >= s.config.MaxSessions {
+		_ = s.removeSession(oldestId)
+	}
+	started.lastUsed = now
+	s.sessions[sessionId] = started
+	return sessionId, s.toolsOf(started), nil
+}
+
+// session returns the functions of the session when it is not ended, and marks it as used.
+func (s *Server) session(sessionId string) (*toolset, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	found, ok := s.sessions[sessionId]
+	if !ok {
+		return nil, false
+	}
+	now := s.now()
+	if s.expired(found.lastUsed, now) {
+		_ = s.removeSession(sessionId)
+		return nil, false
+	}
+	found.lastUsed = now
+	return s.toolsOf(found), true
+}
+
+func (s *Server) endSession(sessionId string) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	found, ok := s.sessions[sessionId]
+	if !ok {
+		return false
+	}
+	_ = s.removeSession(sessionId)
+	return !s.expired(found.lastUsed, s.now())
+}
+
+// removeSession removes the session and closes its functions. The calls of the session still running keep using them.
+// The sessions end without their clients, so the errors of their functions are only returned to Close.
+func (s *Server) removeSession(sessionId string) error {
+	found, ok := s.sessions[sessionId]
+	if !ok {
+		return nil
+	}
+	delete(s.sessions, sessionId)
+	s.stores.Forget(sessionId)
+	if found.toolset == nil {
+		return nil
+	}
+	return found.toolset.close()
+}
+
+// toolsOf returns the functions serving the session.
+func (s *Server) toolsOf(found *session) *toolset {
+	if found.toolset == nil {
+		return s.toolset
+	}
+	return found.toolset
+}
+
+// expired returns true when the session last used at [lastUsed] is unused for longer than the idle timeout.
+func (s *Server) expired(lastUsed time.Time, now time.Time) bool {
+	return now.Sub(lastUsed) > s.config.SessionIdleTimeout
+}
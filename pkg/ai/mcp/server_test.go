@@ -0,0 +1,398 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/meta-metopia/go-packages/pkg/ai/gpt/functions"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testFunction is a function calling [onMessage].
+type testFunction struct {
+	functions.FunctionClient
+	name      string
+	config    functions.FunctionConfig
+	onMessage func(arguments map[string]interface{}) (*functions.FunctionGptResponse, error)
+	onInit    func() error
+	onClose   func() error
+}
+
+func (f *testFunction) Name() string {
+	return f.name
+}
+
+func (f *testFunction) Description() string {
+	return "The " + f.name + " function."
+}
+
+func (f *testFunction) Parameters() map[string]interface{} {
+	return map[string]interface{}{
+		"type":       "object",
+		"properties": map[string]interface{}{"dish": map[string]interface{}{"type": "string"}},
+	}
+}
+
+func (f *testFunction) Config() functions.FunctionConfig {
+	return f.config
+}
+
+func (f *testFunction) SetStore(store *functions.FunctionStore) {
+}
+
+func (f *testFunction) OnMessage(arguments map[string]interface{}) (*functions.FunctionGptResponse, error) {
+	return f.onMessage(arguments)
+}
+
+func (f *testFunction) OnInit() error {
+	if f.onInit != nil {
+		return f.onInit()
+	}
+	return nil
+}
+
+func (f *testFunction) OnClose() error {
+	if f.onClose != nil {
+		return f.onClose()
+	}
+	return nil
+}
+
+// testFunctions returns functions answering with a text, with JSON, failing and panicking.
+func testFunctions() []functions.FunctionInterface {
+	return []functions.FunctionInterface{
+		&testFunction{name: "add-dish", onMessage: func(arguments map[string]interface{}) (*functions.FunctionGptResponse, error) {
+			return &functions.FunctionGptResponse{Content: fmt.Sprintf("added %s", arguments["dish"])}, nil
+		}},
+		&testFunction{name: "get-order", onMessage: func(arguments map[string]interface{}) (*functions.FunctionGptResponse, error) {
+			return &functions.FunctionGptResponse{Content: map[string]any{"dishes": []string{"炒飯"}}}, nil
+		}},
+		&testFunction{name: "fail", onMessage: func(arguments map[string]interface{}) (*functions.FunctionGptResponse, error) {
+			return nil, fmt.Errorf("kitchen closed")
+		}},
+		&testFunction{name: "panic", onMessage: func(arguments map[string]interface{}) (*functions.FunctionGptResponse, error) {
+			panic("boom")
+		}},
+	}
+}
+
+// assertServer checks the tools of the test functions, called with the client.
+func assertServer(t *testing.T, client IClient) {
+	ctx := context.Background()
+	tools, err := client.ListTools(ctx)
+	assert.Nil(t, err)
+	assert.Len(t, tools, 4)
+	assert.Equal(t, Tool{
+		Name:        "add-dish",
+		Description: "The add-dish function.",
+		InputSchema: map[string]any{
+			"type":       "object",
+			"properties": map[string]any{"dish": map[string]any{"type": "string"}},
+		},
+	}, tools[0])
+
+	result, err := client.CallTool(ctx, "add-dish", map[string]any{"dish": "炒飯"})
+	assert.Nil(t, err)
+	assert.Equal(t, &CallToolResult{Content: []Content{TextContent("added 炒飯")}}, result)
+
+	result, err = client.CallTool(ctx, "get-order", nil)
+	assert.Nil(t, err)
+	assert.Equal(t, `{"dishes":["炒飯"]}`, result.Text())
+
+	result, err = client.CallTool(ctx, "fail", nil)
+	assert.Nil(t, err)
+	assert.Equal(t, &CallToolResult{Content: []Content{TextContent("kitchen closed")}, IsError: true}, result)
+
+	result, err = client.CallTool(ctx, "panic", nil)
+	assert.Nil(t, err)
+	assert.True(t, result.IsError)
+	assert.Equal(t, "function panic panicked: boom", result.Text())
+
+	_, err = client.CallTool(ctx, "unknown", nil)
+	assert.EqualError(t, err, "mcp error -32602: unknown tool unknown")
+	_, err = client.ListResources(ctx)
+	assert.EqualError(t, err, "mcp error -32601: method not found: resources/list")
+}
+
+func TestServer_Stdio(t *testing.T) {
+	client, err := NewClient(context.Background(), processTransport(t, "server"), ClientConfig{})
+	assert.Nil(t, err)
+	assert.Equal(t, "test-server", client.ServerInfo().Name)
+
+	assertServer(t, client)
+	assert.Nil(t, client.Close())
+}
+
+func TestServer_Http(t *testing.T) {
+	server, err := NewServer(ServerConfig{Instructions: "Order dishes."}, testFunctions())
+	assert.Nil(t, err)
+	httpServer := httptest.NewServer(server)
+	defer httpServer.Close()
+
+	client, err := NewClient(context.Background(), NewHttpTransport(HttpConfig{Endpoint: httpServer.URL}), ClientConfig{})
+	assert.Nil(t, err)
+	assert.Equal(t, Implementation{Name: "go-packages", Version: "1.0.0"}, client.ServerInfo())
+	assert.Equal(t, "Order dishes.", client.Instructions())
+
+	assertServer(t, client)
+	assert.Nil(t, client.Close())
+	assert.Empty(t, server.sessions)
+}
+
+func TestServer_HttpErrors(t *testing.T) {
+	server, err := NewServer(ServerConfig{}, testFunctions())
+	assert.Nil(t, err)
+
+	tests := []struct {
+		name       string
+		method     string
+		body       string
+		session    string
+		wantStatus int
+	}{
+		{name: "Test without session", method: http.MethodPost, body: `{"jsonrpc":"2.0","id":1,"method":"tools/list"}`, wantStatus: http.StatusBadRequest},
+		{name: "Test with unknown session", method: http.MethodPost, body: `{"jsonrpc":"2.0","id":1,"method":"tools/list"}`, session: "unknown", wantStatus: http.StatusNotFound},
+		{name: "Test with invalid message", method: http.MethodPost, body: `{`, wantStatus: http.StatusBadRequest},
+		{name: "Test with stream", method: http.MethodGet, wantStatus: http.StatusMethodNotAllowed},
+		{name: "Test with end of unknown session", method: http.MethodDelete, session: "unknown", wantStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := httptest.NewRequest(tt.method, "/mcp", strings.NewReader(tt.body))
+			if len(tt.session) > 0 {
+				request.Header.Set(sessionHeader, tt.session)
+			}
+			recorder := httptest.NewRecorder()
+			server.ServeHTTP(recorder, request)
+			assert.Equal(t, tt.wantStatus, recorder.Code)
+		})
+	}
+}
+
+func TestNewServer(t *testing.T) {
+	var closed []string
+	closing := func(name string, initErr error) *testFunction {
+		return &testFunction{
+			name:   name,
+			onInit: func() error { return initErr },
+			onClose: func() error {
+				closed = append(closed, name)
+				return nil
+			},
+		}
+	}
+
+	_, err := NewServer(ServerConfig{}, []functions.FunctionInterface{closing("one", nil), closing("two", nil), closing("three", fmt.Errorf("no database"))})
+	assert.EqualError(t, err, "failed to initialize function three: no database")
+	assert.Equal(t, []string{"two", "one"}, closed)
+
+	_, err = NewServer(ServerConfig{}, []functions.FunctionInterface{closing("one", nil), closing("one", nil)})
+	assert.EqualError(t, err, "duplicated function one")
+
+	closed = nil
+	server, err := NewServer(ServerConfig{}, []functions.FunctionInterface{closing("one", nil), closing("two", nil)})
+	assert.Nil(t, err)
+	assert.Nil(t, server.Close())
+	assert.Nil(t, server.Close())
+	assert.Equal(t, []string{"two", "one"}, closed)
+}
+
+func TestServer_Handle(t *testing.T) {
+	slow := &testFunction{
+		name:   "slow",
+		config: functions.FunctionConfig{Timeout: 10 * time.Millisecond},
+		onMessage: func(arguments map[string]interface{}) (*functions.FunctionGptResponse, error) {
+			time.Sleep(time.Second)
+			return &functions.FunctionGptResponse{}, nil
+		},
+	}
+	empty := &testFunction{
+		name: "empty",
+		onMessage: func(arguments map[string]interface{}) (*functions.FunctionGptResponse, error) {
+			return nil, nil
+		},
+	}
+	server, err := NewServer(ServerConfig{}, []functions.FunctionInterface{slow, empty})
+	assert.Nil(t, err)
+
+	tests := []struct {
+		name    string
+		message string
+		want    string
+	}{
+		{
+			name:    "Test with ping",
+			message: `{"jsonrpc":"2.0","id":"a","method":"ping"}`,
+			want:    `{"jsonrpc":"2.0","id":"a","result":{}}`,
+		},
+		{
+			name:    "Test with timeout",
+			message: `{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"slow"}}`,
+			want:    `{"jsonrpc":"2.0","id":1,"result":{"content":[{"type":"text","text":"function slow: function timed out after 10ms"}],"isError":true}}`,
+		},
+		{
+			name:    "Test without response",
+			message: `{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"empty"}}`,
+			want:    `{"jsonrpc":"2.0","id":2,"result":{"content":[{"type":"text","text":"function empty returned no response"}],"isError":true}}`,
+		},
+		{
+			name:    "Test with invalid params",
+			message: `{"jsonrpc":"2.0","id":3,"method":"tools/call","params":[]}`,
+			want:    `{"jsonrpc":"2.0","id":3,"error":{"code":-32602,"message":"json: cannot unmarshal array into Go value of type mcp.callToolParams"}}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var message Message
+			assert.Nil(t, json.Unmarshal([]byte(tt.message), &message))
+			response, ok := server.Handle(context.Background(), message)
+			assert.True(t, ok)
+			data, err := json.Marshal(response)
+			assert.Nil(t, err)
+			assert.JSONEq(t, tt.want, string(data))
+		})
+	}
+
+	_, ok := server.Handle(context.Background(), Message{JsonRpc: jsonRpcVersion, Method: "notifications/initialized"})
+	assert.False(t, ok)
+}
+
+func TestToContents(t *testing.T) {
+	tests := []struct {
+		name    string
+		content any
+		want    []Content
+	}{
+		{name: "Test with nil", content: nil, want: []Content{}},
+		{name: "Test with text", content: "text", want: []Content{TextContent("text")}},
+		{name: "Test with content", content: Content{Type: "image", Data: "aGk=", MimeType: "image/png"}, want: []Content{{Type: "image", Data: "aGk=", MimeType: "image/png"}}},
+		{name: "Test with struct", content: struct {
+			Total int `json:"total"`
+		}{Total: 120}, want: []Content{TextContent(`{"total":120}`)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content, err := toContents(tt.content)
+			assert.Nil(t, err)
+			assert.Equal(t, tt.want, content)
+		})
+	}
+
+	_, err := toContents(make(chan int))
+	assert.NotNil(t, err)
+}
+
+func TestServer_HttpSessions(t *testing.T) {
+	server, err := NewServer(ServerConfig{SessionIdleTimeout: time.Minute, MaxSessions: 2}, testFunctions())
+	assert.Nil(t, err)
+	now := time.Now()
+	server.now = func() time.Time { return now }
+
+	post := func(session string, body string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodPost, "/mcp", strings.NewReader(body))
+		if len(session) > 0 {
+			request.Header.Set(sessionHeader, session)
+		}
+		recorder := httptest.NewRecorder()
+		server.ServeHTTP(recorder, request)
+		return recorder
+	}
+	initialize := func() string {
+		recorder := post("", `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{}}`)
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		return recorder.Header().Get(sessionHeader)
+	}
+	ping := func(session string) int {
+		return post(session, `{"jsonrpc":"2.0","id":2,"method":"ping"}`).Code
+	}
+
+	first := initialize()
+	now = now.Add(30 * time.Second)
+	second := initialize()
+	now = now.Add(20 * time.Second)
+	assert.Equal(t, http.StatusOK, ping(first))
+
+	// the least recently used session ends to start another one
+	third := initialize()
+	assert.Equal(t, http.StatusNotFound, ping(second))
+	assert.Equal(t, http.StatusOK, ping(first))
+	assert.Equal(t, http.StatusOK, ping(third))
+
+	// the sessions end once they are unused for the idle timeout
+	now = now.Add(time.Minute + time.Second)
+	assert.Equal(t, http.StatusNotFound, ping(first))
+	initialize()
+	assert.Len(t, server.sessions, 1)
+}
+
+// counterFunction counts its calls in the store it is set with.
+type counterFunction struct {
+	testFunction
+	store *functions.FunctionStore
+}
+
+func (c *counterFunction) SetStore(store *functions.FunctionStore) {
+	c.store = store
+}
+
+func (c *counterFunction) OnMessage(arguments map[string]interface{}) (*functions.FunctionGptResponse, error) {
+	count := functions.Update(c.store, "count", func(count int, _ bool) int {
+		return count + 1
+	})
+	return &functions.FunctionGptResponse{Content: fmt.Sprintf("count %d", count)}, nil
+}
+
+func TestServer_HttpSessionFunctions(t *testing.T) {
+	closed := 0
+	server, err := NewServer(ServerConfig{
+		SessionFunctions: func() ([]functions.FunctionInterface, error) {
+			return []functions.FunctionInterface{&counterFunction{testFunction: testFunction{name: "count", onClose: func() error {
+				closed++
+				return nil
+			}}}}, nil
+		},
+	}, []functions.FunctionInterface{&counterFunction{testFunction: testFunction{name: "count"}}})
+	assert.Nil(t, err)
+
+	post := func(method string, session string, body string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(method, "/mcp", strings.NewReader(body))
+		if len(session) > 0 {
+			request.Header.Set(sessionHeader, session)
+		}
+		recorder := httptest.NewRecorder()
+		server.ServeHTTP(recorder, request)
+		return recorder
+	}
+	initialize := func() string {
+		return post(http.MethodPost, "", `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{}}`).Header().Get(sessionHeader)
+	}
+	count := func(session string) string {
+		recorder := post(http.MethodPost, session, `{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"count"}}`)
+		var response struct {
+			Result CallToolResult `json:"result"`
+		}
+		assert.Nil(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+		return response.Result.Text()
+	}
+
+	// every session counts in its own store
+	first := initialize()
+	second := initialize()
+	assert.Equal(t, "count 1", count(first))
+	assert.Equal(t, "count 2", count(first))
+	assert.Equal(t, "count 1", count(second))
+
+	// the functions of the session are closed when it ends
+	assert.Equal(t, http.StatusOK, post(http.MethodDelete, first, "").Code)
+	assert.Equal(t, 1, closed)
+	assert.Nil(t, server.Close())
+	assert.Equal(t, 2, closed)
+}
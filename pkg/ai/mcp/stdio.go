@@ -0,0 +1,168 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+// closeTimeout is how long Close waits for the server to exit after closing its input, before killing it.
+const closeTimeout = 5 * time.Second
+
+type StdioConfig struct {
+	// Command starts the server, which reads the messages from its input and writes them to its output, one per line.
+	Command string
+	Args    []string
+	// Env is added to the environment of the client, as KEY=value.
+	Env []string
+	// Stderr receives the logs of the server. Defaults to discarding them.
+	Stderr io.Writer
+}
+
+// StdioTransport talks to a server running as a sub-process.
+type StdioTransport struct {
+	command *exec.Cmd
+	input   io.WriteCloser
+
+	writeMutex sync.Mutex
+	mutex      sync.Mutex
+	pending    map[string]chan Message
+	done       chan struct{}
+	err        error
+	closeOnce  sync.Once
+}
+
+// NewStdioTransport starts the server and returns a new instance of the transport talking to it.
+func NewStdioTransport(config StdioConfig) (Transport, error) {
+	command := exec.Command(config.Command, config.Args...)
+	command.Env = append(os.Environ(), config.Env...)
+	command.Stderr = config.Stderr
+
+	input, err := command.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	output, err := command.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := command.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start mcp server %s: %w", config.Command, err)
+	}
+
+	transport := &StdioTransport{
+		command: command,
+		input:   input,
+		pending: map[string]chan Message{},
+		done:    make(chan struct{}),
+	}
+	go transport.read(output)
+	return transport, nil
+}
+
+// read dispatches the responses to the pending calls until the output of the server ends.
+func (s *StdioTransport) read(output io.Reader) {
+	scanner := bufio.NewScanner(output)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxMessageSize)
+
+	for scanner.Scan() {
+		var message Message
+		if err := json.Unmarshal(scanner.Bytes(), &message); err != nil {
+			// servers may print other lines, the protocol messages are still read
+			continue
+		}
+		switch {
+		case message.IsResponse():
+			s.mutex.Lock()
+			response, found := s.pending[string(message.Id)]
+			delete(s.pending, string(message.Id))
+			s.mutex.Unlock()
+			if found {
+				response <- message
+			}
+		case message.IsRequest():
+			_ = s.write(answerServerRequest(message))
+		}
+	}
+
+	err := scanner.Err()
+	if err == nil {
+		err = ErrTransportClosed
+	}
+	s.mutex.Lock()
+	s.err = err
+	s.mutex.Unlock()
+	close(s.done)
+}
+
+func (s *StdioTransport) write(message Message) error {
+	data, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+	s.writeMutex.Lock()
+	defer s.writeMutex.Unlock()
+	_, err = s.input.Write(append(data, '\n'))
+	return err
+}
+
+func (s *StdioTransport) Call(ctx context.Context, request Message) (Message, error) {
+	response := make(chan Message, 1)
+	s.mutex.Lock()
+	if s.err != nil {
+		s.mutex.Unlock()
+		return Message{}, s.err
+	}
+	s.pending[string(request.Id)] = response
+	s.mutex.Unlock()
+
+	forget := func() {
+		s.mutex.Lock()
+		delete(s.pending, string(request.Id))
+		s.mutex.Unlock()
+	}
+	if err := s.write(request); err != nil {
+		forget()
+		return Message{}, err
+	}
+
+	select {
+	case message := <-response:
+		return message, nil
+	case <-ctx.Done():
+		forget()
+		return Message{}, ctx.Err()
+	case <-s.done:
+		return Message{}, s.err
+	}
+}
+
+func (s *StdioTransport) Notify(ctx context.Context, notification Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return s.write(notification)
+}
+
+// Close closes the input of the server, and kills it when it does not exit.
+func (s *StdioTransport) Close() error {
+	var err error
+	s.closeOnce.Do(func() {
+		_ = s.input.Close()
+		// the output is read until the server exits, before waiting for the process
+		select {
+		case <-s.done:
+		case <-time.After(closeTimeout):
+			_ = s.command.Process.Kill()
+			<-s.done
+		}
+		err = s.command.Wait()
+	})
+	return err
+}
@@ -0,0 +1,32 @@
+package mcp
+
+import (
+	"context"
+	"errors"
+)
+
+// maxMessageSize is the maximum size of a message read from a transport.
+const maxMessageSize = 10 * 1024 * 1024
+
+// ErrTransportClosed is returned by the calls on a closed transport, or after the server stopped.
+var ErrTransportClosed = errors.New("mcp transport closed")
+
+// Transport sends the JSON-RPC messages to a server.
+type Transport interface {
+	// Call sends the request and returns the response with the same id.
+	Call(ctx context.Context, request Message) (Message, error)
+	// Notify sends the notification, which has no response.
+	Notify(ctx context.Context, notification Message) error
+	// Close stops the session with the server.
+	Close() error
+}
+
+// answerServerRequest returns the response to a request the server sends to the client.
+// The client only answers pings, as it declares no capabilities.
+func answerServerRequest(request Message) Message {
+	if request.Method == "ping" {
+		response, _ := newResponse(request.Id, struct{}{})
+		return response
+	}
+	return newErrorResponse(request.Id, MethodNotFound, "method not found: "+request.Method)
+}
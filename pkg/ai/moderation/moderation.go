@@ -0,0 +1,56 @@
+package moderation
+
+import (
+	"context"
+	"github.com/go-resty/resty/v2"
+	"slices"
+	"strings"
+)
+
+// Result is the result of the moderation of a text.
+type Result struct {
+	Flagged bool
+	// Categories are the sorted categories the text is flagged for.
+	Categories []string
+	// Scores are the scores of the categories between 0 and 1, when the moderator has them.
+	Scores map[string]float64
+}
+
+// Moderator checks whether a text is unsafe.
+type Moderator interface {
+	// Moderate returns the result of the moderation of the text.
+	Moderate(ctx context.Context, text string) (Result, error)
+}
+
+type IModerator interface {
+	Moderator
+	//SetClient sets the resty client for the moderator.
+	SetClient(client *resty.Client)
+}
+
+// Moderate runs the moderators in order and returns the result of the first one flagging the text.
+func Moderate(ctx context.Context, text string, moderators ...Moderator) (Result, error) {
+	if len(strings.TrimSpace(text)) == 0 {
+		return Result{}, nil
+	}
+	for _, moderator := range moderators {
+		result, err := moderator.Moderate(ctx, text)
+		if err != nil {
+			return Result{}, err
+		}
+		if result.Flagged {
+			return result, nil
+		}
+	}
+	return Result{}, nil
+}
+
+// newResult returns the result flagged for the categories.
+func newResult(categories []string, scores map[string]float64) Result {
+	slices.Sort(categories)
+	return Result{
+		Flagged:    len(categories) > 0,
+		Categories: slices.Compact(categories),
+		Scores:     scores,
+	}
+}
@@ -0,0 +1,156 @@
+package moderation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/go-resty/resty/v2"
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"regexp"
+	"testing"
+)
+
+type failingModerator struct{}
+
+func (f failingModerator) Moderate(context.Context, string) (Result, error) {
+	return Result{}, fmt.Errorf("moderation unavailable")
+}
+
+func TestOpenAIModerator_Moderate(t *testing.T) {
+	tests := []struct {
+		name       string
+		thresholds map[string]float64
+		status     int
+		response   string
+		want       Result
+		wantErr    string
+	}{
+		{
+			name:     "Test with flagged categories",
+			status:   http.StatusOK,
+			response: `{"results":[{"flagged":true,"categories":{"violence":true,"hate":false,"harassment":true},"category_scores":{"violence":0.9,"hate":0.1,"harassment":0.6}}]}`,
+			want: Result{
+				Flagged:    true,
+				Categories: []string{"harassment", "violence"},
+				Scores:     map[string]float64{"violence": 0.9, "hate": 0.1, "harassment": 0.6},
+			},
+		},
+		{
+			name:       "Test with thresholds",
+			thresholds: map[string]float64{"hate": 0.05, "harassment": 0.7},
+			status:     http.StatusOK,
+			response:   `{"results":[{"flagged":true,"categories":{"violence":true},"category_scores":{"violence":0.9,"hate":0.1,"harassment":0.6}}]}`,
+			want: Result{
+				Flagged:    true,
+				Categories: []string{"hate"},
+				Scores:     map[string]float64{"violence": 0.9, "hate": 0.1, "harassment": 0.6},
+			},
+		},
+		{
+			name:     "Test with safe text",
+			status:   http.StatusOK,
+			response: `{"results":[{"flagged":false,"categories":{"violence":false},"category_scores":{"violence":0.01}}]}`,
+			want:     Result{Scores: map[string]float64{"violence": 0.01}},
+		},
+		{
+			name:     "Test with error",
+			status:   http.StatusUnauthorized,
+			response: `invalid key`,
+			wantErr:  "failed to moderate text: 401 invalid key",
+		},
+		{
+			name:     "Test without results",
+			status:   http.StatusOK,
+			response: `{"results":[]}`,
+			wantErr:  "failed to moderate text: no results returned",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			restyClient := resty.New()
+			httpmock.ActivateNonDefault(restyClient.GetClient())
+			defer httpmock.DeactivateAndReset()
+
+			var body moderationRequestDto
+			var header http.Header
+			httpmock.RegisterResponder("POST", defaultEndpoint, func(request *http.Request) (*http.Response, error) {
+				header = request.Header
+				if err := json.NewDecoder(request.Body).Decode(&body); err != nil {
+					return nil, err
+				}
+				response := httpmock.NewStringResponse(tt.status, tt.response)
+				response.Header.Set("Content-Type", "application/json")
+				return response, nil
+			})
+
+			moderator := NewOpenAIModerator(OpenAIConfig{ApiKey: "key", Thresholds: tt.thresholds})
+			moderator.SetClient(restyClient)
+
+			result, err := moderator.Moderate(context.Background(), "text")
+			assert.Equal(t, moderationRequestDto{Model: defaultModel, Input: "text"}, body)
+			assert.Equal(t, "Bearer key", header.Get("Authorization"))
+			if len(tt.wantErr) > 0 {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tt.want, result)
+		})
+	}
+}
+
+func TestRuleModerator_Moderate(t *testing.T) {
+	moderator := NewRuleModerator(
+		KeywordRule("weapon", "gun", "炸彈"),
+		KeywordRule("empty"),
+		Rule{Category: "phone", Pattern: regexp.MustCompile(`09\d{8}`)},
+	)
+
+	tests := []struct {
+		name string
+		text string
+		want Result
+	}{
+		{
+			name: "Test with keyword ignoring the case",
+			text: "Where can I buy a GUN?",
+			want: Result{Flagged: true, Categories: []string{"weapon"}},
+		},
+		{
+			name: "Test with several rules",
+			text: "炸彈 0912345678",
+			want: Result{Flagged: true, Categories: []string{"phone", "weapon"}},
+		},
+		{
+			name: "Test with safe text",
+			text: "一份炒飯",
+			want: Result{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := moderator.Moderate(context.Background(), tt.text)
+			assert.Nil(t, err)
+			assert.Equal(t, tt.want, result)
+		})
+	}
+}
+
+func TestModerate(t *testing.T) {
+	rules := NewRuleModerator(KeywordRule("weapon", "gun"))
+
+	result, err := Moderate(context.Background(), "a gun", rules, failingModerator{})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"weapon"}, result.Categories)
+
+	_, err = Moderate(context.Background(), "a spoon", rules, failingModerator{})
+	assert.EqualError(t, err, "moderation unavailable")
+
+	result, err = Moderate(context.Background(), "  ", failingModerator{})
+	assert.Nil(t, err)
+	assert.False(t, result.Flagged)
+}
@@ -0,0 +1,112 @@
+package moderation
+
+import (
+	"context"
+	"fmt"
+	"github.com/go-resty/resty/v2"
+	"os"
+	"strings"
+)
+
+const (
+	defaultEndpoint = "https://api.openai.com/v1/moderations"
+	defaultModel    = "omni-moderation-latest"
+)
+
+type OpenAIConfig struct {
+	// Endpoint defaults to https://api.openai.com/v1/moderations.
+	Endpoint string
+	ApiKey   string
+	// Model defaults to omni-moderation-latest.
+	Model string
+	// Thresholds are the categories checked with the score flagging them, such as {"violence": 0.5}.
+	// By default, the text is flagged for the categories the endpoint flags.
+	Thresholds map[string]float64
+}
+
+type moderationRequestDto struct {
+	Model string `json:"model"`
+	Input string `json:"input"`
+}
+
+type moderationResultDto struct {
+	Flagged        bool               `json:"flagged"`
+	Categories     map[string]bool    `json:"categories"`
+	CategoryScores map[string]float64 `json:"category_scores"`
+}
+
+type moderationResponseDto struct {
+	Results []moderationResultDto `json:"results"`
+}
+
+type OpenAIModerator struct {
+	config     OpenAIConfig
+	httpClient *resty.Client
+}
+
+// NewOpenAIModerator returns a new instance of the moderator calling the OpenAI moderation endpoint.
+func NewOpenAIModerator(config OpenAIConfig) IModerator {
+	if len(config.Endpoint) == 0 {
+		config.Endpoint = defaultEndpoint
+	}
+	if len(config.Model) == 0 {
+		config.Model = defaultModel
+	}
+
+	client := resty.New()
+	client.SetDebug(os.Getenv("DEBUG") == "true")
+
+	return &OpenAIModerator{
+		config:     config,
+		httpClient: client,
+	}
+}
+
+// SetClient sets the resty client for the moderator.
+func (o *OpenAIModerator) SetClient(client *resty.Client) {
+	o.httpClient = client
+}
+
+// Moderate returns the result of the moderation of the text by the endpoint.
+func (o *OpenAIModerator) Moderate(ctx context.Context, text string) (Result, error) {
+	request := o.httpClient.R().SetContext(ctx)
+	if strings.Contains(o.config.Endpoint, "api.openai.com") {
+		request = request.SetHeader("Authorization", "Bearer "+o.config.ApiKey)
+	} else {
+		request = request.SetHeader("api-key", o.config.ApiKey)
+	}
+
+	var body moderationResponseDto
+	response, err := request.SetBody(moderationRequestDto{
+		Model: o.config.Model,
+		Input: text,
+	}).SetResult(&body).Post(o.config.Endpoint)
+	if err != nil {
+		return Result{}, err
+	}
+
+	if !response.IsSuccess() {
+		return Result{}, fmt.Errorf("failed to moderate text: %d %s", response.StatusCode(), response.String())
+	}
+
+	if len(body.Results) == 0 {
+		return Result{}, fmt.Errorf("failed to moderate text: no results returned")
+	}
+
+	result := body.Results[0]
+	var categories []string
+	if len(o.config.Thresholds) == 0 {
+		for category, flagged := range result.Categories {
+			if flagged {
+				categories = append(categories, category)
+			}
+		}
+	} else {
+		for category, threshold := range o.config.Thresholds {
+			if score, found := result.CategoryScores[category]; found && score >= threshold {
+				categories = append(categories, category)
+			}
+		}
+	}
+	return newResult(categories, result.CategoryScores), nil
+}
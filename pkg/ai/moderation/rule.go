@@ -0,0 +1,50 @@
+package moderation
+
+import (
+	"context"
+	"regexp"
+	"strings"
+)
+
+// Rule flags the texts matching its pattern for its category. A rule without a pattern never matches.
+type Rule struct {
+	Category string
+	Pattern  *regexp.Regexp
+}
+
+// KeywordRule returns the rule flagging the texts containing one of the keywords, ignoring the case.
+func KeywordRule(category string, keywords ...string) Rule {
+	if len(keywords) == 0 {
+		return Rule{Category: category}
+	}
+	quoted := make([]string, len(keywords))
+	for index, keyword := range keywords {
+		quoted[index] = regexp.QuoteMeta(keyword)
+	}
+	return Rule{
+		Category: category,
+		Pattern:  regexp.MustCompile("(?i)" + strings.Join(quoted, "|")),
+	}
+}
+
+type RuleModerator struct {
+	rules []Rule
+}
+
+// NewRuleModerator returns a new instance of the moderator checking the texts locally against the rules.
+func NewRuleModerator(rules ...Rule) Moderator {
+	return &RuleModerator{
+		rules: rules,
+	}
+}
+
+// Moderate returns the result flagged for the categories of the rules the text matches.
+func (r *RuleModerator) Moderate(_ context.Context, text string) (Result, error) {
+	var categories []string
+	for _, rule := range r.rules {
+		if rule.Pattern != nil && rule.Pattern.MatchString(text) {
+			categories = append(categories, rule.Category)
+		}
+	}
+	return newResult(categories, nil), nil
+}
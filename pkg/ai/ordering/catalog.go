@@ -0,0 +1,98 @@
+package ordering
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Choice is a possible value of an option, such as a size. Its price is added to the price of the item.
+type Choice struct {
+	Name  string `json:"name"`
+	Price int    `json:"price"`
+}
+
+// Option is a choice the customer makes for an item, such as the size or the spiciness.
+type Option struct {
+	Name string `json:"name"`
+	// Required options must be chosen. The other ones default to no choice.
+	Required bool     `json:"required"`
+	Choices  []Choice `json:"choices"`
+}
+
+// Item is a dish or a drink of the menu.
+type Item struct {
+	Id          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	// Price is in the smallest unit of the currency, so the totals are exact.
+	Price   int      `json:"price"`
+	Options []Option `json:"options,omitempty"`
+	// SoldOut items are listed, but cannot be ordered.
+	SoldOut bool `json:"soldOut,omitempty"`
+}
+
+// Catalog is the menu of the restaurant.
+type Catalog struct {
+	Currency string `json:"currency"`
+	Items    []Item `json:"items"`
+}
+
+// LoadCatalog reads the catalog from a JSON file.
+func LoadCatalog(path string) (*Catalog, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseCatalog(data)
+}
+
+// ParseCatalog parses the catalog from JSON, and checks the items can be ordered.
+func ParseCatalog(data []byte) (*Catalog, error) {
+	var catalog Catalog
+	if err := json.Unmarshal(data, &catalog); err != nil {
+		return nil, fmt.Errorf("invalid catalog: %w", err)
+	}
+
+	ids := map[string]bool{}
+	for _, item := range catalog.Items {
+		if len(item.Id) == 0 || len(item.Name) == 0 {
+			return nil, fmt.Errorf("invalid catalog: items need an id and a name")
+		}
+		if ids[item.Id] {
+			return nil, fmt.Errorf("invalid catalog: duplicated item %s", item.Id)
+		}
+		ids[item.Id] = true
+		if item.Price < 0 {
+			return nil, fmt.Errorf("invalid catalog: negative price of item %s", item.Id)
+		}
+		for _, option := range item.Options {
+			if len(option.Choices) == 0 {
+				return nil, fmt.Errorf("invalid catalog: option %s of item %s has no choices", option.Name, item.Id)
+			}
+		}
+	}
+	return &catalog, nil
+}
+
+// Find returns the item with the id or the name, ignoring the case.
+func (c *Catalog) Find(name string) (Item, bool) {
+	name = strings.TrimSpace(name)
+	for _, item := range c.Items {
+		if strings.EqualFold(item.Id, name) || strings.EqualFold(item.Name, name) {
+			return item, true
+		}
+	}
+	return Item{}, false
+}
+
+// findChoice returns the choice of the option with the name, ignoring the case.
+func (o Option) findChoice(name string) (Choice, bool) {
+	for _, choice := range o.Choices {
+		if strings.EqualFold(choice.Name, strings.TrimSpace(name)) {
+			return choice, true
+		}
+	}
+	return Choice{}, false
+}
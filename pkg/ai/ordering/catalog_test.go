@@ -0,0 +1,74 @@
+package ordering
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestLoadCatalog(t *testing.T) {
+	catalog, err := LoadCatalog("testdata/menu.json")
+	assert.Nil(t, err)
+	assert.Equal(t, "TWD", catalog.Currency)
+	assert.Len(t, catalog.Items, 3)
+
+	item, found := catalog.Find(" Fried-Rice ")
+	assert.True(t, found)
+	assert.Equal(t, "炒飯", item.Name)
+	item, found = catalog.Find("紅茶")
+	assert.True(t, found)
+	assert.Equal(t, "black-tea", item.Id)
+	_, found = catalog.Find("pizza")
+	assert.False(t, found)
+
+	_, err = LoadCatalog("testdata/missing.json")
+	assert.NotNil(t, err)
+}
+
+func TestParseCatalog(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		wantErr string
+	}{
+		{
+			name: "Test with valid catalog",
+			data: `{"currency":"TWD","items":[{"id":"tea","name":"Tea","price":40}]}`,
+		},
+		{
+			name:    "Test with invalid JSON",
+			data:    `{"items":`,
+			wantErr: "invalid catalog: unexpected end of JSON input",
+		},
+		{
+			name:    "Test without id",
+			data:    `{"items":[{"name":"Tea"}]}`,
+			wantErr: "invalid catalog: items need an id and a name",
+		},
+		{
+			name:    "Test with duplicated item",
+			data:    `{"items":[{"id":"tea","name":"Tea"},{"id":"tea","name":"Green tea"}]}`,
+			wantErr: "invalid catalog: duplicated item tea",
+		},
+		{
+			name:    "Test with negative price",
+			data:    `{"items":[{"id":"tea","name":"Tea","price":-1}]}`,
+			wantErr: "invalid catalog: negative price of item tea",
+		},
+		{
+			name:    "Test with option without choices",
+			data:    `{"items":[{"id":"tea","name":"Tea","options":[{"name":"ice"}]}]}`,
+			wantErr: "invalid catalog: option ice of item tea has no choices",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseCatalog([]byte(tt.data))
+			if len(tt.wantErr) > 0 {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			assert.Nil(t, err)
+		})
+	}
+}
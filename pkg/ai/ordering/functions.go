@@ -0,0 +1,282 @@
+package ordering
+
+import (
+	"fmt"
+	"github.com/meta-metopia/go-packages/pkg/ai/gpt/functions"
+	"math"
+	"slices"
+)
+
+// StoreKey is the key of the Order of the conversation in the FunctionStore.
+const StoreKey = "order"
+
+// The names of the functions returned by NewFunctions.
+const (
+	GetMenuFunction         = "get-menu"
+	AddToOrderFunction      = "add-to-order"
+	RemoveFromOrderFunction = "remove-from-order"
+	SetQuantityFunction     = "set-quantity"
+	ViewOrderFunction       = "view-order"
+	CheckoutFunction        = "checkout"
+	ConfirmOrderFunction    = "confirm-order"
+	CancelOrderFunction     = "cancel-order"
+)
+
+type Config struct {
+	Catalog *Catalog
+	// ConfirmRequiresApproval pauses the turn before the order is placed, until the caller approves it.
+	ConfirmRequiresApproval bool
+}
+
+// Summary is the order with its total, as the functions return it to the model.
+type Summary struct {
+	Order
+	Total int `json:"total"`
+}
+
+// Function is a function of the ordering, working on the Order kept in the store of the conversation.
+// The errors, such as an unknown item, are sent to the model so it can tell the customer.
+type Function struct {
+	functions.FunctionClient
+	name        string
+	description string
+	parameters  map[string]interface{}
+	config      functions.FunctionConfig
+	catalog     *Catalog
+	store       *functions.FunctionStore
+	run         func(f *Function, arguments map[string]interface{}) (any, error)
+}
+
+func (f *Function) Name() string {
+	return f.name
+}
+
+func (f *Function) Description() string {
+	return f.description
+}
+
+func (f *Function) Parameters() map[string]interface{} {
+	return f.parameters
+}
+
+func (f *Function) Config() functions.FunctionConfig {
+	return f.config
+}
+
+func (f *Function) SetStore(store *functions.FunctionStore) {
+	f.store = store
+}
+
+func (f *Function) OnMessage(arguments map[string]interface{}) (*functions.FunctionGptResponse, error) {
+	content, err := f.run(f, arguments)
+	if err != nil {
+		return nil, err
+	}
+	return &functions.FunctionGptResponse{Content: content}, nil
+}
+
+// order returns the order of the conversation, or a new one.
+func (f *Function) order() Order {
+	order, found := functions.Get[Order](f.store, StoreKey)
+	if !found {
+		return NewOrder(f.catalog.Currency)
+	}
+	return order
+}
+
+// update changes the order of the conversation, and returns its summary. The order is kept as is when [change] fails.
+func (f *Function) update(change func(order *Order) error) (any, error) {
+	var err error
+	order := functions.Update(f.store, StoreKey, func(order Order, found bool) Order {
+		if !found {
+			order = NewOrder(f.catalog.Currency)
+		}
+		// the lines are copied, so a failed change never alters the stored order
+		updated := order
+		updated.Lines = slices.Clone(order.Lines)
+		if err = change(&updated); err != nil {
+			return order
+		}
+		return updated
+	})
+	if err != nil {
+		return nil, err
+	}
+	return Summary{Order: order, Total: order.Total()}, nil
+}
+
+// NewFunctions returns the functions browsing the catalog and taking the order.
+// They share a store until the client sets the store of the conversation.
+func NewFunctions(config Config) []functions.FunctionInterface {
+	store := functions.NewFunctionStore()
+	itemParameter := map[string]interface{}{
+		"type":        "string",
+		"description": "The id or the name of the item in the menu.",
+	}
+	return []functions.FunctionInterface{
+		newFunction(config, store, GetMenuFunction, "Get the menu, with the prices and the options of the items.", nil, nil,
+			func(f *Function, arguments map[string]interface{}) (any, error) {
+				return f.catalog, nil
+			},
+		),
+		newFunction(config, store, AddToOrderFunction, "Add an item of the menu to the order.", map[string]interface{}{
+			"item": itemParameter,
+			"quantity": map[string]interface{}{
+				"type":        "integer",
+				"description": "The quantity to add. Defaults to 1.",
+			},
+			"options": map[string]interface{}{
+				"type":                 "object",
+				"description":          "The chosen options of the item, by option name, such as {\"size\": \"large\"}.",
+				"additionalProperties": map[string]interface{}{"type": "string"},
+			},
+		}, []string{"item"}, func(f *Function, arguments map[string]interface{}) (any, error) {
+			item, err := stringArgument(arguments, "item")
+			if err != nil {
+				return nil, err
+			}
+			quantity, err := intArgument(arguments, "quantity", 1)
+			if err != nil {
+				return nil, err
+			}
+			choices, err := choicesArgument(arguments, "options")
+			if err != nil {
+				return nil, err
+			}
+			return f.update(func(order *Order) error {
+				return order.Add(f.catalog, item, quantity, choices)
+			})
+		}),
+		newFunction(config, store, RemoveFromOrderFunction, "Remove an item from the order.", map[string]interface{}{
+			"item": itemParameter,
+			"quantity": map[string]interface{}{
+				"type":        "integer",
+				"description": "The quantity to remove. Removes all of them when it is not set.",
+			},
+		}, []string{"item"}, func(f *Function, arguments map[string]interface{}) (any, error) {
+			item, err := stringArgument(arguments, "item")
+			if err != nil {
+				return nil, err
+			}
+			quantity, err := intArgument(arguments, "quantity", 0)
+			if err != nil {
+				return nil, err
+			}
+			return f.update(func(order *Order) error {
+				return order.Remove(f.catalog, item, quantity)
+			})
+		}),
+		newFunction(config, store, SetQuantityFunction, "Change the quantity of an item of the order. 0 removes it.", map[string]interface{}{
+			"item": itemParameter,
+			"quantity": map[string]interface{}{
+				"type":        "integer",
+				"description": "The new quantity.",
+			},
+		}, []string{"item", "quantity"}, func(f *Function, arguments map[string]interface{}) (any, error) {
+			item, err := stringArgument(arguments, "item")
+			if err != nil {
+				return nil, err
+			}
+			quantity, err := intArgument(arguments, "quantity", -1)
+			if err != nil {
+				return nil, err
+			}
+			return f.update(func(order *Order) error {
+				return order.SetQuantity(f.catalog, item, quantity)
+			})
+		}),
+		newFunction(config, store, ViewOrderFunction, "Get the items of the order and its total.", nil, nil,
+			func(f *Function, arguments map[string]interface{}) (any, error) {
+				order := f.order()
+				return Summary{Order: order, Total: order.Total()}, nil
+			},
+		),
+		newFunction(config, store, CheckoutFunction, "Check out the order once the customer does not need anything else. The customer must confirm it afterwards.", nil, nil,
+			func(f *Function, arguments map[string]interface{}) (any, error) {
+				return f.update((*Order).Checkout)
+			},
+		),
+		newFunction(config, store, ConfirmOrderFunction, "Place the order checked out, once the customer confirmed it.", nil, nil,
+			func(f *Function, arguments map[string]interface{}) (any, error) {
+				return f.update((*Order).Confirm)
+			},
+		),
+		newFunction(config, store, CancelOrderFunction, "Cancel the order.", nil, nil,
+			func(f *Function, arguments map[string]interface{}) (any, error) {
+				return f.update((*Order).Cancel)
+			},
+		),
+	}
+}
+
+func newFunction(config Config, store *functions.FunctionStore, name string, description string, properties map[string]interface{}, required []string, run func(f *Function, arguments map[string]interface{}) (any, error)) *Function {
+	if properties == nil {
+		properties = map[string]interface{}{}
+	}
+	if required == nil {
+		required = []string{}
+	}
+	return &Function{
+		name:        name,
+		description: description,
+		parameters: map[string]interface{}{
+			"type":       "object",
+			"required":   required,
+			"properties": properties,
+		},
+		config: functions.FunctionConfig{
+			UseGptToInterpretResponses: true,
+			RequiresApproval:           name == ConfirmOrderFunction && config.ConfirmRequiresApproval,
+			OnError:                    functions.ReportToModel,
+		},
+		catalog: config.Catalog,
+		store:   store,
+		run:     run,
+	}
+}
+
+// stringArgument returns the argument [name], which is required.
+func stringArgument(arguments map[string]interface{}, name string) (string, error) {
+	value, ok := arguments[name].(string)
+	if !ok || len(value) == 0 {
+		return "", fmt.Errorf("%s is required", name)
+	}
+	return value, nil
+}
+
+// intArgument returns the integer argument [name], or [fallback] when it is not set.
+func intArgument(arguments map[string]interface{}, name string, fallback int) (int, error) {
+	switch value := arguments[name].(type) {
+	case nil:
+		return fallback, nil
+	case float64:
+		if value != math.Trunc(value) {
+			return 0, fmt.Errorf("%s must be an integer", name)
+		}
+		return int(value), nil
+	case int:
+		return value, nil
+	}
+	return 0, fmt.Errorf("%s must be an integer", name)
+}
+
+// choicesArgument returns the choices of the options by option name.
+func choicesArgument(arguments map[string]interface{}, name string) (map[string]string, error) {
+	value, found := arguments[name]
+	if !found || value == nil {
+		return nil, nil
+	}
+	options, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s must be an object", name)
+	}
+	choices := make(map[string]string, len(options))
+	for option, choice := range options {
+		text, ok := choice.(string)
+		if !ok {
+			return nil, fmt.Errorf("the choice of option %s must be a string", option)
+		}
+		choices[option] = text
+	}
+	return choices, nil
+}
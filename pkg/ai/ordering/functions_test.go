@@ -0,0 +1,129 @@
+package ordering
+
+import (
+	"encoding/json"
+	"github.com/meta-metopia/go-packages/pkg/ai/gpt/functions"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// call calls the function [name] with the arguments as the model sends them.
+func call(t *testing.T, all []functions.FunctionInterface, name string, arguments string) (any, error) {
+	var parsed map[string]interface{}
+	assert.Nil(t, json.Unmarshal([]byte(arguments), &parsed))
+	for _, function := range all {
+		if function.Name() == name {
+			response, err := function.OnMessage(parsed)
+			if err != nil {
+				return nil, err
+			}
+			return response.Content, nil
+		}
+	}
+	t.Fatalf("function %s is not found", name)
+	return nil, nil
+}
+
+func setStore(all []functions.FunctionInterface, store *functions.FunctionStore) {
+	for _, function := range all {
+		function.SetStore(store)
+	}
+}
+
+func TestNewFunctions(t *testing.T) {
+	catalog := loadCatalog(t)
+	all := NewFunctions(Config{Catalog: catalog, ConfirmRequiresApproval: true})
+
+	var names []string
+	for _, function := range all {
+		names = append(names, function.Name())
+		assert.Equal(t, "object", function.Parameters()["type"])
+		assert.True(t, function.Config().UseGptToInterpretResponses)
+		assert.Equal(t, functions.ReportToModel, function.Config().OnError)
+		assert.Equal(t, function.Name() == ConfirmOrderFunction, function.Config().RequiresApproval)
+	}
+	assert.Equal(t, []string{
+		GetMenuFunction, AddToOrderFunction, RemoveFromOrderFunction, SetQuantityFunction,
+		ViewOrderFunction, CheckoutFunction, ConfirmOrderFunction, CancelOrderFunction,
+	}, names)
+
+	menu, err := call(t, all, GetMenuFunction, `{}`)
+	assert.Nil(t, err)
+	assert.Equal(t, catalog, menu)
+}
+
+func TestFunctions_Flow(t *testing.T) {
+	catalog := loadCatalog(t)
+	all := NewFunctions(Config{Catalog: catalog})
+	store := functions.NewFunctionStore()
+	setStore(all, store)
+
+	summary, err := call(t, all, AddToOrderFunction, `{"item":"炒飯","quantity":2,"options":{"size":"large"}}`)
+	assert.Nil(t, err)
+	assert.Equal(t, 300, summary.(Summary).Total)
+
+	_, err = call(t, all, AddToOrderFunction, `{"item":"black-tea"}`)
+	assert.Nil(t, err)
+	_, err = call(t, all, SetQuantityFunction, `{"item":"fried-rice","quantity":1}`)
+	assert.Nil(t, err)
+
+	// the errors are returned so the model can tell the customer, and the order is kept as is
+	_, err = call(t, all, AddToOrderFunction, `{"item":"pizza"}`)
+	assert.ErrorIs(t, err, ErrUnknownItem)
+	_, err = call(t, all, AddToOrderFunction, `{"item":"black-tea","quantity":1.5}`)
+	assert.EqualError(t, err, "quantity must be an integer")
+	_, err = call(t, all, RemoveFromOrderFunction, `{}`)
+	assert.EqualError(t, err, "item is required")
+	_, err = call(t, all, ConfirmOrderFunction, `{}`)
+	assert.ErrorIs(t, err, ErrInvalidTransition)
+
+	summary, err = call(t, all, ViewOrderFunction, `{}`)
+	assert.Nil(t, err)
+	assert.Equal(t, StatusOpen, summary.(Summary).Status)
+	assert.Equal(t, 190, summary.(Summary).Total)
+
+	summary, err = call(t, all, CheckoutFunction, `{}`)
+	assert.Nil(t, err)
+	assert.Equal(t, StatusCheckout, summary.(Summary).Status)
+
+	// the order is kept in the store of the conversation, which can be saved and loaded
+	data, err := json.Marshal(store)
+	assert.Nil(t, err)
+	loaded := functions.NewFunctionStore()
+	assert.Nil(t, json.Unmarshal(data, loaded))
+	all = NewFunctions(Config{Catalog: catalog})
+	setStore(all, loaded)
+
+	summary, err = call(t, all, ConfirmOrderFunction, `{}`)
+	assert.Nil(t, err)
+	assert.Equal(t, StatusPlaced, summary.(Summary).Status)
+	assert.Equal(t, 190, summary.(Summary).Total)
+
+	_, err = call(t, all, CancelOrderFunction, `{}`)
+	assert.ErrorIs(t, err, ErrInvalidTransition)
+	order, found := functions.Get[Order](loaded, StoreKey)
+	assert.True(t, found)
+	assert.Equal(t, StatusPlaced, order.Status)
+	assert.Len(t, order.Lines, 2)
+}
+
+func TestFunctions_Cancel(t *testing.T) {
+	catalog := loadCatalog(t)
+	all := NewFunctions(Config{Catalog: catalog})
+
+	summary, err := call(t, all, ViewOrderFunction, `{}`)
+	assert.Nil(t, err)
+	assert.Equal(t, Summary{Order: NewOrder("TWD")}, summary)
+
+	_, err = call(t, all, CheckoutFunction, `{}`)
+	assert.ErrorIs(t, err, ErrEmptyOrder)
+
+	_, err = call(t, all, AddToOrderFunction, `{"item":"black-tea","quantity":3}`)
+	assert.Nil(t, err)
+	_, err = call(t, all, RemoveFromOrderFunction, `{"item":"紅茶","quantity":1}`)
+	assert.Nil(t, err)
+	summary, err = call(t, all, CancelOrderFunction, `{}`)
+	assert.Nil(t, err)
+	assert.Equal(t, StatusCancelled, summary.(Summary).Status)
+	assert.Equal(t, 80, summary.(Summary).Total)
+}
@@ -0,0 +1,254 @@
+package ordering
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Status is the step of the order in the checkout.
+//
+//	open ──Checkout──▶ checkout ──Confirm──▶ placed
+//	  ▲                  │
+//	  └──Add, Remove─────┘
+//	open, checkout ──Cancel──▶ cancelled
+type Status string
+
+const (
+	// StatusOpen orders can be changed.
+	StatusOpen Status = "open"
+	// StatusCheckout orders wait for the customer to confirm them. Changing them opens them again.
+	StatusCheckout Status = "checkout"
+	// StatusPlaced orders are confirmed and cannot be changed.
+	StatusPlaced Status = "placed"
+	// StatusCancelled orders are abandoned and cannot be changed.
+	StatusCancelled Status = "cancelled"
+)
+
+var (
+	ErrUnknownItem       = errors.New("unknown item")
+	ErrSoldOut           = errors.New("item sold out")
+	ErrInvalidOption     = errors.New("invalid option")
+	ErrInvalidQuantity   = errors.New("invalid quantity")
+	ErrNotInOrder        = errors.New("item not in the order")
+	ErrEmptyOrder        = errors.New("empty order")
+	ErrInvalidTransition = errors.New("invalid transition")
+)
+
+// SelectedOption is the choice of the customer for an option of the item.
+type SelectedOption struct {
+	Option string `json:"option"`
+	Choice string `json:"choice"`
+	Price  int    `json:"price"`
+}
+
+// Line is an item of the order with its options.
+type Line struct {
+	ItemId  string           `json:"itemId"`
+	Name    string           `json:"name"`
+	Options []SelectedOption `json:"options,omitempty"`
+	// UnitPrice is the price of the item with its options.
+	UnitPrice int `json:"unitPrice"`
+	Quantity  int `json:"quantity"`
+}
+
+// Total returns the price of the line.
+func (l Line) Total() int {
+	return l.UnitPrice * l.Quantity
+}
+
+// Order is the cart of the customer, and its step in the checkout.
+type Order struct {
+	Status   Status `json:"status"`
+	Currency string `json:"currency"`
+	Lines    []Line `json:"lines"`
+}
+
+// NewOrder returns a new instance of an open and empty Order.
+func NewOrder(currency string) Order {
+	return Order{Status: StatusOpen, Currency: currency}
+}
+
+// Total returns the price of the order.
+func (o Order) Total() int {
+	total := 0
+	for _, line := range o.Lines {
+		total += line.Total()
+	}
+	return total
+}
+
+// Add adds the item [name] to the order, with the [choices] of its options by option name.
+// The quantity of the line with the same options is increased.
+// Adding to a placed or cancelled order starts a new one.
+func (o *Order) Add(catalog *Catalog, name string, quantity int, choices map[string]string) error {
+	if quantity < 1 {
+		return fmt.Errorf("%w: %d", ErrInvalidQuantity, quantity)
+	}
+	item, found := catalog.Find(name)
+	if !found {
+		return fmt.Errorf("%w: %s", ErrUnknownItem, name)
+	}
+	if item.SoldOut {
+		return fmt.Errorf("%w: %s", ErrSoldOut, item.Name)
+	}
+	options, err := selectOptions(item, choices)
+	if err != nil {
+		return err
+	}
+
+	if o.Status == StatusPlaced || o.Status == StatusCancelled {
+		*o = NewOrder(catalog.Currency)
+	}
+	o.Status = StatusOpen
+
+	index := slices.IndexFunc(o.Lines, func(line Line) bool {
+		return line.ItemId == item.Id && slices.Equal(line.Options, options)
+	})
+	if index >= 0 {
+		o.Lines[index].Quantity += quantity
+		return nil
+	}
+
+	unitPrice := item.Price
+	for _, option := range options {
+		unitPrice += option.Price
+	}
+	o.Lines = append(o.Lines, Line{
+		ItemId:    item.Id,
+		Name:      item.Name,
+		Options:   options,
+		UnitPrice: unitPrice,
+		Quantity:  quantity,
+	})
+	return nil
+}
+
+// selectOptions returns the options chosen for the item, in the order of the options of the item.
+func selectOptions(item Item, choices map[string]string) ([]SelectedOption, error) {
+	for name := range choices {
+		if !slices.ContainsFunc(item.Options, func(option Option) bool {
+			return strings.EqualFold(option.Name, name)
+		}) {
+			return nil, fmt.Errorf("%w: %s has no option %s", ErrInvalidOption, item.Name, name)
+		}
+	}
+
+	var selected []SelectedOption
+	for _, option := range item.Options {
+		var choiceName string
+		for name, value := range choices {
+			if strings.EqualFold(option.Name, name) {
+				choiceName = value
+			}
+		}
+		if len(choiceName) == 0 {
+			if option.Required {
+				return nil, fmt.Errorf("%w: %s of %s is required", ErrInvalidOption, option.Name, item.Name)
+			}
+			continue
+		}
+
+		choice, found := option.findChoice(choiceName)
+		if !found {
+			return nil, fmt.Errorf("%w: %s is not a choice of %s", ErrInvalidOption, choiceName, option.Name)
+		}
+		selected = append(selected, SelectedOption{Option: option.Name, Choice: choice.Name, Price: choice.Price})
+	}
+	return selected, nil
+}
+
+// Remove removes the quantity of the item [name] from the order. 0 removes the whole line.
+func (o *Order) Remove(catalog *Catalog, name string, quantity int) error {
+	if quantity < 0 {
+		return fmt.Errorf("%w: %d", ErrInvalidQuantity, quantity)
+	}
+	index, err := o.findLine(catalog, name)
+	if err != nil {
+		return err
+	}
+
+	o.Status = StatusOpen
+	if quantity == 0 || quantity >= o.Lines[index].Quantity {
+		o.Lines = slices.Delete(o.Lines, index, index+1)
+		return nil
+	}
+	o.Lines[index].Quantity -= quantity
+	return nil
+}
+
+// SetQuantity sets the quantity of the item [name] in the order. 0 removes the line.
+func (o *Order) SetQuantity(catalog *Catalog, name string, quantity int) error {
+	if quantity < 0 {
+		return fmt.Errorf("%w: %d", ErrInvalidQuantity, quantity)
+	}
+	index, err := o.findLine(catalog, name)
+	if err != nil {
+		return err
+	}
+
+	o.Status = StatusOpen
+	if quantity == 0 {
+		o.Lines = slices.Delete(o.Lines, index, index+1)
+		return nil
+	}
+	o.Lines[index].Quantity = quantity
+	return nil
+}
+
+// findLine returns the index of the first line of the item [name], checking the order can be changed.
+func (o *Order) findLine(catalog *Catalog, name string) (int, error) {
+	if err := o.changeable("change"); err != nil {
+		return 0, err
+	}
+	item, found := catalog.Find(name)
+	if !found {
+		return 0, fmt.Errorf("%w: %s", ErrUnknownItem, name)
+	}
+	index := slices.IndexFunc(o.Lines, func(line Line) bool {
+		return line.ItemId == item.Id
+	})
+	if index < 0 {
+		return 0, fmt.Errorf("%w: %s", ErrNotInOrder, item.Name)
+	}
+	return index, nil
+}
+
+// Checkout asks the customer to confirm the order.
+func (o *Order) Checkout() error {
+	if o.Status != StatusOpen && o.Status != StatusCheckout {
+		return fmt.Errorf("%w: cannot check out a %s order", ErrInvalidTransition, o.Status)
+	}
+	if len(o.Lines) == 0 {
+		return ErrEmptyOrder
+	}
+	o.Status = StatusCheckout
+	return nil
+}
+
+// Confirm places the order the customer checked out.
+func (o *Order) Confirm() error {
+	if o.Status != StatusCheckout {
+		return fmt.Errorf("%w: cannot confirm a %s order", ErrInvalidTransition, o.Status)
+	}
+	o.Status = StatusPlaced
+	return nil
+}
+
+// Cancel abandons the order.
+func (o *Order) Cancel() error {
+	if err := o.changeable("cancel"); err != nil {
+		return err
+	}
+	o.Status = StatusCancelled
+	return nil
+}
+
+// changeable returns an error when the order is placed or cancelled.
+func (o *Order) changeable(action string) error {
+	if o.Status == StatusPlaced || o.Status == StatusCancelled {
+		return fmt.Errorf("%w: cannot %s a %s order", ErrInvalidTransition, action, o.Status)
+	}
+	return nil
+}
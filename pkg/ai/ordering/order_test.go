@@ -0,0 +1,206 @@
+package ordering
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func loadCatalog(t *testing.T) *Catalog {
+	catalog, err := LoadCatalog("testdata/menu.json")
+	assert.Nil(t, err)
+	return catalog
+}
+
+func TestOrder_Add(t *testing.T) {
+	catalog := loadCatalog(t)
+
+	tests := []struct {
+		name     string
+		item     string
+		quantity int
+		choices  map[string]string
+		want     Line
+		wantErr  error
+	}{
+		{
+			name:     "Test with options",
+			item:     "炒飯",
+			quantity: 2,
+			choices:  map[string]string{"Size": "Large", "egg": "extra"},
+			want: Line{
+				ItemId: "fried-rice",
+				Name:   "炒飯",
+				Options: []SelectedOption{
+					{Option: "size", Choice: "large", Price: 30},
+					{Option: "egg", Choice: "extra", Price: 15},
+				},
+				UnitPrice: 165,
+				Quantity:  2,
+			},
+		},
+		{
+			name:     "Test without options",
+			item:     "black-tea",
+			quantity: 1,
+			want:     Line{ItemId: "black-tea", Name: "紅茶", UnitPrice: 40, Quantity: 1},
+		},
+		{
+			name:     "Test without required option",
+			item:     "fried-rice",
+			quantity: 1,
+			wantErr:  ErrInvalidOption,
+		},
+		{
+			name:     "Test with unknown option",
+			item:     "black-tea",
+			quantity: 1,
+			choices:  map[string]string{"sugar": "none"},
+			wantErr:  ErrInvalidOption,
+		},
+		{
+			name:     "Test with unknown choice",
+			item:     "fried-rice",
+			quantity: 1,
+			choices:  map[string]string{"size": "huge"},
+			wantErr:  ErrInvalidOption,
+		},
+		{
+			name:     "Test with unknown item",
+			item:     "pizza",
+			quantity: 1,
+			wantErr:  ErrUnknownItem,
+		},
+		{
+			name:     "Test with sold out item",
+			item:     "牛肉麵",
+			quantity: 1,
+			wantErr:  ErrSoldOut,
+		},
+		{
+			name:     "Test with invalid quantity",
+			item:     "black-tea",
+			quantity: 0,
+			wantErr:  ErrInvalidQuantity,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			order := NewOrder(catalog.Currency)
+			err := order.Add(catalog, tt.item, tt.quantity, tt.choices)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				assert.Empty(t, order.Lines)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, []Line{tt.want}, order.Lines)
+		})
+	}
+}
+
+func TestOrder_Lines(t *testing.T) {
+	catalog := loadCatalog(t)
+	order := NewOrder(catalog.Currency)
+
+	assert.Nil(t, order.Add(catalog, "black-tea", 1, nil))
+	assert.Nil(t, order.Add(catalog, "紅茶", 2, nil))
+	assert.Nil(t, order.Add(catalog, "fried-rice", 1, map[string]string{"size": "small"}))
+	assert.Nil(t, order.Add(catalog, "fried-rice", 1, map[string]string{"size": "large"}))
+	assert.Len(t, order.Lines, 3)
+	assert.Equal(t, 3, order.Lines[0].Quantity)
+	assert.Equal(t, 120+120+150, order.Total())
+
+	assert.Nil(t, order.Remove(catalog, "black-tea", 1))
+	assert.Equal(t, 2, order.Lines[0].Quantity)
+	assert.Nil(t, order.SetQuantity(catalog, "black-tea", 5))
+	assert.Equal(t, 5, order.Lines[0].Quantity)
+	assert.Nil(t, order.SetQuantity(catalog, "black-tea", 0))
+	assert.Len(t, order.Lines, 2)
+
+	// removing removes the first line of the item
+	assert.Nil(t, order.Remove(catalog, "fried-rice", 0))
+	assert.Equal(t, []Line{{
+		ItemId:    "fried-rice",
+		Name:      "炒飯",
+		Options:   []SelectedOption{{Option: "size", Choice: "large", Price: 30}},
+		UnitPrice: 150,
+		Quantity:  1,
+	}}, order.Lines)
+
+	assert.ErrorIs(t, order.Remove(catalog, "black-tea", 1), ErrNotInOrder)
+	assert.ErrorIs(t, order.Remove(catalog, "pizza", 1), ErrUnknownItem)
+	assert.ErrorIs(t, order.Remove(catalog, "fried-rice", -1), ErrInvalidQuantity)
+	assert.ErrorIs(t, order.SetQuantity(catalog, "fried-rice", -1), ErrInvalidQuantity)
+}
+
+func TestOrder_Transitions(t *testing.T) {
+	catalog := loadCatalog(t)
+
+	tests := []struct {
+		name    string
+		status  Status
+		lines   int
+		action  func(order *Order) error
+		want    Status
+		wantErr error
+	}{
+		{name: "Test checkout of open order", status: StatusOpen, lines: 1, action: (*Order).Checkout, want: StatusCheckout},
+		{name: "Test checkout of empty order", status: StatusOpen, action: (*Order).Checkout, want: StatusOpen, wantErr: ErrEmptyOrder},
+		{name: "Test checkout of placed order", status: StatusPlaced, lines: 1, action: (*Order).Checkout, want: StatusPlaced, wantErr: ErrInvalidTransition},
+		{name: "Test confirm of checked out order", status: StatusCheckout, lines: 1, action: (*Order).Confirm, want: StatusPlaced},
+		{name: "Test confirm of open order", status: StatusOpen, lines: 1, action: (*Order).Confirm, want: StatusOpen, wantErr: ErrInvalidTransition},
+		{name: "Test cancel of checked out order", status: StatusCheckout, lines: 1, action: (*Order).Cancel, want: StatusCancelled},
+		{name: "Test cancel of placed order", status: StatusPlaced, lines: 1, action: (*Order).Cancel, want: StatusPlaced, wantErr: ErrInvalidTransition},
+		{
+			name:   "Test change of checked out order",
+			status: StatusCheckout,
+			lines:  1,
+			action: func(order *Order) error {
+				return order.SetQuantity(catalog, "black-tea", 2)
+			},
+			want: StatusOpen,
+		},
+		{
+			name:   "Test change of cancelled order",
+			status: StatusCancelled,
+			lines:  1,
+			action: func(order *Order) error {
+				return order.Remove(catalog, "black-tea", 1)
+			},
+			want:    StatusCancelled,
+			wantErr: ErrInvalidTransition,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			order := NewOrder(catalog.Currency)
+			if tt.lines > 0 {
+				assert.Nil(t, order.Add(catalog, "black-tea", tt.lines, nil))
+			}
+			order.Status = tt.status
+
+			err := tt.action(&order)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			} else {
+				assert.Nil(t, err)
+			}
+			assert.Equal(t, tt.want, order.Status)
+		})
+	}
+}
+
+func TestOrder_AddAfterPlaced(t *testing.T) {
+	catalog := loadCatalog(t)
+	order := NewOrder(catalog.Currency)
+	assert.Nil(t, order.Add(catalog, "black-tea", 1, nil))
+	assert.Nil(t, order.Checkout())
+	assert.Nil(t, order.Confirm())
+
+	assert.Nil(t, order.Add(catalog, "fried-rice", 1, map[string]string{"size": "small"}))
+	assert.Equal(t, StatusOpen, order.Status)
+	assert.Len(t, order.Lines, 1)
+	assert.Equal(t, "fried-rice", order.Lines[0].ItemId)
+}
@@ -0,0 +1,183 @@
+package pii
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Kind is the kind of personal information, used in the placeholders.
+type Kind string
+
+const (
+	KindEmail      Kind = "EMAIL"
+	KindPhone      Kind = "PHONE"
+	KindCreditCard Kind = "CREDIT_CARD"
+	KindTaiwanId   Kind = "TW_ID"
+	KindChinaId    Kind = "CN_ID"
+)
+
+// Match is personal information found in a text.
+type Match struct {
+	Kind Kind
+	// Start and End are the byte offsets of the value in the text.
+	Start int
+	End   int
+	Value string
+}
+
+// Detector finds a kind of personal information with its pattern.
+// Validate is optional and rejects the values that only look like the kind, such as numbers failing a checksum.
+type Detector struct {
+	Kind     Kind
+	Pattern  *regexp.Regexp
+	Validate func(value string) bool
+}
+
+// DefaultDetectors find emails, Taiwan and China ID numbers, credit card numbers, and Taiwan, China and international phone numbers.
+// When the values overlap, the first detector wins.
+var DefaultDetectors = []Detector{
+	{
+		Kind:    KindEmail,
+		Pattern: regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`),
+	},
+	{
+		Kind:     KindChinaId,
+		Pattern:  regexp.MustCompile(`\d{17}[\dXx]`),
+		Validate: validChinaId,
+	},
+	{
+		Kind:     KindTaiwanId,
+		Pattern:  regexp.MustCompile(`[A-Za-z][12]\d{8}`),
+		Validate: validTaiwanId,
+	},
+	{
+		Kind:     KindCreditCard,
+		Pattern:  regexp.MustCompile(`\d(?:[ -]?\d){12,18}`),
+		Validate: validLuhn,
+	},
+	{
+		Kind: KindPhone,
+		Pattern: regexp.MustCompile(strings.Join([]string{
+			// Taiwan mobile and landline numbers
+			`(?:\+886[- ]?|0)9\d{2}[- ]?\d{3}[- ]?\d{3}`,
+			`(?:\+886[- ]?|0)[2-8][- ]?\d{3,4}[- ]?\d{4}`,
+			`\(0[2-8]\)[- ]?\d{3,4}[- ]?\d{4}`,
+			// China mobile numbers
+			`(?:\+86[- ]?)?1[3-9]\d[- ]?\d{4}[- ]?\d{4}`,
+			// other international numbers
+			`\+\d{1,3}[- ]?\d{2,4}(?:[- ]?\d{2,4}){1,3}`,
+		}, "|")),
+		Validate: validPhone,
+	},
+}
+
+// Detect returns the personal information found by the detectors in the text, in the order of the text.
+func Detect(text string, detectors []Detector) []Match {
+	var matches []Match
+	for _, detector := range detectors {
+		for _, location := range detector.Pattern.FindAllStringIndex(text, -1) {
+			match := Match{
+				Kind:  detector.Kind,
+				Start: location[0],
+				End:   location[1],
+				Value: text[location[0]:location[1]],
+			}
+			if !isolated(text, match) || (detector.Validate != nil && !detector.Validate(match.Value)) {
+				continue
+			}
+			if slices.ContainsFunc(matches, func(found Match) bool {
+				return match.Start < found.End && found.Start < match.End
+			}) {
+				continue
+			}
+			matches = append(matches, match)
+		}
+	}
+
+	slices.SortFunc(matches, func(a, b Match) int {
+		return a.Start - b.Start
+	})
+	return matches
+}
+
+// isolated reports whether the match is not a part of a longer word or number.
+func isolated(text string, match Match) bool {
+	if match.Start > 0 && isAlphanumeric(text[match.Start-1]) {
+		return false
+	}
+	return match.End == len(text) || !isAlphanumeric(text[match.End])
+}
+
+func isAlphanumeric(char byte) bool {
+	return char >= '0' && char <= '9' || char >= 'a' && char <= 'z' || char >= 'A' && char <= 'Z'
+}
+
+// digits returns the digits of the value, without its separators.
+func digits(value string) []int {
+	var result []int
+	for _, char := range value {
+		if char >= '0' && char <= '9' {
+			result = append(result, int(char-'0'))
+		}
+	}
+	return result
+}
+
+// validLuhn reports whether the number passes the Luhn checksum of the credit cards.
+func validLuhn(value string) bool {
+	numbers := digits(value)
+	if len(numbers) < 13 || len(numbers) > 19 {
+		return false
+	}
+
+	sum := 0
+	for index := range numbers {
+		number := numbers[len(numbers)-1-index]
+		if index%2 == 1 {
+			number *= 2
+			if number > 9 {
+				number -= 9
+			}
+		}
+		sum += number
+	}
+	return sum%10 == 0
+}
+
+// taiwanIdLetters are the numbers of the first letter of the Taiwan ID numbers, from A to Z.
+var taiwanIdLetters = []int{10, 11, 12, 13, 14, 15, 16, 17, 34, 18, 19, 20, 21, 22, 35, 23, 24, 25, 26, 27, 28, 29, 32, 30, 31, 33}
+
+// validTaiwanId reports whether the value passes the checksum of the Taiwan ID numbers.
+func validTaiwanId(value string) bool {
+	letter := taiwanIdLetters[strings.ToUpper(value)[0]-'A']
+	sum := letter/10 + letter%10*9
+	for index, number := range digits(value[1:]) {
+		weight := 8 - index
+		if weight < 1 {
+			weight = 1
+		}
+		sum += number * weight
+	}
+	return sum%10 == 0
+}
+
+var (
+	chinaIdWeights = []int{7, 9, 10, 5, 8, 4, 2, 1, 6, 3, 7, 9, 10, 5, 8, 4, 2}
+	chinaIdChecks  = "10X98765432"
+)
+
+// validChinaId reports whether the value passes the checksum of the China resident ID numbers.
+func validChinaId(value string) bool {
+	sum := 0
+	for index, weight := range chinaIdWeights {
+		sum += int(value[index]-'0') * weight
+	}
+	return chinaIdChecks[sum%11] == strings.ToUpper(value)[17]
+}
+
+// validPhone reports whether the value has the number of digits of a phone number.
+func validPhone(value string) bool {
+	count := len(digits(value))
+	return count >= 8 && count <= 15
+}
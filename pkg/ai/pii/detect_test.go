@@ -0,0 +1,80 @@
+package pii
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []Match
+	}{
+		{
+			name: "Test with email",
+			text: "請寄到 amy.lin+order@example.com.tw 謝謝",
+			want: []Match{{Kind: KindEmail, Start: 10, End: 38, Value: "amy.lin+order@example.com.tw"}},
+		},
+		{
+			name: "Test with Taiwan phone numbers",
+			text: "手機0912-345-678，公司(02)2345-6789",
+			want: []Match{
+				{Kind: KindPhone, Start: 6, End: 18, Value: "0912-345-678"},
+				{Kind: KindPhone, Start: 27, End: 40, Value: "(02)2345-6789"},
+			},
+		},
+		{
+			name: "Test with international phone numbers",
+			text: "call +886 912 345 678 or +1 415 555 2671 or 13812345678",
+			want: []Match{
+				{Kind: KindPhone, Start: 5, End: 21, Value: "+886 912 345 678"},
+				{Kind: KindPhone, Start: 25, End: 40, Value: "+1 415 555 2671"},
+				{Kind: KindPhone, Start: 44, End: 55, Value: "13812345678"},
+			},
+		},
+		{
+			name: "Test with credit card",
+			text: "card 4111 1111 1111 1111, not 4111 1111 1111 1112",
+			want: []Match{{Kind: KindCreditCard, Start: 5, End: 24, Value: "4111 1111 1111 1111"}},
+		},
+		{
+			name: "Test with Taiwan ID",
+			text: "身分證 A123456789, not A123456788 or XA123456789",
+			want: []Match{{Kind: KindTaiwanId, Start: 10, End: 20, Value: "A123456789"}},
+		},
+		{
+			name: "Test with China ID",
+			text: "身份證 11010519491231002X",
+			want: []Match{{Kind: KindChinaId, Start: 10, End: 28, Value: "11010519491231002X"}},
+		},
+		{
+			name: "Test with numbers that are not personal information",
+			text: "訂單 20240101 共 3 份，總計 1200 元",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Detect(tt.text, DefaultDetectors))
+		})
+	}
+}
+
+func TestValidators(t *testing.T) {
+	assert.True(t, validLuhn("4111-1111-1111-1111"))
+	assert.True(t, validLuhn("5500 0000 0000 0004"))
+	assert.False(t, validLuhn("4111 1111 1111 1112"))
+	assert.False(t, validLuhn("1234"))
+
+	assert.True(t, validTaiwanId("A123456789"))
+	assert.True(t, validTaiwanId("f131104093"))
+	assert.False(t, validTaiwanId("A123456788"))
+
+	assert.True(t, validChinaId("11010519491231002X"))
+	assert.True(t, validChinaId("11010519491231002x"))
+	assert.False(t, validChinaId("110105194912310021"))
+
+	assert.True(t, validPhone("+886 912 345 678"))
+	assert.False(t, validPhone("+1 23"))
+}
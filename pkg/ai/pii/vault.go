@@ -0,0 +1,112 @@
+package pii
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+var placeholderPattern = regexp.MustCompile(`\[([A-Z_]+)_(\d+)\]`)
+
+// Vault replaces the personal information with placeholders, such as [PHONE_1], and restores them.
+// The same value always gets the same placeholder, so a conversation can keep referring to it.
+// It is safe for concurrent use.
+type Vault struct {
+	mutex        sync.RWMutex
+	placeholders map[string]string
+	originals    map[string]string
+	counts       map[Kind]int
+}
+
+// NewVault returns a new instance of Vault.
+func NewVault() *Vault {
+	return &Vault{
+		placeholders: map[string]string{},
+		originals:    map[string]string{},
+		counts:       map[Kind]int{},
+	}
+}
+
+// Redact replaces the personal information found by the detectors with their placeholders.
+func (v *Vault) Redact(text string, detectors []Detector) string {
+	matches := Detect(text, detectors)
+	if len(matches) == 0 {
+		return text
+	}
+
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	var redacted strings.Builder
+	end := 0
+	for _, match := range matches {
+		redacted.WriteString(text[end:match.Start])
+		redacted.WriteString(v.placeholder(match))
+		end = match.End
+	}
+	redacted.WriteString(text[end:])
+	return redacted.String()
+}
+
+// placeholder returns the placeholder of the value, creating it the first time the value is seen.
+func (v *Vault) placeholder(match Match) string {
+	if placeholder, found := v.placeholders[match.Value]; found {
+		return placeholder
+	}
+
+	v.counts[match.Kind]++
+	placeholder := fmt.Sprintf("[%s_%d]", match.Kind, v.counts[match.Kind])
+	v.placeholders[match.Value] = placeholder
+	v.originals[placeholder] = match.Value
+	return placeholder
+}
+
+// Restore replaces the placeholders of the vault with their original values. Unknown placeholders are kept.
+func (v *Vault) Restore(text string) string {
+	v.mutex.RLock()
+	defer v.mutex.RUnlock()
+
+	return placeholderPattern.ReplaceAllStringFunc(text, func(placeholder string) string {
+		if original, found := v.originals[placeholder]; found {
+			return original
+		}
+		return placeholder
+	})
+}
+
+// vaultDto is the JSON form of a Vault.
+type vaultDto struct {
+	Originals map[string]string `json:"originals"`
+	Counts    map[Kind]int      `json:"counts"`
+}
+
+// MarshalJSON marshals the placeholders of the vault, so it can be persisted with the state of the conversation.
+func (v *Vault) MarshalJSON() ([]byte, error) {
+	v.mutex.RLock()
+	defer v.mutex.RUnlock()
+	return json.Marshal(vaultDto{Originals: v.originals, Counts: v.counts})
+}
+
+// UnmarshalJSON replaces the placeholders of the vault with the marshalled ones.
+func (v *Vault) UnmarshalJSON(data []byte) error {
+	var dto vaultDto
+	if err := json.Unmarshal(data, &dto); err != nil {
+		return err
+	}
+
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	v.originals = map[string]string{}
+	v.placeholders = map[string]string{}
+	v.counts = map[Kind]int{}
+	for placeholder, original := range dto.Originals {
+		v.originals[placeholder] = original
+		v.placeholders[original] = placeholder
+	}
+	for kind, count := range dto.Counts {
+		v.counts[kind] = count
+	}
+	return nil
+}
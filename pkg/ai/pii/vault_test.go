@@ -0,0 +1,35 @@
+package pii
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestVault(t *testing.T) {
+	vault := NewVault()
+
+	redacted := vault.Redact("我是 0912345678，email amy@example.com", DefaultDetectors)
+	assert.Equal(t, "我是 [PHONE_1]，email [EMAIL_1]", redacted)
+
+	// the same value keeps its placeholder, and a new value gets the next one
+	redacted = vault.Redact("改成 0987654321，原本是 0912345678", DefaultDetectors)
+	assert.Equal(t, "改成 [PHONE_2]，原本是 [PHONE_1]", redacted)
+
+	assert.Equal(t, "已寄到 amy@example.com", vault.Restore("已寄到 [EMAIL_1]"))
+	assert.Equal(t, "0987654321 [PHONE_9] [phone_1]", vault.Restore("[PHONE_2] [PHONE_9] [phone_1]"))
+	assert.Equal(t, "沒有個資", vault.Redact("沒有個資", DefaultDetectors))
+}
+
+func TestVault_MarshalJSON(t *testing.T) {
+	vault := NewVault()
+	vault.Redact("0912345678 amy@example.com", DefaultDetectors)
+
+	data, err := json.Marshal(vault)
+	assert.Nil(t, err)
+
+	restored := NewVault()
+	assert.Nil(t, json.Unmarshal(data, restored))
+	assert.Equal(t, "0912345678 amy@example.com", restored.Restore("[PHONE_1] [EMAIL_1]"))
+	assert.Equal(t, "[PHONE_1] [PHONE_2]", restored.Redact("0912345678 0987654321", DefaultDetectors))
+}
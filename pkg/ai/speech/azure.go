@@ -0,0 +1,102 @@
+package speech
+
+import (
+	"context"
+	"fmt"
+	"github.com/go-resty/resty/v2"
+	"github.com/meta-metopia/go-packages/pkg/ai/speech/ssml"
+	"os"
+	"strings"
+)
+
+const defaultAzureOutputFormat = "audio-24khz-48kbitrate-mono-mp3"
+
+type AzureConfig struct {
+	// Endpoint is the speech endpoint of the resource, such as https://eastus.tts.speech.microsoft.com
+	Endpoint string
+	ApiKey   string
+	// Voice is the name of the neural voice, such as zh-TW-HsiaoChenNeural.
+	Voice string
+	// Locale is the language of the text, such as zh-TW. Defaults to the locale of the voice.
+	Locale string
+	// Rate is the speaking rate relative to the default rate of the voice. 0 uses the default rate.
+	Rate float64
+	// Locales are the locales of the scripts read by a multilingual voice, such as ssml.ScriptLatin to en-US.
+	// The runs of text written in these scripts are wrapped in a lang element.
+	Locales map[ssml.Script]string
+	// OutputFormat is the X-Microsoft-OutputFormat of the audio. Defaults to audio-24khz-48kbitrate-mono-mp3.
+	OutputFormat string
+}
+
+type AzureSynthesizer struct {
+	config     AzureConfig
+	httpClient *resty.Client
+}
+
+// NewAzureSynthesizer returns a new instance of the Azure Speech synthesizer.
+func NewAzureSynthesizer(config AzureConfig) ISynthesizer {
+	if len(config.OutputFormat) == 0 {
+		config.OutputFormat = defaultAzureOutputFormat
+	}
+	if len(config.Locale) == 0 {
+		config.Locale = voiceLocale(config.Voice)
+	}
+
+	client := resty.New()
+	client.SetDebug(os.Getenv("DEBUG") == "true")
+
+	return &AzureSynthesizer{
+		config:     config,
+		httpClient: client,
+	}
+}
+
+// SetClient sets the resty client for the synthesizer.
+func (a *AzureSynthesizer) SetClient(client *resty.Client) {
+	a.httpClient = client
+}
+
+// Synthesize returns the speech of the text.
+func (a *AzureSynthesizer) Synthesize(ctx context.Context, text string) (Audio, error) {
+	response, err := a.httpClient.R().
+		SetContext(ctx).
+		SetHeader("Ocp-Apim-Subscription-Key", a.config.ApiKey).
+		SetHeader("Content-Type", "application/ssml+xml").
+		SetHeader("X-Microsoft-OutputFormat", a.config.OutputFormat).
+		SetBody(a.ssml(text)).
+		Post(strings.TrimSuffix(a.config.Endpoint, "/") + "/cognitiveservices/v1")
+	if err != nil {
+		return Audio{}, err
+	}
+
+	if !response.IsSuccess() {
+		return Audio{}, fmt.Errorf("failed to synthesize speech: %d %s", response.StatusCode(), response.String())
+	}
+
+	if len(response.Body()) == 0 {
+		return Audio{}, fmt.Errorf("failed to synthesize speech: no audio returned")
+	}
+
+	return Audio{
+		Data:        response.Body(),
+		ContentType: contentType(a.config.OutputFormat),
+	}, nil
+}
+
+// ssml returns the SSML document speaking the text with the voice.
+func (a *AzureSynthesizer) ssml(text string) string {
+	nodes := ssml.MixedText(text, a.config.Locale, a.config.Locales)
+	if a.config.Rate > 0 {
+		nodes = []ssml.Node{ssml.Prosody(ssml.ProsodyOptions{Rate: ssml.Rate(a.config.Rate)}, nodes...)}
+	}
+	return ssml.Speak(a.config.Locale, ssml.Voice(a.config.Voice, nodes...)).String()
+}
+
+// voiceLocale returns the locale of an Azure voice name, such as zh-TW for zh-TW-HsiaoChenNeural.
+func voiceLocale(voice string) string {
+	parts := strings.SplitN(voice, "-", 3)
+	if len(parts) < 3 {
+		return "en-US"
+	}
+	return parts[0] + "-" + parts[1]
+}
@@ -0,0 +1,100 @@
+package speech
+
+import (
+	"context"
+	"fmt"
+	"github.com/go-resty/resty/v2"
+	"os"
+)
+
+type OpenAIConfig struct {
+	// Endpoint is the speech endpoint, such as https://api.openai.com/v1/audio/speech
+	// or the audio/speech endpoint of an Azure OpenAI tts deployment.
+	Endpoint string
+	ApiKey   string
+	// Model is the tts model, such as tts-1. Defaults to tts-1.
+	Model string
+	// Voice is the voice of the speech, such as alloy. Defaults to alloy.
+	Voice string
+	// Format is the audio format: mp3, opus, aac, flac, wav or pcm. Defaults to mp3.
+	Format string
+	// Speed is the speed of the speech between 0.25 and 4. 0 uses the default speed.
+	Speed float64
+}
+
+type openAISpeechRequestDto struct {
+	Model          string   `json:"model"`
+	Input          string   `json:"input"`
+	Voice          string   `json:"voice"`
+	ResponseFormat string   `json:"response_format"`
+	Speed          *float64 `json:"speed,omitempty"`
+}
+
+type OpenAISynthesizer struct {
+	config     OpenAIConfig
+	httpClient *resty.Client
+}
+
+// NewOpenAISynthesizer returns a new instance of the OpenAI speech synthesizer.
+func NewOpenAISynthesizer(config OpenAIConfig) ISynthesizer {
+	if len(config.Model) == 0 {
+		config.Model = "tts-1"
+	}
+	if len(config.Voice) == 0 {
+		config.Voice = "alloy"
+	}
+	if len(config.Format) == 0 {
+		config.Format = "mp3"
+	}
+
+	client := resty.New()
+	client.SetDebug(os.Getenv("DEBUG") == "true")
+
+	return &OpenAISynthesizer{
+		config:     config,
+		httpClient: client,
+	}
+}
+
+// SetClient sets the resty client for the synthesizer.
+func (o *OpenAISynthesizer) SetClient(client *resty.Client) {
+	o.httpClient = client
+}
+
+// Synthesize returns the speech of the text.
+func (o *OpenAISynthesizer) Synthesize(ctx context.Context, text string) (Audio, error) {
+	body := openAISpeechRequestDto{
+		Model:          o.config.Model,
+		Input:          text,
+		Voice:          o.config.Voice,
+		ResponseFormat: o.config.Format,
+	}
+	if o.config.Speed > 0 {
+		body.Speed = &o.config.Speed
+	}
+
+	request := o.httpClient.R().SetContext(ctx)
+	if isOpenAIEndpoint(o.config.Endpoint) {
+		request = request.SetHeader("Authorization", "Bearer "+o.config.ApiKey)
+	} else {
+		request = request.SetHeader("api-key", o.config.ApiKey)
+	}
+
+	response, err := request.SetBody(body).Post(o.config.Endpoint)
+	if err != nil {
+		return Audio{}, err
+	}
+
+	if !response.IsSuccess() {
+		return Audio{}, fmt.Errorf("failed to synthesize speech: %d %s", response.StatusCode(), response.String())
+	}
+
+	if len(response.Body()) == 0 {
+		return Audio{}, fmt.Errorf("failed to synthesize speech: no audio returned")
+	}
+
+	return Audio{
+		Data:        response.Body(),
+		ContentType: contentType(o.config.Format),
+	}, nil
+}
@@ -0,0 +1,153 @@
+package speech
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// DefaultChunkLength is the number of characters of the chunks synthesized at once,
+// well under the limits of the speech services.
+const DefaultChunkLength = 1000
+
+// isSentenceEnd returns true when the rune ends a sentence. A period only ends a sentence
+// when it is followed by a space, so numbers such as 3.5 are not split.
+// It does not end the markers of numbered lists either, such as "1. ".
+func isSentenceEnd(r rune, next rune, before string) bool {
+	switch r {
+	case '。', '！', '？', '；', '!', '?', ';', '\n':
+		return true
+	case '.':
+		return (next == utf8.RuneError || unicode.IsSpace(next)) && !isNumber(strings.TrimSpace(before))
+	}
+	return false
+}
+
+func isNumber(text string) bool {
+	if len(text) == 0 {
+		return false
+	}
+	for _, r := range text {
+		if !unicode.IsDigit(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// isClosing returns true for the quotes and brackets closing a sentence, which belong to the sentence before them.
+func isClosing(r rune) bool {
+	return strings.ContainsRune(`」』）)"'”’`, r)
+}
+
+// isPause returns true for the punctuation where a sentence too long to be synthesized at once can be split.
+func isPause(r rune) bool {
+	return strings.ContainsRune("，,、：:", r)
+}
+
+// sentenceEnd returns the index of the end of the first sentence of the text, or -1 if the sentence is not finished.
+func sentenceEnd(text string) int {
+	for index, r := range text {
+		size := utf8.RuneLen(r)
+		next, _ := utf8.DecodeRuneInString(text[index+size:])
+		if !isSentenceEnd(r, next, text[:index]) {
+			continue
+		}
+
+		end := index + size
+		for end < len(text) {
+			closing, closingSize := utf8.DecodeRuneInString(text[end:])
+			if !isClosing(closing) {
+				break
+			}
+			end += closingSize
+		}
+		return end
+	}
+	return -1
+}
+
+// spans returns the sentences of the text, including the spaces around them.
+func spans(text string) []string {
+	var result []string
+	for len(text) > 0 {
+		end := sentenceEnd(text)
+		if end < 0 {
+			end = len(text)
+		}
+		result = append(result, text[:end])
+		text = text[end:]
+	}
+	return result
+}
+
+// SplitSentences splits the text into sentences, in Chinese or English. Empty sentences are left out.
+func SplitSentences(text string) []string {
+	var sentences []string
+	for _, span := range spans(text) {
+		if sentence := strings.TrimSpace(span); len(sentence) > 0 {
+			sentences = append(sentences, sentence)
+		}
+	}
+	return sentences
+}
+
+// Chunk splits the text into chunks of whole sentences of at most [maxLength] characters.
+// Longer sentences are split at their commas, or at [maxLength] characters when a part is still too long.
+func Chunk(text string, maxLength int) []string {
+	if maxLength <= 0 {
+		maxLength = DefaultChunkLength
+	}
+
+	var chunks []string
+	var current strings.Builder
+	flush := func() {
+		if chunk := strings.TrimSpace(current.String()); len(chunk) > 0 {
+			chunks = append(chunks, chunk)
+		}
+		current.Reset()
+	}
+
+	for _, span := range spans(text) {
+		for _, part := range splitLong(span, maxLength) {
+			if utf8.RuneCountInString(current.String())+utf8.RuneCountInString(part) > maxLength {
+				flush()
+			}
+			current.WriteString(part)
+		}
+	}
+	flush()
+	return chunks
+}
+
+// splitLong splits a sentence longer than [maxLength] characters at its pauses, then at [maxLength] characters.
+func splitLong(sentence string, maxLength int) []string {
+	if utf8.RuneCountInString(sentence) <= maxLength {
+		return []string{sentence}
+	}
+
+	var parts []string
+	start := 0
+	count := 0
+	lastPause := -1
+	for index, r := range sentence {
+		count++
+		if isPause(r) {
+			lastPause = index + utf8.RuneLen(r)
+		}
+		if count <= maxLength {
+			continue
+		}
+
+		// the part is too long: cut it at the last pause, or right before the current rune
+		end := index
+		if lastPause > start {
+			end = lastPause
+		}
+		parts = append(parts, sentence[start:end])
+		start = end
+		count = utf8.RuneCountInString(sentence[start : index+utf8.RuneLen(r)])
+		lastPause = -1
+	}
+	return append(parts, sentence[start:])
+}
@@ -0,0 +1,96 @@
+package speech
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestSplitSentences(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []string
+	}{
+		{
+			name: "Test with empty text",
+			text: "  ",
+			want: nil,
+		},
+		{
+			name: "Test with chinese text",
+			text: "你好！請問要點什麼？我們有炒飯。",
+			want: []string{"你好！", "請問要點什麼？", "我們有炒飯。"},
+		},
+		{
+			name: "Test with english text",
+			text: "Hello! It costs 3.5 dollars. Anything else?",
+			want: []string{"Hello!", "It costs 3.5 dollars.", "Anything else?"},
+		},
+		{
+			name: "Test with closing quotes",
+			text: "他說「好的。」然後離開了",
+			want: []string{"他說「好的。」", "然後離開了"},
+		},
+		{
+			name: "Test with new lines",
+			text: "1. 炒飯\n2. 湯\n",
+			want: []string{"1. 炒飯", "2. 湯"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, SplitSentences(tt.text))
+		})
+	}
+}
+
+func TestChunk(t *testing.T) {
+	tests := []struct {
+		name      string
+		text      string
+		maxLength int
+		want      []string
+	}{
+		{
+			name:      "Test with short text",
+			text:      "你好！請問要點什麼？",
+			maxLength: 100,
+			want:      []string{"你好！請問要點什麼？"},
+		},
+		{
+			name:      "Test with sentences packed in chunks",
+			text:      "One. Two. Three. Four.",
+			maxLength: 10,
+			want:      []string{"One. Two.", "Three.", "Four."},
+		},
+		{
+			name:      "Test with long sentence split at commas",
+			text:      "炒飯，湯麵，水餃，鍋貼。",
+			maxLength: 6,
+			want:      []string{"炒飯，湯麵，", "水餃，鍋貼。"},
+		},
+		{
+			name:      "Test with long sentence without pauses",
+			text:      "一二三四五六七",
+			maxLength: 3,
+			want:      []string{"一二三", "四五六", "七"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Chunk(tt.text, tt.maxLength))
+		})
+	}
+}
+
+func TestChunk_MaxLength(t *testing.T) {
+	text := strings.Repeat("這是一個很長的句子，", 300)
+	for _, chunk := range Chunk(text, DefaultChunkLength) {
+		assert.LessOrEqual(t, utf8.RuneCountInString(chunk), DefaultChunkLength)
+	}
+	assert.Equal(t, text, strings.Join(Chunk(text, DefaultChunkLength), ""))
+}
@@ -0,0 +1,105 @@
+package speech
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// AudioSink receives the synthesized speech, such as a speaker, a file or the response of a server.
+type AudioSink interface {
+	// Write plays, stores or sends the audio. It returns once the sink is done with it.
+	Write(ctx context.Context, audio Audio) error
+}
+
+// extensions maps the mime types of the audio to a file extension.
+var extensions = map[string]string{
+	"audio/mpeg": ".mp3",
+	"audio/ogg":  ".ogg",
+	"audio/webm": ".webm",
+	"audio/aac":  ".aac",
+	"audio/flac": ".flac",
+	"audio/wav":  ".wav",
+	"audio/pcm":  ".pcm",
+}
+
+type FileSink struct {
+	mutex sync.Mutex
+	dir   string
+	count int
+}
+
+// NewFileSink returns a sink writing every audio to a numbered file in the directory, such as speech-001.mp3.
+func NewFileSink(dir string) AudioSink {
+	return &FileSink{
+		dir: dir,
+	}
+}
+
+// Write writes the audio to the next file of the directory.
+func (f *FileSink) Write(ctx context.Context, audio Audio) error {
+	f.mutex.Lock()
+	f.count++
+	count := f.count
+	f.mutex.Unlock()
+
+	extension, ok := extensions[audio.ContentType]
+	if !ok {
+		extension = ".bin"
+	}
+	path := filepath.Join(f.dir, fmt.Sprintf("speech-%03d%s", count, extension))
+	if err := os.WriteFile(path, audio.Data, 0o644); err != nil {
+		return fmt.Errorf("failed to write audio to %s: %w", path, err)
+	}
+	return nil
+}
+
+type WriterSink struct {
+	mutex  sync.Mutex
+	writer io.Writer
+}
+
+// NewWriterSink returns a sink writing the audio data to the writer, such as an http response.
+// The audio of successive writes is concatenated.
+func NewWriterSink(writer io.Writer) AudioSink {
+	return &WriterSink{
+		writer: writer,
+	}
+}
+
+// Write writes the audio data to the writer.
+func (w *WriterSink) Write(ctx context.Context, audio Audio) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	_, err := w.writer.Write(audio.Data)
+	return err
+}
+
+// MemorySink keeps the audio in memory. It is mostly useful in tests.
+type MemorySink struct {
+	mutex  sync.Mutex
+	audios []Audio
+}
+
+// NewMemorySink returns a new instance of MemorySink.
+func NewMemorySink() *MemorySink {
+	return &MemorySink{}
+}
+
+// Write keeps the audio.
+func (m *MemorySink) Write(ctx context.Context, audio Audio) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.audios = append(m.audios, audio)
+	return nil
+}
+
+// Audios returns the audio written so far.
+func (m *MemorySink) Audios() []Audio {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return append([]Audio(nil), m.audios...)
+}
@@ -0,0 +1,46 @@
+package speech
+
+import (
+	"bytes"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileSink(t *testing.T) {
+	dir := t.TempDir()
+	sink := NewFileSink(dir)
+
+	assert.Nil(t, sink.Write(context.Background(), Audio{Data: []byte("first"), ContentType: "audio/mpeg"}))
+	assert.Nil(t, sink.Write(context.Background(), Audio{Data: []byte("second"), ContentType: "audio/wav"}))
+
+	first, err := os.ReadFile(filepath.Join(dir, "speech-001.mp3"))
+	assert.Nil(t, err)
+	assert.Equal(t, "first", string(first))
+	second, err := os.ReadFile(filepath.Join(dir, "speech-002.wav"))
+	assert.Nil(t, err)
+	assert.Equal(t, "second", string(second))
+
+	err = NewFileSink(filepath.Join(dir, "missing")).Write(context.Background(), Audio{})
+	assert.NotNil(t, err)
+}
+
+func TestWriterSink(t *testing.T) {
+	var buffer bytes.Buffer
+	sink := NewWriterSink(&buffer)
+
+	assert.Nil(t, sink.Write(context.Background(), Audio{Data: []byte("first")}))
+	assert.Nil(t, sink.Write(context.Background(), Audio{Data: []byte("second")}))
+	assert.Equal(t, "firstsecond", buffer.String())
+}
+
+func TestMemorySink(t *testing.T) {
+	sink := NewMemorySink()
+	assert.Empty(t, sink.Audios())
+
+	audio := Audio{Data: []byte("audio"), ContentType: "audio/mpeg"}
+	assert.Nil(t, sink.Write(context.Background(), audio))
+	assert.Equal(t, []Audio{audio}, sink.Audios())
+}
@@ -0,0 +1,54 @@
+package speech
+
+import (
+	"context"
+	"github.com/go-resty/resty/v2"
+	"strings"
+)
+
+// Audio is encoded speech, such as an mp3 file.
+type Audio struct {
+	Data []byte
+	// ContentType is the mime type of the data, such as audio/mpeg.
+	ContentType string
+}
+
+// Synthesizer converts text to speech.
+type Synthesizer interface {
+	// Synthesize returns the speech of the text.
+	Synthesize(ctx context.Context, text string) (Audio, error)
+}
+
+type ISynthesizer interface {
+	Synthesizer
+	//SetClient sets the resty client for the synthesizer.
+	SetClient(client *resty.Client)
+}
+
+// contentTypes maps the audio formats of the speech services to their mime type.
+var contentTypes = map[string]string{
+	"mp3":  "audio/mpeg",
+	"opus": "audio/ogg",
+	"ogg":  "audio/ogg",
+	"webm": "audio/webm",
+	"aac":  "audio/aac",
+	"flac": "audio/flac",
+	"wav":  "audio/wav",
+	"riff": "audio/wav",
+	"pcm":  "audio/pcm",
+	"raw":  "audio/pcm",
+}
+
+// contentType returns the mime type of the audio format, such as mp3 or audio-24khz-48kbitrate-mono-mp3.
+func contentType(format string) string {
+	for _, part := range strings.Split(strings.ToLower(format), "-") {
+		if value, ok := contentTypes[part]; ok {
+			return value
+		}
+	}
+	return "application/octet-stream"
+}
+
+func isOpenAIEndpoint(endpoint string) bool {
+	return strings.Contains(endpoint, "api.openai.com")
+}
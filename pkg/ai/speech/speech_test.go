@@ -0,0 +1,130 @@
+package speech
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/go-resty/resty/v2"
+	"github.com/jarcoal/httpmock"
+	"github.com/meta-metopia/go-packages/pkg/ai/speech/ssml"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"testing"
+)
+
+func TestContentType(t *testing.T) {
+	assert.Equal(t, "audio/mpeg", contentType("audio-24khz-48kbitrate-mono-mp3"))
+	assert.Equal(t, "audio/wav", contentType("riff-24khz-16bit-mono-pcm"))
+	assert.Equal(t, "audio/ogg", contentType("opus"))
+	assert.Equal(t, "application/octet-stream", contentType("unknown"))
+}
+
+func TestAzureSynthesizer_Synthesize(t *testing.T) {
+	restyClient := resty.New()
+	httpmock.ActivateNonDefault(restyClient.GetClient())
+	defer httpmock.DeactivateAndReset()
+
+	var body string
+	var header http.Header
+	httpmock.RegisterResponder("POST", "https://eastus.tts.speech.microsoft.com/cognitiveservices/v1", func(request *http.Request) (*http.Response, error) {
+		data, _ := io.ReadAll(request.Body)
+		body = string(data)
+		header = request.Header
+		return httpmock.NewBytesResponse(http.StatusOK, []byte("mp3")), nil
+	})
+
+	synthesizer := NewAzureSynthesizer(AzureConfig{
+		Endpoint: "https://eastus.tts.speech.microsoft.com/",
+		ApiKey:   "key",
+		Voice:    "zh-TW-HsiaoChenNeural",
+		Rate:     1.2,
+	})
+	synthesizer.SetClient(restyClient)
+
+	audio, err := synthesizer.Synthesize(context.Background(), `炒飯 <1> & "湯"`)
+	assert.Nil(t, err)
+	assert.Equal(t, Audio{Data: []byte("mp3"), ContentType: "audio/mpeg"}, audio)
+	assert.Equal(t, "key", header.Get("Ocp-Apim-Subscription-Key"))
+	assert.Equal(t, "audio-24khz-48kbitrate-mono-mp3", header.Get("X-Microsoft-OutputFormat"))
+	assert.Equal(
+		t,
+		`<speak version="1.0" xmlns="http://www.w3.org/2001/10/synthesis" xml:lang="zh-TW"><voice name="zh-TW-HsiaoChenNeural"><prosody rate="1.2">炒飯 &lt;1&gt; &amp; &#34;湯&#34;</prosody></voice></speak>`,
+		body,
+	)
+}
+
+func TestAzureSynthesizer_SynthesizeError(t *testing.T) {
+	restyClient := resty.New()
+	httpmock.ActivateNonDefault(restyClient.GetClient())
+	defer httpmock.DeactivateAndReset()
+
+	httpmock.RegisterResponder("POST", "https://eastus.tts.speech.microsoft.com/cognitiveservices/v1", httpmock.NewStringResponder(http.StatusUnauthorized, "unauthorized"))
+
+	synthesizer := NewAzureSynthesizer(AzureConfig{Endpoint: "https://eastus.tts.speech.microsoft.com", Voice: "en-US-AvaNeural"})
+	synthesizer.SetClient(restyClient)
+
+	_, err := synthesizer.Synthesize(context.Background(), "Hello")
+	assert.ErrorContains(t, err, "failed to synthesize speech: 401")
+}
+
+func TestOpenAISynthesizer_Synthesize(t *testing.T) {
+	tests := []struct {
+		name       string
+		config     OpenAIConfig
+		wantHeader string
+		wantBody   string
+		want       Audio
+	}{
+		{
+			name:       "Test with openai",
+			config:     OpenAIConfig{Endpoint: "https://api.openai.com/v1/audio/speech", ApiKey: "key"},
+			wantHeader: "Bearer key",
+			wantBody:   `{"model":"tts-1","input":"你好","voice":"alloy","response_format":"mp3"}`,
+			want:       Audio{Data: []byte("audio"), ContentType: "audio/mpeg"},
+		},
+		{
+			name:       "Test with azure",
+			config:     OpenAIConfig{Endpoint: "https://example.openai.azure.com/openai/deployments/tts/audio/speech", ApiKey: "key", Voice: "nova", Format: "opus", Speed: 1.5},
+			wantHeader: "key",
+			wantBody:   `{"model":"tts-1","input":"你好","voice":"nova","response_format":"opus","speed":1.5}`,
+			want:       Audio{Data: []byte("audio"), ContentType: "audio/ogg"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			restyClient := resty.New()
+			httpmock.ActivateNonDefault(restyClient.GetClient())
+			defer httpmock.DeactivateAndReset()
+
+			var body json.RawMessage
+			var header string
+			httpmock.RegisterResponder("POST", tt.config.Endpoint, func(request *http.Request) (*http.Response, error) {
+				body, _ = io.ReadAll(request.Body)
+				header = request.Header.Get("Authorization") + request.Header.Get("api-key")
+				return httpmock.NewBytesResponse(http.StatusOK, []byte("audio")), nil
+			})
+
+			synthesizer := NewOpenAISynthesizer(tt.config)
+			synthesizer.SetClient(restyClient)
+			audio, err := synthesizer.Synthesize(context.Background(), "你好")
+			assert.Nil(t, err)
+			assert.Equal(t, tt.want, audio)
+			assert.Equal(t, tt.wantHeader, header)
+			assert.JSONEq(t, tt.wantBody, string(body))
+		})
+	}
+}
+
+func TestAzureSynthesizer_SsmlWithLocales(t *testing.T) {
+	synthesizer := NewAzureSynthesizer(AzureConfig{
+		Voice:   "zh-TW-HsiaoChenNeural",
+		Locales: map[ssml.Script]string{ssml.ScriptLatin: "en-US"},
+	}).(*AzureSynthesizer)
+
+	assert.Equal(
+		t,
+		`<speak version="1.0" xmlns="http://www.w3.org/2001/10/synthesis" xml:lang="zh-TW"><voice name="zh-TW-HsiaoChenNeural">我要 <lang xml:lang="en-US">fried rice</lang></voice></speak>`,
+		synthesizer.ssml("我要 fried rice"),
+	)
+}
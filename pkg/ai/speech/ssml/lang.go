@@ -0,0 +1,74 @@
+package ssml
+
+import (
+	"unicode"
+)
+
+// Script is the writing system of a run of text.
+type Script int
+
+const (
+	// ScriptOther is used for the text without letters, such as numbers and punctuation.
+	ScriptOther Script = iota
+	// ScriptHan is Chinese.
+	ScriptHan
+	// ScriptLatin is English and the other languages written with the latin alphabet.
+	ScriptLatin
+)
+
+// Run is a run of text written in a single script.
+type Run struct {
+	Text   string
+	Script Script
+}
+
+// SplitScripts splits the text into runs of the same script.
+// Spaces, numbers and punctuation belong to the run they are in, so "我要 2 份 fried rice" is split into "我要 2 份 " and "fried rice".
+func SplitScripts(value string) []Run {
+	var runs []Run
+	start := 0
+	current := ScriptOther
+	for index, r := range value {
+		script := scriptOf(r)
+		if script == ScriptOther || script == current {
+			continue
+		}
+		if current != ScriptOther {
+			runs = append(runs, Run{Text: value[start:index], Script: current})
+			start = index
+		}
+		current = script
+	}
+	if start < len(value) {
+		runs = append(runs, Run{Text: value[start:], Script: current})
+	}
+	return runs
+}
+
+// MixedText returns the nodes of the text with the runs of other languages wrapped in a lang element,
+// so a multilingual voice reads them with the right pronunciation.
+// [locales] maps a script to its locale, such as ScriptLatin to en-US. Runs of the scripts missing from it,
+// or whose locale is the voice's [locale], are left as is.
+func MixedText(value string, locale string, locales map[Script]string) []Node {
+	var nodes []Node
+	for _, run := range SplitScripts(value) {
+		runLocale, ok := locales[run.Script]
+		if !ok || runLocale == locale {
+			nodes = append(nodes, Text(run.Text))
+			continue
+		}
+		nodes = append(nodes, Lang(runLocale, Text(run.Text)))
+	}
+	return nodes
+}
+
+func scriptOf(r rune) Script {
+	switch {
+	case unicode.Is(unicode.Han, r):
+		return ScriptHan
+	case unicode.Is(unicode.Latin, r):
+		return ScriptLatin
+	default:
+		return ScriptOther
+	}
+}
@@ -0,0 +1,63 @@
+package ssml
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestSplitScripts(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []Run
+	}{
+		{
+			name: "Test with empty text",
+			text: "",
+			want: nil,
+		},
+		{
+			name: "Test with chinese text",
+			text: "你好，歡迎光臨！",
+			want: []Run{{Text: "你好，歡迎光臨！", Script: ScriptHan}},
+		},
+		{
+			name: "Test with mixed text",
+			text: "我要 2 份 fried rice，謝謝",
+			want: []Run{
+				{Text: "我要 2 份 ", Script: ScriptHan},
+				{Text: "fried rice，", Script: ScriptLatin},
+				{Text: "謝謝", Script: ScriptHan},
+			},
+		},
+		{
+			name: "Test with leading number",
+			text: "3 cokes",
+			want: []Run{{Text: "3 cokes", Script: ScriptLatin}},
+		},
+		{
+			name: "Test with numbers only",
+			text: "123",
+			want: []Run{{Text: "123", Script: ScriptOther}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, SplitScripts(tt.text))
+		})
+	}
+}
+
+func TestMixedText(t *testing.T) {
+	locales := map[Script]string{ScriptHan: "zh-TW", ScriptLatin: "en-US"}
+	voice := Voice("zh-TW-HsiaoChenNeural", MixedText("我要 fried rice & 湯", "zh-TW", locales)...)
+	assert.Equal(
+		t,
+		`<voice name="zh-TW-HsiaoChenNeural">我要 <lang xml:lang="en-US">fried rice &amp; </lang>湯</voice>`,
+		voice.String(),
+	)
+
+	voice = Voice("zh-TW-HsiaoChenNeural", MixedText("我要 fried rice", "zh-TW", nil)...)
+	assert.Equal(t, `<voice name="zh-TW-HsiaoChenNeural">我要 fried rice</voice>`, voice.String())
+}
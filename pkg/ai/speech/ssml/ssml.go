@@ -0,0 +1,174 @@
+package ssml
+
+import (
+	"encoding/xml"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Node is a part of an SSML document.
+type Node interface {
+	write(builder *strings.Builder)
+}
+
+// Attribute is an attribute of an Element. Attributes with an empty value are left out.
+type Attribute struct {
+	Name  string
+	Value string
+}
+
+// Element is an SSML element with its attributes and children.
+type Element struct {
+	Name       string
+	Attributes []Attribute
+	Children   []Node
+}
+
+func (e *Element) write(builder *strings.Builder) {
+	builder.WriteString("<")
+	builder.WriteString(e.Name)
+	for _, attribute := range e.Attributes {
+		if len(attribute.Value) == 0 {
+			continue
+		}
+		builder.WriteString(" ")
+		builder.WriteString(attribute.Name)
+		builder.WriteString(`="`)
+		escape(builder, attribute.Value)
+		builder.WriteString(`"`)
+	}
+	if len(e.Children) == 0 {
+		builder.WriteString("/>")
+		return
+	}
+
+	builder.WriteString(">")
+	for _, child := range e.Children {
+		child.write(builder)
+	}
+	builder.WriteString("</")
+	builder.WriteString(e.Name)
+	builder.WriteString(">")
+}
+
+// Add appends the children to the element and returns it.
+func (e *Element) Add(children ...Node) *Element {
+	e.Children = append(e.Children, children...)
+	return e
+}
+
+// String returns the XML of the element.
+func (e *Element) String() string {
+	var builder strings.Builder
+	e.write(&builder)
+	return builder.String()
+}
+
+type text string
+
+func (t text) write(builder *strings.Builder) {
+	escape(builder, string(t))
+}
+
+// Text returns the node of the text. The text is escaped, so it can contain any character.
+func Text(value string) Node {
+	return text(value)
+}
+
+// Speak returns the root element of the document in the language, such as zh-TW.
+func Speak(lang string, children ...Node) *Element {
+	return &Element{
+		Name: "speak",
+		Attributes: []Attribute{
+			{Name: "version", Value: "1.0"},
+			{Name: "xmlns", Value: "http://www.w3.org/2001/10/synthesis"},
+			{Name: "xml:lang", Value: lang},
+		},
+		Children: children,
+	}
+}
+
+// Voice returns the element speaking the children with the voice, such as zh-TW-HsiaoChenNeural.
+// A document can contain several voices.
+func Voice(name string, children ...Node) *Element {
+	return &Element{
+		Name:       "voice",
+		Attributes: []Attribute{{Name: "name", Value: name}},
+		Children:   children,
+	}
+}
+
+// ProsodyOptions are the attributes of the prosody element. Empty values are left out.
+type ProsodyOptions struct {
+	// Rate is the speaking rate, such as 1.2, +20% or slow.
+	Rate string
+	// Pitch is the pitch, such as +2st or high.
+	Pitch string
+	// Volume is the volume, such as +10% or loud.
+	Volume string
+}
+
+// Prosody returns the element changing the rate, pitch or volume of the children.
+func Prosody(options ProsodyOptions, children ...Node) *Element {
+	return &Element{
+		Name: "prosody",
+		Attributes: []Attribute{
+			{Name: "rate", Value: options.Rate},
+			{Name: "pitch", Value: options.Pitch},
+			{Name: "volume", Value: options.Volume},
+		},
+		Children: children,
+	}
+}
+
+// Rate returns the relative rate of the prosody element for a multiplier of the default rate, such as 1.2.
+func Rate(multiplier float64) string {
+	return strconv.FormatFloat(multiplier, 'f', -1, 64)
+}
+
+// Break returns the element pausing for the duration.
+func Break(duration time.Duration) *Element {
+	return &Element{
+		Name:       "break",
+		Attributes: []Attribute{{Name: "time", Value: strconv.FormatInt(duration.Milliseconds(), 10) + "ms"}},
+	}
+}
+
+// SayAs returns the element telling how to read the text, such as a date, a cardinal number or a telephone number.
+// [format] is optional.
+func SayAs(interpretAs string, format string, value string) *Element {
+	return &Element{
+		Name: "say-as",
+		Attributes: []Attribute{
+			{Name: "interpret-as", Value: interpretAs},
+			{Name: "format", Value: format},
+		},
+		Children: []Node{Text(value)},
+	}
+}
+
+// Phoneme returns the element reading the text with the pronunciation, such as alphabet sapi and ph "ni 3 hao 3".
+func Phoneme(alphabet string, ph string, value string) *Element {
+	return &Element{
+		Name: "phoneme",
+		Attributes: []Attribute{
+			{Name: "alphabet", Value: alphabet},
+			{Name: "ph", Value: ph},
+		},
+		Children: []Node{Text(value)},
+	}
+}
+
+// Lang returns the element speaking the children in another language, for multilingual voices.
+func Lang(lang string, children ...Node) *Element {
+	return &Element{
+		Name:       "lang",
+		Attributes: []Attribute{{Name: "xml:lang", Value: lang}},
+		Children:   children,
+	}
+}
+
+func escape(builder *strings.Builder, value string) {
+	_ = xml.EscapeText(builder, []byte(value))
+}
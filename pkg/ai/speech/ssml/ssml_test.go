@@ -0,0 +1,71 @@
+package ssml
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestElement_String(t *testing.T) {
+	tests := []struct {
+		name    string
+		element *Element
+		want    string
+	}{
+		{
+			name:    "Test with escaped text",
+			element: Speak("en-US", Voice("en-US-AvaNeural", Text(`Fish & chips <today> "only"`))),
+			want:    `<speak version="1.0" xmlns="http://www.w3.org/2001/10/synthesis" xml:lang="en-US"><voice name="en-US-AvaNeural">Fish &amp; chips &lt;today&gt; &#34;only&#34;</voice></speak>`,
+		},
+		{
+			name: "Test with multiple voices",
+			element: Speak(
+				"zh-TW",
+				Voice("zh-TW-HsiaoChenNeural", Text("歡迎光臨")),
+				Voice("zh-TW-YunJheNeural", Text("謝謝")),
+			),
+			want: `<speak version="1.0" xmlns="http://www.w3.org/2001/10/synthesis" xml:lang="zh-TW"><voice name="zh-TW-HsiaoChenNeural">歡迎光臨</voice><voice name="zh-TW-YunJheNeural">謝謝</voice></speak>`,
+		},
+		{
+			name:    "Test with prosody",
+			element: Prosody(ProsodyOptions{Rate: Rate(0.8), Volume: "loud"}, Text("Hi")),
+			want:    `<prosody rate="0.8" volume="loud">Hi</prosody>`,
+		},
+		{
+			name:    "Test with break",
+			element: Voice("en-US-AvaNeural", Text("Hi"), Break(500*time.Millisecond), Text("there")),
+			want:    `<voice name="en-US-AvaNeural">Hi<break time="500ms"/>there</voice>`,
+		},
+		{
+			name:    "Test with say-as",
+			element: SayAs("date", "ymd", "2024-01-01"),
+			want:    `<say-as interpret-as="date" format="ymd">2024-01-01</say-as>`,
+		},
+		{
+			name:    "Test with say-as without format",
+			element: SayAs("cardinal", "", "42"),
+			want:    `<say-as interpret-as="cardinal">42</say-as>`,
+		},
+		{
+			name:    "Test with phoneme",
+			element: Phoneme("sapi", "ni 3 hao 3", "你好"),
+			want:    `<phoneme alphabet="sapi" ph="ni 3 hao 3">你好</phoneme>`,
+		},
+		{
+			name:    "Test with escaped attribute",
+			element: Voice(`a"b`),
+			want:    `<voice name="a&#34;b"/>`,
+		},
+		{
+			name:    "Test with add",
+			element: Voice("en-US-AvaNeural").Add(Text("Hi"), Lang("fr-FR", Text("Bonjour"))),
+			want:    `<voice name="en-US-AvaNeural">Hi<lang xml:lang="fr-FR">Bonjour</lang></voice>`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.element.String())
+		})
+	}
+}
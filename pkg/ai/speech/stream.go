@@ -0,0 +1,165 @@
+package speech
+
+import (
+	"context"
+	"strings"
+	"unicode/utf8"
+)
+
+const defaultConcurrency = 3
+
+// SentenceSplitter splits a text received in deltas into sentences as soon as they are complete.
+type SentenceSplitter struct {
+	maxLength int
+	buffer    string
+}
+
+// NewSentenceSplitter returns a new instance of SentenceSplitter.
+// Sentences longer than [maxLength] characters are split, see Chunk. 0 uses DefaultChunkLength.
+func NewSentenceSplitter(maxLength int) *SentenceSplitter {
+	if maxLength <= 0 {
+		maxLength = DefaultChunkLength
+	}
+	return &SentenceSplitter{
+		maxLength: maxLength,
+	}
+}
+
+// Write adds the delta to the text and returns the sentences it completes.
+// A sentence is only complete once the text after it is received, since a period may be part of a number
+// and closing quotes belong to the sentence.
+func (s *SentenceSplitter) Write(delta string) []string {
+	s.buffer += delta
+
+	var sentences []string
+	for {
+		end := sentenceEnd(s.buffer)
+		if end < 0 || end == len(s.buffer) {
+			break
+		}
+		sentences = append(sentences, Chunk(s.buffer[:end], s.maxLength)...)
+		s.buffer = s.buffer[end:]
+	}
+
+	// an unfinished sentence that is already too long is split at its pauses
+	if utf8.RuneCountInString(s.buffer) > s.maxLength {
+		parts := splitLong(s.buffer, s.maxLength)
+		for _, part := range parts[:len(parts)-1] {
+			if sentence := strings.TrimSpace(part); len(sentence) > 0 {
+				sentences = append(sentences, sentence)
+			}
+		}
+		s.buffer = parts[len(parts)-1]
+	}
+	return sentences
+}
+
+// Flush returns the rest of the text once all the deltas are received.
+func (s *SentenceSplitter) Flush() []string {
+	sentences := Chunk(s.buffer, s.maxLength)
+	s.buffer = ""
+	return sentences
+}
+
+type StreamOptions struct {
+	// Concurrency is the maximum number of sentences synthesized at once. Defaults to 3.
+	Concurrency int
+	// MaxChunkLength is the maximum number of characters synthesized at once. Defaults to DefaultChunkLength.
+	MaxChunkLength int
+}
+
+type synthesisResult struct {
+	audio Audio
+	err   error
+}
+
+// StreamSpeech returns the iterator synthesizing the sentences of the deltas as soon as they are complete,
+// such as the deltas of gpt.Client.GenerateStream. Up to [options.Concurrency] sentences are synthesized at once,
+// and their audio is yielded in the order of the sentences.
+// The iterator stops at the first error. Stopping the iteration cancels the pending syntheses.
+func StreamSpeech(ctx context.Context, synthesizer Synthesizer, deltas func(yield func(delta string, err error) bool), options StreamOptions) func(yield func(audio Audio, err error) bool) {
+	return func(yield func(audio Audio, err error) bool) {
+		concurrency := options.Concurrency
+		if concurrency <= 0 {
+			concurrency = defaultConcurrency
+		}
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		// every sentence gets a result channel, queued in the order of the sentences.
+		// The queue and the semaphore bound the number of sentences synthesized ahead of the consumer.
+		queue := make(chan chan synthesisResult, concurrency)
+		semaphore := make(chan struct{}, concurrency)
+		enqueue := func(sentence string, err error) bool {
+			result := make(chan synthesisResult, 1)
+			select {
+			case queue <- result:
+			case <-ctx.Done():
+				return false
+			}
+
+			if err != nil {
+				result <- synthesisResult{err: err}
+				return false
+			}
+			select {
+			case semaphore <- struct{}{}:
+			case <-ctx.Done():
+				result <- synthesisResult{err: ctx.Err()}
+				return false
+			}
+			go func() {
+				defer func() { <-semaphore }()
+				audio, err := synthesizer.Synthesize(ctx, sentence)
+				result <- synthesisResult{audio: audio, err: err}
+			}()
+			return true
+		}
+
+		go func() {
+			defer close(queue)
+			splitter := NewSentenceSplitter(options.MaxChunkLength)
+			for delta, err := range deltas {
+				if err != nil {
+					enqueue("", err)
+					return
+				}
+				for _, sentence := range splitter.Write(delta) {
+					if !enqueue(sentence, nil) {
+						return
+					}
+				}
+			}
+			for _, sentence := range splitter.Flush() {
+				if !enqueue(sentence, nil) {
+					return
+				}
+			}
+		}()
+
+		for result := range queue {
+			synthesis := <-result
+			if synthesis.err != nil {
+				yield(Audio{}, synthesis.err)
+				return
+			}
+			if !yield(synthesis.audio, nil) {
+				return
+			}
+		}
+	}
+}
+
+// Speak writes the speech of the deltas to the sink as soon as their sentences are complete, see StreamSpeech.
+// The next sentences are synthesized while the sink plays the current one.
+func Speak(ctx context.Context, synthesizer Synthesizer, sink AudioSink, deltas func(yield func(delta string, err error) bool), options StreamOptions) error {
+	for audio, err := range StreamSpeech(ctx, synthesizer, deltas, options) {
+		if err != nil {
+			return err
+		}
+		if err := sink.Write(ctx, audio); err != nil {
+			return err
+		}
+	}
+	return nil
+}
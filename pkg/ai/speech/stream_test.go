@@ -0,0 +1,162 @@
+package speech
+
+import (
+	"context"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+	"time"
+)
+
+// deltasOf returns the iterator yielding the deltas, then the error if there is one.
+func deltasOf(err error, deltas ...string) func(yield func(delta string, err error) bool) {
+	return func(yield func(delta string, err error) bool) {
+		for _, delta := range deltas {
+			if !yield(delta, nil) {
+				return
+			}
+		}
+		if err != nil {
+			yield("", err)
+		}
+	}
+}
+
+// slowSynthesizer synthesizes the first sentences slower than the last ones and records the concurrency.
+type slowSynthesizer struct {
+	mutex         sync.Mutex
+	running       int
+	maxRunning    int
+	failSentence  string
+	delayFirstFor time.Duration
+	calls         int
+}
+
+func (s *slowSynthesizer) Synthesize(ctx context.Context, text string) (Audio, error) {
+	s.mutex.Lock()
+	s.running++
+	s.maxRunning = max(s.maxRunning, s.running)
+	s.calls++
+	delay := s.delayFirstFor / time.Duration(s.calls)
+	s.mutex.Unlock()
+
+	defer func() {
+		s.mutex.Lock()
+		s.running--
+		s.mutex.Unlock()
+	}()
+
+	select {
+	case <-time.After(delay):
+	case <-ctx.Done():
+		return Audio{}, ctx.Err()
+	}
+	if text == s.failSentence {
+		return Audio{}, fmt.Errorf("failed to synthesize %s", text)
+	}
+	return Audio{Data: []byte(text), ContentType: "audio/mpeg"}, nil
+}
+
+func TestSentenceSplitter(t *testing.T) {
+	splitter := NewSentenceSplitter(0)
+
+	assert.Empty(t, splitter.Write("你好"))
+	// the sentence is complete once the text after it is received
+	assert.Empty(t, splitter.Write("！"))
+	assert.Equal(t, []string{"你好！"}, splitter.Write("請"))
+	assert.Equal(t, []string{"請問要點什麼？"}, splitter.Write("問要點什麼？It costs 3."))
+	// the period of the number does not end the sentence
+	assert.Equal(t, []string{"It costs 3.5 dollars."}, splitter.Write("5 dollars. O"))
+	assert.Empty(t, splitter.Write("k"))
+	assert.Equal(t, []string{"Ok"}, splitter.Flush())
+	assert.Empty(t, splitter.Flush())
+}
+
+func TestSentenceSplitter_LongSentence(t *testing.T) {
+	splitter := NewSentenceSplitter(6)
+
+	assert.Empty(t, splitter.Write("炒飯，湯"))
+	assert.Equal(t, []string{"炒飯，湯麵，"}, splitter.Write("麵，水餃"))
+	assert.Equal(t, []string{"水餃。"}, splitter.Write("。鍋貼"))
+	assert.Equal(t, []string{"鍋貼"}, splitter.Flush())
+}
+
+func TestStreamSpeech(t *testing.T) {
+	synthesizer := &slowSynthesizer{delayFirstFor: 50 * time.Millisecond}
+	deltas := deltasOf(nil, "一。", "二。", "三。", "四。", "五。")
+
+	var texts []string
+	for audio, err := range StreamSpeech(context.Background(), synthesizer, deltas, StreamOptions{Concurrency: 2}) {
+		assert.Nil(t, err)
+		texts = append(texts, string(audio.Data))
+	}
+
+	assert.Equal(t, []string{"一。", "二。", "三。", "四。", "五。"}, texts)
+	assert.LessOrEqual(t, synthesizer.maxRunning, 2)
+}
+
+func TestStreamSpeech_Errors(t *testing.T) {
+	tests := []struct {
+		name      string
+		deltas    func(yield func(delta string, err error) bool)
+		fail      string
+		wantTexts []string
+		wantErr   string
+	}{
+		{
+			name:   "Test with deltas error",
+			deltas: deltasOf(fmt.Errorf("stream failed"), "一。", "二。"),
+			// the last sentence is not spoken, since the text after it was never received
+			wantTexts: []string{"一。"},
+			wantErr:   "stream failed",
+		},
+		{
+			name:      "Test with synthesizer error",
+			deltas:    deltasOf(nil, "一。", "二。", "三。"),
+			fail:      "二。",
+			wantTexts: []string{"一。"},
+			wantErr:   "failed to synthesize 二。",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			synthesizer := &slowSynthesizer{failSentence: tt.fail}
+			var texts []string
+			var err error
+			for audio, streamErr := range StreamSpeech(context.Background(), synthesizer, tt.deltas, StreamOptions{}) {
+				if streamErr != nil {
+					err = streamErr
+					continue
+				}
+				texts = append(texts, string(audio.Data))
+			}
+			assert.Equal(t, tt.wantTexts, texts)
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestStreamSpeech_Stopped(t *testing.T) {
+	synthesizer := &slowSynthesizer{}
+	deltas := deltasOf(nil, "一。", "二。", "三。", "四。", "五。")
+
+	var texts []string
+	for audio, err := range StreamSpeech(context.Background(), synthesizer, deltas, StreamOptions{Concurrency: 1}) {
+		assert.Nil(t, err)
+		texts = append(texts, string(audio.Data))
+		break
+	}
+	assert.Equal(t, []string{"一。"}, texts)
+}
+
+func TestSpeak(t *testing.T) {
+	sink := NewMemorySink()
+	err := Speak(context.Background(), &slowSynthesizer{}, sink, deltasOf(nil, "Hello. ", "World"), StreamOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, []Audio{
+		{Data: []byte("Hello."), ContentType: "audio/mpeg"},
+		{Data: []byte("World"), ContentType: "audio/mpeg"},
+	}, sink.Audios())
+}
@@ -5,11 +5,17 @@ package errors
 // 2000-2999 401 Unauthorized,
 // 3000-3999 403 Forbidden,
 // 4000-4999 404 Not Found,
-// 5000-5999 500 Internal Server Error
+// 5000-5999 500 Internal Server Error,
+// 6000-6999 429 Too Many Requests,
+// 7000-7999 502 Bad Gateway
 type ErrorCode int
 
 const (
+	ErrorInvalidRequest   ErrorCode = 1000
+	ErrorContentPolicy    ErrorCode = 1001
 	ErrorMissingAPIKey    ErrorCode = 2000
 	ErrorInvalidAPIKey    ErrorCode = 2001
 	ErrorDocumentNotFound ErrorCode = 4000
+	ErrorRateLimited      ErrorCode = 6000
+	ErrorUpstreamFailed   ErrorCode = 7000
 )
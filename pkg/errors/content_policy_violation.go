@@ -0,0 +1,22 @@
+package errors
+
+type ContentPolicyViolation struct {
+	code    ErrorCode
+	message string
+}
+
+// NewContentPolicyViolation creates a new ContentPolicyViolation with the reason the content is refused, such as an unsafe message
+func NewContentPolicyViolation(message string) *ContentPolicyViolation {
+	return &ContentPolicyViolation{
+		code:    ErrorContentPolicy,
+		message: message,
+	}
+}
+
+func (e *ContentPolicyViolation) Error() string {
+	return e.message
+}
+
+func (e *ContentPolicyViolation) Code() ErrorCode {
+	return e.code
+}
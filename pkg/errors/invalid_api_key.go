@@ -0,0 +1,20 @@
+package errors
+
+type InvalidAPIKey struct {
+	code ErrorCode
+}
+
+// NewInvalidAPIKey creates a new InvalidAPIKey
+func NewInvalidAPIKey() *InvalidAPIKey {
+	return &InvalidAPIKey{
+		code: ErrorInvalidAPIKey,
+	}
+}
+
+func (e *InvalidAPIKey) Error() string {
+	return "The given API key is invalid"
+}
+
+func (e *InvalidAPIKey) Code() ErrorCode {
+	return e.code
+}
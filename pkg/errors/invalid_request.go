@@ -0,0 +1,22 @@
+package errors
+
+type InvalidRequest struct {
+	code    ErrorCode
+	message string
+}
+
+// NewInvalidRequest creates a new InvalidRequest with the reason the request is invalid
+func NewInvalidRequest(message string) *InvalidRequest {
+	return &InvalidRequest{
+		code:    ErrorInvalidRequest,
+		message: message,
+	}
+}
+
+func (e *InvalidRequest) Error() string {
+	return e.message
+}
+
+func (e *InvalidRequest) Code() ErrorCode {
+	return e.code
+}
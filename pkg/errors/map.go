@@ -19,6 +19,12 @@ func MapErrorCodeToHTTPStatus(code ErrorCode) int {
 	if code >= 5000 && code < 6000 {
 		return http.StatusInternalServerError
 	}
+	if code >= 6000 && code < 7000 {
+		return http.StatusTooManyRequests
+	}
+	if code >= 7000 && code < 8000 {
+		return http.StatusBadGateway
+	}
 	return http.StatusInternalServerError
 }
 
@@ -24,6 +24,26 @@ func TestMapErrorCodeToHTTPStatus(t *testing.T) {
 			args: args{code: ErrorInvalidAPIKey},
 			want: 401,
 		},
+		{
+			name: "InvalidRequest",
+			args: args{code: ErrorInvalidRequest},
+			want: 400,
+		},
+		{
+			name: "ContentPolicy",
+			args: args{code: ErrorContentPolicy},
+			want: 400,
+		},
+		{
+			name: "RateLimited",
+			args: args{code: ErrorRateLimited},
+			want: 429,
+		},
+		{
+			name: "UpstreamFailed",
+			args: args{code: ErrorUpstreamFailed},
+			want: 502,
+		},
 		{
 			name: "Unknown",
 			args: args{code: 1},
@@ -53,6 +73,31 @@ func TestMapErrorToHTTPStatus(t *testing.T) {
 			args: args{error: NewDocumentNotFound()},
 			want: 404,
 		},
+		{
+			name: "InvalidAPIKey",
+			args: args{error: NewInvalidAPIKey()},
+			want: 401,
+		},
+		{
+			name: "InvalidRequest",
+			args: args{error: NewInvalidRequest("messages are required")},
+			want: 400,
+		},
+		{
+			name: "ContentPolicy",
+			args: args{error: NewContentPolicyViolation("the message was flagged")},
+			want: 400,
+		},
+		{
+			name: "RateLimited",
+			args: args{error: NewRateLimited()},
+			want: 429,
+		},
+		{
+			name: "UpstreamFailed",
+			args: args{error: NewUpstreamFailed()},
+			want: 502,
+		},
 		{
 			name: "OtherError",
 			args: args{error: fmt.Errorf("other error")},
@@ -0,0 +1,20 @@
+package errors
+
+type MissingAPIKey struct {
+	code ErrorCode
+}
+
+// NewMissingAPIKey creates a new MissingAPIKey
+func NewMissingAPIKey() *MissingAPIKey {
+	return &MissingAPIKey{
+		code: ErrorMissingAPIKey,
+	}
+}
+
+func (e *MissingAPIKey) Error() string {
+	return "The API key is missing"
+}
+
+func (e *MissingAPIKey) Code() ErrorCode {
+	return e.code
+}
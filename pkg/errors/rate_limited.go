@@ -0,0 +1,20 @@
+package errors
+
+type RateLimited struct {
+	code ErrorCode
+}
+
+// NewRateLimited creates a new RateLimited, returned when a service called to answer the request limits its rate
+func NewRateLimited() *RateLimited {
+	return &RateLimited{
+		code: ErrorRateLimited,
+	}
+}
+
+func (e *RateLimited) Error() string {
+	return "Too many requests, please try again later"
+}
+
+func (e *RateLimited) Code() ErrorCode {
+	return e.code
+}
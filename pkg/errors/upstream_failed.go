@@ -0,0 +1,20 @@
+package errors
+
+type UpstreamFailed struct {
+	code ErrorCode
+}
+
+// NewUpstreamFailed creates a new UpstreamFailed, returned when a service called to answer the request fails
+func NewUpstreamFailed() *UpstreamFailed {
+	return &UpstreamFailed{
+		code: ErrorUpstreamFailed,
+	}
+}
+
+func (e *UpstreamFailed) Error() string {
+	return "The upstream service failed to answer the request"
+}
+
+func (e *UpstreamFailed) Code() ErrorCode {
+	return e.code
+}